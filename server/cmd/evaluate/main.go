@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go-shazam/internal/evaluation"
+	"go-shazam/internal/logger"
+	"go-shazam/internal/recognition"
+	"go-shazam/pkg/audio"
+	"io"
+	"io/fs"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+func main() {
+	refs := flag.String("refs", "", "directory of reference tracks to index")
+	manifestPath := flag.String("manifest", "", "JSON query manifest with the expected reference track of each query")
+	clipLength := flag.Duration("clip", 5*time.Second, "length of the clips cut from each query")
+	clips := flag.Int("clips", 5, "clips per query, unless the manifest overrides it")
+	seed := flag.Int64("seed", 1, "seed of the clip offsets and noise")
+	snr := flag.Float64("snr", math.Inf(1), "add white noise at this signal-to-noise ratio in dB")
+	gain := flag.Float64("gain", 0, "gain in dB, clipped to full scale")
+	lowPass := flag.Float64("lowpass", 0, "low-pass cutoff in Hz")
+	format := flag.String("format", "markdown", "report format: markdown or json")
+	output := flag.String("out", "", "write the report to this file instead of stdout")
+	flag.Parse()
+
+	options := evaluation.Options{
+		ClipLength:    *clipLength,
+		ClipsPerQuery: *clips,
+		Seed:          *seed,
+		Distortion:    evaluation.Distortion{SNR: *snr, Gain: *gain, LowPass: *lowPass},
+	}
+	if err := run(*refs, *manifestPath, options, *format, *output); err != nil {
+		fmt.Fprintf(os.Stderr, "evaluate: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(refs string, manifestPath string, options evaluation.Options, format string, output string) error {
+	if refs == "" || manifestPath == "" {
+		return fmt.Errorf("-refs and -manifest are required")
+	}
+	if format != "markdown" && format != "json" {
+		return fmt.Errorf("unknown format %q", format)
+	}
+
+	// stdout is kept for the report
+	logger.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn})))
+
+	manifest, err := evaluation.LoadManifest(manifestPath)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	index := evaluation.NewIndex(recognition.LoadConfig())
+	if err := indexReferences(ctx, index, refs); err != nil {
+		return err
+	}
+
+	queries := make([]evaluation.QueryAudio, len(manifest.Queries))
+	for i, q := range manifest.Queries {
+		samples, err := audio.DecodeFile(q.File, audio.TargetSampleRate)
+		if err != nil {
+			return fmt.Errorf("failed to decode query: %w", err)
+		}
+		queries[i] = evaluation.QueryAudio{Query: q, Samples: samples}
+	}
+
+	report, err := evaluation.Evaluate(ctx, index, queries, options)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if format == "json" {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+	return evaluation.WriteMarkdown(w, report)
+}
+
+// indexReferences fingerprints every audio file below dir, named by its path relative to dir.
+// Files ffmpeg cannot decode are skipped.
+func indexReferences(ctx context.Context, index *evaluation.Index, dir string) error {
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(d.Name(), ".") && path != dir {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		samples, err := audio.DecodeFile(path, audio.TargetSampleRate)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "evaluate: skipping %s: %v\n", path, err)
+			return nil
+		}
+
+		name, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "indexing %s\n", name)
+		return index.Add(ctx, filepath.ToSlash(name), samples)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to index reference tracks: %w", err)
+	}
+	if index.Size() == 0 {
+		return fmt.Errorf("no reference tracks in %s", dir)
+	}
+	return nil
+}
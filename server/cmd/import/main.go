@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go-shazam/internal/app"
+	"go-shazam/internal/core/db"
+	"go-shazam/internal/fingerprint"
+	"go-shazam/internal/library"
+	"go-shazam/internal/logger"
+	"go-shazam/internal/song"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"runtime"
+
+	"go.uber.org/fx"
+)
+
+func main() {
+	dir := flag.String("dir", "", "directory of audio files to import, walked recursively")
+	workers := flag.Int("workers", runtime.NumCPU(), "files fingerprinted in parallel")
+	format := flag.String("format", "text", "report format: text or json")
+	output := flag.String("out", "", "write the report to this file instead of stdout")
+	flag.Parse()
+
+	if err := run(*dir, *workers, *format, *output); err != nil {
+		fmt.Fprintf(os.Stderr, "import: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(dir string, workers int, format string, output string) error {
+	if dir == "" {
+		return fmt.Errorf("-dir is required")
+	}
+	if format != "text" && format != "json" {
+		return fmt.Errorf("unknown format %q", format)
+	}
+
+	// stdout is kept for the report
+	logger.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn})))
+
+	var (
+		songRepository     song.SongRepositoryInterface
+		fingerprintService *fingerprint.FingerprintService
+		transactionManager *db.TransactionManager
+	)
+	cli := app.NewCommandApp(fx.Populate(&songRepository, &fingerprintService, &transactionManager))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := cli.Start(ctx); err != nil {
+		return err
+	}
+	defer cli.Stop(context.Background())
+
+	// Songs are only saved, which needs no metadata source, downloader or queue
+	songService := song.NewSongService(nil, nil, songRepository, fingerprintService, transactionManager, nil)
+	importer := library.NewImporter(songRepository, songService)
+	report, importErr := importer.Import(ctx, dir, library.Options{
+		Workers: workers,
+		Progress: func(f library.FileResult) {
+			fmt.Fprintf(os.Stderr, "%-9s %s\n", f.Status, f.Path)
+		},
+	})
+
+	// An interrupted import still reports the files it got through
+	var w io.Writer = os.Stdout
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if format == "json" {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			return err
+		}
+	} else if err := library.WriteReport(w, report); err != nil {
+		return err
+	}
+	return importErr
+}
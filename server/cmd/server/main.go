@@ -1,9 +1,31 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"go-shazam/internal/app"
+	"go-shazam/internal/core/db"
+	"os"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(os.Args[2:])
+		return
+	}
+
 	app.NewWebApp().Run()
 }
+
+// runMigrate handles "server migrate up|down|status"
+func runMigrate(args []string) {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: server migrate up|down|status")
+		os.Exit(2)
+	}
+
+	if err := db.RunMigrateCommand(context.Background(), args[0], os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "migrate: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go-shazam/internal/app"
+	"go-shazam/internal/stats"
+	"io"
+	"os"
+
+	"go.uber.org/fx"
+)
+
+func main() {
+	format := flag.String("format", "text", "report format: text or json")
+	cached := flag.Bool("cached", false, "print the last cached snapshot instead of recomputing")
+	save := flag.Bool("save", false, "store the recomputed statistics as the cached snapshot")
+	output := flag.String("out", "", "write the report to this file instead of stdout")
+	flag.Parse()
+
+	if err := run(*format, *cached, *save, *output); err != nil {
+		fmt.Fprintf(os.Stderr, "stats: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(format string, cached bool, save bool, output string) error {
+	if format != "text" && format != "json" {
+		return fmt.Errorf("unknown format %q", format)
+	}
+
+	var service *stats.StatsService
+	cli := app.NewCommandApp(stats.Module, fx.Populate(&service))
+
+	ctx := context.Background()
+	if err := cli.Start(ctx); err != nil {
+		return err
+	}
+	defer cli.Stop(ctx)
+
+	var (
+		result *stats.CatalogStats
+		err    error
+	)
+	switch {
+	case cached:
+		result, err = service.GetStats(ctx)
+	case save:
+		result, err = service.Refresh(ctx)
+	default:
+		result, err = service.Compute(ctx)
+	}
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if format == "json" {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(result)
+	}
+	return stats.WriteReport(w, result)
+}
@@ -40,9 +40,7 @@ check_service "${DB_HOST}" "${DB_PORT}" "Database"
 check_service "${REDIS_HOST}" "${REDIS_PORT}" "Redis"
 
 echo "Migrating database..."
-export GOOSE_DRIVER=postgres
-export GOOSE_DBSTRING="host=${DB_HOST} port=${DB_PORT} user=${DB_USER} password=${DB_PASSWORD} dbname=${DB_DATABASE} sslmode=disable"
-goose -dir ./migrations up
+/server migrate up
 echo "Database migrated successfully!"
 
 echo "Starting server..."
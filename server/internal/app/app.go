@@ -6,11 +6,14 @@ import (
 	"go-shazam/internal/auth"
 	"go-shazam/internal/core"
 	"go-shazam/internal/fingerprint"
+	"go-shazam/internal/history"
 	appHttp "go-shazam/internal/http"
+	"go-shazam/internal/musicbrainz"
 	"go-shazam/internal/queue"
 	"go-shazam/internal/recognition"
 	"go-shazam/internal/song"
 	"go-shazam/internal/spotify"
+	"go-shazam/internal/stats"
 	"go-shazam/internal/user"
 	"go-shazam/internal/youtube"
 	"net/http"
@@ -27,14 +30,19 @@ func NewWebApp() *fx.App {
 		// Core modules
 		song.Module,
 		spotify.Module,
+		musicbrainz.Module,
 		youtube.Module,
 		recognition.Module,
 		queue.Module,
 		user.Module,
+		stats.Module,
+		history.Module,
 		// Http modules
 		song.HttpModule,
 		recognition.HttpModule,
 		user.HttpModule,
+		stats.HttpModule,
+		history.HttpModule,
 
 		fx.Invoke(core.RegisterCoreMiddleware),
 		fx.Invoke(func(r *http.Server) {}),
@@ -47,15 +55,30 @@ func NewWorkerApp() *fx.App {
 		fingerprint.Module,
 		song.Module,
 		spotify.Module,
+		musicbrainz.Module,
 		youtube.Module,
 		recognition.Module,
 		queue.Module,
+		stats.Module,
+		history.Module,
 		song.QueueModule,
+		stats.QueueModule,
+		recognition.QueueModule,
 		fx.Invoke(registerWorkerLifecycle),
 	)
 }
 
-func registerWorkerLifecycle(lc fx.Lifecycle, w queue.WorkerServer) {
+// NewCommandApp builds a short-lived app for command line tools on top of the data modules.
+func NewCommandApp(options ...fx.Option) *fx.App {
+	return fx.New(append([]fx.Option{
+		fx.NopLogger,
+		core.Module,
+		fingerprint.Module,
+		song.Module,
+	}, options...)...)
+}
+
+func registerWorkerLifecycle(lc fx.Lifecycle, w queue.WorkerServer, s queue.Scheduler) {
 	lc.Append(fx.Hook{
 		OnStart: func(ctx context.Context) error {
 			if err := w.Start(); err != nil {
@@ -63,9 +86,17 @@ func registerWorkerLifecycle(lc fx.Lifecycle, w queue.WorkerServer) {
 				return err
 			}
 			fmt.Println("Worker server started successfully")
+			if err := s.Start(); err != nil {
+				fmt.Printf("Failed to start scheduler: %v\n", err)
+				w.Stop()
+				return err
+			}
+			fmt.Println("Scheduler started successfully")
 			return nil
 		},
 		OnStop: func(ctx context.Context) error {
+			fmt.Println("Stopping scheduler...")
+			s.Stop()
 			fmt.Println("Stopping worker server...")
 			w.Stop()
 			return nil
@@ -1,8 +1,10 @@
 package auth
 
 import (
+	"strings"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/spf13/viper"
 )
 
@@ -14,6 +16,7 @@ type Config struct {
 	EmailEncryptionKey string
 	CookieDomain       string
 	CookieSecure       bool // true for HTTPS
+	AdminUserIDs       []uuid.UUID
 }
 
 func LoadConfig() *Config {
@@ -28,6 +31,7 @@ func LoadConfig() *Config {
 	viper.SetDefault("EMAIL_ENCRYPTION_KEY", "change-me-email-encryption-key!")
 	viper.SetDefault("COOKIE_DOMAIN", "")
 	viper.SetDefault("COOKIE_SECURE", false)
+	viper.SetDefault("ADMIN_USER_IDS", "")
 
 	return &Config{
 		AccessTokenSecret:  viper.GetString("JWT_ACCESS_SECRET"),
@@ -37,5 +41,29 @@ func LoadConfig() *Config {
 		EmailEncryptionKey: viper.GetString("EMAIL_ENCRYPTION_KEY"),
 		CookieDomain:       viper.GetString("COOKIE_DOMAIN"),
 		CookieSecure:       viper.GetBool("COOKIE_SECURE"),
+		AdminUserIDs:       parseUserIDs(viper.GetString("ADMIN_USER_IDS")),
 	}
 }
+
+// parseUserIDs parses a comma-separated list of user IDs, skipping invalid entries
+func parseUserIDs(value string) []uuid.UUID {
+	var ids []uuid.UUID
+	for _, part := range strings.Split(value, ",") {
+		id, err := uuid.Parse(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// IsAdmin reports whether the user is listed in ADMIN_USER_IDS
+func (c *Config) IsAdmin(userID uuid.UUID) bool {
+	for _, id := range c.AdminUserIDs {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
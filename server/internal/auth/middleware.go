@@ -7,6 +7,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
 )
 
 type contextKey string
@@ -15,8 +16,16 @@ const (
 	UserIDContextKey contextKey = "user_id"
 	authHeaderName   string     = "Authorization"
 	bearerPrefix     string     = "Bearer "
+	// Browsers cannot set headers on WebSocket handshakes, so the token is offered as a
+	// subprotocol "access_token.<token>" next to WebSocketProtocol. Unlike a query parameter,
+	// the Sec-WebSocket-Protocol header is not written to request and proxy logs.
+	accessTokenProtocolPrefix string = "access_token."
 )
 
+// WebSocketProtocol is the subprotocol the server selects for WebSocket clients that offer
+// their access token as a subprotocol; browsers close connections that get none of theirs back.
+const WebSocketProtocol = "go-shazam"
+
 // AuthMiddleware validates JWT tokens and adds user ID to context
 func AuthMiddleware(jwtService *JWTService) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -53,10 +62,19 @@ func AuthMiddleware(jwtService *JWTService) gin.HandlerFunc {
 	}
 }
 
-// OptionalAuthMiddleware validates JWT tokens if present, but doesn't require them
+// OptionalAuthMiddleware validates JWT tokens if present, but doesn't require them.
+// WebSocket handshakes may offer the token as an "access_token.<token>" subprotocol instead.
 func OptionalAuthMiddleware(jwtService *JWTService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader(authHeaderName)
+		if authHeader == "" && websocket.IsWebSocketUpgrade(c.Request) {
+			for _, protocol := range websocket.Subprotocols(c.Request) {
+				if token, ok := strings.CutPrefix(protocol, accessTokenProtocolPrefix); ok {
+					authHeader = bearerPrefix + token
+					break
+				}
+			}
+		}
 		if authHeader == "" {
 			c.Next()
 			return
@@ -82,6 +100,25 @@ func OptionalAuthMiddleware(jwtService *JWTService) gin.HandlerFunc {
 	}
 }
 
+// AdminMiddleware requires an authenticated user listed in the admin configuration.
+// It must be registered after AuthMiddleware.
+func AdminMiddleware(config *Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := GetUserIDFromContext(c.Request.Context())
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+			return
+		}
+
+		if !config.IsAdmin(userID) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "admin access required"})
+			return
+		}
+
+		c.Next()
+	}
+}
+
 func GetUserIDFromContext(ctx context.Context) (uuid.UUID, bool) {
 	userID, ok := ctx.Value(UserIDContextKey).(uuid.UUID)
 	return userID, ok
@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testJWTService = NewJWTService(&Config{
+	AccessTokenSecret:  "test-access-secret",
+	RefreshTokenSecret: "test-refresh-secret",
+	AccessTokenTTL:     time.Minute,
+	RefreshTokenTTL:    time.Hour,
+})
+
+// whoAmI answers with the user ID the optional auth middleware found, if any
+func whoAmI() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/ws", OptionalAuthMiddleware(testJWTService), func(c *gin.Context) {
+		userID, _ := GetUserIDFromContext(c.Request.Context())
+		c.String(http.StatusOK, userID.String())
+	})
+	return router
+}
+
+func upgradeRequest(target string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	return req
+}
+
+func TestOptionalAuthMiddleware_WebSocketProtocolToken(t *testing.T) {
+	userID := uuid.New()
+	tokens, err := testJWTService.GenerateTokenPair(userID)
+	require.NoError(t, err)
+
+	req := upgradeRequest("/ws")
+	req.Header.Set("Sec-WebSocket-Protocol", WebSocketProtocol+", "+accessTokenProtocolPrefix+tokens.AccessToken)
+	w := httptest.NewRecorder()
+	whoAmI().ServeHTTP(w, req)
+
+	assert.Equal(t, userID.String(), w.Body.String())
+}
+
+func TestOptionalAuthMiddleware_IgnoresQueryToken(t *testing.T) {
+	tokens, err := testJWTService.GenerateTokenPair(uuid.New())
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	whoAmI().ServeHTTP(w, upgradeRequest("/ws?access_token="+tokens.AccessToken))
+
+	assert.Equal(t, uuid.Nil.String(), w.Body.String())
+}
@@ -1,15 +1,18 @@
 package db
 
 import (
+	"fmt"
+
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	Host     string
-	Port     int
-	User     string
-	Password string
-	Database string
+	Host           string
+	Port           int
+	User           string
+	Password       string
+	Database       string
+	MigrateOnStart bool
 }
 
 func LoadDBConfig() *Config {
@@ -17,11 +20,18 @@ func LoadDBConfig() *Config {
 	viper.ReadInConfig()
 	viper.AutomaticEnv()
 
+	viper.SetDefault("MIGRATE_ON_START", false)
+
 	return &Config{
-		Host:     viper.GetString("DB_HOST"),
-		Port:     viper.GetInt("DB_PORT"),
-		User:     viper.GetString("DB_USER"),
-		Password: viper.GetString("DB_PASSWORD"),
-		Database: viper.GetString("DB_DATABASE"),
+		Host:           viper.GetString("DB_HOST"),
+		Port:           viper.GetInt("DB_PORT"),
+		User:           viper.GetString("DB_USER"),
+		Password:       viper.GetString("DB_PASSWORD"),
+		Database:       viper.GetString("DB_DATABASE"),
+		MigrateOnStart: viper.GetBool("MIGRATE_ON_START"),
 	}
 }
+
+func (c *Config) DSN() string {
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable", c.Host, c.Port, c.User, c.Password, c.Database)
+}
@@ -0,0 +1,93 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"go-shazam/migrations"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/lock"
+)
+
+const (
+	MigrateUp     = "up"
+	MigrateDown   = "down"
+	MigrateStatus = "status"
+)
+
+// Migrate applies a migration command ("up", "down" or "status") using the embedded migrations
+// and writes a human-readable summary to out. The command holds a Postgres advisory lock, so
+// the server and the worker can both migrate on start without running the same migration twice.
+func Migrate(ctx context.Context, db *sql.DB, command string, out io.Writer) error {
+	locker, err := lock.NewPostgresSessionLocker()
+	if err != nil {
+		return fmt.Errorf("failed to create migration lock: %w", err)
+	}
+
+	provider, err := goose.NewProvider(goose.DialectPostgres, db, migrations.FS, goose.WithSessionLocker(locker))
+	if err != nil {
+		return fmt.Errorf("failed to create migration provider: %w", err)
+	}
+
+	switch command {
+	case MigrateUp:
+		results, err := provider.Up(ctx)
+		for _, result := range results {
+			fmt.Fprintln(out, result)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to apply migrations: %w", err)
+		}
+		if len(results) == 0 {
+			fmt.Fprintln(out, "No pending migrations")
+		}
+	case MigrateDown:
+		result, err := provider.Down(ctx)
+		if result != nil {
+			fmt.Fprintln(out, result)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to roll back migration: %w", err)
+		}
+	case MigrateStatus:
+		statuses, err := provider.Status(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get migration status: %w", err)
+		}
+		writeMigrationStatus(out, statuses)
+	default:
+		return fmt.Errorf("unknown migrate command %q (expected %s, %s or %s)", command, MigrateUp, MigrateDown, MigrateStatus)
+	}
+
+	return nil
+}
+
+// RunMigrateCommand opens a connection from the environment configuration and runs a migration command.
+func RunMigrateCommand(ctx context.Context, command string, out io.Writer) error {
+	config := LoadDBConfig()
+	db, err := sqlx.Open("pgx", config.DSN())
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	return Migrate(ctx, db.DB, command, out)
+}
+
+func writeMigrationStatus(out io.Writer, statuses []*goose.MigrationStatus) {
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "Version\tState\tApplied At\tSource")
+	for _, status := range statuses {
+		appliedAt := "-"
+		if !status.AppliedAt.IsZero() {
+			appliedAt = status.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", status.Source.Version, status.State, appliedAt, status.Source.Path)
+	}
+	tw.Flush()
+}
@@ -15,3 +15,30 @@ func NewRepository(tm *TransactionManager) *Repository {
 func (r *Repository) Connection(ctx context.Context) Executor {
 	return r.tm.GetConnection(ctx)
 }
+
+// RelationSize is the on-disk size of a table (including TOAST and indexes) or of a single index
+type RelationSize struct {
+	Name  string `db:"name" json:"name"`
+	Kind  string `db:"kind" json:"kind"`
+	Bytes int64  `db:"bytes" json:"bytes"`
+}
+
+// RelationSizes returns the total size of the table followed by the size of each of its indexes
+func (r *Repository) RelationSizes(ctx context.Context, table string) ([]RelationSize, error) {
+	query := `
+		SELECT c.relname AS name, 'table' AS kind, pg_total_relation_size(c.oid) AS bytes
+		FROM pg_class c
+		WHERE c.oid = $1::regclass
+		UNION ALL
+		SELECT i.relname AS name, 'index' AS kind, pg_relation_size(i.oid) AS bytes
+		FROM pg_index x
+		JOIN pg_class i ON i.oid = x.indexrelid
+		WHERE x.indrelid = $1::regclass
+		ORDER BY kind DESC, bytes DESC
+	`
+	var sizes []RelationSize
+	if err := r.Connection(ctx).SelectContext(ctx, &sizes, query, table); err != nil {
+		return nil, err
+	}
+	return sizes, nil
+}
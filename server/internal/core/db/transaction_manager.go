@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"os"
 
 	_ "github.com/jackc/pgx/v5/stdlib"
 	"github.com/jmoiron/sqlx"
@@ -30,13 +31,19 @@ func NewTransactionManager(db *sqlx.DB) *TransactionManager {
 
 func NewDB(lc fx.Lifecycle) *sqlx.DB {
 	config := LoadDBConfig()
-	dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable", config.Host, config.Port, config.User, config.Password, config.Database)
-	db := sqlx.MustOpen("pgx", dsn)
+	db := sqlx.MustOpen("pgx", config.DSN())
 
 	lc.Append(fx.Hook{
 		OnStart: func(ctx context.Context) error {
 			fmt.Println("DB connected")
-			return db.PingContext(ctx)
+			if err := db.PingContext(ctx); err != nil {
+				return err
+			}
+			if config.MigrateOnStart {
+				fmt.Println("Migrating database...")
+				return Migrate(ctx, db.DB, MigrateUp, os.Stdout)
+			}
+			return nil
 		},
 		OnStop: func(ctx context.Context) error {
 			fmt.Println("DB disconnected")
@@ -0,0 +1,78 @@
+package evaluation
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+)
+
+// Distortion degrades clips the way recordings of a playing track are degraded
+type Distortion struct {
+	SNR     float64 // white noise at this signal-to-noise ratio in dB, +Inf for none
+	Gain    float64 // gain in dB applied before clipping to [-1, 1], 0 for none
+	LowPass float64 // cutoff in Hz of a first-order low-pass filter, 0 for none
+}
+
+// NoDistortion leaves clips untouched
+var NoDistortion = Distortion{SNR: math.Inf(1)}
+
+func (d Distortion) String() string {
+	var parts []string
+	if d.LowPass > 0 {
+		parts = append(parts, fmt.Sprintf("low-pass %.0f Hz", d.LowPass))
+	}
+	if d.Gain != 0 {
+		parts = append(parts, fmt.Sprintf("gain %+.1f dB", d.Gain))
+	}
+	if !math.IsInf(d.SNR, 1) {
+		parts = append(parts, fmt.Sprintf("noise at %.1f dB SNR", d.SNR))
+	}
+	if len(parts) == 0 {
+		return "none"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Apply returns a distorted copy of the clip: filtered, amplified and clipped, then mixed
+// with noise relative to the level of the filtered signal.
+func (d Distortion) Apply(samples []float64, sampleRate int, rng *rand.Rand) []float64 {
+	out := make([]float64, len(samples))
+	copy(out, samples)
+
+	if d.LowPass > 0 {
+		alpha := 1 - math.Exp(-2*math.Pi*d.LowPass/float64(sampleRate))
+		prev := 0.0
+		for i, s := range out {
+			prev += alpha * (s - prev)
+			out[i] = prev
+		}
+	}
+
+	if d.Gain != 0 {
+		factor := math.Pow(10, d.Gain/20)
+		for i := range out {
+			out[i] = math.Max(-1, math.Min(1, out[i]*factor))
+		}
+	}
+
+	if !math.IsInf(d.SNR, 1) {
+		noiseRMS := rms(out) / math.Pow(10, d.SNR/20)
+		for i := range out {
+			out[i] += rng.NormFloat64() * noiseRMS
+		}
+	}
+
+	return out
+}
+
+func rms(samples []float64) float64 {
+	if len(samples) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, s := range samples {
+		sum += s * s
+	}
+	return math.Sqrt(sum / float64(len(samples)))
+}
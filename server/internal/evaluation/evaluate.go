@@ -0,0 +1,86 @@
+package evaluation
+
+import (
+	"context"
+	"fmt"
+	"go-shazam/pkg/audio"
+	"math/rand"
+	"time"
+)
+
+// Options of an evaluation run
+type Options struct {
+	ClipLength    time.Duration
+	ClipsPerQuery int
+	Seed          int64 // clip offsets and noise are reproducible for the same seed
+	Distortion    Distortion
+}
+
+// QueryAudio is a query of the manifest with its decoded samples at the target rate
+type QueryAudio struct {
+	Query
+	Samples []float64
+}
+
+// Evaluate cuts clips at random offsets from every query, distorts them and recognises them
+// against the index.
+func Evaluate(ctx context.Context, index *Index, queries []QueryAudio, options Options) (*Report, error) {
+	if options.ClipLength <= 0 {
+		return nil, fmt.Errorf("clip length must be positive")
+	}
+
+	rng := rand.New(rand.NewSource(options.Seed))
+	clipSamples := int(options.ClipLength.Seconds() * audio.TargetSampleRate)
+	report := newReport(ctx, index, options)
+	var latencies []time.Duration
+
+	for _, q := range queries {
+		if q.Expected != "" && !index.Has(q.Expected) {
+			return nil, fmt.Errorf("query %s expects %q, which is not among the reference tracks", q.File, q.Expected)
+		}
+
+		clips := q.Clips
+		if clips == 0 {
+			clips = options.ClipsPerQuery
+		}
+
+		result := QueryReport{File: q.File, Expected: q.Expected}
+		for c := 0; c < clips; c++ {
+			clip := cutClip(q.Samples, clipSamples, rng)
+			clip = options.Distortion.Apply(clip, audio.TargetSampleRate, rng)
+
+			start := time.Now()
+			recognized, err := index.Recognize(ctx, clip)
+			if err != nil {
+				return nil, fmt.Errorf("failed to recognise a clip of %s: %w", q.File, err)
+			}
+			latencies = append(latencies, time.Since(start))
+
+			result.Clips++
+			switch {
+			case q.Expected == "" && recognized != "":
+				result.FalsePositives++
+			case q.Expected == "":
+			case recognized == q.Expected:
+				result.Correct++
+			case recognized == "":
+				result.Missed++
+			default:
+				result.Wrong++
+			}
+		}
+		report.add(result)
+	}
+
+	report.Latency = summarizeLatency(latencies)
+	return report, nil
+}
+
+// cutClip returns a clip of n samples at a random offset, or the whole query if it is shorter
+func cutClip(samples []float64, n int, rng *rand.Rand) []float64 {
+	if len(samples) <= n {
+		return samples
+	}
+	offset := rng.Intn(len(samples) - n + 1)
+	return samples[offset : offset+n]
+}
@@ -0,0 +1,201 @@
+package evaluation
+
+import (
+	"bytes"
+	"context"
+	"go-shazam/internal/recognition"
+	"go-shazam/pkg/audio"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// melody returns a track of random notes with harmonics and a little noise at the target rate
+func melody(seed int64, seconds float64) []float64 {
+	rng := rand.New(rand.NewSource(seed))
+	samples := make([]float64, int(seconds*audio.TargetSampleRate))
+	noteLength := audio.TargetSampleRate / 4
+	freq := 0.0
+	for i := range samples {
+		if i%noteLength == 0 {
+			freq = 150 + rng.Float64()*1500
+		}
+		t := float64(i) / audio.TargetSampleRate
+		samples[i] = 0.5*math.Sin(2*math.Pi*freq*t) + 0.25*math.Sin(2*math.Pi*2*freq*t) + 0.02*rng.NormFloat64()
+	}
+	return samples
+}
+
+func newTestIndex(t *testing.T, tracks map[string][]float64) *Index {
+	index := NewIndex(&recognition.Config{MaxCandidates: 5})
+	for name, samples := range tracks {
+		require.NoError(t, index.Add(context.Background(), name, samples))
+	}
+	return index
+}
+
+func TestEvaluate(t *testing.T) {
+	trackA := melody(1, 20)
+	trackB := melody(2, 20)
+	index := newTestIndex(t, map[string][]float64{"a.mp3": trackA, "b.mp3": trackB})
+
+	queries := []QueryAudio{
+		{Query: Query{File: "a.mp3", Expected: "a.mp3"}, Samples: trackA},
+		{Query: Query{File: "b.mp3", Expected: "b.mp3", Clips: 2}, Samples: trackB},
+		{Query: Query{File: "unknown.mp3"}, Samples: melody(3, 20)},
+	}
+
+	report, err := Evaluate(context.Background(), index, queries, Options{
+		ClipLength:    5 * time.Second,
+		ClipsPerQuery: 3,
+		Seed:          1,
+		Distortion:    Distortion{SNR: 10, Gain: -6, LowPass: 3000},
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.References)
+	assert.Equal(t, recognition.DefaultThresholds, report.Parameters.Thresholds)
+	assert.Equal(t, InCatalog{Clips: 5, Correct: 5, Top1Accuracy: 1}, report.InCatalog)
+	assert.Equal(t, OutOfCatalog{Clips: 3}, report.OutOfCatalog)
+	assert.Equal(t, []QueryReport{
+		{File: "a.mp3", Expected: "a.mp3", Clips: 3, Correct: 3},
+		{File: "b.mp3", Expected: "b.mp3", Clips: 2, Correct: 2},
+		{File: "unknown.mp3", Clips: 3},
+	}, report.Queries)
+	assert.Greater(t, report.Latency.P50, 0.0)
+	assert.GreaterOrEqual(t, report.Latency.Max, report.Latency.P99)
+}
+
+func TestEvaluate_UnknownExpectedTrack(t *testing.T) {
+	index := newTestIndex(t, map[string][]float64{"a.mp3": melody(1, 10)})
+
+	_, err := Evaluate(context.Background(), index, []QueryAudio{
+		{Query: Query{File: "q.mp3", Expected: "missing.mp3"}, Samples: melody(1, 10)},
+	}, Options{ClipLength: 5 * time.Second, ClipsPerQuery: 1})
+
+	assert.Error(t, err)
+}
+
+func TestIndex_AddRejectsDuplicates(t *testing.T) {
+	index := newTestIndex(t, map[string][]float64{"a.mp3": melody(1, 5)})
+
+	err := index.Add(context.Background(), "a.mp3", melody(1, 5))
+
+	assert.ErrorIs(t, err, ErrDuplicateReference)
+}
+
+func TestCutClip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	samples := make([]float64, 100)
+	for i := range samples {
+		samples[i] = float64(i)
+	}
+
+	clip := cutClip(samples, 10, rng)
+	require.Len(t, clip, 10)
+	assert.Equal(t, clip[0]+9, clip[9], "clips are contiguous")
+
+	assert.Len(t, cutClip(samples, 200, rng), 100, "short queries are used whole")
+}
+
+func TestDistortion_Apply(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	signal := melody(1, 2)
+
+	assert.Equal(t, signal, NoDistortion.Apply(signal, audio.TargetSampleRate, rng))
+
+	noisy := Distortion{SNR: 10}.Apply(signal, audio.TargetSampleRate, rng)
+	noise := make([]float64, len(signal))
+	for i := range signal {
+		noise[i] = noisy[i] - signal[i]
+	}
+	assert.InDelta(t, 10, 20*math.Log10(rms(signal)/rms(noise)), 0.2)
+
+	loud := Distortion{SNR: math.Inf(1), Gain: 20}.Apply(signal, audio.TargetSampleRate, rng)
+	for _, s := range loud {
+		require.LessOrEqual(t, math.Abs(s), 1.0)
+	}
+
+	// A 4 kHz tone is attenuated by a 500 Hz low-pass, a 100 Hz tone hardly
+	tone := func(freq float64) []float64 {
+		samples := make([]float64, audio.TargetSampleRate)
+		for i := range samples {
+			samples[i] = math.Sin(2 * math.Pi * freq * float64(i) / audio.TargetSampleRate)
+		}
+		return samples
+	}
+	lowPass := Distortion{SNR: math.Inf(1), LowPass: 500}
+	assert.Less(t, rms(lowPass.Apply(tone(4000), audio.TargetSampleRate, rng)), 0.2)
+	assert.Greater(t, rms(lowPass.Apply(tone(100), audio.TargetSampleRate, rng)), 0.6)
+}
+
+func TestDistortion_String(t *testing.T) {
+	assert.Equal(t, "none", NoDistortion.String())
+	assert.Equal(t, "low-pass 3000 Hz, gain -6.0 dB, noise at 10.0 dB SNR", Distortion{SNR: 10, Gain: -6, LowPass: 3000}.String())
+}
+
+func TestSummarizeLatency(t *testing.T) {
+	var latencies []time.Duration
+	for i := 1; i <= 100; i++ {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+
+	summary := summarizeLatency(latencies)
+
+	assert.Equal(t, LatencySummary{Mean: 50.5, P50: 50, P90: 90, P99: 99, Max: 100}, summary)
+	assert.Equal(t, LatencySummary{}, summarizeLatency(nil))
+}
+
+func TestLoadManifest(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "manifest.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"queries": [
+		{"file": "clips/a.m4a", "expected": "a.mp3", "clips": 2},
+		{"file": "/abs/unknown.mp3"}
+	]}`), 0o644))
+
+	manifest, err := LoadManifest(path)
+
+	require.NoError(t, err)
+	assert.Equal(t, []Query{
+		{File: filepath.Join(dir, "clips/a.m4a"), Expected: "a.mp3", Clips: 2},
+		{File: "/abs/unknown.mp3"},
+	}, manifest.Queries)
+}
+
+func TestLoadManifest_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	for _, content := range []string{`{`, `{"queries": []}`, `{"queries": [{"expected": "a.mp3"}]}`, `{"queries": [{"file": "a", "clips": -1}]}`} {
+		path := filepath.Join(dir, "manifest.json")
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+
+		_, err := LoadManifest(path)
+		assert.ErrorIs(t, err, ErrInvalidManifest, content)
+	}
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	report := &Report{
+		References: 2,
+		ClipLength: 5,
+		Distortion: "none",
+		InCatalog:  InCatalog{Clips: 4, Correct: 3, Missed: 1, Top1Accuracy: 0.75},
+		OutOfCatalog: OutOfCatalog{
+			Clips: 2, FalsePositives: 1, FalsePositiveRate: 0.5,
+		},
+		Queries: []QueryReport{{File: "q.mp3", Clips: 2, FalsePositives: 1}},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteMarkdown(&buf, report))
+
+	assert.Contains(t, buf.String(), "| Top-1 accuracy | 75.0% (3/4) |")
+	assert.Contains(t, buf.String(), "| False-positive rate | 50.0% (1/2) |")
+	assert.Contains(t, buf.String(), "| q.mp3 | (out of catalog) | 2 | 0 | 0 | 0 | 1 |")
+}
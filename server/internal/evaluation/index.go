@@ -0,0 +1,120 @@
+package evaluation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-shazam/internal/fingerprint"
+	"go-shazam/internal/recognition"
+	"go-shazam/internal/song"
+	"go-shazam/pkg/audio"
+	"math"
+
+	"github.com/google/uuid"
+)
+
+var ErrDuplicateReference = errors.New("reference track is already indexed")
+
+// Index is an isolated catalog kept in memory. Queries are recognised by the production
+// RecognitionService, only the hash lookup does not go to the database.
+type Index struct {
+	store        *fingerprint.MemoryStore
+	fingerprints *fingerprint.FingerprintService
+	songs        *catalog
+	service      *recognition.RecognitionService
+	names        map[string]uuid.UUID
+}
+
+func NewIndex(config *recognition.Config) *Index {
+	store := fingerprint.NewMemoryStore()
+	fingerprints := fingerprint.NewFingerprintServiceWithStore(store)
+	songs := &catalog{songs: make(map[uuid.UUID]*song.SongEntity)}
+
+	return &Index{
+		store:        store,
+		fingerprints: fingerprints,
+		songs:        songs,
+		// Evaluations compare tuning changes, so the fixed default thresholds apply
+		service: recognition.NewRecognitionService(config, fingerprints, songs, nil),
+		names:   make(map[string]uuid.UUID),
+	}
+}
+
+// Add fingerprints a reference track given as samples at the target rate, the same way
+// SongService indexes uploaded songs.
+func (i *Index) Add(ctx context.Context, name string, samples []float64) error {
+	if _, ok := i.names[name]; ok {
+		return fmt.Errorf("%w: %s", ErrDuplicateReference, name)
+	}
+
+	fragments, err := audio.ProcessAudio(samples, audio.TargetSampleRate)
+	if err != nil {
+		return fmt.Errorf("failed to process %s: %w", name, err)
+	}
+
+	songID := uuid.New()
+	hashes := i.fingerprints.CreateFingerprints(fragments, songID, audio.TargetSampleRate)
+	if err := i.fingerprints.SaveFingerprints(ctx, hashes); err != nil {
+		return fmt.Errorf("failed to index %s: %w", name, err)
+	}
+
+	i.names[name] = songID
+	i.songs.songs[songID] = &song.SongEntity{
+		ID:       songID,
+		Title:    name,
+		Duration: int(math.Round(float64(len(samples)) / audio.TargetSampleRate * 1000)),
+	}
+	return nil
+}
+
+// Thresholds returns the thresholds the index applies to candidates
+func (i *Index) Thresholds(ctx context.Context) recognition.Thresholds {
+	return i.service.Thresholds(ctx)
+}
+
+// Recognize runs a clip at the target rate through the recognition pipeline and returns the
+// name of the best match, or "" if nothing was recognised.
+func (i *Index) Recognize(ctx context.Context, samples []float64) (string, error) {
+	if len(samples) < audio.WindowSize {
+		return "", nil
+	}
+
+	fragments, err := audio.ProcessAudio(samples, audio.TargetSampleRate)
+	if err != nil {
+		return "", fmt.Errorf("failed to process clip: %w", err)
+	}
+
+	matches, err := i.service.IdentifySong(ctx, fragments, audio.TargetSampleRate)
+	if err != nil {
+		return "", err
+	}
+	if len(matches) == 0 {
+		return "", nil
+	}
+	return matches[0].Song.Title, nil
+}
+
+// Has reports whether a reference track of that name is indexed
+func (i *Index) Has(name string) bool {
+	_, ok := i.names[name]
+	return ok
+}
+
+func (i *Index) Size() int {
+	return len(i.names)
+}
+
+func (i *Index) Hashes() int64 {
+	return i.store.CountHashes()
+}
+
+// catalog serves the indexed tracks to the RecognitionService, which only looks songs up by
+// ID. The other repository methods are not available on an evaluation index.
+type catalog struct {
+	song.SongRepositoryInterface
+	songs map[uuid.UUID]*song.SongEntity
+}
+
+func (c *catalog) FindByID(ctx context.Context, id uuid.UUID) (*song.SongEntity, error) {
+	return c.songs[id], nil
+}
@@ -0,0 +1,60 @@
+package evaluation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+var ErrInvalidManifest = errors.New("invalid query manifest")
+
+// Query is an audio file to cut clips from. Expected is the file name of the reference track
+// it contains, relative to the reference directory; queries without one are out of the
+// catalog and must not be recognised.
+type Query struct {
+	File     string `json:"file"`
+	Expected string `json:"expected,omitempty"`
+	Clips    int    `json:"clips,omitempty"` // overrides the clips per query of the run
+}
+
+// Manifest lists the queries of an evaluation with their ground truth, e.g.
+//
+//	{"queries": [
+//	  {"file": "recordings/kitchen.m4a", "expected": "artist - title.mp3"},
+//	  {"file": "unknown/street.mp3"}
+//	]}
+type Manifest struct {
+	Queries []Query `json:"queries"`
+}
+
+// LoadManifest reads a manifest. Query files are resolved relative to the manifest.
+func LoadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidManifest, err)
+	}
+	if len(manifest.Queries) == 0 {
+		return nil, fmt.Errorf("%w: no queries", ErrInvalidManifest)
+	}
+
+	dir := filepath.Dir(path)
+	for i, q := range manifest.Queries {
+		if q.File == "" {
+			return nil, fmt.Errorf("%w: query %d has no file", ErrInvalidManifest, i)
+		}
+		if q.Clips < 0 {
+			return nil, fmt.Errorf("%w: query %d has a negative clip count", ErrInvalidManifest, i)
+		}
+		if !filepath.IsAbs(q.File) {
+			manifest.Queries[i].File = filepath.Join(dir, q.File)
+		}
+	}
+	return &manifest, nil
+}
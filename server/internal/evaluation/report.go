@@ -0,0 +1,171 @@
+package evaluation
+
+import (
+	"context"
+	"fmt"
+	"go-shazam/internal/recognition"
+	"go-shazam/pkg/landmark"
+	"io"
+	"math"
+	"sort"
+	"time"
+)
+
+// Report summarises an evaluation run. Parameters records the tuning constants and the
+// thresholds applied, so that runs before and after a change can be told apart.
+type Report struct {
+	Parameters    Parameters     `json:"parameters"`
+	References    int            `json:"references"`
+	IndexedHashes int64          `json:"indexed_hashes"`
+	ClipLength    float64        `json:"clip_length"` // seconds
+	Seed          int64          `json:"seed"`
+	Distortion    string         `json:"distortion"`
+	InCatalog     InCatalog      `json:"in_catalog"`
+	OutOfCatalog  OutOfCatalog   `json:"out_of_catalog"`
+	Latency       LatencySummary `json:"latency"`
+	Queries       []QueryReport  `json:"queries"`
+}
+
+type Parameters struct {
+	ProfileVersion    int     `json:"profile_version"`
+	MADMultiplier     float64 `json:"mad_multiplier"`
+	FanOut            int     `json:"fan_out"`
+	TimeBinResolution int     `json:"time_bin_resolution"`
+	recognition.Thresholds
+}
+
+// InCatalog counts the clips of queries whose track is indexed
+type InCatalog struct {
+	Clips        int     `json:"clips"`
+	Correct      int     `json:"correct"`
+	Wrong        int     `json:"wrong"`  // recognised as another track
+	Missed       int     `json:"missed"` // not recognised at all
+	Top1Accuracy float64 `json:"top1_accuracy"`
+}
+
+// OutOfCatalog counts the clips of queries that must not be recognised
+type OutOfCatalog struct {
+	Clips             int     `json:"clips"`
+	FalsePositives    int     `json:"false_positives"`
+	FalsePositiveRate float64 `json:"false_positive_rate"`
+}
+
+// LatencySummary is the time to fingerprint and recognise a clip, in milliseconds
+type LatencySummary struct {
+	Mean float64 `json:"mean_ms"`
+	P50  float64 `json:"p50_ms"`
+	P90  float64 `json:"p90_ms"`
+	P99  float64 `json:"p99_ms"`
+	Max  float64 `json:"max_ms"`
+}
+
+type QueryReport struct {
+	File           string `json:"file"`
+	Expected       string `json:"expected,omitempty"`
+	Clips          int    `json:"clips"`
+	Correct        int    `json:"correct"`
+	Wrong          int    `json:"wrong"`
+	Missed         int    `json:"missed"`
+	FalsePositives int    `json:"false_positives"`
+}
+
+func newReport(ctx context.Context, index *Index, options Options) *Report {
+	return &Report{
+		Parameters: Parameters{
+			ProfileVersion:    landmark.ProfileVersion,
+			MADMultiplier:     landmark.MADMultiplier,
+			FanOut:            landmark.FanOut,
+			TimeBinResolution: recognition.TimeBinResolution,
+			Thresholds:        index.Thresholds(ctx),
+		},
+		References:    index.Size(),
+		IndexedHashes: index.Hashes(),
+		ClipLength:    options.ClipLength.Seconds(),
+		Seed:          options.Seed,
+		Distortion:    options.Distortion.String(),
+		Queries:       []QueryReport{},
+	}
+}
+
+func (r *Report) add(q QueryReport) {
+	r.Queries = append(r.Queries, q)
+
+	if q.Expected == "" {
+		r.OutOfCatalog.Clips += q.Clips
+		r.OutOfCatalog.FalsePositives += q.FalsePositives
+		r.OutOfCatalog.FalsePositiveRate = ratio(r.OutOfCatalog.FalsePositives, r.OutOfCatalog.Clips)
+		return
+	}
+	r.InCatalog.Clips += q.Clips
+	r.InCatalog.Correct += q.Correct
+	r.InCatalog.Wrong += q.Wrong
+	r.InCatalog.Missed += q.Missed
+	r.InCatalog.Top1Accuracy = ratio(r.InCatalog.Correct, r.InCatalog.Clips)
+}
+
+func ratio(n int, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total)
+}
+
+func summarizeLatency(latencies []time.Duration) LatencySummary {
+	if len(latencies) == 0 {
+		return LatencySummary{}
+	}
+
+	ms := make([]float64, len(latencies))
+	sum := 0.0
+	for i, l := range latencies {
+		ms[i] = float64(l) / float64(time.Millisecond)
+		sum += ms[i]
+	}
+	sort.Float64s(ms)
+
+	return LatencySummary{
+		Mean: sum / float64(len(ms)),
+		P50:  percentile(ms, 0.50),
+		P90:  percentile(ms, 0.90),
+		P99:  percentile(ms, 0.99),
+		Max:  ms[len(ms)-1],
+	}
+}
+
+// percentile returns the nearest-rank percentile of sorted values
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	return sorted[max(0, min(rank, len(sorted)-1))]
+}
+
+// WriteMarkdown renders the report as Markdown tables.
+func WriteMarkdown(w io.Writer, r *Report) error {
+	p := r.Parameters
+	fmt.Fprintf(w, "# Recognition evaluation\n\n")
+	fmt.Fprintf(w, "%d reference tracks (%d hashes), %.1fs clips, seed %d, distortion: %s\n\n",
+		r.References, r.IndexedHashes, r.ClipLength, r.Seed, r.Distortion)
+	fmt.Fprintf(w, "Profile v%d, MADMultiplier %g, FanOut %d, TimeBinResolution %d, MinAbsoluteScore %d, MinScoreRatio %g\n\n",
+		p.ProfileVersion, p.MADMultiplier, p.FanOut, p.TimeBinResolution, p.MinAbsoluteScore, p.MinScoreRatio)
+
+	fmt.Fprintf(w, "| Metric | Value |\n|---|---|\n")
+	fmt.Fprintf(w, "| Top-1 accuracy | %.1f%% (%d/%d) |\n", r.InCatalog.Top1Accuracy*100, r.InCatalog.Correct, r.InCatalog.Clips)
+	fmt.Fprintf(w, "| Wrong track | %d |\n", r.InCatalog.Wrong)
+	fmt.Fprintf(w, "| Missed | %d |\n", r.InCatalog.Missed)
+	fmt.Fprintf(w, "| False-positive rate | %.1f%% (%d/%d) |\n", r.OutOfCatalog.FalsePositiveRate*100, r.OutOfCatalog.FalsePositives, r.OutOfCatalog.Clips)
+	fmt.Fprintf(w, "| Latency p50 / p90 / p99 | %.1f / %.1f / %.1f ms |\n", r.Latency.P50, r.Latency.P90, r.Latency.P99)
+	fmt.Fprintf(w, "| Latency mean / max | %.1f / %.1f ms |\n\n", r.Latency.Mean, r.Latency.Max)
+
+	fmt.Fprintf(w, "| Query | Expected | Clips | Correct | Wrong | Missed | False positives |\n|---|---|---|---|---|---|---|\n")
+	for _, q := range r.Queries {
+		expected := q.Expected
+		if expected == "" {
+			expected = "(out of catalog)"
+		}
+		_, err := fmt.Fprintf(w, "| %s | %s | %d | %d | %d | %d | %d |\n",
+			q.File, expected, q.Clips, q.Correct, q.Wrong, q.Missed, q.FalsePositives)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
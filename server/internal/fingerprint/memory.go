@@ -0,0 +1,85 @@
+package fingerprint
+
+import (
+	"context"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// MemoryStore is a Store that keeps the hashes in memory, for indexes that must not touch the
+// catalog in the database.
+type MemoryStore struct {
+	mu     sync.RWMutex
+	hashes map[int64][]Hash
+	counts map[uuid.UUID]int64
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		hashes: make(map[int64][]Hash),
+		counts: make(map[uuid.UUID]int64),
+	}
+}
+
+func (s *MemoryStore) SaveFingerprints(ctx context.Context, hashes []Hash) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, h := range hashes {
+		s.hashes[h.HashValue] = append(s.hashes[h.HashValue], h)
+		s.counts[h.SongID]++
+	}
+	return nil
+}
+
+func (s *MemoryStore) FindHashesByValues(ctx context.Context, hashValues []int64) ([]Hash, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// Like the SQL IN clause, every stored hash is returned once however often its value is asked for
+	seen := make(map[int64]bool, len(hashValues))
+	var result []Hash
+	for _, value := range hashValues {
+		if seen[value] {
+			continue
+		}
+		seen[value] = true
+		result = append(result, s.hashes[value]...)
+	}
+	return result, nil
+}
+
+func (s *MemoryStore) FindBySongInRange(ctx context.Context, songID uuid.UUID, from float64, to float64) ([]Hash, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []Hash
+	for _, hashes := range s.hashes {
+		for _, h := range hashes {
+			if h.SongID == songID && h.TimeOffset >= from && h.TimeOffset < to {
+				result = append(result, h)
+			}
+		}
+	}
+	return result, nil
+}
+
+func (s *MemoryStore) CountBySongID(ctx context.Context, songID uuid.UUID) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.counts[songID], nil
+}
+
+// CountHashes returns the number of stored hashes
+func (s *MemoryStore) CountHashes() int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var total int64
+	for _, count := range s.counts {
+		total += count
+	}
+	return total
+}
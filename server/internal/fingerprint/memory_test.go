@@ -0,0 +1,39 @@
+package fingerprint
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	songA := uuid.New()
+	songB := uuid.New()
+
+	require.NoError(t, store.SaveFingerprints(ctx, []Hash{
+		{HashValue: 1, SongID: songA, TimeOffset: 0.5},
+		{HashValue: 2, SongID: songA, TimeOffset: 1.0},
+		{HashValue: 1, SongID: songB, TimeOffset: 3.0},
+	}))
+
+	hashes, err := store.FindHashesByValues(ctx, []int64{1, 1, 3})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []Hash{
+		{HashValue: 1, SongID: songA, TimeOffset: 0.5},
+		{HashValue: 1, SongID: songB, TimeOffset: 3.0},
+	}, hashes)
+
+	inRange, err := store.FindBySongInRange(ctx, songA, 0.5, 1.0)
+	require.NoError(t, err)
+	assert.Equal(t, []Hash{{HashValue: 1, SongID: songA, TimeOffset: 0.5}}, inRange)
+
+	count, err := store.CountBySongID(ctx, songA)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+	assert.Equal(t, int64(3), store.CountHashes())
+}
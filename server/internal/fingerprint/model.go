@@ -1,16 +1,19 @@
 package fingerprint
 
-import "github.com/google/uuid"
+import "go-shazam/pkg/landmark"
 
-type Peak struct {
-	Frequency float64
-	Magnitude float64
-	Time      float64
-	BandIndex int
+// Hash is a stored landmark hash of a song
+type Hash = landmark.Hash
+
+// HashesPerSongSummary describes how many hashes the indexed songs have
+type HashesPerSongSummary struct {
+	Min    int64   `db:"min" json:"min"`
+	Median float64 `db:"median" json:"median"`
+	Max    int64   `db:"max" json:"max"`
 }
 
-type Hash struct {
-	HashValue  int64     `db:"hash"`
-	SongID     uuid.UUID `db:"song_id"`
-	TimeOffset float64   `db:"time_offset"`
+// DocumentFrequency is the number of distinct hash values that occur in exactly Songs songs
+type DocumentFrequency struct {
+	Songs  int   `db:"songs" json:"songs"`
+	Hashes int64 `db:"hashes" json:"hashes"`
 }
@@ -6,6 +6,7 @@ import (
 	"go-shazam/internal/core/db"
 	"strings"
 
+	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
 )
 
@@ -37,6 +38,76 @@ func (r *Repository) FindHashesByValues(ctx context.Context, hashValues []int64)
 	return hashes, nil
 }
 
+func (r *Repository) FindBySongInRange(ctx context.Context, songID uuid.UUID, from float64, to float64) ([]Hash, error) {
+	query := "SELECT hash, song_id, time_offset FROM fingerprints WHERE song_id = $1 AND time_offset >= $2 AND time_offset < $3"
+
+	var hashes []Hash
+	if err := r.db.Connection(ctx).SelectContext(ctx, &hashes, query, songID, from, to); err != nil {
+		return nil, err
+	}
+	return hashes, nil
+}
+
+func (r *Repository) CountBySongID(ctx context.Context, songID uuid.UUID) (int64, error) {
+	var count int64
+	if err := r.db.Connection(ctx).GetContext(ctx, &count, "SELECT COUNT(*) FROM fingerprints WHERE song_id = $1", songID); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (r *Repository) CountHashes(ctx context.Context) (int64, error) {
+	var count int64
+	if err := r.db.Connection(ctx).GetContext(ctx, &count, "SELECT COUNT(*) FROM fingerprints"); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// HashesPerSong summarises the hash count of every song, including songs without hashes.
+func (r *Repository) HashesPerSong(ctx context.Context) (*HashesPerSongSummary, error) {
+	query := `
+		SELECT
+			COALESCE(MIN(cnt), 0) AS min,
+			COALESCE(percentile_cont(0.5) WITHIN GROUP (ORDER BY cnt), 0) AS median,
+			COALESCE(MAX(cnt), 0) AS max
+		FROM (
+			SELECT COUNT(f.id) AS cnt
+			FROM songs s
+			LEFT JOIN fingerprints f ON f.song_id = s.id
+			GROUP BY s.id
+		) per_song
+	`
+	var summary HashesPerSongSummary
+	if err := r.db.Connection(ctx).GetContext(ctx, &summary, query); err != nil {
+		return nil, err
+	}
+	return &summary, nil
+}
+
+// DocumentFrequencies returns how many hash values are shared by 1, 2, ... songs.
+func (r *Repository) DocumentFrequencies(ctx context.Context) ([]DocumentFrequency, error) {
+	query := `
+		SELECT songs, COUNT(*) AS hashes
+		FROM (
+			SELECT COUNT(DISTINCT song_id) AS songs
+			FROM fingerprints
+			GROUP BY hash
+		) per_hash
+		GROUP BY songs
+		ORDER BY songs
+	`
+	var frequencies []DocumentFrequency
+	if err := r.db.Connection(ctx).SelectContext(ctx, &frequencies, query); err != nil {
+		return nil, err
+	}
+	return frequencies, nil
+}
+
+func (r *Repository) StorageSizes(ctx context.Context) ([]db.RelationSize, error) {
+	return r.db.RelationSizes(ctx, "fingerprints")
+}
+
 func (r *Repository) SaveFingerprints(ctx context.Context, hashes []Hash) error {
 	if len(hashes) == 0 {
 		return nil
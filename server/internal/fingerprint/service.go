@@ -2,24 +2,40 @@ package fingerprint
 
 import (
 	"context"
-	"go-shazam/internal/audio"
+	"go-shazam/pkg/audio"
+	"go-shazam/pkg/landmark"
 
 	"github.com/google/uuid"
 )
 
+// Store keeps the hashes of the catalog. Repository stores them in Postgres, MemoryStore keeps
+// an isolated index in memory.
+type Store interface {
+	SaveFingerprints(ctx context.Context, hashes []Hash) error
+	FindHashesByValues(ctx context.Context, hashValues []int64) ([]Hash, error)
+	FindBySongInRange(ctx context.Context, songID uuid.UUID, from float64, to float64) ([]Hash, error)
+	CountBySongID(ctx context.Context, songID uuid.UUID) (int64, error)
+}
+
 type FingerprintService struct {
-	repo *Repository
+	repo Store
 }
 
 func NewFingerprintService(repo *Repository) *FingerprintService {
 	return &FingerprintService{repo: repo}
 }
 
+// NewFingerprintServiceWithStore creates a service on another store than the database, e.g.
+// a MemoryStore for evaluation runs.
+func NewFingerprintServiceWithStore(store Store) *FingerprintService {
+	return &FingerprintService{repo: store}
+}
+
 // CreateFingerprints calculates fingerprints (peaks and hashes) from audio fragments.
 // This method is CPU-bound and should be called before starting a database transaction if possible.
 func (s *FingerprintService) CreateFingerprints(fragments []audio.ProcessedFragment, songID uuid.UUID, sampleRate int) []Hash {
-	peaks := ExtractPeaks(fragments, sampleRate)
-	return CreateHashes(peaks, songID)
+	peaks := landmark.ExtractPeaks(fragments, sampleRate)
+	return landmark.CreateHashes(peaks, songID)
 }
 
 // SaveFingerprints saves the pre-calculated hashes to the database.
@@ -35,3 +51,14 @@ func (s *FingerprintService) GetMatchingHashes(ctx context.Context, sampleHashes
 	}
 	return s.repo.FindHashesByValues(ctx, hashValues)
 }
+
+// GetSongHashes returns the hashes of the song anchored between from (inclusive) and to
+// (exclusive) seconds.
+func (s *FingerprintService) GetSongHashes(ctx context.Context, songID uuid.UUID, from float64, to float64) ([]Hash, error) {
+	return s.repo.FindBySongInRange(ctx, songID, from, to)
+}
+
+// CountSongHashes returns the number of hashes stored for the song.
+func (s *FingerprintService) CountSongHashes(ctx context.Context, songID uuid.UUID) (int64, error) {
+	return s.repo.CountBySongID(ctx, songID)
+}
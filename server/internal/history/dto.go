@@ -0,0 +1,49 @@
+package history
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// NewRecognition is a successful recognition to add to a user's history
+type NewRecognition struct {
+	UserID     uuid.UUID
+	SongID     uuid.UUID
+	Score      int
+	Confidence float64
+	Metadata   json.RawMessage
+}
+
+type ListRecognitionsRequest struct {
+	Limit  int `form:"limit" binding:"omitempty,min=1,max=100"`
+	Offset int `form:"offset" binding:"omitempty,min=0"`
+}
+
+type RecognizedSong struct {
+	ID       string `json:"id"`
+	Title    string `json:"title"`
+	Artist   string `json:"artist"`
+	Duration int    `json:"duration"`
+}
+
+type RecognitionResponse struct {
+	ID         string          `json:"id"`
+	Song       RecognizedSong  `json:"song"`
+	Score      int             `json:"score"`
+	Confidence float64         `json:"confidence"`
+	Metadata   json.RawMessage `json:"metadata,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+type RecognitionListResponse struct {
+	Items  []RecognitionResponse `json:"items"`
+	Total  int                   `json:"total"`
+	Limit  int                   `json:"limit"`
+	Offset int                   `json:"offset"`
+}
+
+type DeleteRecognitionsResponse struct {
+	Deleted int64 `json:"deleted"`
+}
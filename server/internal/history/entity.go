@@ -0,0 +1,25 @@
+package history
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type RecognitionEntity struct {
+	ID         uuid.UUID `db:"id"`
+	UserID     uuid.UUID `db:"user_id"`
+	SongID     uuid.UUID `db:"song_id"`
+	Score      int       `db:"score"`
+	Confidence float64   `db:"confidence"`
+	Metadata   []byte    `db:"metadata"` // Optional client metadata (JSON object)
+	CreatedAt  time.Time `db:"created_at"`
+}
+
+// RecognitionRow is a history entry joined with its song
+type RecognitionRow struct {
+	RecognitionEntity
+	SongTitle    string `db:"song_title"`
+	SongArtist   string `db:"song_artist"`
+	SongDuration int    `db:"song_duration"`
+}
@@ -0,0 +1,101 @@
+package history
+
+import (
+	"errors"
+	"go-shazam/internal/auth"
+	"go-shazam/internal/logger"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type HistoryHandler struct {
+	historyService *HistoryService
+}
+
+func NewHistoryHandler(historyService *HistoryService) *HistoryHandler {
+	return &HistoryHandler{historyService: historyService}
+}
+
+func RegisterRoutes(r *gin.Engine, h *HistoryHandler, jwtService *auth.JWTService) {
+	historyGroup := r.Group("/api/user/recognitions")
+	historyGroup.Use(auth.AuthMiddleware(jwtService))
+	{
+		historyGroup.GET("", h.List)
+		historyGroup.DELETE("", h.DeleteAll)
+		historyGroup.DELETE("/:id", h.Delete)
+	}
+}
+
+func (h *HistoryHandler) List(c *gin.Context) {
+	log := logger.FromContext(c.Request.Context())
+
+	userID, ok := auth.GetUserIDFromContext(c.Request.Context())
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	var req ListRecognitionsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+
+	recognitions, err := h.historyService.ListRecognitions(c.Request.Context(), userID, &req)
+	if err != nil {
+		log.Error("failed to list recognitions", "error", err, "user_id", userID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list recognitions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, recognitions)
+}
+
+func (h *HistoryHandler) Delete(c *gin.Context) {
+	log := logger.FromContext(c.Request.Context())
+
+	userID, ok := auth.GetUserIDFromContext(c.Request.Context())
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid recognition id"})
+		return
+	}
+
+	if err := h.historyService.DeleteRecognition(c.Request.Context(), userID, id); err != nil {
+		if errors.Is(err, ErrRecognitionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": ErrRecognitionNotFound.Error()})
+			return
+		}
+		log.Error("failed to delete recognition", "error", err, "user_id", userID, "recognition_id", id)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete recognition"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *HistoryHandler) DeleteAll(c *gin.Context) {
+	log := logger.FromContext(c.Request.Context())
+
+	userID, ok := auth.GetUserIDFromContext(c.Request.Context())
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	result, err := h.historyService.DeleteAllRecognitions(c.Request.Context(), userID)
+	if err != nil {
+		log.Error("failed to delete recognitions", "error", err, "user_id", userID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete recognitions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,127 @@
+package history
+
+import (
+	"encoding/json"
+	"go-shazam/internal/auth"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+var testJWTService = auth.NewJWTService(&auth.Config{
+	AccessTokenSecret:  "test-access-secret",
+	RefreshTokenSecret: "test-refresh-secret",
+	AccessTokenTTL:     time.Minute,
+	RefreshTokenTTL:    time.Hour,
+})
+
+func setupTestRouter(mockRepo *MockHistoryRepository) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	RegisterRoutes(router, NewHistoryHandler(NewHistoryService(mockRepo)), testJWTService)
+	return router
+}
+
+func authorizedRequest(t *testing.T, method string, target string, userID uuid.UUID) *http.Request {
+	tokens, err := testJWTService.GenerateTokenPair(userID)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(method, target, nil)
+	req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+	return req
+}
+
+func TestHistoryHandler_RequiresAuth(t *testing.T) {
+	router := setupTestRouter(new(MockHistoryRepository))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/user/recognitions", nil))
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestHistoryHandler_List(t *testing.T) {
+	mockRepo := new(MockHistoryRepository)
+	router := setupTestRouter(mockRepo)
+	userID := uuid.New()
+
+	mockRepo.On("CountByUser", mock.Anything, userID).Return(0, nil)
+	mockRepo.On("ListByUser", mock.Anything, userID, 5, 10).Return([]RecognitionRow{}, nil)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, authorizedRequest(t, http.MethodGet, "/api/user/recognitions?limit=5&offset=10", userID))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var response RecognitionListResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, RecognitionListResponse{Items: []RecognitionResponse{}, Total: 0, Limit: 5, Offset: 10}, response)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestHistoryHandler_List_InvalidLimit(t *testing.T) {
+	router := setupTestRouter(new(MockHistoryRepository))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, authorizedRequest(t, http.MethodGet, "/api/user/recognitions?limit=1000", uuid.New()))
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+}
+
+func TestHistoryHandler_Delete(t *testing.T) {
+	mockRepo := new(MockHistoryRepository)
+	router := setupTestRouter(mockRepo)
+	userID := uuid.New()
+	id := uuid.New()
+
+	mockRepo.On("Delete", mock.Anything, userID, id).Return(true, nil)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, authorizedRequest(t, http.MethodDelete, "/api/user/recognitions/"+id.String(), userID))
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestHistoryHandler_Delete_NotFound(t *testing.T) {
+	mockRepo := new(MockHistoryRepository)
+	router := setupTestRouter(mockRepo)
+	userID := uuid.New()
+	id := uuid.New()
+
+	mockRepo.On("Delete", mock.Anything, userID, id).Return(false, nil)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, authorizedRequest(t, http.MethodDelete, "/api/user/recognitions/"+id.String(), userID))
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestHistoryHandler_Delete_InvalidID(t *testing.T) {
+	router := setupTestRouter(new(MockHistoryRepository))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, authorizedRequest(t, http.MethodDelete, "/api/user/recognitions/not-a-uuid", uuid.New()))
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestHistoryHandler_DeleteAll(t *testing.T) {
+	mockRepo := new(MockHistoryRepository)
+	router := setupTestRouter(mockRepo)
+	userID := uuid.New()
+
+	mockRepo.On("DeleteByUser", mock.Anything, userID).Return(int64(3), nil)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, authorizedRequest(t, http.MethodDelete, "/api/user/recognitions", userID))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"deleted": 3}`, w.Body.String())
+}
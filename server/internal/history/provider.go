@@ -0,0 +1,17 @@
+package history
+
+import "go.uber.org/fx"
+
+var Module = fx.Module(
+	"history",
+	fx.Provide(
+		NewHistoryRepository,
+		NewHistoryService,
+	),
+)
+
+var HttpModule = fx.Module(
+	"history-http",
+	fx.Provide(NewHistoryHandler),
+	fx.Invoke(RegisterRoutes),
+)
@@ -0,0 +1,80 @@
+package history
+
+import (
+	"context"
+	"go-shazam/internal/core/db"
+
+	"github.com/google/uuid"
+)
+
+type HistoryRepositoryInterface interface {
+	Save(ctx context.Context, recognition *RecognitionEntity) error
+	ListByUser(ctx context.Context, userID uuid.UUID, limit int, offset int) ([]RecognitionRow, error)
+	CountByUser(ctx context.Context, userID uuid.UUID) (int, error)
+	Delete(ctx context.Context, userID uuid.UUID, id uuid.UUID) (bool, error)
+	DeleteByUser(ctx context.Context, userID uuid.UUID) (int64, error)
+}
+
+type HistoryRepository struct {
+	db *db.Repository
+}
+
+func NewHistoryRepository(db *db.Repository) HistoryRepositoryInterface {
+	return &HistoryRepository{db: db}
+}
+
+func (r *HistoryRepository) Save(ctx context.Context, recognition *RecognitionEntity) error {
+	query := `
+		INSERT INTO recognitions (id, user_id, song_id, score, confidence, metadata, created_at)
+		VALUES (:id, :user_id, :song_id, :score, :confidence, :metadata, :created_at)
+	`
+	_, err := r.db.Connection(ctx).NamedExecContext(ctx, query, recognition)
+	return err
+}
+
+// ListByUser returns the user's recognitions, newest first.
+func (r *HistoryRepository) ListByUser(ctx context.Context, userID uuid.UUID, limit int, offset int) ([]RecognitionRow, error) {
+	query := `
+		SELECT r.*, s.title AS song_title, s.artist AS song_artist, s.duration AS song_duration
+		FROM recognitions r
+		JOIN songs s ON s.id = r.song_id
+		WHERE r.user_id = $1
+		ORDER BY r.created_at DESC, r.id DESC
+		LIMIT $2 OFFSET $3
+	`
+	rows := []RecognitionRow{}
+	if err := r.db.Connection(ctx).SelectContext(ctx, &rows, query, userID, limit, offset); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func (r *HistoryRepository) CountByUser(ctx context.Context, userID uuid.UUID) (int, error) {
+	var count int
+	if err := r.db.Connection(ctx).GetContext(ctx, &count, "SELECT COUNT(*) FROM recognitions WHERE user_id = $1", userID); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// Delete removes one of the user's recognitions and reports whether it existed.
+func (r *HistoryRepository) Delete(ctx context.Context, userID uuid.UUID, id uuid.UUID) (bool, error) {
+	result, err := r.db.Connection(ctx).ExecContext(ctx, "DELETE FROM recognitions WHERE id = $1 AND user_id = $2", id, userID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// DeleteByUser clears the user's history and returns the number of removed recognitions.
+func (r *HistoryRepository) DeleteByUser(ctx context.Context, userID uuid.UUID) (int64, error) {
+	result, err := r.db.Connection(ctx).ExecContext(ctx, "DELETE FROM recognitions WHERE user_id = $1", userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
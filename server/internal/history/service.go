@@ -0,0 +1,146 @@
+package history
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultRecognitionListLimit = 20
+	// MaxMetadataBytes limits the client metadata stored with each recognition
+	MaxMetadataBytes = 4096
+)
+
+var (
+	ErrRecognitionNotFound = errors.New("recognition not found")
+	ErrInvalidMetadata     = fmt.Errorf("metadata must be a JSON object of at most %d bytes", MaxMetadataBytes)
+)
+
+type HistoryService struct {
+	historyRepository HistoryRepositoryInterface
+}
+
+func NewHistoryService(historyRepository HistoryRepositoryInterface) *HistoryService {
+	return &HistoryService{historyRepository: historyRepository}
+}
+
+// ValidateMetadata checks client metadata before it is accepted with a recognition request.
+// Empty metadata is valid.
+func ValidateMetadata(metadata json.RawMessage) error {
+	if len(metadata) == 0 {
+		return nil
+	}
+	if len(metadata) > MaxMetadataBytes {
+		return ErrInvalidMetadata
+	}
+	var object map[string]any
+	if err := json.Unmarshal(metadata, &object); err != nil || object == nil {
+		return ErrInvalidMetadata
+	}
+	return nil
+}
+
+// Record adds a recognition to the user's history.
+func (s *HistoryService) Record(ctx context.Context, recognition NewRecognition) error {
+	if err := ValidateMetadata(recognition.Metadata); err != nil {
+		return err
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return fmt.Errorf("failed to generate uuid: %w", err)
+	}
+
+	var metadata []byte
+	if len(recognition.Metadata) > 0 {
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, recognition.Metadata); err != nil {
+			return ErrInvalidMetadata
+		}
+		metadata = compact.Bytes()
+	}
+
+	entity := &RecognitionEntity{
+		ID:         id,
+		UserID:     recognition.UserID,
+		SongID:     recognition.SongID,
+		Score:      recognition.Score,
+		Confidence: recognition.Confidence,
+		Metadata:   metadata,
+		CreatedAt:  time.Now(),
+	}
+	if err := s.historyRepository.Save(ctx, entity); err != nil {
+		return fmt.Errorf("failed to save recognition: %w", err)
+	}
+	return nil
+}
+
+func (s *HistoryService) ListRecognitions(ctx context.Context, userID uuid.UUID, req *ListRecognitionsRequest) (*RecognitionListResponse, error) {
+	limit := req.Limit
+	if limit == 0 {
+		limit = defaultRecognitionListLimit
+	}
+
+	total, err := s.historyRepository.CountByUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count recognitions: %w", err)
+	}
+
+	rows, err := s.historyRepository.ListByUser(ctx, userID, limit, req.Offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list recognitions: %w", err)
+	}
+
+	items := make([]RecognitionResponse, len(rows))
+	for i := range rows {
+		items[i] = toRecognitionResponse(&rows[i])
+	}
+
+	return &RecognitionListResponse{
+		Items:  items,
+		Total:  total,
+		Limit:  limit,
+		Offset: req.Offset,
+	}, nil
+}
+
+func (s *HistoryService) DeleteRecognition(ctx context.Context, userID uuid.UUID, id uuid.UUID) error {
+	deleted, err := s.historyRepository.Delete(ctx, userID, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete recognition: %w", err)
+	}
+	if !deleted {
+		return ErrRecognitionNotFound
+	}
+	return nil
+}
+
+func (s *HistoryService) DeleteAllRecognitions(ctx context.Context, userID uuid.UUID) (*DeleteRecognitionsResponse, error) {
+	deleted, err := s.historyRepository.DeleteByUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete recognitions: %w", err)
+	}
+	return &DeleteRecognitionsResponse{Deleted: deleted}, nil
+}
+
+func toRecognitionResponse(row *RecognitionRow) RecognitionResponse {
+	return RecognitionResponse{
+		ID: row.ID.String(),
+		Song: RecognizedSong{
+			ID:       row.SongID.String(),
+			Title:    row.SongTitle,
+			Artist:   row.SongArtist,
+			Duration: row.SongDuration,
+		},
+		Score:      row.Score,
+		Confidence: row.Confidence,
+		Metadata:   json.RawMessage(row.Metadata),
+		CreatedAt:  row.CreatedAt,
+	}
+}
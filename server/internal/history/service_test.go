@@ -0,0 +1,192 @@
+package history
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockHistoryRepository struct {
+	mock.Mock
+}
+
+func (m *MockHistoryRepository) Save(ctx context.Context, recognition *RecognitionEntity) error {
+	args := m.Called(ctx, recognition)
+	return args.Error(0)
+}
+
+func (m *MockHistoryRepository) ListByUser(ctx context.Context, userID uuid.UUID, limit int, offset int) ([]RecognitionRow, error) {
+	args := m.Called(ctx, userID, limit, offset)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]RecognitionRow), args.Error(1)
+}
+
+func (m *MockHistoryRepository) CountByUser(ctx context.Context, userID uuid.UUID) (int, error) {
+	args := m.Called(ctx, userID)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockHistoryRepository) Delete(ctx context.Context, userID uuid.UUID, id uuid.UUID) (bool, error) {
+	args := m.Called(ctx, userID, id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockHistoryRepository) DeleteByUser(ctx context.Context, userID uuid.UUID) (int64, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func TestValidateMetadata(t *testing.T) {
+	assert.NoError(t, ValidateMetadata(nil))
+	assert.NoError(t, ValidateMetadata(json.RawMessage(`{"device": "phone", "app": {"version": "1.2"}}`)))
+
+	assert.ErrorIs(t, ValidateMetadata(json.RawMessage(`null`)), ErrInvalidMetadata)
+	assert.ErrorIs(t, ValidateMetadata(json.RawMessage(`[1, 2]`)), ErrInvalidMetadata)
+	assert.ErrorIs(t, ValidateMetadata(json.RawMessage(`{"device":`)), ErrInvalidMetadata)
+
+	large := `{"note": "` + strings.Repeat("x", MaxMetadataBytes) + `"}`
+	assert.ErrorIs(t, ValidateMetadata(json.RawMessage(large)), ErrInvalidMetadata)
+}
+
+func TestHistoryService_Record(t *testing.T) {
+	mockRepo := new(MockHistoryRepository)
+	service := NewHistoryService(mockRepo)
+	userID := uuid.New()
+	songID := uuid.New()
+
+	mockRepo.On("Save", mock.Anything, mock.MatchedBy(func(r *RecognitionEntity) bool {
+		return r.ID != uuid.Nil &&
+			r.UserID == userID &&
+			r.SongID == songID &&
+			r.Score == 30 &&
+			r.Confidence == 0.75 &&
+			string(r.Metadata) == `{"device":"phone"}` &&
+			!r.CreatedAt.IsZero()
+	})).Return(nil)
+
+	err := service.Record(context.Background(), NewRecognition{
+		UserID:     userID,
+		SongID:     songID,
+		Score:      30,
+		Confidence: 0.75,
+		Metadata:   json.RawMessage(`{ "device": "phone" }`),
+	})
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestHistoryService_Record_WithoutMetadata(t *testing.T) {
+	mockRepo := new(MockHistoryRepository)
+	service := NewHistoryService(mockRepo)
+
+	mockRepo.On("Save", mock.Anything, mock.MatchedBy(func(r *RecognitionEntity) bool {
+		return r.Metadata == nil
+	})).Return(nil)
+
+	err := service.Record(context.Background(), NewRecognition{UserID: uuid.New(), SongID: uuid.New()})
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestHistoryService_ListRecognitions(t *testing.T) {
+	mockRepo := new(MockHistoryRepository)
+	service := NewHistoryService(mockRepo)
+	userID := uuid.New()
+	createdAt := time.Date(2026, 10, 18, 20, 0, 0, 0, time.UTC)
+
+	row := RecognitionRow{
+		RecognitionEntity: RecognitionEntity{
+			ID:         uuid.New(),
+			UserID:     userID,
+			SongID:     uuid.New(),
+			Score:      30,
+			Confidence: 0.75,
+			Metadata:   []byte(`{"device":"phone"}`),
+			CreatedAt:  createdAt,
+		},
+		SongTitle:    "Song",
+		SongArtist:   "Artist",
+		SongDuration: 180000,
+	}
+
+	mockRepo.On("CountByUser", mock.Anything, userID).Return(21, nil)
+	mockRepo.On("ListByUser", mock.Anything, userID, defaultRecognitionListLimit, 20).Return([]RecognitionRow{row}, nil)
+
+	result, err := service.ListRecognitions(context.Background(), userID, &ListRecognitionsRequest{Offset: 20})
+
+	require.NoError(t, err)
+	assert.Equal(t, 21, result.Total)
+	assert.Equal(t, defaultRecognitionListLimit, result.Limit)
+	assert.Equal(t, 20, result.Offset)
+	require.Len(t, result.Items, 1)
+	assert.Equal(t, RecognitionResponse{
+		ID: row.ID.String(),
+		Song: RecognizedSong{
+			ID:       row.SongID.String(),
+			Title:    "Song",
+			Artist:   "Artist",
+			Duration: 180000,
+		},
+		Score:      30,
+		Confidence: 0.75,
+		Metadata:   json.RawMessage(`{"device":"phone"}`),
+		CreatedAt:  createdAt,
+	}, result.Items[0])
+	mockRepo.AssertExpectations(t)
+}
+
+func TestHistoryService_DeleteRecognition(t *testing.T) {
+	userID := uuid.New()
+	id := uuid.New()
+
+	t.Run("deleted", func(t *testing.T) {
+		mockRepo := new(MockHistoryRepository)
+		mockRepo.On("Delete", mock.Anything, userID, id).Return(true, nil)
+
+		err := NewHistoryService(mockRepo).DeleteRecognition(context.Background(), userID, id)
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("not found or owned by another user", func(t *testing.T) {
+		mockRepo := new(MockHistoryRepository)
+		mockRepo.On("Delete", mock.Anything, userID, id).Return(false, nil)
+
+		err := NewHistoryService(mockRepo).DeleteRecognition(context.Background(), userID, id)
+
+		assert.ErrorIs(t, err, ErrRecognitionNotFound)
+	})
+
+	t.Run("repository error", func(t *testing.T) {
+		mockRepo := new(MockHistoryRepository)
+		mockRepo.On("Delete", mock.Anything, userID, id).Return(false, errors.New("db down"))
+
+		err := NewHistoryService(mockRepo).DeleteRecognition(context.Background(), userID, id)
+
+		assert.Error(t, err)
+		assert.NotErrorIs(t, err, ErrRecognitionNotFound)
+	})
+}
+
+func TestHistoryService_DeleteAllRecognitions(t *testing.T) {
+	mockRepo := new(MockHistoryRepository)
+	userID := uuid.New()
+	mockRepo.On("DeleteByUser", mock.Anything, userID).Return(int64(7), nil)
+
+	result, err := NewHistoryService(mockRepo).DeleteAllRecognitions(context.Background(), userID)
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), result.Deleted)
+}
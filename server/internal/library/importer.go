@@ -0,0 +1,294 @@
+package library
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go-shazam/internal/song"
+	"go-shazam/internal/utils/converter"
+	"go-shazam/pkg/audio"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	StatusImported  = "imported"
+	StatusSkipped   = "skipped"   // imported by an earlier run, or the same file twice
+	StatusDuplicate = "duplicate" // another song with the same title and artist is in the catalog
+	StatusFailed    = "failed"
+)
+
+var ErrMissingTags = errors.New("title or artist tag missing")
+
+// audioExtensions are the files an import picks up; covers, playlists and cue sheets are ignored
+var audioExtensions = map[string]bool{
+	".mp3": true, ".flac": true, ".ogg": true, ".oga": true, ".opus": true, ".m4a": true,
+	".mp4": true, ".aac": true, ".wav": true, ".aif": true, ".aiff": true, ".wma": true,
+	".wv": true, ".ape": true,
+}
+
+// SongWriter saves imported songs; *song.SongService in the import command
+type SongWriter interface {
+	AddSongSamples(ctx context.Context, songMeta *song.SongMetadata, samples []float64, sourceID string) (*song.SongMetadata, error)
+}
+
+type Options struct {
+	Workers  int              // files read and fingerprinted at once
+	Progress func(FileResult) // called as each file is done, from any worker
+}
+
+// Importer adds the audio files of a local music library to the catalog. Songs are identified
+// by the checksum of their file, like uploaded songs, so an interrupted import can be run
+// again and only picks up the files it has not imported yet.
+type Importer struct {
+	songRepository song.SongRepositoryInterface
+	songs          SongWriter
+	readTags       func(path string) (*converter.Tags, error)
+	decode         func(path string) ([]float64, error)
+}
+
+func NewImporter(songRepository song.SongRepositoryInterface, songs SongWriter) *Importer {
+	return &Importer{
+		songRepository: songRepository,
+		songs:          songs,
+		readTags:       converter.ReadTags,
+		decode: func(path string) ([]float64, error) {
+			return audio.DecodeFile(path, audio.TargetSampleRate)
+		},
+	}
+}
+
+// Import walks dir and imports its audio files with options.Workers workers. Failures of single
+// files are recorded in the report; only a failure to walk dir is returned as an error.
+func (i *Importer) Import(ctx context.Context, dir string, options Options) (*Report, error) {
+	workers := max(options.Workers, 1)
+	report := &Report{Dir: dir, Workers: workers, StartedAt: time.Now()}
+
+	paths := make(chan string)
+	results := make(chan FileResult)
+	claimed := &claims{files: make(map[string]string), songs: make(map[string]*songClaim)}
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range paths {
+				result := i.importFile(ctx, dir, path, claimed)
+				if options.Progress != nil {
+					options.Progress(result)
+				}
+				results <- result
+			}
+		}()
+	}
+
+	walkErr := make(chan error, 1)
+	go func() {
+		defer close(paths)
+		walkErr <- filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if strings.HasPrefix(d.Name(), ".") && path != dir {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if d.IsDir() || !audioExtensions[strings.ToLower(filepath.Ext(path))] {
+				return nil
+			}
+			select {
+			case paths <- path:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	for result := range results {
+		report.add(result)
+	}
+	report.Duration = time.Since(report.StartedAt).Seconds()
+	sort.Slice(report.Files, func(a, b int) bool { return report.Files[a].Path < report.Files[b].Path })
+
+	if err := <-walkErr; err != nil {
+		return report, fmt.Errorf("failed to walk %s: %w", dir, err)
+	}
+	return report, nil
+}
+
+// importFile reads, fingerprints and saves one file unless its checksum is already imported
+// or its song is in the catalog
+func (i *Importer) importFile(ctx context.Context, dir string, path string, claimed *claims) FileResult {
+	result := FileResult{Path: path}
+	if name, err := filepath.Rel(dir, path); err == nil {
+		result.Path = filepath.ToSlash(name)
+	}
+	fail := func(err error) FileResult {
+		result.Status = StatusFailed
+		result.Error = err.Error()
+		return result
+	}
+
+	checksum, err := fileChecksum(path)
+	if err != nil {
+		return fail(err)
+	}
+	result.SourceID = song.UploadSourceID(checksum)
+
+	if other, ok := claimed.claimFile(checksum, result.Path); !ok {
+		result.Status = StatusSkipped
+		result.Error = "same file as " + other
+		return result
+	}
+	existing, err := i.songRepository.FindBySourceID(ctx, result.SourceID)
+	if err != nil {
+		return fail(fmt.Errorf("failed to look up source ID: %w", err))
+	}
+	if existing != nil {
+		result.Status = StatusSkipped
+		result.Title, result.Artist, result.DurationMs = existing.Title, existing.Artist, existing.Duration
+		return result
+	}
+
+	tags, err := i.readTags(path)
+	if err != nil {
+		return fail(fmt.Errorf("%w: %s", song.ErrUnsupportedAudio, err))
+	}
+	result.Title, result.Artist, result.Album, result.DurationMs = tags.Title, tags.Artist, tags.Album, tags.DurationMs
+	if tags.Title == "" || tags.Artist == "" {
+		return fail(ErrMissingTags)
+	}
+
+	// Another file of the run with the same song is imported or reported as the duplicate. If
+	// the file that claimed the song fails, the claim passes to the next file of the song.
+	if other, ok := claimed.claimSong(tags.Title, tags.Artist, result.Path); !ok {
+		result.Status = StatusDuplicate
+		result.Error = "same song as " + other
+		return result
+	}
+	defer func() {
+		claimed.releaseSong(tags.Title, tags.Artist, result.Status != StatusFailed)
+	}()
+	existing, err = i.songRepository.FindByTitleAndArtist(ctx, tags.Title, tags.Artist)
+	if err != nil {
+		return fail(fmt.Errorf("failed to look up song: %w", err))
+	}
+	if existing != nil {
+		result.Status = StatusDuplicate
+		return result
+	}
+
+	samples, err := i.decode(path)
+	if err != nil {
+		return fail(fmt.Errorf("%w: %s", song.ErrUnsupportedAudio, err))
+	}
+	if len(samples) < audio.WindowSize {
+		return fail(fmt.Errorf("%w: too short to fingerprint", song.ErrUnsupportedAudio))
+	}
+
+	songMeta := &song.SongMetadata{Title: tags.Title, Artist: tags.Artist, DurationMs: tags.DurationMs}
+	saved, err := i.songs.AddSongSamples(ctx, songMeta, samples, result.SourceID)
+	if err != nil {
+		return fail(err)
+	}
+	if saved != songMeta {
+		// The song was added by someone else since the lookup; the catalog song is returned
+		result.Status = StatusDuplicate
+		return result
+	}
+	result.Status = StatusImported
+	result.DurationMs = saved.DurationMs
+	return result
+}
+
+// claims remembers the files and songs of the current run, so that copies of a file, or files
+// of the same song, are imported once even when they are read at the same time.
+type claims struct {
+	mu    sync.Mutex
+	files map[string]string     // checksum to the path that claimed it
+	songs map[string]*songClaim // title and artist to the file importing them
+}
+
+// songClaim is held by the file importing a song until its result is known
+type songClaim struct {
+	path string
+	done chan struct{}
+}
+
+func (c *claims) claimFile(checksum string, path string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if other, ok := c.files[checksum]; ok {
+		return other, false
+	}
+	c.files[checksum] = path
+	return "", true
+}
+
+// claimSong claims a song for the file at path. A song claimed by another file is only
+// reported once that file is done with it; if it failed, the claim is taken over.
+func (c *claims) claimSong(title string, artist string, path string) (string, bool) {
+	key := title + "\x00" + artist
+	for {
+		c.mu.Lock()
+		claim, ok := c.songs[key]
+		if !ok {
+			c.songs[key] = &songClaim{path: path, done: make(chan struct{})}
+			c.mu.Unlock()
+			return "", true
+		}
+		c.mu.Unlock()
+
+		<-claim.done
+		c.mu.Lock()
+		kept := c.songs[key] == claim
+		c.mu.Unlock()
+		if kept {
+			return claim.path, false
+		}
+	}
+}
+
+// releaseSong ends the claim of a song. The claim of a song that is in the catalog stays, so
+// that the other files of the song are duplicates; the claim of a failed file is dropped.
+func (c *claims) releaseSong(title string, artist string, kept bool) {
+	key := title + "\x00" + artist
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	claim := c.songs[key]
+	if !kept {
+		delete(c.songs, key)
+	}
+	close(claim.done)
+}
+
+// fileChecksum returns the hex SHA-256 of the file
+func fileChecksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,244 @@
+package library
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"go-shazam/internal/song"
+	"go-shazam/internal/utils/converter"
+	"go-shazam/pkg/audio"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// catalog is an in-memory song repository that the stub writer adds to
+type catalog struct {
+	song.SongRepositoryInterface
+	mu    sync.Mutex
+	songs []song.SongEntity
+	added []string // source IDs saved by the writer
+}
+
+func (c *catalog) FindBySourceID(ctx context.Context, sourceID string) (*song.SongEntity, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := range c.songs {
+		if c.songs[i].SourceID == sourceID {
+			return &c.songs[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (c *catalog) FindByTitleAndArtist(ctx context.Context, title string, artist string) (*song.SongEntity, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := range c.songs {
+		if c.songs[i].Title == title && c.songs[i].Artist == artist {
+			return &c.songs[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (c *catalog) AddSongSamples(ctx context.Context, songMeta *song.SongMetadata, samples []float64, sourceID string) (*song.SongMetadata, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Like the song service, the catalog song is returned when the title and artist exist
+	for _, existing := range c.songs {
+		if existing.Title == songMeta.Title && existing.Artist == songMeta.Artist {
+			return &song.SongMetadata{Title: existing.Title, Artist: existing.Artist, DurationMs: existing.Duration}, nil
+		}
+	}
+	if songMeta.DurationMs == 0 {
+		songMeta.DurationMs = len(samples) * 1000 / audio.TargetSampleRate
+	}
+	c.songs = append(c.songs, song.SongEntity{ID: uuid.New(), Title: songMeta.Title, Artist: songMeta.Artist, Duration: songMeta.DurationMs, SourceID: sourceID})
+	c.added = append(c.added, sourceID)
+	return songMeta, nil
+}
+
+// musicLibrary writes the files of a music library; the content of each file names its tags
+func musicLibrary(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+	return dir
+}
+
+func newTestImporter(c *catalog, tags map[string]*converter.Tags) *Importer {
+	importer := NewImporter(c, c)
+	importer.readTags = func(path string) (*converter.Tags, error) {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if t, ok := tags[string(content)]; ok {
+			return t, nil
+		}
+		return nil, errors.New("invalid data found when processing input")
+	}
+	importer.decode = func(path string) ([]float64, error) {
+		return make([]float64, 3*audio.TargetSampleRate), nil
+	}
+	return importer
+}
+
+func TestImporter_Import(t *testing.T) {
+	c := &catalog{songs: []song.SongEntity{{ID: uuid.New(), Title: "Known", Artist: "Band", SourceID: "spotify"}}}
+	tags := map[string]*converter.Tags{
+		"one":      {Title: "One", Artist: "Band", Album: "First", DurationMs: 200000},
+		"two":      {Title: "Two", Artist: "Band"},
+		"known":    {Title: "Known", Artist: "Band"},
+		"untagged": {Title: "Untagged"},
+	}
+	dir := musicLibrary(t, map[string]string{
+		"Band/First/01 One.mp3":  "one",
+		"Band/Copies/One.MP3":    "one",
+		"Band/Second/Two.flac":   "two",
+		"Band/Known.ogg":         "known",
+		"untagged.mp3":           "untagged",
+		"broken.wav":             "broken",
+		"Band/First/cover.jpg":   "one",
+		".trash/Deleted One.mp3": "deleted",
+	})
+	importer := newTestImporter(c, tags)
+
+	var progress []string
+	var mu sync.Mutex
+	report, err := importer.Import(context.Background(), dir, Options{Workers: 4, Progress: func(f FileResult) {
+		mu.Lock()
+		defer mu.Unlock()
+		progress = append(progress, f.Path)
+	}})
+	require.NoError(t, err)
+
+	assert.Len(t, report.Files, 6)
+	assert.Len(t, progress, 6)
+	assert.Equal(t, 2, report.Imported)
+	assert.Equal(t, 1, report.Skipped, "the copy of One")
+	assert.Equal(t, 1, report.Duplicates)
+	assert.Equal(t, 2, report.Failed)
+	assert.Len(t, c.added, 2)
+
+	statuses := make(map[string]FileResult)
+	for _, f := range report.Files {
+		statuses[f.Path] = f
+	}
+	assert.Equal(t, StatusImported, statuses["Band/Second/Two.flac"].Status)
+	assert.Equal(t, 3000, statuses["Band/Second/Two.flac"].DurationMs, "taken from the audio without a duration tag")
+	assert.Equal(t, StatusDuplicate, statuses["Band/Known.ogg"].Status)
+	assert.Equal(t, ErrMissingTags.Error(), statuses["untagged.mp3"].Error)
+	assert.Contains(t, statuses["broken.wav"].Error, song.ErrUnsupportedAudio.Error())
+	assert.Equal(t, statuses["Band/First/01 One.mp3"].SourceID, statuses["Band/Copies/One.MP3"].SourceID)
+	assert.ElementsMatch(t, []string{StatusImported, StatusSkipped}, []string{statuses["Band/First/01 One.mp3"].Status, statuses["Band/Copies/One.MP3"].Status})
+
+	// A second run only retries the files that were not imported
+	report, err = importer.Import(context.Background(), dir, Options{Workers: 2})
+	require.NoError(t, err)
+
+	assert.Equal(t, 0, report.Imported)
+	assert.Equal(t, 3, report.Skipped)
+	assert.Equal(t, 1, report.Duplicates)
+	assert.Equal(t, 2, report.Failed)
+	assert.Len(t, c.added, 2)
+}
+
+func TestImporter_Import_SameSongInOneRun(t *testing.T) {
+	c := &catalog{}
+	tags := map[string]*converter.Tags{
+		"album": {Title: "One", Artist: "Band"},
+		"live":  {Title: "One", Artist: "Band"},
+	}
+	dir := musicLibrary(t, map[string]string{"Album/One.mp3": "album", "Live/One.mp3": "live"})
+
+	report, err := newTestImporter(c, tags).Import(context.Background(), dir, Options{Workers: 2})
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, report.Imported)
+	assert.Equal(t, 1, report.Duplicates)
+	assert.Len(t, c.added, 1)
+}
+
+func TestImporter_Import_SameSongAfterFailedFile(t *testing.T) {
+	tags := map[string]*converter.Tags{
+		"album": {Title: "One", Artist: "Band"},
+		"live":  {Title: "One", Artist: "Band"},
+	}
+
+	for _, workers := range []int{1, 2} {
+		t.Run(fmt.Sprintf("%d workers", workers), func(t *testing.T) {
+			c := &catalog{}
+			dir := musicLibrary(t, map[string]string{"Album/One.mp3": "album", "Live/One.mp3": "live"})
+			importer := newTestImporter(c, tags)
+			importer.decode = func(path string) ([]float64, error) {
+				if strings.Contains(path, "Album") {
+					return nil, errors.New("invalid data found when processing input")
+				}
+				return make([]float64, 3*audio.TargetSampleRate), nil
+			}
+
+			report, err := importer.Import(context.Background(), dir, Options{Workers: workers})
+			require.NoError(t, err)
+
+			statuses := make(map[string]string)
+			for _, f := range report.Files {
+				statuses[f.Path] = f.Status
+			}
+			assert.Equal(t, StatusImported, statuses["Live/One.mp3"], "the song is imported from the other file")
+			// The broken file fails, or is the duplicate when the other file claims the song first
+			assert.Contains(t, []string{StatusFailed, StatusDuplicate}, statuses["Album/One.mp3"])
+			assert.Len(t, c.added, 1)
+		})
+	}
+}
+
+// lateCatalog does not find songs by title and artist, as if they were added after the lookup
+type lateCatalog struct {
+	*catalog
+}
+
+func (c lateCatalog) FindByTitleAndArtist(ctx context.Context, title string, artist string) (*song.SongEntity, error) {
+	return nil, nil
+}
+
+func TestImporter_Import_SongAddedAfterLookup(t *testing.T) {
+	c := &catalog{songs: []song.SongEntity{{ID: uuid.New(), Title: "Known", Artist: "Band", SourceID: "spotify"}}}
+	dir := musicLibrary(t, map[string]string{"Known.mp3": "known"})
+	importer := newTestImporter(c, map[string]*converter.Tags{"known": {Title: "Known", Artist: "Band"}})
+	importer.songRepository = lateCatalog{c}
+
+	report, err := importer.Import(context.Background(), dir, Options{Workers: 1})
+	require.NoError(t, err)
+
+	require.Len(t, report.Files, 1)
+	assert.Equal(t, StatusDuplicate, report.Files[0].Status)
+	assert.Empty(t, c.added)
+}
+
+func TestWriteReport(t *testing.T) {
+	report := &Report{Dir: "/music", Workers: 2, Imported: 1, Failed: 1, Files: []FileResult{
+		{Path: "a.mp3", Status: StatusImported, Title: "A", Artist: "Band"},
+		{Path: "b.mp3", Status: StatusFailed, Error: ErrMissingTags.Error()},
+	}}
+
+	var out bytes.Buffer
+	require.NoError(t, WriteReport(&out, report))
+
+	assert.Regexp(t, `Imported:\s+1\n`, out.String())
+	assert.Contains(t, out.String(), "b.mp3")
+	assert.Contains(t, out.String(), ErrMissingTags.Error())
+	assert.NotContains(t, out.String(), "a.mp3", "imported files are only counted")
+}
@@ -0,0 +1,87 @@
+package library
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+)
+
+// Report summarises an import run, with the outcome of every audio file found
+type Report struct {
+	Dir        string       `json:"dir"`
+	Workers    int          `json:"workers"`
+	StartedAt  time.Time    `json:"started_at"`
+	Duration   float64      `json:"duration"` // seconds
+	Imported   int          `json:"imported"`
+	Skipped    int          `json:"skipped"`
+	Duplicates int          `json:"duplicates"`
+	Failed     int          `json:"failed"`
+	Files      []FileResult `json:"files"`
+}
+
+type FileResult struct {
+	Path       string `json:"path"` // relative to the imported directory
+	Status     string `json:"status"`
+	SourceID   string `json:"source_id,omitempty"`
+	Title      string `json:"title,omitempty"`
+	Artist     string `json:"artist,omitempty"`
+	Album      string `json:"album,omitempty"`
+	DurationMs int    `json:"duration_ms,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+func (r *Report) add(result FileResult) {
+	switch result.Status {
+	case StatusImported:
+		r.Imported++
+	case StatusSkipped:
+		r.Skipped++
+	case StatusDuplicate:
+		r.Duplicates++
+	case StatusFailed:
+		r.Failed++
+	}
+	r.Files = append(r.Files, result)
+}
+
+// WriteReport renders the import as a plain-text report. Imported and skipped files are only
+// counted; duplicates and failures are listed, since they need attention.
+func WriteReport(w io.Writer, report *Report) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "Import of %s (started %s, %s, %d workers)\n\n",
+		report.Dir, report.StartedAt.Format(time.RFC3339), time.Duration(report.Duration*float64(time.Second)).Round(time.Second), report.Workers)
+
+	fmt.Fprintf(tw, "Files:\t%d\n", len(report.Files))
+	fmt.Fprintf(tw, "Imported:\t%d\n", report.Imported)
+	fmt.Fprintf(tw, "Skipped (already imported):\t%d\n", report.Skipped)
+	fmt.Fprintf(tw, "Duplicates:\t%d\n", report.Duplicates)
+	fmt.Fprintf(tw, "Failed:\t%d\n\n", report.Failed)
+
+	fmt.Fprintln(tw, "Duplicates of catalog songs")
+	writeFiles(tw, report, StatusDuplicate)
+	fmt.Fprintln(tw)
+
+	fmt.Fprintln(tw, "Failures")
+	writeFiles(tw, report, StatusFailed)
+
+	return tw.Flush()
+}
+
+func writeFiles(tw *tabwriter.Writer, report *Report, status string) {
+	found := false
+	for _, f := range report.Files {
+		if f.Status != status {
+			continue
+		}
+		if !found {
+			fmt.Fprintln(tw, "Path\tArtist\tTitle\tError")
+			found = true
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", f.Path, f.Artist, f.Title, f.Error)
+	}
+	if !found {
+		fmt.Fprintln(tw, "(none)")
+	}
+}
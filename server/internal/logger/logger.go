@@ -21,3 +21,8 @@ func FromContext(ctx context.Context) *slog.Logger {
 func Global() *slog.Logger {
 	return defaultLogger
 }
+
+// SetDefault replaces the logger, e.g. for command line tools that keep stdout for their output
+func SetDefault(l *slog.Logger) {
+	defaultLogger = l
+}
@@ -0,0 +1,35 @@
+package musicbrainz
+
+import (
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+type Config struct {
+	BaseURL     string        // root of the MusicBrainz web service
+	UserAgent   string        // MusicBrainz rejects requests without a meaningful user agent
+	MinInterval time.Duration // least time between two requests, one per second for anonymous clients
+	MaxTracks   int           // most recordings added from one release link
+}
+
+func LoadConfig() *Config {
+	viper.SetConfigFile(".env")
+	viper.ReadInConfig()
+	viper.AutomaticEnv()
+
+	viper.SetDefault("MUSICBRAINZ_BASE_URL", "https://musicbrainz.org/ws/2")
+	viper.SetDefault("MUSICBRAINZ_USER_AGENT", "go-shazam/1.0")
+	viper.SetDefault("MUSICBRAINZ_MIN_INTERVAL_MS", 1000)
+	viper.SetDefault("MUSICBRAINZ_MAX_TRACKS", 200)
+	if viper.GetInt("MUSICBRAINZ_MAX_TRACKS") < 1 {
+		panic("MUSICBRAINZ_MAX_TRACKS must be at least 1")
+	}
+
+	return &Config{
+		BaseURL:     viper.GetString("MUSICBRAINZ_BASE_URL"),
+		UserAgent:   viper.GetString("MUSICBRAINZ_USER_AGENT"),
+		MinInterval: time.Duration(viper.GetInt("MUSICBRAINZ_MIN_INTERVAL_MS")) * time.Millisecond,
+		MaxTracks:   viper.GetInt("MUSICBRAINZ_MAX_TRACKS"),
+	}
+}
@@ -0,0 +1,26 @@
+package musicbrainz
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadConfig_Defaults(t *testing.T) {
+	config := LoadConfig()
+
+	assert.Equal(t, "https://musicbrainz.org/ws/2", config.BaseURL)
+	assert.Equal(t, time.Second, config.MinInterval)
+	assert.Equal(t, 200, config.MaxTracks)
+}
+
+func TestLoadConfig_InvalidMaxTracks(t *testing.T) {
+	os.Setenv("MUSICBRAINZ_MAX_TRACKS", "0")
+	defer os.Unsetenv("MUSICBRAINZ_MAX_TRACKS")
+
+	assert.Panics(t, func() {
+		LoadConfig()
+	})
+}
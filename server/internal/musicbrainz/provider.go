@@ -0,0 +1,14 @@
+package musicbrainz
+
+import (
+	"go-shazam/internal/song"
+
+	"go.uber.org/fx"
+)
+
+var Module = fx.Module("musicbrainz",
+	fx.Provide(
+		fx.Annotate(NewMusicBrainzSongMetadataSource, fx.ResultTags(song.MetadataProvidersGroup)),
+		LoadConfig,
+	),
+)
@@ -0,0 +1,184 @@
+package musicbrainz
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-shazam/internal/song"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	linkRecording = "recording"
+	linkRelease   = "release"
+)
+
+// hosts are the hosts of MusicBrainz links
+var hosts = []string{"musicbrainz.org", "www.musicbrainz.org", "beta.musicbrainz.org"}
+
+var ErrNoTracks = errors.New("no tracks found behind the MusicBrainz link")
+
+// MusicBrainzSongMetadataSource reads song metadata from the MusicBrainz web service. Its
+// source IDs are recording MBIDs; release links stand for the recordings of all their tracks.
+type MusicBrainzSongMetadataSource struct {
+	client      *http.Client
+	baseURL     string
+	userAgent   string
+	minInterval time.Duration
+	maxTracks   int // most recordings taken from a release
+	lastRequest time.Time
+	mu          sync.Mutex
+}
+
+type artistCredit struct {
+	Name string `json:"name"`
+}
+
+type recording struct {
+	ID           string         `json:"id"`
+	Title        string         `json:"title"`
+	Length       int            `json:"length"` // ms, 0 when unknown
+	ArtistCredit []artistCredit `json:"artist-credit"`
+}
+
+type release struct {
+	Media []struct {
+		Tracks []struct {
+			Recording recording `json:"recording"`
+		} `json:"tracks"`
+	} `json:"media"`
+}
+
+func NewMusicBrainzSongMetadataSource(config *Config) song.MetadataProvider {
+	return &MusicBrainzSongMetadataSource{
+		client:      &http.Client{Timeout: 10 * time.Second},
+		baseURL:     strings.TrimSuffix(config.BaseURL, "/"),
+		userAgent:   config.UserAgent,
+		minInterval: config.MinInterval,
+		maxTracks:   config.MaxTracks,
+	}
+}
+
+func (s *MusicBrainzSongMetadataSource) Name() string {
+	return "musicbrainz"
+}
+
+func (s *MusicBrainzSongMetadataSource) Hosts() []string {
+	return hosts
+}
+
+func (s *MusicBrainzSongMetadataSource) GetSongMetadata(ctx context.Context, id string) (*song.SongMetadata, error) {
+	var rec recording
+	if err := s.get(ctx, "recording/"+url.PathEscape(id), "artist-credits", &rec); err != nil {
+		return nil, fmt.Errorf("failed to get recording from MusicBrainz: %w", err)
+	}
+
+	if len(rec.ArtistCredit) == 0 {
+		return nil, errors.New("recording has no artists")
+	}
+
+	return &song.SongMetadata{
+		Title:      rec.Title,
+		Artist:     rec.ArtistCredit[0].Name,
+		DurationMs: rec.Length,
+	}, nil
+}
+
+// ExtractSourceIDs returns the recording IDs behind a recording or release link. Releases are
+// cut at MaxTracks recordings.
+func (s *MusicBrainzSongMetadataSource) ExtractSourceIDs(ctx context.Context, link string) ([]string, error) {
+	kind, id, err := s.parseLink(link)
+	if err != nil {
+		return nil, err
+	}
+	if kind == linkRecording {
+		return []string{id}, nil
+	}
+
+	var rel release
+	if err := s.get(ctx, "release/"+id, "recordings", &rel); err != nil {
+		return nil, fmt.Errorf("failed to get release from MusicBrainz: %w", err)
+	}
+
+	var ids []string
+	for _, medium := range rel.Media {
+		for _, track := range medium.Tracks {
+			if track.Recording.ID != "" {
+				ids = append(ids, track.Recording.ID)
+			}
+		}
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNoTracks, kind)
+	}
+	return ids[:min(len(ids), s.maxTracks)], nil
+}
+
+// parseLink returns the kind of a MusicBrainz link and the MBID of the recording or release.
+// The registry only hands over links of MusicBrainz hosts.
+func (s *MusicBrainzSongMetadataSource) parseLink(link string) (string, string, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to parse URL: %w", err)
+	}
+
+	pathParts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(pathParts) < 2 || (pathParts[0] != linkRecording && pathParts[0] != linkRelease) {
+		return "", "", errors.New("invalid MusicBrainz link: recording or release ID not found")
+	}
+
+	id, err := uuid.Parse(pathParts[1])
+	if err != nil {
+		return "", "", fmt.Errorf("invalid MusicBrainz link: %w", err)
+	}
+	return pathParts[0], id.String(), nil
+}
+
+// get fetches a resource of the web service as JSON, keeping to the rate limit
+func (s *MusicBrainzSongMetadataSource) get(ctx context.Context, path string, inc string, v any) error {
+	if err := s.wait(ctx); err != nil {
+		return err
+	}
+
+	query := url.Values{"fmt": {"json"}, "inc": {inc}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.baseURL+"/"+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", s.userAgent)
+	req.Header.Set("Accept", "application/json")
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", res.Status)
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+// wait blocks until the next request is allowed by the rate limit
+func (s *MusicBrainzSongMetadataSource) wait(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if delay := time.Until(s.lastRequest.Add(s.minInterval)); delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	s.lastRequest = time.Now()
+	return nil
+}
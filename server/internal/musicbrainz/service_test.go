@@ -0,0 +1,122 @@
+package musicbrainz
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	recordingID = "b1a9c0e9-d987-4042-ae91-78d6a3267d69"
+	releaseID   = "4f2b1f5c-6a0e-4c5c-8f4c-7d0c4f3f6b2e"
+)
+
+// stubSource points a source at a stub of the MusicBrainz web service
+func stubSource(t *testing.T, handler http.HandlerFunc) *MusicBrainzSongMetadataSource {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return NewMusicBrainzSongMetadataSource(&Config{BaseURL: server.URL + "/ws/2/", UserAgent: "go-shazam-test/1.0", MaxTracks: 10}).(*MusicBrainzSongMetadataSource)
+}
+
+func TestMusicBrainzSongMetadataSource_GetSongMetadata(t *testing.T) {
+	source := stubSource(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/ws/2/recording/"+recordingID, r.URL.Path)
+		assert.Equal(t, "artist-credits", r.URL.Query().Get("inc"))
+		assert.Equal(t, "json", r.URL.Query().Get("fmt"))
+		assert.Equal(t, "go-shazam-test/1.0", r.UserAgent())
+		fmt.Fprintf(w, `{"id":%q,"title":"Smells Like Teen Spirit","length":301920,"artist-credit":[{"name":"Nirvana","joinphrase":""}]}`, recordingID)
+	})
+
+	meta, err := source.GetSongMetadata(context.Background(), recordingID)
+
+	require.NoError(t, err)
+	assert.Equal(t, "Smells Like Teen Spirit", meta.Title)
+	assert.Equal(t, "Nirvana", meta.Artist)
+	assert.Equal(t, 301920, meta.DurationMs)
+}
+
+func TestMusicBrainzSongMetadataSource_GetSongMetadata_NotFound(t *testing.T) {
+	source := stubSource(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"error":"Not Found"}`)
+	})
+
+	meta, err := source.GetSongMetadata(context.Background(), recordingID)
+
+	assert.Nil(t, meta)
+	assert.ErrorContains(t, err, "404")
+}
+
+func TestMusicBrainzSongMetadataSource_ExtractSourceIDs(t *testing.T) {
+	source := stubSource(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ws/2/release/" + releaseID:
+			assert.Equal(t, "recordings", r.URL.Query().Get("inc"))
+			fmt.Fprint(w, `{"media":[
+				{"tracks":[{"recording":{"id":"r1"}},{"recording":{"id":"r2"}}]},
+				{"tracks":[{"recording":{"id":"r3"}}]}
+			]}`)
+		default:
+			fmt.Fprint(w, `{"media":[]}`)
+		}
+	})
+
+	tests := []struct {
+		name string
+		link string
+		ids  []string
+		err  string
+	}{
+		{"recording", "https://musicbrainz.org/recording/" + recordingID, []string{recordingID}, ""},
+		{"recording subpage", "https://beta.musicbrainz.org/recording/" + recordingID + "/fingerprints", []string{recordingID}, ""},
+		{"release on all media", "https://musicbrainz.org/release/" + releaseID, []string{"r1", "r2", "r3"}, ""},
+		{"empty release", "https://musicbrainz.org/release/" + recordingID, nil, ErrNoTracks.Error()},
+		{"unsupported kind", "https://musicbrainz.org/artist/" + recordingID, nil, "recording or release ID not found"},
+		{"invalid MBID", "https://musicbrainz.org/recording/123", nil, "invalid MusicBrainz link"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids, err := source.ExtractSourceIDs(context.Background(), tt.link)
+			if tt.err != "" {
+				assert.ErrorContains(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.ids, ids)
+		})
+	}
+
+	source.maxTracks = 2
+	ids, err := source.ExtractSourceIDs(context.Background(), "https://musicbrainz.org/release/"+releaseID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"r1", "r2"}, ids, "releases are cut at the limit")
+}
+
+func TestMusicBrainzSongMetadataSource_RateLimit(t *testing.T) {
+	var mu sync.Mutex
+	var requests []time.Time
+	source := stubSource(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests = append(requests, time.Now())
+		fmt.Fprint(w, `{"title":"Song","artist-credit":[{"name":"Band"}]}`)
+	})
+	source.minInterval = 50 * time.Millisecond
+
+	for range 3 {
+		_, err := source.GetSongMetadata(context.Background(), recordingID)
+		require.NoError(t, err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, requests, 3)
+	assert.GreaterOrEqual(t, requests[2].Sub(requests[0]), 100*time.Millisecond)
+}
@@ -0,0 +1,43 @@
+package queue
+
+import (
+	"errors"
+
+	"github.com/hibiken/asynq"
+)
+
+// ErrTaskNotFound is returned when the task does not exist or its retention has expired
+var ErrTaskNotFound = errors.New("task not found")
+
+type Inspector interface {
+	GetTaskInfo(queue string, id string) (*asynq.TaskInfo, error)
+	Close() error
+}
+
+type inspector struct {
+	inspector *asynq.Inspector
+}
+
+func NewInspector(cfg *Config) Inspector {
+	i := asynq.NewInspector(asynq.RedisClientOpt{
+		Addr:     cfg.Addr,
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	})
+
+	return &inspector{
+		inspector: i,
+	}
+}
+
+func (i *inspector) GetTaskInfo(queue string, id string) (*asynq.TaskInfo, error) {
+	info, err := i.inspector.GetTaskInfo(queue, id)
+	if errors.Is(err, asynq.ErrTaskNotFound) || errors.Is(err, asynq.ErrQueueNotFound) {
+		return nil, ErrTaskNotFound
+	}
+	return info, err
+}
+
+func (i *inspector) Close() error {
+	return i.inspector.Close()
+}
@@ -7,5 +7,7 @@ var Module = fx.Module("queue",
 		NewConfig,
 		NewQueueService,
 		NewWorkerServer,
+		NewScheduler,
+		NewInspector,
 	),
 )
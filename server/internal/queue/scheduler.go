@@ -0,0 +1,98 @@
+package queue
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/robfig/cron/v3"
+)
+
+type Scheduler interface {
+	Register(cronspec string, taskType string, payload []byte, opts ...asynq.Option) (string, error)
+	// RegisterOnce enqueues the task once per tick however many instances register it
+	RegisterOnce(cronspec string, taskType string, payload []byte, opts ...asynq.Option) (string, error)
+	Start() error
+	Stop()
+}
+
+type scheduler struct {
+	cron   *cron.Cron
+	client *asynq.Client
+}
+
+func NewScheduler(cfg *Config) Scheduler {
+	client := asynq.NewClient(asynq.RedisClientOpt{
+		Addr:     cfg.Addr,
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	})
+
+	return &scheduler{
+		cron:   cron.New(cron.WithLocation(time.UTC)),
+		client: client,
+	}
+}
+
+func (s *scheduler) Register(cronspec string, taskType string, payload []byte, opts ...asynq.Option) (string, error) {
+	return s.register(cronspec, taskType, payload, opts, false)
+}
+
+// RegisterOnce names each enqueued task after its tick, so the instances that enqueue the same
+// tick conflict on the task ID. Completed tasks are kept until the next tick to hold their ID.
+func (s *scheduler) RegisterOnce(cronspec string, taskType string, payload []byte, opts ...asynq.Option) (string, error) {
+	return s.register(cronspec, taskType, payload, opts, true)
+}
+
+func (s *scheduler) register(cronspec string, taskType string, payload []byte, opts []asynq.Option, once bool) (string, error) {
+	schedule, err := cron.ParseStandard(cronspec)
+	if err != nil {
+		fmt.Printf("[Queue] Failed to schedule task %s: %v\n", taskType, err)
+		return "", err
+	}
+
+	entryID := s.cron.Schedule(schedule, cron.FuncJob(func() {
+		taskOpts := opts
+		if once {
+			tick, period := scheduledTick(schedule, time.Now().In(time.UTC))
+			taskOpts = append(append([]asynq.Option(nil), opts...),
+				asynq.TaskID(fmt.Sprintf("%s:%d", taskType, tick.Unix())),
+				asynq.Retention(period),
+			)
+		}
+
+		info, err := s.client.Enqueue(asynq.NewTask(taskType, payload), taskOpts...)
+		if errors.Is(err, asynq.ErrTaskIDConflict) {
+			// Another instance enqueued this tick
+			return
+		}
+		if err != nil {
+			fmt.Printf("[Queue] Failed to enqueue scheduled task %s: %v\n", taskType, err)
+			return
+		}
+		fmt.Printf("[Queue] Enqueued scheduled task: %s, ID: %s\n", taskType, info.ID)
+	}))
+	fmt.Printf("[Queue] Scheduled task: %s, Spec: %s, Entry: %d\n", taskType, cronspec, entryID)
+	return fmt.Sprint(entryID), nil
+}
+
+func (s *scheduler) Start() error {
+	s.cron.Start()
+	return nil
+}
+
+func (s *scheduler) Stop() {
+	<-s.cron.Stop().Done()
+	s.client.Close()
+}
+
+// scheduledTick returns the tick of the schedule that fired at now and the time until the
+// next one. Ticks fire late by the scheduling delay and by the clock skew between instances,
+// so now is matched to the closest tick within half a period.
+func scheduledTick(schedule cron.Schedule, now time.Time) (time.Time, time.Duration) {
+	next := schedule.Next(now)
+	period := schedule.Next(next).Sub(next)
+	tick := schedule.Next(now.Add(-period / 2))
+	return tick, schedule.Next(tick).Sub(tick)
+}
@@ -0,0 +1,32 @@
+package queue
+
+import (
+	"testing"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduledTick(t *testing.T) {
+	schedule, err := cron.ParseStandard("*/15 * * * *")
+	require.NoError(t, err)
+	tick := time.Date(2026, 1, 1, 12, 15, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		now  time.Time
+	}{
+		{"on time", tick},
+		{"late", tick.Add(3 * time.Second)},
+		{"within half a period", tick.Add(7 * time.Minute)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, period := scheduledTick(schedule, tt.now)
+			assert.Equal(t, tick, got)
+			assert.Equal(t, 15*time.Minute, period)
+		})
+	}
+}
@@ -0,0 +1,372 @@
+package recognition
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-shazam/internal/fingerprint"
+	"go-shazam/internal/song"
+	"go-shazam/pkg/audio"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// Length range of the calibration queries, in seconds
+	calibrationMinQueryDuration = 3.0
+	calibrationMaxQueryDuration = 15.0
+	// A calibrated threshold never accepts a single chance collision
+	minCalibratedScore = 2
+	// Share of the landmarks of a song a recorded excerpt reproduces. The stored hashes of an
+	// excerpt are thinned out to it and mixed with the hashes of noise, like a recording.
+	calibrationHashSurvival = 0.3
+)
+
+var (
+	ErrCatalogTooSmall     = errors.New("catalog is too small to calibrate")
+	ErrCalibrationRejected = errors.New("calibrated thresholds miss too many songs of the catalog")
+)
+
+// Calibration derives the thresholds from the best scores of queries that must not match:
+// random audio, and simulated recordings of indexed songs scored with the song itself left
+// out, which stand in for music that is not in the catalog. The same recordings scored with
+// their song check that the thresholds still recognise the catalog.
+type Calibration struct {
+	ComputedAt          time.Time    `json:"computed_at"`
+	Songs               int          `json:"songs"`
+	RandomQueries       int          `json:"random_queries"`
+	OutOfCatalogQueries int          `json:"out_of_catalog_queries"`
+	NullScores          ScoreSummary `json:"null_scores"` // best scores of the queries
+	TargetFPR           float64      `json:"target_false_positive_rate"`
+	Thresholds          Thresholds   `json:"thresholds"`
+	Clamped             bool         `json:"clamped"` // the derived thresholds were outside the configured range
+	// False-positive rates on the calibration queries before verification
+	AchievedFPR float64 `json:"achieved_false_positive_rate"`
+	DefaultFPR  float64 `json:"default_false_positive_rate"` // of DefaultThresholds, for comparison
+	// Share of the recordings recognised as their own song with the thresholds
+	Recall float64 `json:"recall"`
+}
+
+type ScoreSummary struct {
+	P50 int `json:"p50"`
+	P90 int `json:"p90"`
+	P99 int `json:"p99"`
+	Max int `json:"max"`
+}
+
+// nullSample is the best score a non-matching query reached
+type nullSample struct {
+	hashes int
+	score  int
+}
+
+// recallSample is the score of a recording for its own song and the best score of the others
+type recallSample struct {
+	hashes int
+	score  int
+	other  int
+}
+
+type CalibrationService struct {
+	config             *Config
+	recognitionService *RecognitionService
+	fingerprintService *fingerprint.FingerprintService
+	songRepository     song.SongRepositoryInterface
+	repository         *CalibrationRepository
+}
+
+func NewCalibrationService(
+	config *Config,
+	recognitionService *RecognitionService,
+	fingerprintService *fingerprint.FingerprintService,
+	songRepository song.SongRepositoryInterface,
+	repository *CalibrationRepository,
+) *CalibrationService {
+	return &CalibrationService{
+		config:             config,
+		recognitionService: recognitionService,
+		fingerprintService: fingerprintService,
+		songRepository:     songRepository,
+		repository:         repository,
+	}
+}
+
+// Latest returns the latest calibration or nil if none has run yet.
+func (s *CalibrationService) Latest(ctx context.Context) (*Calibration, error) {
+	return s.repository.FindLatest(ctx)
+}
+
+// Refresh calibrates and stores the result. Recognition picks the new thresholds up once its
+// cache expires; a rejected calibration is not stored and the previous thresholds stay.
+func (s *CalibrationService) Refresh(ctx context.Context) (*Calibration, error) {
+	calibration, err := s.Compute(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repository.Save(ctx, calibration); err != nil {
+		return nil, fmt.Errorf("failed to save calibration: %w", err)
+	}
+	return calibration, nil
+}
+
+// Compute runs the calibration queries against the live index and derives the thresholds
+// for the target false-positive rate, within the configured range. Half of the queries are
+// random audio, half are recordings of indexed songs. Thresholds that recognise less than
+// CalibrationMinRecall of the recordings are rejected with ErrCalibrationRejected.
+func (s *CalibrationService) Compute(ctx context.Context) (*Calibration, error) {
+	songs, err := s.songRepository.Count(ctx, song.SongFilter{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to count songs: %w", err)
+	}
+	// Leaving a song out needs at least one other song to collide with
+	if songs < 2 {
+		return nil, ErrCatalogTooSmall
+	}
+
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	calibration := &Calibration{Songs: songs, TargetFPR: s.config.CalibrationTargetFPR}
+	var (
+		samples  []nullSample
+		recalled []recallSample
+	)
+
+	for i := 0; i < s.config.CalibrationQueries; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		duration := calibrationMinQueryDuration + rng.Float64()*(calibrationMaxQueryDuration-calibrationMinQueryDuration)
+
+		var (
+			sampleHashes []fingerprint.Hash
+			source       uuid.UUID
+		)
+		if i%2 == 0 {
+			sampleHashes, err = s.randomQuery(rng, duration)
+			calibration.RandomQueries++
+		} else {
+			sampleHashes, source, err = s.recordedQuery(ctx, rng, songs, duration)
+			calibration.OutOfCatalogQueries++
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(sampleHashes) == 0 {
+			continue
+		}
+
+		score, other, err := s.scores(ctx, sampleHashes, source)
+		if err != nil {
+			return nil, err
+		}
+		samples = append(samples, nullSample{hashes: len(sampleHashes), score: other})
+		if source != uuid.Nil {
+			recalled = append(recalled, recallSample{hashes: len(sampleHashes), score: score, other: other})
+		}
+	}
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("no calibration query produced hashes")
+	}
+
+	calibration.ComputedAt = time.Now().UTC()
+	calibration.NullScores = summarizeScores(samples)
+	calibration.Thresholds, calibration.Clamped = clampThresholds(deriveThresholds(samples, s.config.CalibrationTargetFPR), s.config)
+	calibration.AchievedFPR = falsePositiveRate(samples, calibration.Thresholds)
+	calibration.DefaultFPR = falsePositiveRate(samples, DefaultThresholds)
+	calibration.Recall = recall(recalled, calibration.Thresholds)
+	if len(recalled) > 0 && calibration.Recall < s.config.CalibrationMinRecall {
+		return nil, fmt.Errorf("%w: min score %d and min ratio %.4f recognise %.3f of the recordings, expected %.3f",
+			ErrCalibrationRejected, calibration.Thresholds.MinAbsoluteScore, calibration.Thresholds.MinScoreRatio, calibration.Recall, s.config.CalibrationMinRecall)
+	}
+	return calibration, nil
+}
+
+// randomQuery fingerprints synthetic audio: white noise or a sequence of random tones
+func (s *CalibrationService) randomQuery(rng *rand.Rand, duration float64) ([]fingerprint.Hash, error) {
+	samples := randomAudio(rng, duration)
+	fragments, err := audio.ProcessAudio(samples, audio.TargetSampleRate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to process random audio: %w", err)
+	}
+	return s.fingerprintService.CreateFingerprints(fragments, uuid.Nil, audio.TargetSampleRate), nil
+}
+
+// recordedQuery simulates a recording of a random excerpt of a random song: a recording only
+// reproduces part of the landmarks of the song, and noise adds landmarks of its own. The
+// song is returned to be scored apart.
+func (s *CalibrationService) recordedQuery(ctx context.Context, rng *rand.Rand, songs int, duration float64) ([]fingerprint.Hash, uuid.UUID, error) {
+	list, err := s.songRepository.List(ctx, song.SongFilter{Limit: 1, Offset: rng.Intn(songs)})
+	if err != nil {
+		return nil, uuid.Nil, fmt.Errorf("failed to pick a song: %w", err)
+	}
+	if len(list) == 0 {
+		return nil, uuid.Nil, nil
+	}
+	picked := list[0]
+
+	start := 0.0
+	if length := float64(picked.Duration)/1000 - duration; length > 0 {
+		start = rng.Float64() * length
+	}
+
+	hashes, err := s.fingerprintService.GetSongHashes(ctx, picked.ID, start, start+duration)
+	if err != nil {
+		return nil, uuid.Nil, fmt.Errorf("failed to get hashes of song %s: %w", picked.ID, err)
+	}
+
+	// Rebase on the excerpt like a recorded query
+	recorded := hashes[:0]
+	for _, h := range hashes {
+		if rng.Float64() >= calibrationHashSurvival {
+			continue
+		}
+		h.SongID = uuid.Nil
+		h.TimeOffset -= start
+		recorded = append(recorded, h)
+	}
+
+	noise, err := s.randomQuery(rng, duration)
+	if err != nil {
+		return nil, uuid.Nil, err
+	}
+	return append(recorded, noise...), picked.ID, nil
+}
+
+// scores returns the score of the song the query was taken from and the score of the best
+// other candidate
+func (s *CalibrationService) scores(ctx context.Context, sampleHashes []fingerprint.Hash, source uuid.UUID) (int, int, error) {
+	dbHashes, err := s.fingerprintService.GetMatchingHashes(ctx, sampleHashes)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get matching hashes: %w", err)
+	}
+
+	score, other := 0, 0
+	for _, c := range scoreCandidates(sampleHashes, dbHashes) {
+		if c.songID == source {
+			score = c.score
+		} else {
+			other = max(other, c.score)
+		}
+	}
+	return score, other, nil
+}
+
+// deriveThresholds picks thresholds that at most targetFPR of the samples reach: the
+// absolute score just above the (1 - targetFPR) quantile of the best scores, and the same
+// quantile of the best scores relative to the query size, which raises the threshold for
+// long queries whose chance collisions pile up higher.
+func deriveThresholds(samples []nullSample, targetFPR float64) Thresholds {
+	scores := make([]float64, len(samples))
+	ratios := make([]float64, len(samples))
+	for i, sample := range samples {
+		scores[i] = float64(sample.score)
+		ratios[i] = float64(sample.score) / float64(max(sample.hashes, 1))
+	}
+	sort.Float64s(scores)
+	sort.Float64s(ratios)
+
+	q := 1 - targetFPR
+	return Thresholds{
+		MinAbsoluteScore: max(minCalibratedScore, int(quantile(scores, q))+1),
+		MinScoreRatio:    quantile(ratios, q),
+	}
+}
+
+// clampThresholds keeps derived thresholds within the configured range, 0 leaves a bound open
+func clampThresholds(t Thresholds, config *Config) (Thresholds, bool) {
+	clamped := t
+	clamped.MinAbsoluteScore = max(clamped.MinAbsoluteScore, config.CalibrationMinScore)
+	if config.CalibrationMaxScore > 0 {
+		clamped.MinAbsoluteScore = min(clamped.MinAbsoluteScore, config.CalibrationMaxScore)
+	}
+	clamped.MinScoreRatio = math.Max(clamped.MinScoreRatio, config.CalibrationMinRatio)
+	if config.CalibrationMaxRatio > 0 {
+		clamped.MinScoreRatio = math.Min(clamped.MinScoreRatio, config.CalibrationMaxRatio)
+	}
+	return clamped, clamped != t
+}
+
+// recall is the share of recordings whose own song reaches the thresholds and beats the others
+func recall(samples []recallSample, thresholds Thresholds) float64 {
+	if len(samples) == 0 {
+		return 0
+	}
+	recognised := 0
+	for _, sample := range samples {
+		if sample.score >= thresholds.matchThreshold(sample.hashes) && sample.score > sample.other {
+			recognised++
+		}
+	}
+	return float64(recognised) / float64(len(samples))
+}
+
+// falsePositiveRate is the share of samples whose best score reaches the thresholds
+func falsePositiveRate(samples []nullSample, thresholds Thresholds) float64 {
+	if len(samples) == 0 {
+		return 0
+	}
+	passed := 0
+	for _, sample := range samples {
+		if sample.score >= thresholds.matchThreshold(sample.hashes) {
+			passed++
+		}
+	}
+	return float64(passed) / float64(len(samples))
+}
+
+func summarizeScores(samples []nullSample) ScoreSummary {
+	scores := make([]float64, len(samples))
+	for i, sample := range samples {
+		scores[i] = float64(sample.score)
+	}
+	sort.Float64s(scores)
+
+	return ScoreSummary{
+		P50: int(quantile(scores, 0.50)),
+		P90: int(quantile(scores, 0.90)),
+		P99: int(quantile(scores, 0.99)),
+		Max: int(scores[len(scores)-1]),
+	}
+}
+
+// quantile returns the nearest-rank quantile of sorted values
+func quantile(sorted []float64, q float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(q*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
+
+// randomAudio returns white noise or a sequence of random tones with harmonics
+func randomAudio(rng *rand.Rand, duration float64) []float64 {
+	samples := make([]float64, int(duration*audio.TargetSampleRate))
+	if rng.Intn(2) == 0 {
+		for i := range samples {
+			samples[i] = 0.3 * rng.NormFloat64()
+		}
+		return samples
+	}
+
+	noteLength := audio.TargetSampleRate / (2 + rng.Intn(6))
+	freq := 0.0
+	for i := range samples {
+		if i%noteLength == 0 {
+			freq = 80 + rng.Float64()*3000
+		}
+		t := float64(i) / audio.TargetSampleRate
+		samples[i] = 0.5*math.Sin(2*math.Pi*freq*t) + 0.2*math.Sin(2*math.Pi*2*freq*t) + 0.02*rng.NormFloat64()
+	}
+	return samples
+}
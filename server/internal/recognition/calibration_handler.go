@@ -0,0 +1,71 @@
+package recognition
+
+import (
+	"go-shazam/internal/auth"
+	"go-shazam/internal/logger"
+	"go-shazam/internal/queue"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CalibrationHandler struct {
+	calibrationService *CalibrationService
+	recognitionService *RecognitionService
+	queue              queue.QueueService
+}
+
+func NewCalibrationHandler(
+	calibrationService *CalibrationService,
+	recognitionService *RecognitionService,
+	queue queue.QueueService,
+) *CalibrationHandler {
+	return &CalibrationHandler{
+		calibrationService: calibrationService,
+		recognitionService: recognitionService,
+		queue:              queue,
+	}
+}
+
+func RegisterCalibrationRoutes(r *gin.Engine, h *CalibrationHandler, jwtService *auth.JWTService, authConfig *auth.Config) {
+	admin := r.Group("/api/recognize/calibration", auth.AuthMiddleware(jwtService), auth.AdminMiddleware(authConfig))
+	admin.GET("", h.Get)
+	admin.POST("", h.Calibrate)
+}
+
+// CalibrationResponse shows the thresholds in effect and the calibration they come from,
+// which is nil while the defaults apply.
+type CalibrationResponse struct {
+	Thresholds  Thresholds   `json:"thresholds"`
+	Calibration *Calibration `json:"calibration"`
+}
+
+func (h *CalibrationHandler) Get(c *gin.Context) {
+	log := logger.FromContext(c.Request.Context())
+
+	calibration, err := h.calibrationService.Latest(c.Request.Context())
+	if err != nil {
+		log.Error("failed to get calibration", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get calibration"})
+		return
+	}
+
+	c.JSON(http.StatusOK, CalibrationResponse{
+		Thresholds:  h.recognitionService.Thresholds(c.Request.Context()),
+		Calibration: calibration,
+	})
+}
+
+// Calibrate starts a calibration on the worker outside of its schedule.
+func (h *CalibrationHandler) Calibrate(c *gin.Context) {
+	log := logger.FromContext(c.Request.Context())
+
+	info, err := h.queue.Enqueue(CalibrateTaskType, nil)
+	if err != nil {
+		log.Error("failed to enqueue calibration", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start calibration"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"task_id": info.ID})
+}
@@ -0,0 +1,174 @@
+package recognition
+
+import (
+	"context"
+	"go-shazam/internal/fingerprint"
+	"go-shazam/internal/song"
+	"math/rand"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// catalogSongRepository lists songs from memory for the calibration queries
+type catalogSongRepository struct {
+	song.SongRepositoryInterface
+	songs []song.SongEntity
+}
+
+func (r *catalogSongRepository) Count(ctx context.Context, filter song.SongFilter) (int, error) {
+	return len(r.songs), nil
+}
+
+func (r *catalogSongRepository) List(ctx context.Context, filter song.SongFilter) ([]song.SongEntity, error) {
+	if filter.Offset >= len(r.songs) {
+		return nil, nil
+	}
+	end := min(filter.Offset+filter.Limit, len(r.songs))
+	return r.songs[filter.Offset:end], nil
+}
+
+func newTestCalibrationService(t *testing.T, rng *rand.Rand, songs int) *CalibrationService {
+	return newTestCalibrationServiceWithConfig(t, rng, songs, &Config{CalibrationQueries: 10, CalibrationTargetFPR: 0.1})
+}
+
+func newTestCalibrationServiceWithConfig(t *testing.T, rng *rand.Rand, songs int, config *Config) *CalibrationService {
+	t.Helper()
+
+	store := fingerprint.NewMemoryStore()
+	repository := &catalogSongRepository{}
+	for s := 0; s < songs; s++ {
+		songID := uuid.New()
+		repository.songs = append(repository.songs, song.SongEntity{ID: songID, Duration: 60000})
+
+		hashes := make([]fingerprint.Hash, 3000)
+		for i := range hashes {
+			hashes[i] = fingerprint.Hash{HashValue: rng.Int63n(500), SongID: songID, TimeOffset: rng.Float64() * 60}
+		}
+		require.NoError(t, store.SaveFingerprints(context.Background(), hashes))
+	}
+
+	fingerprintService := fingerprint.NewFingerprintServiceWithStore(store)
+	return NewCalibrationService(config, nil, fingerprintService, repository, nil)
+}
+
+func TestCalibrationCompute(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	service := newTestCalibrationService(t, rng, 3)
+
+	calibration, err := service.Compute(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, calibration.Songs)
+	assert.Equal(t, 5, calibration.RandomQueries)
+	assert.Equal(t, 5, calibration.OutOfCatalogQueries)
+	assert.GreaterOrEqual(t, calibration.Thresholds.MinAbsoluteScore, minCalibratedScore)
+	assert.LessOrEqual(t, calibration.AchievedFPR, calibration.TargetFPR)
+	// Excerpts of the other songs collide a lot in the small hash space
+	assert.Greater(t, calibration.NullScores.Max, 0)
+	// The recordings still score highest for their own song
+	assert.Greater(t, calibration.Recall, 0.5)
+	assert.False(t, calibration.ComputedAt.IsZero())
+}
+
+func TestCalibrationCompute_RejectsThresholdsThatMissTheCatalog(t *testing.T) {
+	config := &Config{CalibrationQueries: 10, CalibrationTargetFPR: 0.1, CalibrationMinRecall: 0.5, CalibrationMinScore: 100000}
+	service := newTestCalibrationServiceWithConfig(t, rand.New(rand.NewSource(3)), 3, config)
+
+	_, err := service.Compute(context.Background())
+	assert.ErrorIs(t, err, ErrCalibrationRejected)
+}
+
+func TestCalibrationCompute_CatalogTooSmall(t *testing.T) {
+	service := newTestCalibrationService(t, rand.New(rand.NewSource(1)), 1)
+
+	_, err := service.Compute(context.Background())
+	assert.ErrorIs(t, err, ErrCatalogTooSmall)
+}
+
+func TestDeriveThresholds(t *testing.T) {
+	var samples []nullSample
+	for i := 1; i <= 100; i++ {
+		samples = append(samples, nullSample{hashes: 100, score: i})
+	}
+
+	thresholds := deriveThresholds(samples, 0.05)
+	assert.Equal(t, 96, thresholds.MinAbsoluteScore)
+	assert.InDelta(t, 0.95, thresholds.MinScoreRatio, 1e-9)
+	assert.LessOrEqual(t, falsePositiveRate(samples, thresholds), 0.05)
+}
+
+func TestDeriveThresholds_NeverAcceptsSingleCollision(t *testing.T) {
+	samples := []nullSample{{hashes: 500, score: 0}, {hashes: 500, score: 1}}
+
+	thresholds := deriveThresholds(samples, 0.01)
+	assert.Equal(t, minCalibratedScore, thresholds.MinAbsoluteScore)
+}
+
+func TestClampThresholds(t *testing.T) {
+	config := &Config{CalibrationMinScore: 3, CalibrationMaxScore: 20, CalibrationMinRatio: 0.005, CalibrationMaxRatio: 0.05}
+
+	thresholds, clamped := clampThresholds(Thresholds{MinAbsoluteScore: 8, MinScoreRatio: 0.01}, config)
+	assert.False(t, clamped)
+	assert.Equal(t, Thresholds{MinAbsoluteScore: 8, MinScoreRatio: 0.01}, thresholds)
+
+	thresholds, clamped = clampThresholds(Thresholds{MinAbsoluteScore: 96, MinScoreRatio: 0.001}, config)
+	assert.True(t, clamped)
+	assert.Equal(t, Thresholds{MinAbsoluteScore: 20, MinScoreRatio: 0.005}, thresholds)
+
+	// 0 leaves the upper bounds open
+	thresholds, _ = clampThresholds(Thresholds{MinAbsoluteScore: 96, MinScoreRatio: 0.9}, &Config{})
+	assert.Equal(t, Thresholds{MinAbsoluteScore: 96, MinScoreRatio: 0.9}, thresholds)
+}
+
+func TestRecall(t *testing.T) {
+	samples := []recallSample{
+		{hashes: 100, score: 20, other: 3},
+		{hashes: 100, score: 4, other: 2},   // below the threshold
+		{hashes: 100, score: 20, other: 25}, // another song scores higher
+		{hashes: 100, score: 10, other: 10},
+	}
+
+	assert.InDelta(t, 0.25, recall(samples, Thresholds{MinAbsoluteScore: 5, MinScoreRatio: 0.01}), 1e-9)
+	assert.Zero(t, recall(nil, DefaultThresholds))
+}
+
+func TestFalsePositiveRate(t *testing.T) {
+	samples := []nullSample{
+		{hashes: 10, score: 3},
+		{hashes: 10, score: 5},
+		{hashes: 1000, score: 5}, // below the ratio threshold of a long query
+		{hashes: 10, score: 9},
+	}
+	thresholds := Thresholds{MinAbsoluteScore: 5, MinScoreRatio: 0.01}
+
+	assert.InDelta(t, 0.5, falsePositiveRate(samples, thresholds), 1e-9)
+	assert.Zero(t, falsePositiveRate(nil, thresholds))
+}
+
+func TestSummarizeScores(t *testing.T) {
+	var samples []nullSample
+	for i := 1; i <= 10; i++ {
+		samples = append(samples, nullSample{hashes: 10, score: i})
+	}
+
+	assert.Equal(t, ScoreSummary{P50: 5, P90: 9, P99: 10, Max: 10}, summarizeScores(samples))
+}
+
+func TestQuantile(t *testing.T) {
+	sorted := []float64{1, 2, 3, 4}
+
+	assert.Equal(t, 1.0, quantile(sorted, 0))
+	assert.Equal(t, 2.0, quantile(sorted, 0.5))
+	assert.Equal(t, 4.0, quantile(sorted, 0.99))
+	assert.Equal(t, 4.0, quantile(sorted, 1))
+	assert.Zero(t, quantile(nil, 0.5))
+}
+
+func TestThresholdCache_DefaultsWithoutRepository(t *testing.T) {
+	var nilCache *thresholdCache
+	assert.Equal(t, DefaultThresholds, nilCache.get(context.Background()))
+	assert.Equal(t, DefaultThresholds, newThresholdCache(nil, 0).get(context.Background()))
+}
@@ -0,0 +1,166 @@
+package recognition
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+type Config struct {
+	MaxCandidates int
+	// Incremental recognition while audio is streaming
+	StreamInterval       time.Duration // audio to accumulate between re-scoring passes
+	StreamMinDuration    time.Duration // audio required before the first pass
+	EarlyMatchConfidence float64       // confidence at which a final match is sent before the client stops
+	// Timeline recognition of long recordings
+	TimelineWindow time.Duration // length of each analysis window
+	TimelineHop    time.Duration // distance between window starts
+	// Following the playback position in sync sessions
+	SyncWindow    time.Duration // latest audio aligned with the song on every update
+	SyncMinScore  int           // aligned hashes for the song to count as still playing
+	SyncMaxMisses int           // updates in a row without the song before the track changed
+	SyncPreload   time.Duration // hashes of the followed song loaded at a time
+	// Recognition of uploaded files
+	UploadDir             string        // shared with the worker, which picks up long uploads
+	UploadMaxBytes        int64         // largest accepted upload
+	UploadSyncMaxDuration time.Duration // longer uploads are recognised by a background job
+	UploadMaxDuration     time.Duration // longest accepted upload
+	JobRetention          time.Duration // how long job results can be polled after completion
+	// Recognition of remote media
+	URLMaxBytes        int64         // largest media downloaded
+	URLMaxDuration     time.Duration // longest audio recognised
+	URLDownloadTimeout time.Duration
+	URLAllowPrivate    bool     // allow URLs on loopback and private networks, for development
+	URLMediaHosts      []string // sites whose pages are downloaded with yt-dlp, with their subdomains
+	URLExtractors      []string // yt-dlp extractors allowed on those pages, never the generic one
+	// Recognition of hashes computed by the client
+	FingerprintMaxHashes int   // most hashes accepted in one submission
+	FingerprintMaxBytes  int64 // largest accepted submission body
+	// Threshold calibration against a null distribution of non-matching queries
+	ThresholdRefresh     time.Duration // how often the thresholds of the latest calibration are reloaded
+	CalibrationCron      string
+	CalibrationQueries   int     // random and out-of-catalog queries per calibration
+	CalibrationTargetFPR float64 // false-positive rate the thresholds are derived for
+	CalibrationMinRecall float64 // share of recordings of catalog songs the thresholds must recognise
+	// Range the calibrated thresholds are clamped to, 0 leaves an upper bound open
+	CalibrationMinScore int
+	CalibrationMaxScore int
+	CalibrationMinRatio float64
+	CalibrationMaxRatio float64
+	// Limits of the recognition WebSocket, 0 disables a limit
+	WSMaxSessions      int           // concurrent sessions per server
+	WSMaxRecognitions  int           // concurrent recognitions per session
+	WSMaxMessageBytes  int64         // largest frame accepted
+	WSMaxAudioBytes    int64         // audio bytes accepted per recognition, per WSMaxAudioDuration in sync sessions
+	WSMaxAudioDuration time.Duration // audio accepted per recognition, the identification window of sync sessions
+	WSMaxSyncDuration  time.Duration // audio accepted per recognition in sync sessions
+	WSReadTimeout      time.Duration // connections that send nothing, not even a pong, are closed
+	WSWriteTimeout     time.Duration // clients that do not read in time are disconnected
+	WSPingInterval     time.Duration // keepalive pings, shorter than WSReadTimeout
+}
+
+func LoadConfig() *Config {
+	viper.SetConfigFile(".env")
+	viper.ReadInConfig()
+	viper.AutomaticEnv()
+
+	viper.SetDefault("RECOGNITION_MAX_CANDIDATES", 5)
+	viper.SetDefault("RECOGNITION_STREAM_INTERVAL_MS", 1000)
+	viper.SetDefault("RECOGNITION_STREAM_MIN_DURATION_MS", 3000)
+	viper.SetDefault("RECOGNITION_EARLY_MATCH_CONFIDENCE", 0.6)
+	viper.SetDefault("RECOGNITION_TIMELINE_WINDOW_MS", 10000)
+	viper.SetDefault("RECOGNITION_TIMELINE_HOP_MS", 5000)
+	viper.SetDefault("RECOGNITION_SYNC_WINDOW_MS", 5000)
+	viper.SetDefault("RECOGNITION_SYNC_MIN_SCORE", 10)
+	viper.SetDefault("RECOGNITION_SYNC_MAX_MISSES", 3)
+	viper.SetDefault("RECOGNITION_SYNC_PRELOAD_MS", 60000)
+	viper.SetDefault("RECOGNITION_UPLOAD_DIR", filepath.Join(os.TempDir(), "go-shazam-uploads"))
+	viper.SetDefault("RECOGNITION_UPLOAD_MAX_BYTES", 50<<20)
+	viper.SetDefault("RECOGNITION_UPLOAD_SYNC_MAX_DURATION_MS", 30000)
+	viper.SetDefault("RECOGNITION_UPLOAD_MAX_DURATION_MS", 20*60*1000)
+	viper.SetDefault("RECOGNITION_JOB_RETENTION_MS", 24*60*60*1000)
+	viper.SetDefault("RECOGNITION_URL_MAX_BYTES", 200<<20)
+	viper.SetDefault("RECOGNITION_URL_MAX_DURATION_MS", 30*60*1000)
+	viper.SetDefault("RECOGNITION_URL_DOWNLOAD_TIMEOUT_MS", 5*60*1000)
+	viper.SetDefault("RECOGNITION_URL_ALLOW_PRIVATE", false)
+	viper.SetDefault("RECOGNITION_URL_MEDIA_HOSTS", "youtube.com,youtu.be,soundcloud.com,vimeo.com,mixcloud.com,bandcamp.com")
+	viper.SetDefault("RECOGNITION_URL_EXTRACTORS", "youtube,soundcloud,vimeo,mixcloud,bandcamp")
+	viper.SetDefault("RECOGNITION_FINGERPRINT_MAX_HASHES", 100000)
+	viper.SetDefault("RECOGNITION_FINGERPRINT_MAX_BYTES", 4<<20)
+	viper.SetDefault("RECOGNITION_THRESHOLD_REFRESH_MS", 60000)
+	viper.SetDefault("RECOGNITION_CALIBRATION_CRON", "@every 24h")
+	viper.SetDefault("RECOGNITION_CALIBRATION_QUERIES", 200)
+	viper.SetDefault("RECOGNITION_CALIBRATION_TARGET_FPR", 0.01)
+	viper.SetDefault("RECOGNITION_CALIBRATION_MIN_RECALL", 0.8)
+	viper.SetDefault("RECOGNITION_CALIBRATION_MIN_SCORE", 3)
+	viper.SetDefault("RECOGNITION_CALIBRATION_MAX_SCORE", 50)
+	viper.SetDefault("RECOGNITION_CALIBRATION_MIN_RATIO", 0.005)
+	viper.SetDefault("RECOGNITION_CALIBRATION_MAX_RATIO", 0.05)
+	viper.SetDefault("RECOGNITION_WS_MAX_SESSIONS", 500)
+	viper.SetDefault("RECOGNITION_WS_MAX_RECOGNITIONS", 4)
+	viper.SetDefault("RECOGNITION_WS_MAX_MESSAGE_BYTES", 1<<20)
+	viper.SetDefault("RECOGNITION_WS_MAX_AUDIO_BYTES", 32<<20)
+	viper.SetDefault("RECOGNITION_WS_MAX_AUDIO_DURATION_MS", 60000)
+	viper.SetDefault("RECOGNITION_WS_MAX_SYNC_DURATION_MS", 4*60*60*1000)
+	viper.SetDefault("RECOGNITION_WS_READ_TIMEOUT_MS", 60000)
+	viper.SetDefault("RECOGNITION_WS_WRITE_TIMEOUT_MS", 10000)
+	viper.SetDefault("RECOGNITION_WS_PING_INTERVAL_MS", 20000)
+
+	return &Config{
+		MaxCandidates:         viper.GetInt("RECOGNITION_MAX_CANDIDATES"),
+		StreamInterval:        time.Duration(viper.GetInt("RECOGNITION_STREAM_INTERVAL_MS")) * time.Millisecond,
+		StreamMinDuration:     time.Duration(viper.GetInt("RECOGNITION_STREAM_MIN_DURATION_MS")) * time.Millisecond,
+		EarlyMatchConfidence:  viper.GetFloat64("RECOGNITION_EARLY_MATCH_CONFIDENCE"),
+		TimelineWindow:        time.Duration(viper.GetInt("RECOGNITION_TIMELINE_WINDOW_MS")) * time.Millisecond,
+		TimelineHop:           time.Duration(viper.GetInt("RECOGNITION_TIMELINE_HOP_MS")) * time.Millisecond,
+		SyncWindow:            time.Duration(viper.GetInt("RECOGNITION_SYNC_WINDOW_MS")) * time.Millisecond,
+		SyncMinScore:          viper.GetInt("RECOGNITION_SYNC_MIN_SCORE"),
+		SyncMaxMisses:         viper.GetInt("RECOGNITION_SYNC_MAX_MISSES"),
+		SyncPreload:           time.Duration(viper.GetInt("RECOGNITION_SYNC_PRELOAD_MS")) * time.Millisecond,
+		UploadDir:             viper.GetString("RECOGNITION_UPLOAD_DIR"),
+		UploadMaxBytes:        viper.GetInt64("RECOGNITION_UPLOAD_MAX_BYTES"),
+		UploadSyncMaxDuration: time.Duration(viper.GetInt("RECOGNITION_UPLOAD_SYNC_MAX_DURATION_MS")) * time.Millisecond,
+		UploadMaxDuration:     time.Duration(viper.GetInt("RECOGNITION_UPLOAD_MAX_DURATION_MS")) * time.Millisecond,
+		JobRetention:          time.Duration(viper.GetInt("RECOGNITION_JOB_RETENTION_MS")) * time.Millisecond,
+		URLMaxBytes:           viper.GetInt64("RECOGNITION_URL_MAX_BYTES"),
+		URLMaxDuration:        time.Duration(viper.GetInt("RECOGNITION_URL_MAX_DURATION_MS")) * time.Millisecond,
+		URLDownloadTimeout:    time.Duration(viper.GetInt("RECOGNITION_URL_DOWNLOAD_TIMEOUT_MS")) * time.Millisecond,
+		URLAllowPrivate:       viper.GetBool("RECOGNITION_URL_ALLOW_PRIVATE"),
+		URLMediaHosts:         parseList(viper.GetString("RECOGNITION_URL_MEDIA_HOSTS")),
+		URLExtractors:         parseList(viper.GetString("RECOGNITION_URL_EXTRACTORS")),
+		FingerprintMaxHashes:  viper.GetInt("RECOGNITION_FINGERPRINT_MAX_HASHES"),
+		FingerprintMaxBytes:   viper.GetInt64("RECOGNITION_FINGERPRINT_MAX_BYTES"),
+		ThresholdRefresh:      time.Duration(viper.GetInt("RECOGNITION_THRESHOLD_REFRESH_MS")) * time.Millisecond,
+		CalibrationCron:       viper.GetString("RECOGNITION_CALIBRATION_CRON"),
+		CalibrationQueries:    viper.GetInt("RECOGNITION_CALIBRATION_QUERIES"),
+		CalibrationTargetFPR:  viper.GetFloat64("RECOGNITION_CALIBRATION_TARGET_FPR"),
+		CalibrationMinRecall:  viper.GetFloat64("RECOGNITION_CALIBRATION_MIN_RECALL"),
+		CalibrationMinScore:   viper.GetInt("RECOGNITION_CALIBRATION_MIN_SCORE"),
+		CalibrationMaxScore:   viper.GetInt("RECOGNITION_CALIBRATION_MAX_SCORE"),
+		CalibrationMinRatio:   viper.GetFloat64("RECOGNITION_CALIBRATION_MIN_RATIO"),
+		CalibrationMaxRatio:   viper.GetFloat64("RECOGNITION_CALIBRATION_MAX_RATIO"),
+		WSMaxSessions:         viper.GetInt("RECOGNITION_WS_MAX_SESSIONS"),
+		WSMaxRecognitions:     viper.GetInt("RECOGNITION_WS_MAX_RECOGNITIONS"),
+		WSMaxMessageBytes:     viper.GetInt64("RECOGNITION_WS_MAX_MESSAGE_BYTES"),
+		WSMaxAudioBytes:       viper.GetInt64("RECOGNITION_WS_MAX_AUDIO_BYTES"),
+		WSMaxAudioDuration:    time.Duration(viper.GetInt("RECOGNITION_WS_MAX_AUDIO_DURATION_MS")) * time.Millisecond,
+		WSMaxSyncDuration:     time.Duration(viper.GetInt("RECOGNITION_WS_MAX_SYNC_DURATION_MS")) * time.Millisecond,
+		WSReadTimeout:         time.Duration(viper.GetInt("RECOGNITION_WS_READ_TIMEOUT_MS")) * time.Millisecond,
+		WSWriteTimeout:        time.Duration(viper.GetInt("RECOGNITION_WS_WRITE_TIMEOUT_MS")) * time.Millisecond,
+		WSPingInterval:        time.Duration(viper.GetInt("RECOGNITION_WS_PING_INTERVAL_MS")) * time.Millisecond,
+	}
+}
+
+// parseList parses a comma-separated list, lowercased and without empty entries
+func parseList(value string) []string {
+	var items []string
+	for _, part := range strings.Split(value, ",") {
+		if item := strings.ToLower(strings.TrimSpace(part)); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
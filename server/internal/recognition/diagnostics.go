@@ -0,0 +1,161 @@
+package recognition
+
+import (
+	"fmt"
+	"go-shazam/pkg/audio"
+	"math"
+	"sort"
+)
+
+// Levels below which a query gets a hint
+const (
+	QuietLevel        = -45.0 // dBFS RMS
+	MaxClippingRatio  = 0.01  // share of samples at full scale
+	ClippingLevel     = 0.99  // absolute sample value counted as clipped
+	NoisySNR          = 6.0   // dB
+	ShortDuration     = 3.0   // seconds
+	MinPeaksPerSecond = 10.0  // of at most ~44 (4 bands at ~11 frames per second)
+)
+
+// Reported levels are clamped to this range so that silence stays representable in JSON
+const (
+	silenceLevel = -100.0 // dBFS
+	minSNR       = -20.0  // dB
+	maxSNR       = 60.0   // dB
+)
+
+// Frequency range of the fingerprints, where the SNR is estimated
+const (
+	snrMinFrequency = 80.0
+	snrMaxFrequency = 5600.0
+)
+
+// Hint codes
+const (
+	HintTooQuiet    = "too_quiet"
+	HintClipping    = "clipping"
+	HintNoisy       = "noisy"
+	HintTooShort    = "too_short"
+	HintFewFeatures = "few_features"
+)
+
+// Diagnostics describe the quality of the query audio, so that clients can tell the user
+// how to record a better query when nothing was found.
+type Diagnostics struct {
+	Duration       float64 `json:"duration"`         // seconds
+	RMSLevel       float64 `json:"rms_level"`        // dBFS
+	ClippingRatio  float64 `json:"clipping_ratio"`   // share of samples at full scale
+	SNR            float64 `json:"snr"`              // estimated signal-to-noise ratio in dB
+	PeaksPerSecond float64 `json:"peaks_per_second"` // spectrogram peaks kept for hashing
+	Hashes         int     `json:"hashes"`
+	Hints          []Hint  `json:"hints"`
+}
+
+type Hint struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// queryQuality accumulates the statistics of a query as its audio arrives
+type queryQuality struct {
+	samples    int
+	sumSquares float64
+	clipped    int
+
+	signalPower float64
+	noisePower  float64
+
+	peaks int
+}
+
+// addSamples takes samples at audio.TargetSampleRate
+func (q *queryQuality) addSamples(samples []float64) {
+	q.samples += len(samples)
+	for _, s := range samples {
+		q.sumSquares += s * s
+		if math.Abs(s) >= ClippingLevel {
+			q.clipped++
+		}
+	}
+}
+
+// addFragments estimates the noise of every fragment from its spectral floor. Within the
+// fingerprinted range the median bin power, corrected for the bias of the median of
+// exponentially distributed noise power, is taken as the noise power of every bin; what the
+// bins hold beyond that is signal. Music concentrates in a few bins and leaves the median
+// low, broadband noise raises it.
+func (q *queryQuality) addFragments(fragments []audio.ProcessedFragment) {
+	binSize := float64(audio.TargetSampleRate) / float64(audio.WindowSize)
+	low := int(math.Ceil(snrMinFrequency / binSize))
+	high := min(int(snrMaxFrequency/binSize), audio.WindowSize/2)
+
+	powers := make([]float64, 0, high-low)
+	for _, fragment := range fragments {
+		if len(fragment.Magnitudes) < high {
+			continue
+		}
+		powers = powers[:0]
+		total := 0.0
+		for _, m := range fragment.Magnitudes[low:high] {
+			powers = append(powers, m*m)
+			total += m * m
+		}
+		sort.Float64s(powers)
+
+		noise := math.Min(total, powers[len(powers)/2]/math.Ln2*float64(len(powers)))
+		q.noisePower += noise
+		q.signalPower += total - noise
+	}
+}
+
+func (q *queryQuality) addPeaks(n int) {
+	q.peaks += n
+}
+
+func (q *queryQuality) diagnostics(hashes int) Diagnostics {
+	d := Diagnostics{
+		Duration: float64(q.samples) / audio.TargetSampleRate,
+		RMSLevel: silenceLevel,
+		SNR:      minSNR,
+		Hashes:   hashes,
+	}
+	if q.samples > 0 {
+		d.ClippingRatio = float64(q.clipped) / float64(q.samples)
+		if rms := math.Sqrt(q.sumSquares / float64(q.samples)); rms > 0 {
+			d.RMSLevel = math.Max(silenceLevel, 20*math.Log10(rms))
+		}
+	}
+	switch {
+	case q.noisePower > 0 && q.signalPower > 0:
+		d.SNR = math.Max(minSNR, math.Min(maxSNR, 10*math.Log10(q.signalPower/q.noisePower)))
+	case q.signalPower > 0:
+		d.SNR = maxSNR
+	}
+	if d.Duration > 0 {
+		d.PeaksPerSecond = float64(q.peaks) / d.Duration
+	}
+
+	d.Hints = hints(d)
+	return d
+}
+
+// hints turns the diagnostics into advice, most important first
+func hints(d Diagnostics) []Hint {
+	hints := []Hint{}
+	if d.RMSLevel < QuietLevel {
+		hints = append(hints, Hint{HintTooQuiet, "The recording is very quiet. Move closer to the music or turn it up."})
+	}
+	if d.ClippingRatio > MaxClippingRatio {
+		hints = append(hints, Hint{HintClipping, "The recording is distorted. Move away from the speaker or lower the input volume."})
+	}
+	if d.RMSLevel >= QuietLevel && d.SNR < NoisySNR {
+		hints = append(hints, Hint{HintNoisy, "The music is drowned in background noise. Move closer to the music or away from the noise."})
+	}
+	if d.Duration < ShortDuration {
+		hints = append(hints, Hint{HintTooShort, fmt.Sprintf("The recording is short. Record at least %g seconds.", ShortDuration)})
+	}
+	if d.Duration >= ShortDuration && d.PeaksPerSecond < MinPeaksPerSecond {
+		hints = append(hints, Hint{HintFewFeatures, "Few distinctive sounds were heard. Try a part of the song with more going on."})
+	}
+	return hints
+}
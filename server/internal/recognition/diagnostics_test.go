@@ -0,0 +1,134 @@
+package recognition
+
+import (
+	"context"
+	"go-shazam/internal/fingerprint"
+	"go-shazam/internal/song"
+	"go-shazam/pkg/audio"
+	"go-shazam/pkg/landmark"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func queryDiagnostics(t *testing.T, samples []float64) Diagnostics {
+	t.Helper()
+	fragments, err := audio.ProcessAudio(samples, audio.TargetSampleRate)
+	require.NoError(t, err)
+
+	var quality queryQuality
+	quality.addSamples(samples)
+	quality.addFragments(fragments)
+	peaks := landmark.ExtractPeaks(fragments, audio.TargetSampleRate)
+	quality.addPeaks(len(peaks))
+	return quality.diagnostics(len(landmark.CreateHashes(peaks, uuid.Nil)))
+}
+
+func whiteNoise(seconds float64, level float64) []float64 {
+	rng := rand.New(rand.NewSource(1))
+	samples := make([]float64, int(seconds*audio.TargetSampleRate))
+	for i := range samples {
+		samples[i] = level * rng.NormFloat64()
+	}
+	return samples
+}
+
+func hintCodes(d Diagnostics) []string {
+	codes := []string{}
+	for _, hint := range d.Hints {
+		codes = append(codes, hint.Code)
+	}
+	return codes
+}
+
+func scaled(samples []float64, gain float64) []float64 {
+	out := make([]float64, len(samples))
+	for i, s := range samples {
+		out[i] = math.Max(-1, math.Min(1, s*gain))
+	}
+	return out
+}
+
+func TestDiagnostics_CleanMusic(t *testing.T) {
+	d := queryDiagnostics(t, toneTrack(5, 1))
+
+	assert.InDelta(t, 5, d.Duration, 0.01)
+	assert.InDelta(t, -8, d.RMSLevel, 1)
+	assert.Zero(t, d.ClippingRatio)
+	assert.Greater(t, d.SNR, 15.0)
+	assert.Greater(t, d.PeaksPerSecond, MinPeaksPerSecond)
+	assert.Positive(t, d.Hashes)
+	assert.Empty(t, d.Hints)
+}
+
+func TestDiagnostics_Noise(t *testing.T) {
+	d := queryDiagnostics(t, whiteNoise(5, 0.2))
+	assert.Less(t, d.SNR, 0.0)
+	assert.Contains(t, hintCodes(d), HintNoisy)
+
+	// Music under noise of about a quarter of its power
+	music := toneTrack(5, 1)
+	noise := whiteNoise(5, 0.2)
+	for i := range music {
+		music[i] += noise[i]
+	}
+	d = queryDiagnostics(t, scaled(music, 0.5))
+	assert.InDelta(t, 6, d.SNR, 2)
+}
+
+func TestDiagnostics_Clipping(t *testing.T) {
+	d := queryDiagnostics(t, scaled(toneTrack(5, 1), 4))
+
+	assert.Greater(t, d.ClippingRatio, MaxClippingRatio)
+	assert.Equal(t, []string{HintClipping}, hintCodes(d))
+}
+
+func TestDiagnostics_TooQuietAndShort(t *testing.T) {
+	d := queryDiagnostics(t, scaled(toneTrack(2, 1), 0.001))
+
+	assert.InDelta(t, -68, d.RMSLevel, 1)
+	assert.Equal(t, []string{HintTooQuiet, HintTooShort}, hintCodes(d))
+	assert.Equal(t, "The recording is short. Record at least 3 seconds.", d.Hints[1].Message)
+}
+
+func TestIdentifySamples_SilenceIsNotFound(t *testing.T) {
+	service := NewRecognitionService(&Config{}, fingerprint.NewFingerprintServiceWithStore(fingerprint.NewMemoryStore()), &stubSongRepository{}, nil)
+
+	response, err := service.IdentifySamples(context.Background(), make([]float64, 5*audio.TargetSampleRate))
+	require.NoError(t, err)
+
+	assert.False(t, response.Found)
+	require.NotNil(t, response.Diagnostics)
+	assert.Equal(t, silenceLevel, response.Diagnostics.RMSLevel)
+	assert.Zero(t, response.Diagnostics.Hashes)
+	assert.Equal(t, []string{HintTooQuiet, HintFewFeatures}, hintCodes(*response.Diagnostics))
+}
+
+func TestStreamRecognizer_ReportsDiagnostics(t *testing.T) {
+	store := fingerprint.NewMemoryStore()
+	fingerprintService := fingerprint.NewFingerprintServiceWithStore(store)
+	track := &song.SongEntity{ID: uuid.New(), Title: "Track"}
+	samples := toneTrack(10, 1)
+	fragments, err := audio.ProcessAudio(samples, audio.TargetSampleRate)
+	require.NoError(t, err)
+	require.NoError(t, store.SaveFingerprints(context.Background(), fingerprintService.CreateFingerprints(fragments, track.ID, audio.TargetSampleRate)))
+
+	service := NewRecognitionService(&Config{MaxCandidates: 5}, fingerprintService, &stubSongRepository{songs: map[uuid.UUID]*song.SongEntity{track.ID: track}}, nil)
+	stream := service.NewStream(audio.TargetSampleRate)
+	stream.Append(samples[2*audio.TargetSampleRate : 4*audio.TargetSampleRate])
+	stream.Append(samples[4*audio.TargetSampleRate : 7*audio.TargetSampleRate])
+
+	matches, err := stream.Recognize(context.Background())
+	require.NoError(t, err)
+	response := stream.Response(matches)
+
+	assert.True(t, response.Found)
+	require.NotNil(t, response.Diagnostics)
+	assert.InDelta(t, 5, response.Diagnostics.Duration, 0.01)
+	assert.Positive(t, response.Diagnostics.Hashes)
+	assert.Empty(t, response.Diagnostics.Hints)
+}
@@ -0,0 +1,45 @@
+package recognition
+
+import "go-shazam/internal/song"
+
+// RecognitionResponse is returned by the recognition endpoints. The top-level match fields
+// mirror the best candidate for clients that only show a single result. Diagnostics are set
+// when the query was recognised from audio.
+type RecognitionResponse struct {
+	Found       bool             `json:"found"`
+	Song        *song.SongEntity `json:"song,omitempty"`
+	TimeOffset  float64          `json:"time_offset,omitempty"`
+	Position    float64          `json:"position,omitempty"`
+	Score       int              `json:"score,omitempty"`
+	Confidence  float64          `json:"confidence,omitempty"`
+	Candidates  []MatchResult    `json:"candidates"`
+	Diagnostics *Diagnostics     `json:"diagnostics,omitempty"`
+}
+
+func NewRecognitionResponse(matches []MatchResult) RecognitionResponse {
+	if len(matches) == 0 {
+		return RecognitionResponse{Found: false, Candidates: []MatchResult{}}
+	}
+
+	best := matches[0]
+	return RecognitionResponse{
+		Found:      true,
+		Song:       best.Song,
+		TimeOffset: best.TimeOffset,
+		Position:   best.Position,
+		Score:      best.Score,
+		Confidence: best.Confidence,
+		Candidates: matches,
+	}
+}
+
+// RecognitionJobResponse reports the state of a background recognition job. Result, or
+// Timeline for URL recognitions, is set once the job has completed, Error once it has
+// failed for good.
+type RecognitionJobResponse struct {
+	ID       string               `json:"id"`
+	Status   string               `json:"status"`
+	Result   *RecognitionResponse `json:"result,omitempty"`
+	Timeline *Timeline            `json:"timeline,omitempty"`
+	Error    string               `json:"error,omitempty"`
+}
@@ -0,0 +1,186 @@
+package recognition
+
+import (
+	"context"
+	"fmt"
+	"go-shazam/internal/fingerprint"
+	"go-shazam/internal/song"
+	"go-shazam/pkg/audio"
+	"math"
+	"sort"
+
+	"github.com/google/uuid"
+)
+
+const (
+	ExplainMaxCandidates = 10 // candidates explained, best first
+	ExplainTopBins       = 5  // offset histogram bins reported per candidate
+)
+
+// Explanation shows how a query was scored, for admins investigating a missed or wrong match.
+// It mirrors the decisions of rankMatches.
+type Explanation struct {
+	SampleHashes    int                    `json:"sample_hashes"`
+	DatabaseHashes  int                    `json:"database_hashes"` // database hashes sharing a value with the sample
+	TotalCandidates int                    `json:"total_candidates"`
+	Thresholds      Thresholds             `json:"thresholds"`
+	Threshold       int                    `json:"threshold"` // score required for this sample
+	Candidates      []CandidateExplanation `json:"candidates"`
+}
+
+type CandidateExplanation struct {
+	SongID         uuid.UUID        `json:"song_id"`
+	Song           *song.SongEntity `json:"song,omitempty"`
+	Score          int              `json:"score"`
+	Offset         float64          `json:"offset"`          // track-minus-query offset of the best bin, in seconds
+	MatchedPairs   int              `json:"matched_pairs"`   // matched (sample, database) hash pairs across all bins
+	DistinctHashes int              `json:"distinct_hashes"` // distinct hash values matched
+	TopBins        []OffsetBinCount `json:"top_bins"`
+	// Nil for candidates that were not verified
+	Verification *VerificationExplanation `json:"verification,omitempty"`
+	Accepted     bool                     `json:"accepted"`
+	Reason       string                   `json:"reason"`
+}
+
+type OffsetBinCount struct {
+	Offset float64 `json:"offset"` // seconds
+	Count  int     `json:"count"`
+}
+
+type VerificationExplanation struct {
+	Slope     float64 `json:"slope"`
+	Intercept float64 `json:"intercept"`
+	Pairs     int     `json:"pairs"`
+	Inliers   int     `json:"inliers"`
+	Spread    float64 `json:"spread"`
+}
+
+// ExplainResponse is the recognition result together with its explanation
+type ExplainResponse struct {
+	RecognitionResponse
+	Explanation *Explanation `json:"explanation"`
+}
+
+// ExplainSong recognises the fragments like IdentifySong and explains the decision.
+func (s *RecognitionService) ExplainSong(ctx context.Context, fragments []audio.ProcessedFragment, sampleRate int) (*ExplainResponse, error) {
+	sampleHashes := s.fingerprintService.CreateFingerprints(fragments, uuid.Nil, sampleRate)
+	if len(sampleHashes) == 0 {
+		return nil, ErrNoFingerprints
+	}
+
+	dbHashes, err := s.fingerprintService.GetMatchingHashes(ctx, sampleHashes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get matching hashes: %w", err)
+	}
+	return s.explainMatches(ctx, sampleHashes, dbHashes, queryDuration(fragments, sampleRate))
+}
+
+// explainMatches ranks the matches and explains every candidate up to ExplainMaxCandidates,
+// with the same thresholds for both.
+func (s *RecognitionService) explainMatches(ctx context.Context, sampleHashes []fingerprint.Hash, dbHashes []fingerprint.Hash, duration float64) (*ExplainResponse, error) {
+	thresholds := s.thresholds.get(ctx)
+	matches, err := s.rankMatches(ctx, sampleHashes, dbHashes, duration, thresholds)
+	if err != nil {
+		return nil, err
+	}
+
+	threshold := thresholds.matchThreshold(len(sampleHashes))
+	candidates := scoreCandidates(sampleHashes, dbHashes)
+	sampleHashMap, querySpan := sampleTimes(sampleHashes)
+
+	explanation := &Explanation{
+		SampleHashes:    len(sampleHashes),
+		DatabaseHashes:  len(dbHashes),
+		TotalCandidates: len(candidates),
+		Thresholds:      thresholds,
+		Threshold:       threshold,
+		Candidates:      []CandidateExplanation{},
+	}
+
+	verified := 0
+	for _, c := range candidates[:min(len(candidates), ExplainMaxCandidates)] {
+		bins, distinct := offsetHistogram(sampleHashMap, dbHashes, c.songID)
+		e := CandidateExplanation{
+			SongID:         c.songID,
+			Score:          c.score,
+			Offset:         c.offset,
+			MatchedPairs:   c.matchedHashes,
+			DistinctHashes: distinct,
+			TopBins:        bins[:min(len(bins), ExplainTopBins)],
+		}
+
+		songEntity, err := s.songRepository.FindByID(ctx, c.songID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find song %s: %w", c.songID, err)
+		}
+		e.Song = songEntity
+
+		switch {
+		case candidates[0].score < threshold:
+			e.Reason = fmt.Sprintf("best score %d is below the threshold %d", candidates[0].score, threshold)
+		case c.score < threshold:
+			e.Reason = fmt.Sprintf("score %d is below the threshold %d", c.score, threshold)
+		case verified >= s.config.MaxCandidates:
+			e.Reason = fmt.Sprintf("not verified, %d candidates passed before it", verified)
+		default:
+			v := verifyPairs(candidatePairs(sampleHashMap, dbHashes, c.songID, c.offset), querySpan)
+			e.Verification = &VerificationExplanation{
+				Slope:     v.slope,
+				Intercept: v.intercept,
+				Pairs:     v.pairs,
+				Inliers:   v.inliers,
+				Spread:    v.spread,
+			}
+			if rejection := v.rejection(threshold); rejection != "" {
+				e.Reason = "failed temporal verification: " + rejection
+				break
+			}
+			verified++
+			if songEntity == nil {
+				e.Reason = "song no longer exists"
+				break
+			}
+			e.Accepted = true
+			e.Reason = "passed the threshold and temporal verification"
+		}
+
+		explanation.Candidates = append(explanation.Candidates, e)
+	}
+
+	return &ExplainResponse{
+		RecognitionResponse: NewRecognitionResponse(matches),
+		Explanation:         explanation,
+	}, nil
+}
+
+// offsetHistogram returns the offset bins of a song, largest first, and the number of distinct
+// hash values it matched.
+func offsetHistogram(sampleHashMap map[int64][]float64, dbHashes []fingerprint.Hash, songID uuid.UUID) ([]OffsetBinCount, int) {
+	counts := make(map[int]int)
+	distinct := make(map[int64]bool)
+	for _, dbHash := range dbHashes {
+		if dbHash.SongID != songID {
+			continue
+		}
+		sampleOffsets, ok := sampleHashMap[dbHash.HashValue]
+		if !ok {
+			continue
+		}
+		distinct[dbHash.HashValue] = true
+		for _, sampleOffset := range sampleOffsets {
+			counts[int(math.Round((dbHash.TimeOffset-sampleOffset)*TimeBinResolution))]++
+		}
+	}
+
+	bins := make([]OffsetBinCount, 0, len(counts))
+	for bin, count := range counts {
+		bins = append(bins, OffsetBinCount{Offset: float64(bin) / TimeBinResolution, Count: count})
+	}
+	sort.Slice(bins, func(i, j int) bool {
+		if bins[i].Count != bins[j].Count {
+			return bins[i].Count > bins[j].Count
+		}
+		return bins[i].Offset < bins[j].Offset
+	})
+	return bins, len(distinct)
+}
@@ -0,0 +1,130 @@
+package recognition
+
+import (
+	"context"
+	"go-shazam/internal/fingerprint"
+	"go-shazam/internal/song"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplainMatches_AcceptsVerifiedMatch(t *testing.T) {
+	track := &song.SongEntity{ID: uuid.New(), Title: "Track"}
+	weak := &song.SongEntity{ID: uuid.New(), Title: "Weak"}
+	sample, db := alignedHashes(track.ID, 50, 61.0, 1000)
+	weakSample, weakDB := alignedHashes(weak.ID, 3, 12.0, 5000)
+	sample = append(sample, weakSample...)
+
+	response, err := newTestService(track, weak).explainMatches(context.Background(), sample, append(db, weakDB...), 5)
+	require.NoError(t, err)
+
+	assert.True(t, response.Found)
+	assert.Equal(t, track.ID, response.Song.ID)
+
+	explanation := response.Explanation
+	assert.Equal(t, 53, explanation.SampleHashes)
+	assert.Equal(t, 53, explanation.DatabaseHashes)
+	assert.Equal(t, 2, explanation.TotalCandidates)
+	assert.Equal(t, DefaultThresholds, explanation.Thresholds)
+	assert.Equal(t, MinAbsoluteScore, explanation.Threshold)
+	require.Len(t, explanation.Candidates, 2)
+
+	best := explanation.Candidates[0]
+	assert.True(t, best.Accepted)
+	assert.Equal(t, "Track", best.Song.Title)
+	assert.Equal(t, 50, best.Score)
+	assert.Equal(t, 50, best.DistinctHashes)
+	assert.Equal(t, []OffsetBinCount{{Offset: 61, Count: 50}}, best.TopBins)
+	require.NotNil(t, best.Verification)
+	assert.InDelta(t, 1, best.Verification.Slope, 1e-9)
+	assert.Equal(t, 50, best.Verification.Inliers)
+
+	other := explanation.Candidates[1]
+	assert.False(t, other.Accepted)
+	assert.Nil(t, other.Verification)
+	assert.Equal(t, "score 3 is below the threshold 5", other.Reason)
+}
+
+func TestExplainMatches_BelowThreshold(t *testing.T) {
+	track := &song.SongEntity{ID: uuid.New()}
+	sample, db := alignedHashes(track.ID, 4, 10.0, 1000)
+
+	response, err := newTestService(track).explainMatches(context.Background(), sample, db, 1)
+	require.NoError(t, err)
+
+	assert.False(t, response.Found)
+	require.Len(t, response.Explanation.Candidates, 1)
+	assert.False(t, response.Explanation.Candidates[0].Accepted)
+	assert.Equal(t, "best score 4 is below the threshold 5", response.Explanation.Candidates[0].Reason)
+}
+
+func TestExplainMatches_FailedVerification(t *testing.T) {
+	track := &song.SongEntity{ID: uuid.New()}
+
+	// A burst of matches at a single query time, in a query spanning ten seconds
+	var sample, db []fingerprint.Hash
+	for i := 0; i < 20; i++ {
+		sample = append(sample, fingerprint.Hash{HashValue: int64(i), TimeOffset: 2})
+		db = append(db, fingerprint.Hash{HashValue: int64(i), SongID: track.ID, TimeOffset: 32})
+	}
+	sample = append(sample, fingerprint.Hash{HashValue: 100, TimeOffset: 10})
+
+	response, err := newTestService(track).explainMatches(context.Background(), sample, db, 10)
+	require.NoError(t, err)
+
+	assert.False(t, response.Found)
+	require.Len(t, response.Explanation.Candidates, 1)
+	candidate := response.Explanation.Candidates[0]
+	assert.False(t, candidate.Accepted)
+	require.NotNil(t, candidate.Verification)
+	assert.Contains(t, candidate.Reason, "failed temporal verification")
+}
+
+func TestOffsetHistogram(t *testing.T) {
+	songID := uuid.New()
+	sample := []fingerprint.Hash{
+		{HashValue: 1, TimeOffset: 0},
+		{HashValue: 2, TimeOffset: 1},
+		{HashValue: 2, TimeOffset: 2}, // repeated value: two pairs for one database hash
+		{HashValue: 3, TimeOffset: 3},
+	}
+	db := []fingerprint.Hash{
+		{HashValue: 1, SongID: songID, TimeOffset: 10},
+		{HashValue: 2, SongID: songID, TimeOffset: 11},
+		{HashValue: 3, SongID: uuid.New(), TimeOffset: 13},
+	}
+	sampleHashMap, _ := sampleTimes(sample)
+
+	bins, distinct := offsetHistogram(sampleHashMap, db, songID)
+
+	assert.Equal(t, 2, distinct)
+	assert.Equal(t, []OffsetBinCount{{Offset: 10, Count: 2}, {Offset: 9, Count: 1}}, bins)
+}
+
+func TestUpload_ExplainRequiresAdmin(t *testing.T) {
+	router := setupUploadRouter(&stubInspector{})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/recognize?explain=true", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.JSONEq(t, `{"error": "explain mode requires an admin"}`, w.Body.String())
+}
+
+func TestLegacyProtocol_ExplainRequiresAdmin(t *testing.T) {
+	conn := dialTestServer(t)
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("start:44100")))
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(explainCommand)))
+
+	var msg map[string]any
+	require.NoError(t, conn.ReadJSON(&msg))
+	assert.Equal(t, ErrExplainForbidden.Error(), msg["error"])
+}
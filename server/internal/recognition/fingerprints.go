@@ -0,0 +1,101 @@
+package recognition
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-shazam/internal/fingerprint"
+	"go-shazam/pkg/landmark"
+	"math"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrProfileMismatch     = errors.New("fingerprint profile version does not match the server")
+	ErrInvalidFingerprints = errors.New("invalid fingerprints")
+)
+
+// FingerprintHash is a precomputed (hash, time_offset) pair, encoded as a two-element JSON
+// array to keep large submissions small: [hash, time_offset].
+type FingerprintHash struct {
+	Hash       int64
+	TimeOffset float64 // seconds from the start of the query to the anchor peak
+}
+
+func (h FingerprintHash) MarshalJSON() ([]byte, error) {
+	return json.Marshal([2]any{h.Hash, h.TimeOffset})
+}
+
+func (h *FingerprintHash) UnmarshalJSON(b []byte) error {
+	var pair []json.Number
+	if err := json.Unmarshal(b, &pair); err != nil || len(pair) != 2 {
+		return fmt.Errorf("%w: expected a [hash, time_offset] pair, got %s", ErrInvalidFingerprints, b)
+	}
+
+	hash, err := pair[0].Int64()
+	if err != nil {
+		return fmt.Errorf("%w: hash %s is not an integer", ErrInvalidFingerprints, pair[0])
+	}
+	offset, err := pair[1].Float64()
+	if err != nil {
+		return fmt.Errorf("%w: time offset %s is not a number", ErrInvalidFingerprints, pair[1])
+	}
+
+	h.Hash = hash
+	h.TimeOffset = offset
+	return nil
+}
+
+// FingerprintRequest submits hashes computed by the client with the profile ProfileVersion
+// refers to, instead of the audio itself.
+type FingerprintRequest struct {
+	ProfileVersion int               `json:"profile_version"`
+	Duration       float64           `json:"duration"` // seconds of audio fingerprinted, defaults to the last offset
+	Hashes         []FingerprintHash `json:"hashes"`
+	// Client metadata stored with the recognition in the history of authenticated users
+	Metadata json.RawMessage `json:"metadata,omitempty"`
+}
+
+// sampleHashes validates the submission and converts it to sample hashes for IdentifyHashes.
+// It also returns the duration of the query.
+func (r *FingerprintRequest) sampleHashes(maxHashes int) ([]fingerprint.Hash, float64, error) {
+	if r.ProfileVersion != landmark.ProfileVersion {
+		return nil, 0, fmt.Errorf("%w: got %d, expected %d", ErrProfileMismatch, r.ProfileVersion, landmark.ProfileVersion)
+	}
+	if len(r.Hashes) == 0 {
+		return nil, 0, fmt.Errorf("%w: no hashes submitted", ErrInvalidFingerprints)
+	}
+	if len(r.Hashes) > maxHashes {
+		return nil, 0, fmt.Errorf("%w: %d hashes exceed the limit of %d", ErrInvalidFingerprints, len(r.Hashes), maxHashes)
+	}
+	if math.IsNaN(r.Duration) || math.IsInf(r.Duration, 0) || r.Duration < 0 {
+		return nil, 0, fmt.Errorf("%w: duration must be a non-negative number", ErrInvalidFingerprints)
+	}
+
+	duration := r.Duration
+	hashes := make([]fingerprint.Hash, len(r.Hashes))
+	for i, h := range r.Hashes {
+		if h.Hash < 0 || h.Hash > landmark.MaxHashValue {
+			return nil, 0, fmt.Errorf("%w: hash %d is out of range", ErrInvalidFingerprints, h.Hash)
+		}
+		if math.IsNaN(h.TimeOffset) || math.IsInf(h.TimeOffset, 0) || h.TimeOffset < 0 {
+			return nil, 0, fmt.Errorf("%w: time offset of hash %d must be a non-negative number", ErrInvalidFingerprints, i)
+		}
+		hashes[i] = fingerprint.Hash{HashValue: h.Hash, SongID: uuid.Nil, TimeOffset: h.TimeOffset}
+		if r.Duration == 0 {
+			duration = math.Max(duration, h.TimeOffset)
+		}
+	}
+
+	return hashes, duration, nil
+}
+
+// NewFingerprintHashes converts computed hashes to their wire format
+func NewFingerprintHashes(hashes []fingerprint.Hash) []FingerprintHash {
+	submitted := make([]FingerprintHash, len(hashes))
+	for i, h := range hashes {
+		submitted[i] = FingerprintHash{Hash: h.HashValue, TimeOffset: h.TimeOffset}
+	}
+	return submitted
+}
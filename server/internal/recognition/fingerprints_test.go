@@ -0,0 +1,131 @@
+package recognition
+
+import (
+	"bytes"
+	"encoding/json"
+	"go-shazam/internal/fingerprint"
+	"go-shazam/pkg/landmark"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFingerprintHash_JSON(t *testing.T) {
+	b, err := json.Marshal([]FingerprintHash{{Hash: 17179869183, TimeOffset: 1.5}})
+	require.NoError(t, err)
+	assert.JSONEq(t, `[[17179869183, 1.5]]`, string(b))
+
+	var decoded []FingerprintHash
+	require.NoError(t, json.Unmarshal(b, &decoded))
+	assert.Equal(t, []FingerprintHash{{Hash: 17179869183, TimeOffset: 1.5}}, decoded)
+
+	for _, invalid := range []string{`[[1]]`, `[[1, 2, 3]]`, `[[1.5, 2]]`, `[{"hash": 1}]`, `[["a", 2]]`} {
+		assert.ErrorIs(t, json.Unmarshal([]byte(invalid), &decoded), ErrInvalidFingerprints, invalid)
+	}
+}
+
+func TestFingerprintRequest_SampleHashes(t *testing.T) {
+	req := FingerprintRequest{
+		ProfileVersion: landmark.ProfileVersion,
+		Hashes:         []FingerprintHash{{Hash: 42, TimeOffset: 0.5}, {Hash: 43, TimeOffset: 4.25}},
+	}
+
+	hashes, duration, err := req.sampleHashes(10)
+
+	require.NoError(t, err)
+	assert.Equal(t, []fingerprint.Hash{{HashValue: 42, TimeOffset: 0.5}, {HashValue: 43, TimeOffset: 4.25}}, hashes)
+	assert.Equal(t, 4.25, duration, "duration defaults to the last offset")
+
+	req.Duration = 6
+	_, duration, err = req.sampleHashes(10)
+	require.NoError(t, err)
+	assert.Equal(t, 6.0, duration)
+}
+
+func TestFingerprintRequest_SampleHashesRejectsInvalid(t *testing.T) {
+	valid := []FingerprintHash{{Hash: 42, TimeOffset: 0.5}}
+
+	tests := []struct {
+		name     string
+		req      FingerprintRequest
+		expected error
+	}{
+		{"other profile", FingerprintRequest{ProfileVersion: landmark.ProfileVersion + 1, Hashes: valid}, ErrProfileMismatch},
+		{"missing profile", FingerprintRequest{Hashes: valid}, ErrProfileMismatch},
+		{"no hashes", FingerprintRequest{ProfileVersion: landmark.ProfileVersion}, ErrInvalidFingerprints},
+		{"too many hashes", FingerprintRequest{ProfileVersion: landmark.ProfileVersion, Hashes: append(valid, valid...)}, ErrInvalidFingerprints},
+		{"negative hash", FingerprintRequest{ProfileVersion: landmark.ProfileVersion, Hashes: []FingerprintHash{{Hash: -1}}}, ErrInvalidFingerprints},
+		{"hash out of range", FingerprintRequest{ProfileVersion: landmark.ProfileVersion, Hashes: []FingerprintHash{{Hash: landmark.MaxHashValue + 1}}}, ErrInvalidFingerprints},
+		{"negative offset", FingerprintRequest{ProfileVersion: landmark.ProfileVersion, Hashes: []FingerprintHash{{Hash: 1, TimeOffset: -1}}}, ErrInvalidFingerprints},
+		{"negative duration", FingerprintRequest{ProfileVersion: landmark.ProfileVersion, Hashes: valid, Duration: -1}, ErrInvalidFingerprints},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := tt.req.sampleHashes(1)
+			assert.ErrorIs(t, err, tt.expected)
+		})
+	}
+}
+
+func TestRecognitionHandler_SubmitFingerprints_ProfileMismatch(t *testing.T) {
+	router := setupUploadRouter(&stubInspector{})
+
+	body := `{"profile_version": 99, "hashes": [[42, 0.5]]}`
+	req := httptest.NewRequest(http.MethodPost, "/api/recognize/fingerprints", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusConflict, w.Code)
+	var response struct {
+		ProfileVersion int `json:"profile_version"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, landmark.ProfileVersion, response.ProfileVersion)
+}
+
+func TestRecognitionHandler_SubmitFingerprints_Invalid(t *testing.T) {
+	router := setupUploadRouter(&stubInspector{})
+
+	for _, body := range []string{
+		`{"profile_version": 1, "hashes": [[42]]}`,
+		`{"profile_version": 1, "hashes": []}`,
+		`{"profile_version": 1, "hashes": [[42, 0.5]], "metadata": "phone"}`,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/api/recognize/fingerprints", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code, body)
+	}
+}
+
+func TestRecognitionHandler_SubmitFingerprints_TooLarge(t *testing.T) {
+	router := setupUploadRouter(&stubInspector{})
+
+	body := `{"profile_version": 1, "hashes": [` + strings.Repeat(`[42, 0.5], `, 200) + `[42, 0.5]]}`
+	req := httptest.NewRequest(http.MethodPost, "/api/recognize/fingerprints", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+}
+
+func TestRecognitionHandler_GetFingerprintProfile(t *testing.T) {
+	router := setupUploadRouter(&stubInspector{})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/recognize/fingerprints/profile", nil))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var profile landmark.Profile
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &profile))
+	assert.Equal(t, landmark.CurrentProfile(), profile)
+}
@@ -1,66 +1,414 @@
 package recognition
 
 import (
+	"bytes"
+	"context"
 	"encoding/binary"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"go-shazam/internal/audio"
+	"go-shazam/internal/auth"
+	"go-shazam/internal/history"
+	"go-shazam/internal/logger"
+	"go-shazam/pkg/audio"
+	"go-shazam/pkg/landmark"
 	"math"
 	"net/http"
 	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 )
 
+const (
+	// Message types sent in incremental mode
+	MessageTypeCandidate = "candidate"
+	MessageTypeMatch     = "match"
+
+	incrementalMode = "incremental"
+	// Legacy command that answers with the result and its explanation, for admins
+	explainCommand = "analyze:explain"
+)
+
+var ErrExplainForbidden = errors.New("explain mode requires an admin")
+
 var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool { return true },
+	CheckOrigin:  func(r *http.Request) bool { return true },
+	Subprotocols: []string{auth.WebSocketProtocol},
 }
 
 type RecognitionHandler struct {
-	service *RecognitionService
+	service        *RecognitionService
+	uploadService  *UploadService
+	historyService *history.HistoryService
+	config         *Config
+	authConfig     *auth.Config
+	sessions       *sessionRegistry
+}
+
+func NewRecognitionHandler(
+	service *RecognitionService,
+	uploadService *UploadService,
+	historyService *history.HistoryService,
+	config *Config,
+	authConfig *auth.Config,
+) *RecognitionHandler {
+	return &RecognitionHandler{
+		service:        service,
+		uploadService:  uploadService,
+		historyService: historyService,
+		config:         config,
+		authConfig:     authConfig,
+		sessions:       newSessionRegistry(config.WSMaxSessions),
+	}
+}
+
+// RegisterRoutes registers the recognition endpoints. Recognitions of authenticated users are
+// added to their history.
+func RegisterRoutes(r *gin.Engine, h *RecognitionHandler, jwtService *auth.JWTService) {
+	optionalAuth := auth.OptionalAuthMiddleware(jwtService)
+	r.GET("/api/recognize/ws", optionalAuth, h.HandleWebSocket)
+	r.POST("/api/recognize", optionalAuth, h.Upload)
+	r.GET("/api/recognize/jobs/:id", h.GetJob)
+	r.POST("/api/recognize/fingerprints", optionalAuth, h.SubmitFingerprints)
+	r.GET("/api/recognize/fingerprints/profile", h.GetFingerprintProfile)
+}
+
+// Upload recognises an audio file sent as the "file" field of a multipart form. Short files
+// are answered with the result, long files with 202 and a job to poll.
+//
+// Admins can add ?explain=true to get the result of any file synchronously together with an
+// explanation of how the candidates were scored. Explained recognitions are not added to
+// the history.
+func (h *RecognitionHandler) Upload(c *gin.Context) {
+	log := logger.FromContext(c.Request.Context())
+
+	explain := c.Query("explain") == "true"
+	if explain && !h.isAdmin(c.Request.Context()) {
+		c.JSON(http.StatusForbidden, gin.H{"error": ErrExplainForbidden.Error()})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.config.UploadMaxBytes)
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("file is larger than %d bytes", h.config.UploadMaxBytes)})
+			return
+		}
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "audio file is required in the \"file\" field"})
+		return
+	}
+
+	metadata := json.RawMessage(c.PostForm("metadata"))
+	if err := history.ValidateMetadata(metadata); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		log.Error("failed to open uploaded file", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read uploaded file"})
+		return
+	}
+	defer file.Close()
+
+	if explain {
+		response, err := h.uploadService.Explain(c.Request.Context(), file)
+		if err != nil {
+			h.uploadError(c, err, fileHeader.Filename)
+			return
+		}
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	result, err := h.uploadService.Recognize(c.Request.Context(), file, metadata)
+	if err != nil {
+		h.uploadError(c, err, fileHeader.Filename)
+		return
+	}
+
+	if result.Job != nil {
+		c.Header("Location", "/api/recognize/jobs/"+result.Job.ID)
+		c.JSON(http.StatusAccepted, result.Job)
+		return
+	}
+
+	userID, _ := auth.GetUserIDFromContext(c.Request.Context())
+	recordHistory(c.Request.Context(), h.historyService, userID, *result.Result, metadata)
+
+	c.JSON(http.StatusOK, result.Result)
+}
+
+func (h *RecognitionHandler) uploadError(c *gin.Context, err error, filename string) {
+	switch {
+	case errors.Is(err, ErrUnsupportedAudio):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": ErrUnsupportedAudio.Error()})
+	case errors.Is(err, ErrAudioTooShort), errors.Is(err, ErrAudioTooLong):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		logger.FromContext(c.Request.Context()).Error("failed to recognise upload", "error", err, "filename", filename)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to recognise audio"})
+	}
+}
+
+func (h *RecognitionHandler) isAdmin(ctx context.Context) bool {
+	userID, ok := auth.GetUserIDFromContext(ctx)
+	return ok && h.authConfig != nil && h.authConfig.IsAdmin(userID)
+}
+
+// GetJob reports the state of a recognition job. With ?format=cue, the timeline of a completed
+// URL recognition is downloaded as a CUE sheet instead.
+func (h *RecognitionHandler) GetJob(c *gin.Context) {
+	log := logger.FromContext(c.Request.Context())
+
+	jobID := c.Param("id")
+	if _, err := uuid.Parse(jobID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job id"})
+		return
+	}
+
+	switch c.Query("format") {
+	case "", "json":
+	case "cue":
+		h.exportCUE(c, jobID)
+		return
+	default:
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "format must be json or cue"})
+		return
+	}
+
+	job, err := h.uploadService.GetJob(c.Request.Context(), jobID)
+	if err != nil {
+		if errors.Is(err, ErrJobNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": ErrJobNotFound.Error()})
+			return
+		}
+		log.Error("failed to get recognition job", "error", err, "job_id", jobID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get recognition job"})
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+func (h *RecognitionHandler) exportCUE(c *gin.Context, jobID string) {
+	var sheet bytes.Buffer
+	if err := h.uploadService.ExportCUE(c.Request.Context(), jobID, &sheet); err != nil {
+		switch {
+		case errors.Is(err, ErrJobNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": ErrJobNotFound.Error()})
+		case errors.Is(err, ErrNoTimeline):
+			c.JSON(http.StatusConflict, gin.H{"error": ErrNoTimeline.Error()})
+		default:
+			logger.FromContext(c.Request.Context()).Error("failed to export recognition job", "error", err, "job_id", jobID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to export recognition job"})
+		}
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.cue"`, jobID))
+	c.Data(http.StatusOK, "text/plain; charset=utf-8", sheet.Bytes())
+}
+
+// SubmitFingerprints recognises (hash, time_offset) pairs computed by the client, for
+// integrations that do not send audio. The hashes must be computed with the current
+// fingerprint profile; other versions are rejected with 409 and the expected version.
+func (h *RecognitionHandler) SubmitFingerprints(c *gin.Context) {
+	log := logger.FromContext(c.Request.Context())
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.config.FingerprintMaxBytes)
+	var req FingerprintRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("request is larger than %d bytes", h.config.FingerprintMaxBytes)})
+			return
+		}
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	if err := history.ValidateMetadata(req.Metadata); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+
+	sampleHashes, duration, err := req.sampleHashes(h.config.FingerprintMaxHashes)
+	if err != nil {
+		if errors.Is(err, ErrProfileMismatch) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "profile_version": landmark.ProfileVersion})
+			return
+		}
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+
+	matches, err := h.service.IdentifyHashes(c.Request.Context(), sampleHashes, duration)
+	if err != nil {
+		log.Error("failed to recognise fingerprints", "error", err, "hashes", len(sampleHashes))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to recognise fingerprints"})
+		return
+	}
+
+	response := NewRecognitionResponse(matches)
+	userID, _ := auth.GetUserIDFromContext(c.Request.Context())
+	recordHistory(c.Request.Context(), h.historyService, userID, response, req.Metadata)
+
+	c.JSON(http.StatusOK, response)
 }
 
-func NewRecognitionHandler(service *RecognitionService) *RecognitionHandler {
-	return &RecognitionHandler{service: service}
+// GetFingerprintProfile describes how the server computes hashes
+func (h *RecognitionHandler) GetFingerprintProfile(c *gin.Context) {
+	c.JSON(http.StatusOK, landmark.CurrentProfile())
 }
 
-func RegisterRoutes(r *gin.Engine, h *RecognitionHandler) {
-	r.GET("/api/recognize/ws", h.HandleWebSocket)
+// StreamMessage is sent in legacy incremental mode: "candidate" messages while audio is still
+// arriving and a single "match" message with the final answer.
+type StreamMessage struct {
+	Type string `json:"type"`
+	RecognitionResponse
+	Duration float64 `json:"duration"` // seconds of audio analysed
 }
 
+// HandleWebSocket receives audio over a WebSocket and answers with recognition results.
+//
+// A connection that opens with a JSON "hello" message speaks the versioned protocol described
+// in protocol.go. Any other first message selects the legacy protocol, see serveLegacy.
 func (h *RecognitionHandler) HandleWebSocket(c *gin.Context) {
-	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	upgraded, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
 		return
 	}
+	conn := newWSConn(upgraded, h.config)
 	defer conn.Close()
 
+	ctx := c.Request.Context()
+
+	if err := h.sessions.acquire(conn); err != nil {
+		logger.FromContext(ctx).Warn("recognition session rejected", "error", err, "sessions", h.sessions.count())
+		if errors.Is(err, ErrServerShuttingDown) {
+			conn.closeWithError(websocket.CloseGoingAway, ErrorCodeServerShutdown, err)
+		} else {
+			conn.closeWithError(websocket.CloseTryAgainLater, ErrorCodeTooManySessions, err)
+		}
+		return
+	}
+	defer h.sessions.release(conn)
+
+	done := make(chan struct{})
+	defer close(done)
+	go conn.keepalive(done)
+
+	messageType, p, err := conn.ReadMessage()
+	if err == nil {
+		if messageType == websocket.TextMessage && json.Valid(p) {
+			userID, _ := auth.GetUserIDFromContext(ctx)
+			err = newSession(conn, h, userID).serve(ctx, messageType, p)
+		} else {
+			err = h.serveLegacy(ctx, conn, messageType, p)
+		}
+	}
+	conn.closeAfterReadError(err)
+}
+
+// Shutdown closes the open WebSocket sessions and waits until they have ended.
+func (h *RecognitionHandler) Shutdown(ctx context.Context) error {
+	return h.sessions.shutdown(ctx)
+}
+
+// serveLegacy speaks the original text protocol, starting with the first message that was
+// already read to detect the protocol. It returns the error that ended the connection.
+//
+// Text frames: "start[:<rate>[:incremental]]" resets the session, "stop"/"analyze" requests
+// the result and "analyze:explain" (admins only) the result with its explanation. Binary
+// frames carry little-endian float32 mono samples. In incremental mode the audio is re-scored
+// as it arrives and the final match may be sent before "stop".
+func (h *RecognitionHandler) serveLegacy(ctx context.Context, conn *wsConn, messageType int, p []byte) error {
 	var audioData []float64
 	sampleRate := 44100 // Default
 
-	for {
-		messageType, p, err := conn.ReadMessage()
-		if err != nil {
-			break
-		}
+	// Incremental mode state
+	var stream *StreamRecognizer
+	finished := false
 
+	// Audio received since the last reset, limited by the session limits
+	var audioBytes int64
+	limited := false
+
+	var err error
+	for ; err == nil; messageType, p, err = conn.ReadMessage() {
 		if messageType == websocket.BinaryMessage {
-			// Append audio chunks
+			if limited {
+				continue
+			}
+			audioBytes += int64(len(p))
+			duration := float64(len(audioData)) / float64(sampleRate)
+			if stream != nil {
+				duration = stream.Duration()
+			}
+			if err := h.config.audioLimitError(audioBytes, duration); err != nil {
+				limited = true
+				conn.WriteJSON(gin.H{"error": err.Error(), "code": ErrorCodeAudioLimit})
+				continue
+			}
+
 			floats := bytesToFloats(p)
-			audioData = append(audioData, floats...)
+			if stream == nil {
+				// Append audio chunks
+				audioData = append(audioData, floats...)
+				continue
+			}
+			if finished {
+				continue
+			}
+
+			stream.Append(floats)
+			if stream.Duration() < h.config.StreamMinDuration.Seconds() ||
+				stream.PendingDuration() < h.config.StreamInterval.Seconds() {
+				continue
+			}
+
+			finished = h.sendStreamUpdate(ctx, conn, stream)
 		} else if messageType == websocket.TextMessage {
 			msg := string(p)
+
 			if strings.HasPrefix(msg, "start") {
-				// Reset and optional set sample rate: "start:48000"
+				// Reset and optional set sample rate: "start:48000", "start:48000:incremental"
 				audioData = []float64{}
+				stream = nil
+				finished = false
+				audioBytes = 0
+				limited = false
 				parts := strings.Split(msg, ":")
 				if len(parts) > 1 {
 					if rate, err := strconv.Atoi(parts[1]); err == nil {
 						sampleRate = rate
 					}
 				}
+				if len(parts) > 2 && parts[2] == incrementalMode {
+					stream = h.service.NewStream(sampleRate)
+				}
+			} else if msg == explainCommand {
+				if !h.sendExplanation(ctx, conn, audioData, sampleRate, stream) {
+					continue
+				}
+				audioData = []float64{}
+				audioBytes = 0
+				limited = false
+				finished = true
 			} else if msg == "stop" || msg == "analyze" {
+				if stream != nil {
+					if !finished {
+						finished = h.sendStreamResult(ctx, conn, stream)
+					}
+					continue
+				}
+
 				if len(audioData) == 0 {
 					conn.WriteJSON(gin.H{"error": "no audio data received"})
 					continue
@@ -76,32 +424,132 @@ func (h *RecognitionHandler) HandleWebSocket(c *gin.Context) {
 					}
 				}
 
-				fragments, err := audio.ProcessAudio(audioData, audio.TargetSampleRate)
-				if err != nil {
-					conn.WriteJSON(gin.H{"error": fmt.Sprintf("processing error: %s", err.Error())})
-					continue
-				}
-
-				match, err := h.service.IdentifySong(c.Request.Context(), fragments, audio.TargetSampleRate)
+				response, err := h.service.IdentifySamples(ctx, audioData)
 				if err != nil {
 					conn.WriteJSON(gin.H{"error": fmt.Sprintf("recognition error: %s", err.Error())})
-				} else if match == nil {
-					conn.WriteJSON(gin.H{"found": false})
 				} else {
-					conn.WriteJSON(gin.H{
-						"found":       true,
-						"song":        match.Song,
-						"time_offset": match.TimeOffset,
-						"score":       match.Score,
-					})
+					h.recordHistory(ctx, *response)
+					conn.WriteJSON(response)
 				}
 
 				// Clear buffer after analysis
 				// Usually "stop" implies end of this session.
 				audioData = []float64{}
+				audioBytes = 0
+				limited = false
 			}
 		}
 	}
+	return err
+}
+
+// sendExplanation scores the buffered audio, or the whole stream in incremental mode, and
+// sends the result with its explanation. Nothing is added to the history. It reports whether
+// the explanation was sent.
+func (h *RecognitionHandler) sendExplanation(ctx context.Context, conn *wsConn, audioData []float64, sampleRate int, stream *StreamRecognizer) bool {
+	if !h.isAdmin(ctx) {
+		conn.WriteJSON(gin.H{"error": ErrExplainForbidden.Error()})
+		return false
+	}
+
+	var (
+		response *ExplainResponse
+		err      error
+	)
+	if stream != nil {
+		if stream.Duration() == 0 {
+			conn.WriteJSON(gin.H{"error": "no audio data received"})
+			return false
+		}
+		response, err = stream.Explain(ctx)
+	} else {
+		if len(audioData) == 0 {
+			conn.WriteJSON(gin.H{"error": "no audio data received"})
+			return false
+		}
+		response, err = h.explainSamples(ctx, audioData, sampleRate)
+	}
+	if err != nil {
+		conn.WriteJSON(gin.H{"error": fmt.Sprintf("recognition error: %s", err.Error())})
+		return false
+	}
+
+	conn.WriteJSON(response)
+	return true
+}
+
+func (h *RecognitionHandler) explainSamples(ctx context.Context, samples []float64, sampleRate int) (*ExplainResponse, error) {
+	if sampleRate != audio.TargetSampleRate {
+		var err error
+		samples, err = audio.Resample(samples, sampleRate, audio.TargetSampleRate)
+		if err != nil {
+			return nil, fmt.Errorf("resampling error: %w", err)
+		}
+	}
+
+	fragments, err := audio.ProcessAudio(samples, audio.TargetSampleRate)
+	if err != nil {
+		return nil, fmt.Errorf("processing error: %w", err)
+	}
+	return h.service.ExplainSong(ctx, fragments, audio.TargetSampleRate)
+}
+
+// sendStreamUpdate re-scores the stream and sends an interim candidate, or the final match
+// once the best candidate is confident enough. It reports whether the match was sent.
+func (h *RecognitionHandler) sendStreamUpdate(ctx context.Context, conn *wsConn, stream *StreamRecognizer) bool {
+	matches, err := stream.Recognize(ctx)
+	if err != nil {
+		conn.WriteJSON(gin.H{"error": fmt.Sprintf("recognition error: %s", err.Error())})
+		return false
+	}
+	if len(matches) == 0 {
+		return false
+	}
+
+	messageType := MessageTypeCandidate
+	response := stream.Response(matches)
+	if matches[0].Confidence >= h.config.EarlyMatchConfidence {
+		messageType = MessageTypeMatch
+		h.recordHistory(ctx, response)
+	}
+
+	conn.WriteJSON(StreamMessage{
+		Type:                messageType,
+		RecognitionResponse: response,
+		Duration:            stream.Duration(),
+	})
+	return messageType == MessageTypeMatch
+}
+
+// sendStreamResult scores everything received and sends the final match, found or not.
+// It reports whether the match was sent.
+func (h *RecognitionHandler) sendStreamResult(ctx context.Context, conn *wsConn, stream *StreamRecognizer) bool {
+	if stream.Duration() == 0 {
+		conn.WriteJSON(gin.H{"error": "no audio data received"})
+		return false
+	}
+
+	matches, err := stream.Recognize(ctx)
+	if err != nil {
+		conn.WriteJSON(gin.H{"error": fmt.Sprintf("recognition error: %s", err.Error())})
+		return false
+	}
+
+	response := stream.Response(matches)
+	h.recordHistory(ctx, response)
+
+	conn.WriteJSON(StreamMessage{
+		Type:                MessageTypeMatch,
+		RecognitionResponse: response,
+		Duration:            stream.Duration(),
+	})
+	return true
+}
+
+// recordHistory adds a final legacy result to the history of the authenticated user, if any
+func (h *RecognitionHandler) recordHistory(ctx context.Context, response RecognitionResponse) {
+	userID, _ := auth.GetUserIDFromContext(ctx)
+	recordHistory(ctx, h.historyService, userID, response, nil)
 }
 
 func bytesToFloats(b []byte) []float64 {
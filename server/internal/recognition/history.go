@@ -0,0 +1,29 @@
+package recognition
+
+import (
+	"context"
+	"encoding/json"
+	"go-shazam/internal/history"
+	"go-shazam/internal/logger"
+
+	"github.com/google/uuid"
+)
+
+// recordHistory adds a successful recognition to the history of an authenticated user.
+// Failures are logged and do not affect the recognition result.
+func recordHistory(ctx context.Context, historyService *history.HistoryService, userID uuid.UUID, response RecognitionResponse, metadata json.RawMessage) {
+	if historyService == nil || userID == uuid.Nil || !response.Found {
+		return
+	}
+
+	err := historyService.Record(ctx, history.NewRecognition{
+		UserID:     userID,
+		SongID:     response.Song.ID,
+		Score:      response.Score,
+		Confidence: response.Confidence,
+		Metadata:   metadata,
+	})
+	if err != nil {
+		logger.FromContext(ctx).Error("failed to record recognition history", "error", err, "user_id", userID)
+	}
+}
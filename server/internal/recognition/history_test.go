@@ -0,0 +1,59 @@
+package recognition
+
+import (
+	"context"
+	"encoding/json"
+	"go-shazam/internal/auth"
+	"go-shazam/internal/history"
+	"go-shazam/internal/song"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testJWTService = auth.NewJWTService(&auth.Config{
+	AccessTokenSecret:  "test-access-secret",
+	RefreshTokenSecret: "test-refresh-secret",
+	AccessTokenTTL:     time.Minute,
+	RefreshTokenTTL:    time.Hour,
+})
+
+// stubHistoryRepository keeps saved recognitions in memory; listing is not used here
+type stubHistoryRepository struct {
+	history.HistoryRepositoryInterface
+	saved []*history.RecognitionEntity
+}
+
+func (r *stubHistoryRepository) Save(ctx context.Context, recognition *history.RecognitionEntity) error {
+	r.saved = append(r.saved, recognition)
+	return nil
+}
+
+func TestRecordHistory(t *testing.T) {
+	userID := uuid.New()
+	found := NewRecognitionResponse([]MatchResult{{
+		Song:       &song.SongEntity{ID: uuid.New(), Title: "Song", Artist: "Artist"},
+		Score:      42,
+		Confidence: 0.8,
+	}})
+	notFound := NewRecognitionResponse(nil)
+	metadata := json.RawMessage(`{"device": "phone"}`)
+
+	repository := &stubHistoryRepository{}
+	service := history.NewHistoryService(repository)
+
+	recordHistory(context.Background(), service, userID, found, metadata)
+	recordHistory(context.Background(), service, uuid.Nil, found, metadata)
+	recordHistory(context.Background(), service, userID, notFound, metadata)
+
+	require.Len(t, repository.saved, 1)
+	saved := repository.saved[0]
+	assert.Equal(t, userID, saved.UserID)
+	assert.Equal(t, found.Song.ID, saved.SongID)
+	assert.Equal(t, 42, saved.Score)
+	assert.Equal(t, 0.8, saved.Confidence)
+	assert.JSONEq(t, `{"device":"phone"}`, string(saved.Metadata))
+}
@@ -0,0 +1,224 @@
+package recognition
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var (
+	ErrInvalidMediaURL  = errors.New("media URL must be an absolute http or https URL")
+	ErrMediaTooLarge    = errors.New("media exceeds the download limits")
+	ErrMediaUnavailable = errors.New("media is not available")
+	ErrPrivateAddress   = errors.New("media URL points to a private address")
+	ErrUnsupportedMedia = errors.New("media URL is neither an audio file nor a page of a supported media site")
+)
+
+// MediaDownload is a media page to download the audio of
+type MediaDownload struct {
+	URL         string
+	Path        string
+	MaxBytes    int64
+	MaxDuration time.Duration
+	Extractors  []string // yt-dlp extractors allowed to handle the page
+	Proxy       string   // HTTP proxy every request of the download must go through
+}
+
+// MediaDownloader downloads the audio of a media page (a video, a podcast episode) that is
+// not a direct link to an audio file. Media longer than MaxDuration or larger than MaxBytes
+// is rejected with ErrMediaTooLarge.
+type MediaDownloader interface {
+	DownloadMedia(ctx context.Context, download MediaDownload) error
+}
+
+// MediaFetcher downloads the media behind a URL. Pages of the media sites in URLMediaHosts
+// are handed to the MediaDownloader, any other URL must be a direct link to an audio or
+// video file, which is fetched over HTTP.
+type MediaFetcher struct {
+	config     *Config
+	downloader MediaDownloader
+	dialer     *net.Dialer
+	http       *http.Client
+}
+
+func NewMediaFetcher(config *Config, downloader MediaDownloader) *MediaFetcher {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	if !config.URLAllowPrivate {
+		dialer.Control = rejectPrivateAddress
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &MediaFetcher{
+		config:     config,
+		downloader: downloader,
+		dialer:     dialer,
+		http:       &http.Client{Transport: transport},
+	}
+}
+
+// ParseMediaURL checks that rawURL can be fetched.
+func ParseMediaURL(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, ErrInvalidMediaURL
+	}
+	return u, nil
+}
+
+// Fetch stores the media behind rawURL at path. Nothing is left at path when it fails.
+func (f *MediaFetcher) Fetch(ctx context.Context, rawURL string, path string) error {
+	u, err := ParseMediaURL(rawURL)
+	if err != nil {
+		return err
+	}
+	if f.isMediaHost(u) {
+		return f.download(ctx, u, path)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := f.http.Do(req)
+	if err != nil {
+		if errors.Is(err, ErrPrivateAddress) {
+			return ErrPrivateAddress
+		}
+		return fmt.Errorf("failed to fetch media: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		return fmt.Errorf("%w: %s answered %d", ErrMediaUnavailable, u.Host, resp.StatusCode)
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("failed to fetch media: %s answered %d", u.Host, resp.StatusCode)
+	}
+
+	if !isMediaType(resp.Header.Get("Content-Type")) {
+		return fmt.Errorf("%w: %s serves %s", ErrUnsupportedMedia, u.Host, resp.Header.Get("Content-Type"))
+	}
+	if f.config.URLMaxBytes > 0 && resp.ContentLength > f.config.URLMaxBytes {
+		return fmt.Errorf("%w: %d bytes, at most %d are accepted", ErrMediaTooLarge, resp.ContentLength, f.config.URLMaxBytes)
+	}
+	return f.save(resp.Body, path)
+}
+
+// download hands a media page to the MediaDownloader, through a proxy that dials like the
+// direct downloads so that the downloader cannot be led to a private address either.
+func (f *MediaFetcher) download(ctx context.Context, u *url.URL, path string) error {
+	proxy, err := startGuardedProxy(f.dialer.DialContext)
+	if err != nil {
+		return err
+	}
+	defer proxy.Close()
+
+	err = f.downloader.DownloadMedia(ctx, MediaDownload{
+		URL:         u.String(),
+		Path:        path,
+		MaxBytes:    f.config.URLMaxBytes,
+		MaxDuration: f.config.URLMaxDuration,
+		Extractors:  f.config.URLExtractors,
+		Proxy:       proxy.URL(),
+	})
+	if err != nil && proxy.refused.Load() {
+		return ErrPrivateAddress
+	}
+	return err
+}
+
+// isMediaHost reports whether the URL is on one of the media sites in URLMediaHosts or
+// a subdomain of one.
+func (f *MediaFetcher) isMediaHost(u *url.URL) bool {
+	host := strings.ToLower(u.Hostname())
+	for _, mediaHost := range f.config.URLMediaHosts {
+		if host == mediaHost || strings.HasSuffix(host, "."+mediaHost) {
+			return true
+		}
+	}
+	return false
+}
+
+// save copies the body to path, failing once it grows beyond URLMaxBytes
+func (f *MediaFetcher) save(body io.Reader, path string) error {
+	out, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create media file: %w", err)
+	}
+	defer out.Close()
+
+	if f.config.URLMaxBytes > 0 {
+		// One byte more than allowed tells a file at the limit from a larger one
+		body = io.LimitReader(body, f.config.URLMaxBytes+1)
+	}
+	n, err := io.Copy(out, body)
+	if err == nil && f.config.URLMaxBytes > 0 && n > f.config.URLMaxBytes {
+		err = fmt.Errorf("%w: more than %d bytes", ErrMediaTooLarge, f.config.URLMaxBytes)
+	} else if err != nil {
+		err = fmt.Errorf("failed to download media: %w", err)
+	}
+	if err != nil {
+		out.Close()
+		os.Remove(path)
+	}
+	return err
+}
+
+// isMediaType reports whether a Content-Type is an audio or video file rather than a page
+func isMediaType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(mediaType, "audio/") ||
+		strings.HasPrefix(mediaType, "video/") ||
+		mediaType == "application/ogg" ||
+		mediaType == "application/octet-stream"
+}
+
+// deniedPrefixes are the non-public ranges the address helpers of netip do not cover
+var deniedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),     // this network
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT, used by cloud internal networks
+	netip.MustParsePrefix("192.0.0.0/24"),  // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"), // benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),   // reserved, and broadcast
+	netip.MustParsePrefix("64:ff9b::/96"),  // NAT64, which reaches IPv4 addresses over IPv6
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+}
+
+// rejectPrivateAddress keeps user supplied URLs, and the redirects they lead to, from reaching
+// the internal network. It runs after name resolution, so every address dialled is checked.
+func rejectPrivateAddress(network string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return ErrPrivateAddress
+	}
+	ip = ip.Unmap()
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast() {
+		return ErrPrivateAddress
+	}
+	for _, prefix := range deniedPrefixes {
+		if prefix.Contains(ip) {
+			return ErrPrivateAddress
+		}
+	}
+	return nil
+}
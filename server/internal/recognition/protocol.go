@@ -0,0 +1,269 @@
+package recognition
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-shazam/internal/history"
+	"go-shazam/internal/song"
+	"go-shazam/pkg/audio"
+	"math"
+)
+
+// ProtocolVersion is the version of the JSON protocol spoken on /api/recognize/ws. Clients
+// that open the connection with a plain text frame ("start:<rate>") get the legacy protocol.
+const ProtocolVersion = 1
+
+// Client message types. start, stop and abort apply to the recognition of their request_id;
+// the recognition without request ID is started by hello.
+const (
+	MessageTypeHello = "hello" // negotiates the audio format, answered with "ready"
+	MessageTypeStart = "start" // discards the audio received so far and starts a new recognition
+	MessageTypeStop  = "stop"  // ends the recognition, answered with "result"
+	MessageTypeAbort = "abort" // cancels the recognition, answered with "aborted"
+)
+
+// Server message types
+const (
+	MessageTypeReady        = "ready"
+	MessageTypeResult       = "result"
+	MessageTypeSync         = "sync"          // position update in sync sessions
+	MessageTypeTrackChanged = "track_changed" // the followed song stopped playing in sync sessions
+	MessageTypeAborted      = "aborted"
+	MessageTypeError        = "error"
+)
+
+// CodecPCM is the default codec: binary frames carry raw samples in the negotiated format.
+// Compressed codecs (audio.CodecWebM, audio.CodecOgg, audio.CodecMP3) are decoded on the server;
+// their sample rate and channels are read from the stream.
+const CodecPCM = "pcm"
+
+// Sample formats of binary audio frames
+const (
+	SampleFormatFloat32 = "float32" // little-endian IEEE 754, [-1, 1]
+	SampleFormatInt16   = "int16"   // little-endian signed PCM
+)
+
+// Error codes sent in "error" messages
+const (
+	ErrorCodeInvalidMessage     = "invalid_message"
+	ErrorCodeUnsupportedVersion = "unsupported_version"
+	ErrorCodeUnsupportedFormat  = "unsupported_format"
+	ErrorCodeHandshakeRequired  = "handshake_required"
+	ErrorCodeInvalidAudio       = "invalid_audio"
+	ErrorCodeNoAudio            = "no_audio"
+	ErrorCodeRecognitionFailed  = "recognition_failed"
+	ErrorCodeAudioLimit         = "audio_limit_exceeded" // further audio is ignored until "start"
+	ErrorCodeTooManySessions    = "too_many_sessions"
+	ErrorCodeServerShutdown     = "server_shutdown"
+	ErrorCodeIdleTimeout        = "idle_timeout"
+	ErrorCodeUnknownRequest     = "unknown_request"
+	ErrorCodeTooManyRequests    = "too_many_requests"
+	ErrorCodeForbidden          = "forbidden"
+)
+
+const (
+	MinSampleRate = 8000
+	MaxSampleRate = 192000
+	MaxChannels   = 2
+	// Request IDs are sent as a length byte in front of multiplexed audio frames
+	MaxRequestIDLength = 64
+)
+
+var (
+	ErrUnsupportedSampleFormat = errors.New("unsupported sample format")
+	ErrInvalidFrame            = errors.New("audio frame is not a whole number of samples")
+	ErrInvalidRequestFrame     = errors.New("audio frame is shorter than its request ID header")
+	ErrInvalidRequestID        = fmt.Errorf("request_id must be at most %d bytes", MaxRequestIDLength)
+)
+
+// ClientMessage is the envelope of every JSON text frame sent by the client. Type selects the
+// message; the other fields are only read for the message types that use them. A start message
+// with any AudioFormat field uses that format for its recognition instead of the session's.
+type ClientMessage struct {
+	Type      string `json:"type"`
+	RequestID string `json:"request_id"`
+	// start only: score the recognition once at stop and send its result with the
+	// explanation of how the candidates were scored. Admins only, not added to the history.
+	Explain bool `json:"explain"`
+	HelloMessage
+}
+
+// AudioFormat describes the binary frames of a recognition: interleaved samples in the sample
+// format, which are downmixed to mono, or a compressed stream in the codec. It is negotiated
+// by hello for the session and can be replaced for a single recognition by start.
+type AudioFormat struct {
+	Codec      string `json:"codec"`
+	SampleRate int    `json:"sample_rate"`
+	Channels   int    `json:"channels"`
+	Format     string `json:"format"`
+}
+
+// HelloMessage opens a session and negotiates the audio format of its recognitions.
+type HelloMessage struct {
+	Version int `json:"version"`
+	AudioFormat
+	Incremental bool `json:"incremental"` // send "candidate" messages while audio is arriving
+	// Keep following the playback position after the result, with "sync" updates, and
+	// identify the next song when the track changes. Recognitions run until stopped.
+	Sync bool `json:"sync"`
+	// Binary frames start with the request ID they belong to: one byte with its length, then
+	// the ID. Without multiplexing all audio belongs to the recognition without request ID.
+	Multiplex bool `json:"multiplex"`
+	// Client metadata (a JSON object, e.g. app version or device) stored with the recognition
+	// in the history of authenticated users
+	Metadata json.RawMessage `json:"metadata,omitempty"`
+}
+
+// ReadyMessage confirms the negotiated session parameters.
+type ReadyMessage struct {
+	Type      string `json:"type"`
+	SessionID string `json:"session_id"`
+	HelloMessage
+}
+
+// ResultMessage carries an interim "candidate" (incremental sessions only) or the final
+// "result" of a recognition.
+type ResultMessage struct {
+	Type      string `json:"type"`
+	SessionID string `json:"session_id"`
+	RequestID string `json:"request_id,omitempty"`
+	RecognitionResponse
+	Duration    float64      `json:"duration"`              // seconds of audio analysed
+	Explanation *Explanation `json:"explanation,omitempty"` // recognitions started with explain only
+}
+
+// SyncMessage reports where playback is in the followed song.
+type SyncMessage struct {
+	Type      string           `json:"type"`
+	SessionID string           `json:"session_id"`
+	RequestID string           `json:"request_id,omitempty"`
+	Song      *song.SongEntity `json:"song"`
+	Position  float64          `json:"position"` // seconds into the song at the end of the audio analysed
+	// Seconds the song moved against the audio since the previous update: positive when
+	// playback skipped ahead or runs fast, negative when it went back or runs slow
+	Drift    float64 `json:"drift"`
+	Score    int     `json:"score"`    // hashes aligned with the song in the last window
+	Duration float64 `json:"duration"` // seconds of audio received
+}
+
+// TrackChangedMessage tells that the followed song is no longer heard. The next song is
+// identified from the audio that follows and reported with a new "result".
+type TrackChangedMessage struct {
+	Type      string           `json:"type"`
+	SessionID string           `json:"session_id"`
+	RequestID string           `json:"request_id,omitempty"`
+	Song      *song.SongEntity `json:"song"`     // the song that stopped
+	Duration  float64          `json:"duration"` // seconds of audio received
+}
+
+type ErrorMessage struct {
+	Type      string `json:"type"`
+	SessionID string `json:"session_id,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+}
+
+// AbortedMessage confirms that a recognition was cancelled
+type AbortedMessage struct {
+	Type      string `json:"type"`
+	SessionID string `json:"session_id"`
+	RequestID string `json:"request_id"`
+}
+
+// validate checks the requested parameters and fills in the defaults: mono float32 PCM at 44.1kHz.
+func (m *HelloMessage) validate() (string, error) {
+	if m.Version != ProtocolVersion {
+		return ErrorCodeUnsupportedVersion, fmt.Errorf("protocol version %d is not supported, use %d", m.Version, ProtocolVersion)
+	}
+	if err := history.ValidateMetadata(m.Metadata); err != nil {
+		return ErrorCodeInvalidMessage, err
+	}
+	return m.AudioFormat.validate()
+}
+
+// validate checks the format and fills in the defaults: mono float32 PCM at 44.1kHz.
+func (f *AudioFormat) validate() (string, error) {
+	if f.Codec == "" {
+		f.Codec = CodecPCM
+	}
+	if f.Codec != CodecPCM {
+		if !audio.IsSupportedCodec(f.Codec) {
+			return ErrorCodeUnsupportedFormat, fmt.Errorf("%w: %q", audio.ErrUnsupportedCodec, f.Codec)
+		}
+		// Decoded by ffmpeg straight to the rate of the database
+		f.SampleRate = audio.TargetSampleRate
+		f.Channels = 1
+		f.Format = SampleFormatFloat32
+		return "", nil
+	}
+	if f.SampleRate == 0 {
+		f.SampleRate = 44100
+	}
+	if f.Channels == 0 {
+		f.Channels = 1
+	}
+	if f.Format == "" {
+		f.Format = SampleFormatFloat32
+	}
+
+	if f.SampleRate < MinSampleRate || f.SampleRate > MaxSampleRate {
+		return ErrorCodeUnsupportedFormat, fmt.Errorf("sample rate must be between %d and %d", MinSampleRate, MaxSampleRate)
+	}
+	if f.Channels < 1 || f.Channels > MaxChannels {
+		return ErrorCodeUnsupportedFormat, fmt.Errorf("channels must be between 1 and %d", MaxChannels)
+	}
+	if _, err := bytesPerSample(f.Format); err != nil {
+		return ErrorCodeUnsupportedFormat, err
+	}
+	return "", nil
+}
+
+// splitFrame returns the request ID and the audio of a multiplexed binary frame
+func splitFrame(p []byte) (string, []byte, error) {
+	if len(p) == 0 || len(p) < 1+int(p[0]) {
+		return "", nil, ErrInvalidRequestFrame
+	}
+	n := int(p[0])
+	return string(p[1 : 1+n]), p[1+n:], nil
+}
+
+func bytesPerSample(format string) (int, error) {
+	switch format {
+	case SampleFormatFloat32:
+		return 4, nil
+	case SampleFormatInt16:
+		return 2, nil
+	}
+	return 0, fmt.Errorf("%w: %q", ErrUnsupportedSampleFormat, format)
+}
+
+// decodeSamples converts a binary frame of interleaved samples to mono float samples.
+func decodeSamples(b []byte, format string, channels int) ([]float64, error) {
+	size, err := bytesPerSample(format)
+	if err != nil {
+		return nil, err
+	}
+	frameSize := size * channels
+	if len(b)%frameSize != 0 {
+		return nil, ErrInvalidFrame
+	}
+
+	samples := make([]float64, len(b)/frameSize)
+	for i := range samples {
+		sum := 0.0
+		for ch := 0; ch < channels; ch++ {
+			offset := i*frameSize + ch*size
+			switch format {
+			case SampleFormatFloat32:
+				sum += float64(math.Float32frombits(binary.LittleEndian.Uint32(b[offset:])))
+			case SampleFormatInt16:
+				sum += float64(int16(binary.LittleEndian.Uint16(b[offset:]))) / 32768
+			}
+		}
+		samples[i] = sum / float64(channels)
+	}
+	return samples, nil
+}
@@ -0,0 +1,214 @@
+package recognition
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"go-shazam/internal/auth"
+	"go-shazam/pkg/audio"
+	"math"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func dialTestServer(t *testing.T) *websocket.Conn {
+	conn, _, _ := dialTestServerWithConfig(t, &Config{})
+	return conn
+}
+
+// dialTestServerWithConfig starts a server with the given limits and returns a connection to
+// it, its handler and its URL for further connections.
+func dialTestServerWithConfig(t *testing.T, config *Config) (*websocket.Conn, *RecognitionHandler, string) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	handler := NewRecognitionHandler(newTestService(), nil, nil, config, nil)
+	RegisterRoutes(router, handler, testJWTService)
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/recognize/ws"
+	return dial(t, url), handler, url
+}
+
+func dial(t *testing.T, url string) *websocket.Conn {
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestHandleWebSocket_SelectsProtocolOfTokenClients(t *testing.T) {
+	_, _, url := dialTestServerWithConfig(t, &Config{})
+	tokens, err := testJWTService.GenerateTokenPair(uuid.New())
+	require.NoError(t, err)
+
+	dialer := websocket.Dialer{Subprotocols: []string{auth.WebSocketProtocol, "access_token." + tokens.AccessToken}}
+	conn, _, err := dialer.Dial(url, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	assert.Equal(t, auth.WebSocketProtocol, conn.Subprotocol(), "the token is never echoed")
+}
+
+func TestHelloMessage_ValidateFillsDefaults(t *testing.T) {
+	hello := HelloMessage{Version: ProtocolVersion}
+
+	code, err := hello.validate()
+
+	require.NoError(t, err)
+	assert.Empty(t, code)
+	assert.Equal(t, HelloMessage{Version: ProtocolVersion, AudioFormat: AudioFormat{Codec: CodecPCM, SampleRate: 44100, Channels: 1, Format: SampleFormatFloat32}}, hello)
+}
+
+func TestHelloMessage_ValidateRejectsUnsupported(t *testing.T) {
+	tests := []struct {
+		name  string
+		hello HelloMessage
+		code  string
+	}{
+		{"version", HelloMessage{Version: 2}, ErrorCodeUnsupportedVersion},
+		{"sample rate", HelloMessage{Version: ProtocolVersion, AudioFormat: AudioFormat{SampleRate: 1000}}, ErrorCodeUnsupportedFormat},
+		{"channels", HelloMessage{Version: ProtocolVersion, AudioFormat: AudioFormat{Channels: 6}}, ErrorCodeUnsupportedFormat},
+		{"format", HelloMessage{Version: ProtocolVersion, AudioFormat: AudioFormat{Format: "float64"}}, ErrorCodeUnsupportedFormat},
+		{"codec", HelloMessage{Version: ProtocolVersion, AudioFormat: AudioFormat{Codec: "flac"}}, ErrorCodeUnsupportedFormat},
+		{"metadata", HelloMessage{Version: ProtocolVersion, Metadata: json.RawMessage(`"phone"`)}, ErrorCodeInvalidMessage},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := tt.hello.validate()
+			assert.Error(t, err)
+			assert.Equal(t, tt.code, code)
+		})
+	}
+}
+
+func TestHelloMessage_ValidateCompressedCodec(t *testing.T) {
+	hello := HelloMessage{Version: ProtocolVersion, AudioFormat: AudioFormat{Codec: audio.CodecWebM, SampleRate: 48000, Channels: 2, Format: SampleFormatInt16}}
+
+	code, err := hello.validate()
+
+	require.NoError(t, err)
+	assert.Empty(t, code)
+	// The decoder output replaces whatever the client declared
+	assert.Equal(t, HelloMessage{Version: ProtocolVersion, AudioFormat: AudioFormat{Codec: audio.CodecWebM, SampleRate: audio.TargetSampleRate, Channels: 1, Format: SampleFormatFloat32}}, hello)
+}
+
+func TestDecodeSamples_Float32StereoIsDownmixed(t *testing.T) {
+	b := make([]byte, 16)
+	binary.LittleEndian.PutUint32(b[0:], math.Float32bits(0.5))
+	binary.LittleEndian.PutUint32(b[4:], math.Float32bits(-0.5))
+	binary.LittleEndian.PutUint32(b[8:], math.Float32bits(1))
+	binary.LittleEndian.PutUint32(b[12:], math.Float32bits(0.5))
+
+	samples, err := decodeSamples(b, SampleFormatFloat32, 2)
+
+	require.NoError(t, err)
+	assert.Equal(t, []float64{0, 0.75}, samples)
+}
+
+func TestDecodeSamples_Int16(t *testing.T) {
+	b := make([]byte, 6)
+	binary.LittleEndian.PutUint16(b[0:], uint16(16384))
+	binary.LittleEndian.PutUint16(b[2:], uint16(0x8000)) // -32768
+	binary.LittleEndian.PutUint16(b[4:], 0)
+
+	samples, err := decodeSamples(b, SampleFormatInt16, 1)
+
+	require.NoError(t, err)
+	assert.Equal(t, []float64{0.5, -1, 0}, samples)
+}
+
+func TestDecodeSamples_PartialFrame(t *testing.T) {
+	_, err := decodeSamples(make([]byte, 6), SampleFormatInt16, 2)
+
+	assert.ErrorIs(t, err, ErrInvalidFrame)
+}
+
+func TestSession_Handshake(t *testing.T) {
+	conn := dialTestServer(t)
+
+	require.NoError(t, conn.WriteJSON(gin.H{"type": "hello", "version": 1, "sample_rate": 48000, "format": "int16"}))
+
+	var ready ReadyMessage
+	require.NoError(t, conn.ReadJSON(&ready))
+	assert.Equal(t, MessageTypeReady, ready.Type)
+	assert.NotEmpty(t, ready.SessionID)
+	assert.Equal(t, HelloMessage{Version: 1, AudioFormat: AudioFormat{Codec: CodecPCM, SampleRate: 48000, Channels: 1, Format: SampleFormatInt16}}, ready.HelloMessage)
+
+	require.NoError(t, conn.WriteJSON(gin.H{"type": "stop"}))
+
+	var errMsg ErrorMessage
+	require.NoError(t, conn.ReadJSON(&errMsg))
+	assert.Equal(t, ErrorMessage{Type: MessageTypeError, SessionID: ready.SessionID, Code: ErrorCodeNoAudio, Message: "no audio data received"}, errMsg)
+}
+
+func TestSession_AudioBeforeHello(t *testing.T) {
+	conn := dialTestServer(t)
+
+	require.NoError(t, conn.WriteJSON(gin.H{"type": "start"}))
+
+	var errMsg ErrorMessage
+	require.NoError(t, conn.ReadJSON(&errMsg))
+	assert.Equal(t, ErrorCodeHandshakeRequired, errMsg.Code)
+
+	require.NoError(t, conn.WriteMessage(websocket.BinaryMessage, make([]byte, 8)))
+	require.NoError(t, conn.ReadJSON(&errMsg))
+	assert.Equal(t, ErrorCodeHandshakeRequired, errMsg.Code)
+}
+
+func TestSession_UnknownMessage(t *testing.T) {
+	conn := dialTestServer(t)
+
+	require.NoError(t, conn.WriteJSON(gin.H{"type": "rewind"}))
+
+	var errMsg ErrorMessage
+	require.NoError(t, conn.ReadJSON(&errMsg))
+	assert.Equal(t, ErrorCodeInvalidMessage, errMsg.Code)
+}
+
+func TestLegacyProtocol(t *testing.T) {
+	conn := dialTestServer(t)
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("start:48000")))
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("stop")))
+
+	var response map[string]string
+	require.NoError(t, conn.ReadJSON(&response))
+	assert.Equal(t, map[string]string{"error": "no audio data received"}, response)
+}
+
+func TestSession_CompressedStream(t *testing.T) {
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		t.Skip("ffmpeg is not installed")
+	}
+	data, err := os.ReadFile("../audio/testdata/silence.webm")
+	require.NoError(t, err)
+
+	conn := dialTestServer(t)
+	require.NoError(t, conn.WriteJSON(gin.H{"type": "hello", "version": 1, "codec": "webm"}))
+
+	var ready ReadyMessage
+	require.NoError(t, conn.ReadJSON(&ready))
+	require.Equal(t, MessageTypeReady, ready.Type)
+
+	for start := 0; start < len(data); start += 200 {
+		require.NoError(t, conn.WriteMessage(websocket.BinaryMessage, data[start:min(start+200, len(data))]))
+	}
+	require.NoError(t, conn.WriteJSON(gin.H{"type": "stop"}))
+
+	// Silence decodes fine but yields no fingerprints to look up
+	var result ResultMessage
+	require.NoError(t, conn.ReadJSON(&result))
+	assert.Equal(t, MessageTypeResult, result.Type)
+	assert.False(t, result.Found)
+	assert.InDelta(t, 2.0, result.Duration, 0.1)
+}
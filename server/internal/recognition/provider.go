@@ -1,12 +1,47 @@
 package recognition
 
-import "go.uber.org/fx"
+import (
+	"context"
+	"fmt"
+	"go-shazam/internal/queue"
+
+	"go.uber.org/fx"
+)
 
 var Module = fx.Module("recognition",
-	fx.Provide(NewRecognitionService),
+	fx.Provide(LoadConfig, NewCalibrationRepository, NewRecognitionService, NewUploadService, NewCalibrationService, NewMediaFetcher, NewURLService),
 )
 
 var HttpModule = fx.Module("recognition-http",
-	fx.Provide(NewRecognitionHandler),
-	fx.Invoke(RegisterRoutes),
+	fx.Provide(NewRecognitionHandler, NewCalibrationHandler, NewURLHandler),
+	fx.Invoke(RegisterRoutes, RegisterCalibrationRoutes, RegisterURLRoutes, registerWebSocketLifecycle),
 )
+
+var QueueModule = fx.Module("recognition-queue",
+	fx.Provide(NewRecognizeUploadTaskHandler, NewRecognizeURLTaskHandler, NewCalibrateTaskHandler),
+	fx.Invoke(func(w queue.WorkerServer, h *RecognizeUploadTaskHandler) {
+		fmt.Printf("[Queue] Registering handler for task type: %s\n", RecognizeUploadTaskType)
+		w.RegisterServiceHandler(RecognizeUploadTaskType, h)
+	}),
+	fx.Invoke(func(w queue.WorkerServer, h *RecognizeURLTaskHandler) {
+		fmt.Printf("[Queue] Registering handler for task type: %s\n", RecognizeURLTaskType)
+		w.RegisterServiceHandler(RecognizeURLTaskType, h)
+	}),
+	fx.Invoke(func(w queue.WorkerServer, s queue.Scheduler, h *CalibrateTaskHandler, config *Config) error {
+		fmt.Printf("[Queue] Registering handler for task type: %s\n", CalibrateTaskType)
+		w.RegisterServiceHandler(CalibrateTaskType, h)
+		_, err := s.RegisterOnce(config.CalibrationCron, CalibrateTaskType, nil)
+		return err
+	}),
+)
+
+// registerWebSocketLifecycle drains the recognition WebSocket sessions on shutdown. The HTTP
+// server does not track hijacked connections, so its Shutdown does not wait for them.
+func registerWebSocketLifecycle(lc fx.Lifecycle, h *RecognitionHandler) {
+	lc.Append(fx.Hook{
+		OnStop: func(ctx context.Context) error {
+			fmt.Println("Draining recognition sessions...")
+			return h.Shutdown(ctx)
+		},
+	})
+}
@@ -0,0 +1,175 @@
+package recognition
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// guardedProxy is an HTTP proxy on the loopback interface through which yt-dlp downloads
+// media pages. yt-dlp resolves names and follows redirects on its own; going through the
+// proxy puts every address it connects to through the same dialer as direct downloads.
+type guardedProxy struct {
+	listener  net.Listener
+	server    *http.Server
+	dial      func(ctx context.Context, network string, address string) (net.Conn, error)
+	transport *http.Transport
+	refused   atomic.Bool // a request was refused because it led to a private address
+
+	mu      sync.Mutex
+	tunnels map[net.Conn]struct{} // hijacked connections, which server.Close does not track
+}
+
+func startGuardedProxy(dial func(ctx context.Context, network string, address string) (net.Conn, error)) (*guardedProxy, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to start download proxy: %w", err)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dial
+
+	p := &guardedProxy{
+		listener:  listener,
+		dial:      dial,
+		transport: transport,
+		tunnels:   make(map[net.Conn]struct{}),
+	}
+	p.server = &http.Server{Handler: p, ReadHeaderTimeout: 10 * time.Second}
+	go p.server.Serve(listener)
+	return p, nil
+}
+
+// URL is the proxy address to hand to the downloader
+func (p *guardedProxy) URL() string {
+	return "http://" + p.listener.Addr().String()
+}
+
+// Close stops the proxy and every connection still open through it.
+func (p *guardedProxy) Close() error {
+	err := p.server.Close()
+	p.transport.CloseIdleConnections()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for conn := range p.tunnels {
+		conn.Close()
+	}
+	p.tunnels = nil
+	return err
+}
+
+func (p *guardedProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodConnect {
+		p.tunnel(w, r)
+		return
+	}
+	if !r.URL.IsAbs() {
+		http.Error(w, "only proxy requests are served", http.StatusBadRequest)
+		return
+	}
+
+	out := r.Clone(r.Context())
+	out.RequestURI = ""
+	out.Header.Del("Proxy-Connection")
+	out.Header.Del("Proxy-Authorization")
+
+	resp, err := p.transport.RoundTrip(out)
+	if err != nil {
+		p.fail(w, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	for key, values := range resp.Header {
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
+
+// tunnel connects an HTTPS request to its destination and copies bytes both ways
+func (p *guardedProxy) tunnel(w http.ResponseWriter, r *http.Request) {
+	upstream, err := p.dial(r.Context(), "tcp", r.Host)
+	if err != nil {
+		p.fail(w, err)
+		return
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		upstream.Close()
+		http.Error(w, "tunnelling is not supported", http.StatusInternalServerError)
+		return
+	}
+	client, _, err := hijacker.Hijack()
+	if err != nil {
+		upstream.Close()
+		return
+	}
+	if !p.track(client, upstream) {
+		return
+	}
+	defer p.untrack(client, upstream)
+
+	if _, err := io.WriteString(client, "HTTP/1.1 200 Connection established\r\n\r\n"); err != nil {
+		return
+	}
+
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(upstream, client)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(client, upstream)
+		done <- struct{}{}
+	}()
+	// Either side closing ends the tunnel
+	<-done
+}
+
+// track registers the connections of a tunnel, closing them if the proxy already stopped
+func (p *guardedProxy) track(conns ...net.Conn) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.tunnels == nil {
+		for _, conn := range conns {
+			conn.Close()
+		}
+		return false
+	}
+	for _, conn := range conns {
+		p.tunnels[conn] = struct{}{}
+	}
+	return true
+}
+
+func (p *guardedProxy) untrack(conns ...net.Conn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, conn := range conns {
+		conn.Close()
+		delete(p.tunnels, conn)
+	}
+}
+
+func (p *guardedProxy) fail(w http.ResponseWriter, err error) {
+	if errors.Is(err, ErrPrivateAddress) {
+		p.refused.Store(true)
+		http.Error(w, ErrPrivateAddress.Error(), http.StatusForbidden)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusBadGateway)
+}
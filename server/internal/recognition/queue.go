@@ -0,0 +1,157 @@
+package recognition
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-shazam/internal/history"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+)
+
+const (
+	RecognizeUploadTaskType = "recognition:recognize_upload"
+	RecognizeURLTaskType    = "recognition:recognize_url"
+	CalibrateTaskType       = "recognition:calibrate"
+)
+
+type RecognizeUploadTaskPayload struct {
+	File     string          `json:"file"`               // name of the stored upload in the upload directory
+	UserID   uuid.UUID       `json:"user_id"`            // uuid.Nil for anonymous uploads
+	Metadata json.RawMessage `json:"metadata,omitempty"` // client metadata for the history
+}
+
+type RecognizeUploadTaskHandler struct {
+	uploadService  *UploadService
+	historyService *history.HistoryService
+}
+
+func NewRecognizeUploadTaskHandler(uploadService *UploadService, historyService *history.HistoryService) *RecognizeUploadTaskHandler {
+	return &RecognizeUploadTaskHandler{uploadService: uploadService, historyService: historyService}
+}
+
+func (h *RecognizeUploadTaskHandler) ProcessTask(ctx context.Context, task *asynq.Task) error {
+	fmt.Printf("[Worker] Received task: %s\n", task.Type())
+
+	var payload RecognizeUploadTaskPayload
+	if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+		fmt.Printf("[Worker] Failed to unmarshal payload: %v\n", err)
+		return fmt.Errorf("failed to unmarshal payload: %w", err)
+	}
+
+	result, err := h.uploadService.RecognizeFile(ctx, payload.File)
+	if err != nil {
+		fmt.Printf("[Worker] Failed to recognise upload %s: %v\n", payload.File, err)
+		if errors.Is(err, ErrUnsupportedAudio) || errors.Is(err, ErrAudioTooShort) {
+			h.removeFile(payload.File)
+			return fmt.Errorf("failed to recognise upload: %v: %w", err, asynq.SkipRetry)
+		}
+		// Keep the file for the next attempt
+		if retried, _ := asynq.GetRetryCount(ctx); retried >= uploadMaxRetry {
+			h.removeFile(payload.File)
+		}
+		return fmt.Errorf("failed to recognise upload: %w", err)
+	}
+	h.removeFile(payload.File)
+	recordHistory(ctx, h.historyService, payload.UserID, *result, payload.Metadata)
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to marshal result: %w", err)
+	}
+	if _, err := task.ResultWriter().Write(data); err != nil {
+		return fmt.Errorf("failed to write result: %w", err)
+	}
+
+	fmt.Printf("[Worker] Recognised upload %s, found: %t\n", payload.File, result.Found)
+	return nil
+}
+
+func (h *RecognizeUploadTaskHandler) removeFile(filename string) {
+	if err := h.uploadService.RemoveFile(filename); err != nil {
+		fmt.Printf("[Worker] Failed to remove upload %s: %v\n", filename, err)
+	}
+}
+
+type RecognizeURLTaskPayload struct {
+	URL string `json:"url"`
+}
+
+type RecognizeURLTaskHandler struct {
+	urlService *URLService
+}
+
+func NewRecognizeURLTaskHandler(urlService *URLService) *RecognizeURLTaskHandler {
+	return &RecognizeURLTaskHandler{urlService: urlService}
+}
+
+func (h *RecognizeURLTaskHandler) ProcessTask(ctx context.Context, task *asynq.Task) error {
+	fmt.Printf("[Worker] Received task: %s\n", task.Type())
+
+	var payload RecognizeURLTaskPayload
+	if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+		fmt.Printf("[Worker] Failed to unmarshal payload: %v\n", err)
+		return fmt.Errorf("failed to unmarshal payload: %w", err)
+	}
+
+	timeline, err := h.urlService.RecognizeURL(ctx, payload.URL)
+	if err != nil {
+		fmt.Printf("[Worker] Failed to recognise %s: %v\n", payload.URL, err)
+		if isPermanentURLError(err) {
+			return fmt.Errorf("failed to recognise URL: %v: %w", err, asynq.SkipRetry)
+		}
+		return fmt.Errorf("failed to recognise URL: %w", err)
+	}
+
+	data, err := json.Marshal(timeline)
+	if err != nil {
+		return fmt.Errorf("failed to marshal result: %w", err)
+	}
+	if _, err := task.ResultWriter().Write(data); err != nil {
+		return fmt.Errorf("failed to write result: %w", err)
+	}
+
+	fmt.Printf("[Worker] Recognised %s, %d segments\n", payload.URL, len(timeline.Segments))
+	return nil
+}
+
+// isPermanentURLError reports whether retrying the URL cannot succeed
+func isPermanentURLError(err error) bool {
+	for _, target := range []error{
+		ErrInvalidMediaURL, ErrMediaTooLarge, ErrMediaUnavailable, ErrPrivateAddress, ErrUnsupportedMedia,
+		ErrUnsupportedAudio, ErrAudioTooShort, ErrAudioTooLong, ErrNoFingerprints,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+type CalibrateTaskHandler struct {
+	calibrationService *CalibrationService
+}
+
+func NewCalibrateTaskHandler(calibrationService *CalibrationService) *CalibrateTaskHandler {
+	return &CalibrateTaskHandler{calibrationService: calibrationService}
+}
+
+func (h *CalibrateTaskHandler) ProcessTask(ctx context.Context, task *asynq.Task) error {
+	fmt.Printf("[Worker] Received task: %s\n", task.Type())
+
+	calibration, err := h.calibrationService.Refresh(ctx)
+	if err != nil {
+		if errors.Is(err, ErrCatalogTooSmall) || errors.Is(err, ErrCalibrationRejected) {
+			fmt.Printf("[Worker] Skipping calibration: %v\n", err)
+			return fmt.Errorf("failed to calibrate thresholds: %v: %w", err, asynq.SkipRetry)
+		}
+		fmt.Printf("[Worker] Failed to calibrate thresholds: %v\n", err)
+		return fmt.Errorf("failed to calibrate thresholds: %w", err)
+	}
+
+	fmt.Printf("[Worker] Calibrated thresholds: min score %d, min ratio %.4f (false-positive rate %.3f, was %.3f with the defaults; recall %.3f)\n",
+		calibration.Thresholds.MinAbsoluteScore, calibration.Thresholds.MinScoreRatio, calibration.AchievedFPR, calibration.DefaultFPR, calibration.Recall)
+	return nil
+}
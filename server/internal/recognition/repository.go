@@ -0,0 +1,60 @@
+package recognition
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"go-shazam/internal/core/db"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CalibrationRepository stores the calibrations; the latest one sets the thresholds
+type CalibrationRepository struct {
+	db *db.Repository
+}
+
+func NewCalibrationRepository(db *db.Repository) *CalibrationRepository {
+	return &CalibrationRepository{db: db}
+}
+
+type calibrationRow struct {
+	ComputedAt time.Time `db:"computed_at"`
+	Payload    []byte    `db:"payload"`
+}
+
+// FindLatest returns the latest calibration or nil if none has run yet.
+func (r *CalibrationRepository) FindLatest(ctx context.Context) (*Calibration, error) {
+	query := "SELECT computed_at, payload FROM recognition_calibrations ORDER BY computed_at DESC LIMIT 1"
+	var row calibrationRow
+	if err := r.db.Connection(ctx).GetContext(ctx, &row, query); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var calibration Calibration
+	if err := json.Unmarshal(row.Payload, &calibration); err != nil {
+		return nil, err
+	}
+	return &calibration, nil
+}
+
+func (r *CalibrationRepository) Save(ctx context.Context, calibration *Calibration) error {
+	payload, err := json.Marshal(calibration)
+	if err != nil {
+		return err
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return err
+	}
+
+	query := "INSERT INTO recognition_calibrations (id, computed_at, payload) VALUES ($1, $2, $3)"
+	_, err = r.db.Connection(ctx).ExecContext(ctx, query, id, calibration.ComputedAt, payload)
+	return err
+}
@@ -3,52 +3,130 @@ package recognition
 import (
 	"context"
 	"fmt"
-	"go-shazam/internal/audio"
 	"go-shazam/internal/fingerprint"
 	"go-shazam/internal/logger"
 	"go-shazam/internal/song"
+	"go-shazam/pkg/audio"
+	"go-shazam/pkg/landmark"
 	"math"
+	"sort"
 
 	"github.com/google/uuid"
 )
 
 const (
-	TimeBinResolution = 20    // 50ms bins (1/0.05)
-	MinAbsoluteScore  = 5     // Minimum absolute score to consider a match
-	MinScoreRatio     = 0.015 // Minimum score as ratio of sample hashes (1.5%)
+	TimeBinResolution   = 20    // 50ms bins (1/0.05)
+	MinAbsoluteScore    = 5     // Minimum absolute score to consider a match, until calibrated
+	MinScoreRatio       = 0.015 // Minimum score as ratio of sample hashes (1.5%), until calibrated
+	FullConfidenceRatio = 0.05  // Share of aligned sample hashes that counts as full coverage (5%)
 )
 
 type RecognitionService struct {
+	config             *Config
 	fingerprintService *fingerprint.FingerprintService
 	songRepository     song.SongRepositoryInterface
+	thresholds         *thresholdCache
 }
 
+// NewRecognitionService creates the service. Without a calibration repository the default
+// thresholds apply.
 func NewRecognitionService(
+	config *Config,
 	fingerprintService *fingerprint.FingerprintService,
 	songRepository song.SongRepositoryInterface,
+	calibrationRepository *CalibrationRepository,
 ) *RecognitionService {
 	return &RecognitionService{
+		config:             config,
 		fingerprintService: fingerprintService,
 		songRepository:     songRepository,
+		thresholds:         newThresholdCache(calibrationRepository, config.ThresholdRefresh),
 	}
 }
 
+// Thresholds returns the thresholds currently applied to candidates.
+func (s *RecognitionService) Thresholds(ctx context.Context) Thresholds {
+	return s.thresholds.get(ctx)
+}
+
 // TODO: return song metadata instead of song entity
 type MatchResult struct {
-	Song       *song.SongEntity `json:"song"`
-	TimeOffset float64          `json:"time_offset"`
-	Score      int              `json:"score"`
+	Song          *song.SongEntity `json:"song"`
+	TimeOffset    float64          `json:"time_offset"`   // where the query starts in the track, in seconds
+	Position      float64          `json:"position"`      // playback position at the end of the query
+	MatchedRange  TimeRange        `json:"matched_range"` // track time covered by the aligned hashes
+	Score         int              `json:"score"`
+	Confidence    float64          `json:"confidence"`
+	MatchedHashes int              `json:"matched_hashes"`
 }
 
-// IdentifySong processes audio fragments and returns the best matching song.
-func (s *RecognitionService) IdentifySong(ctx context.Context, fragments []audio.ProcessedFragment, sampleRate int) (*MatchResult, error) {
-	log := logger.FromContext(ctx)
+type TimeRange struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+}
+
+// candidate is the scoring state of a single song
+type candidate struct {
+	songID        uuid.UUID
+	score         int     // count in the best offset bin
+	offset        float64 // mean track-minus-query offset of the pairs in the best bin
+	matchedRange  TimeRange
+	matchedHashes int // matched (sample, database) hash pairs across all bins
+}
+
+// offsetBin accumulates the pairs that agree on one track-minus-query offset
+type offsetBin struct {
+	count   int
+	sumDiff float64
+	minRef  float64
+	maxRef  float64
+}
 
+// IdentifySong processes audio fragments and returns the best matching songs, best first.
+// An empty result means that no candidate passed the threshold.
+func (s *RecognitionService) IdentifySong(ctx context.Context, fragments []audio.ProcessedFragment, sampleRate int) ([]MatchResult, error) {
 	// Generate fingerprints from sample (use Nil UUID since we don't know the song)
 	sampleHashes := s.fingerprintService.CreateFingerprints(fragments, uuid.Nil, sampleRate)
+	return s.IdentifyHashes(ctx, sampleHashes, queryDuration(fragments, sampleRate))
+}
+
+// IdentifySamples recognises samples at audio.TargetSampleRate and reports the quality of
+// the query along with the result. Audio without any fingerprints is not found rather than
+// an error, so that the diagnostics can tell the user why.
+func (s *RecognitionService) IdentifySamples(ctx context.Context, samples []float64) (*RecognitionResponse, error) {
+	fragments, err := audio.ProcessAudio(samples, audio.TargetSampleRate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to process audio: %w", err)
+	}
+
+	var quality queryQuality
+	quality.addSamples(samples)
+	quality.addFragments(fragments)
+	peaks := landmark.ExtractPeaks(fragments, audio.TargetSampleRate)
+	quality.addPeaks(len(peaks))
+	sampleHashes := landmark.CreateHashes(peaks, uuid.Nil)
+
+	var matches []MatchResult
+	if len(sampleHashes) > 0 {
+		matches, err = s.IdentifyHashes(ctx, sampleHashes, queryDuration(fragments, audio.TargetSampleRate))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	response := NewRecognitionResponse(matches)
+	diagnostics := quality.diagnostics(len(sampleHashes))
+	response.Diagnostics = &diagnostics
+	return &response, nil
+}
+
+// IdentifyHashes scores precomputed sample hashes against the database. duration is the
+// length of the query in seconds and is used to report the playback position.
+func (s *RecognitionService) IdentifyHashes(ctx context.Context, sampleHashes []fingerprint.Hash, duration float64) ([]MatchResult, error) {
+	log := logger.FromContext(ctx)
 
 	if len(sampleHashes) == 0 {
-		return nil, fmt.Errorf("no fingerprints generated from audio")
+		return nil, ErrNoFingerprints
 	}
 
 	dbHashes, err := s.fingerprintService.GetMatchingHashes(ctx, sampleHashes)
@@ -61,17 +139,82 @@ func (s *RecognitionService) IdentifySong(ctx context.Context, fragments []audio
 		return nil, nil
 	}
 
+	return s.rankMatches(ctx, sampleHashes, dbHashes, duration, s.thresholds.get(ctx))
+}
+
+// rankMatches scores the sample against the database hashes it matched and resolves the
+// candidates that pass the thresholds and the temporal verification.
+func (s *RecognitionService) rankMatches(ctx context.Context, sampleHashes []fingerprint.Hash, dbHashes []fingerprint.Hash, duration float64, thresholds Thresholds) ([]MatchResult, error) {
+	log := logger.FromContext(ctx)
+
+	candidates := scoreCandidates(sampleHashes, dbHashes)
+
+	bestScore := 0
+	if len(candidates) > 0 {
+		bestScore = candidates[0].score
+	}
+	log.Info("Recognition analysis complete",
+		"bestScore", bestScore,
+		"totalCandidates", len(candidates),
+		"sampleHashes", len(sampleHashes),
+	)
+
+	minThreshold := thresholds.matchThreshold(len(sampleHashes))
+	if bestScore < minThreshold {
+		log.Info("Score below threshold",
+			"bestScore", bestScore,
+			"threshold", minThreshold,
+		)
+		return nil, nil
+	}
+
+	verified := verifyCandidates(sampleHashes, dbHashes, candidates, minThreshold, s.config.MaxCandidates)
+	if rejected := len(candidates) - len(verified); rejected > 0 {
+		log.Info("Candidates rejected by temporal verification", "rejected", rejected)
+	}
+	candidates = verified
+
+	var results []MatchResult
+	for i, c := range candidates {
+		if c.score < minThreshold || len(results) >= s.config.MaxCandidates {
+			break
+		}
+
+		// Fetch song details
+		songEntity, err := s.songRepository.FindByID(ctx, c.songID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find song %s: %w", c.songID, err)
+		}
+		if songEntity == nil {
+			continue
+		}
+
+		results = append(results, MatchResult{
+			Song:          songEntity,
+			TimeOffset:    c.offset,
+			Position:      c.offset + duration,
+			MatchedRange:  c.matchedRange,
+			Score:         c.score,
+			Confidence:    confidence(c.score, competitorScore(candidates, i), len(sampleHashes)),
+			MatchedHashes: c.matchedHashes,
+		})
+	}
+
+	return results, nil
+}
+
+// scoreCandidates builds an offset histogram per song and returns the songs ordered by
+// their best bin count. The best bin of a song is where the query lines up with the track.
+func scoreCandidates(sampleHashes []fingerprint.Hash, dbHashes []fingerprint.Hash) []candidate {
 	sampleHashMap := make(map[int64][]float64)
 	for _, h := range sampleHashes {
 		sampleHashMap[h.HashValue] = append(sampleHashMap[h.HashValue], h.TimeOffset)
 	}
 
-	// scores[songID][timeBin] = count
-	scores := make(map[uuid.UUID]map[int]int)
-
-	bestScore := 0
-	var bestSongID uuid.UUID
-	var bestTimeOffset float64
+	// scores[songID][timeBin] = pairs aligned at that offset
+	scores := make(map[uuid.UUID]map[int]*offsetBin)
+	bySong := make(map[uuid.UUID]*candidate)
+	bestBins := make(map[uuid.UUID]*offsetBin)
 
 	for _, dbHash := range dbHashes {
 		sampleOffsets, ok := sampleHashMap[dbHash.HashValue]
@@ -79,54 +222,85 @@ func (s *RecognitionService) IdentifySong(ctx context.Context, fragments []audio
 			continue
 		}
 
+		c, ok := bySong[dbHash.SongID]
+		if !ok {
+			c = &candidate{songID: dbHash.SongID}
+			bySong[dbHash.SongID] = c
+			scores[dbHash.SongID] = make(map[int]*offsetBin)
+		}
+
 		for _, sampleOffset := range sampleOffsets {
 			diff := dbHash.TimeOffset - sampleOffset
-
 			bin := int(math.Round(diff * TimeBinResolution)) // 50 ms resolution
 
-			if scores[dbHash.SongID] == nil {
-				scores[dbHash.SongID] = make(map[int]int)
+			b, ok := scores[dbHash.SongID][bin]
+			if !ok {
+				b = &offsetBin{minRef: dbHash.TimeOffset, maxRef: dbHash.TimeOffset}
+				scores[dbHash.SongID][bin] = b
 			}
+			b.count++
+			b.sumDiff += diff
+			b.minRef = math.Min(b.minRef, dbHash.TimeOffset)
+			b.maxRef = math.Max(b.maxRef, dbHash.TimeOffset)
 
-			scores[dbHash.SongID][bin]++
-			count := scores[dbHash.SongID][bin]
-
-			if count > bestScore {
-				bestScore = count
-				bestSongID = dbHash.SongID
-				bestTimeOffset = dbHash.TimeOffset
+			c.matchedHashes++
+			if b.count > c.score {
+				c.score = b.count
+				bestBins[dbHash.SongID] = b
 			}
 		}
 	}
 
-	log.Info("Recognition analysis complete",
-		"bestScore", bestScore,
-		"totalCandidates", len(scores),
-		"sampleHashes", len(sampleHashes),
-	)
+	candidates := make([]candidate, 0, len(bySong))
+	for songID, c := range bySong {
+		b := bestBins[songID]
+		c.offset = b.sumDiff / float64(b.count)
+		c.matchedRange = TimeRange{Start: b.minRef, End: b.maxRef}
+		candidates = append(candidates, *c)
+	}
 
-	// Adaptive threshold: max(MinAbsoluteScore, sampleHashes * MinScoreRatio)
-	minThreshold := max(MinAbsoluteScore, int(float64(len(sampleHashes))*MinScoreRatio))
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].score != candidates[j].score {
+			return candidates[i].score > candidates[j].score
+		}
+		return candidates[i].songID.String() < candidates[j].songID.String()
+	})
 
-	if bestScore < minThreshold {
-		log.Info("Score below threshold",
-			"bestScore", bestScore,
-			"threshold", minThreshold,
-		)
-		return nil, nil
+	return candidates
+}
+
+// queryDuration returns the length in seconds of the audio the fragments were taken from.
+func queryDuration(fragments []audio.ProcessedFragment, sampleRate int) float64 {
+	if len(fragments) == 0 || sampleRate <= 0 {
+		return 0
 	}
+	return fragments[len(fragments)-1].TimeOffset + float64(audio.WindowSize)/float64(sampleRate)
+}
 
-	// Fetch song details
-	songEntity, err := s.songRepository.FindByID(ctx, bestSongID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to find song %s: %w", bestSongID, err)
+// competitorScore returns the best score among the other candidates: the runner-up for
+// the winner, the winner for everyone else.
+func competitorScore(candidates []candidate, index int) int {
+	if index == 0 {
+		if len(candidates) > 1 {
+			return candidates[1].score
+		}
+		return 0
 	}
+	return candidates[0].score
+}
+
+// confidence maps a raw score to [0, 1]. Coverage measures how much of the sample aligned
+// with the candidate; separation measures how clearly it beats its strongest competitor.
+// A candidate tied with another song gets at most half of its coverage.
+func confidence(score int, competitorScore int, sampleHashes int) float64 {
+	if score <= 0 || sampleHashes <= 0 {
+		return 0
+	}
+
+	coverage := math.Min(1, float64(score)/(float64(sampleHashes)*FullConfidenceRatio))
+	separation := math.Max(0, 1-float64(competitorScore)/float64(score))
 
-	return &MatchResult{
-		Song:       songEntity,
-		TimeOffset: bestTimeOffset,
-		Score:      bestScore,
-	}, nil
+	return coverage * (0.5 + 0.5*separation)
 }
 
 func max(a, b int) int {
@@ -0,0 +1,174 @@
+package recognition
+
+import (
+	"context"
+	"go-shazam/internal/fingerprint"
+	"go-shazam/internal/song"
+	"go-shazam/pkg/audio"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubSongRepository serves songs from memory; other repository methods are not used by scoring
+type stubSongRepository struct {
+	song.SongRepositoryInterface
+	songs map[uuid.UUID]*song.SongEntity
+}
+
+func (r *stubSongRepository) FindByID(ctx context.Context, id uuid.UUID) (*song.SongEntity, error) {
+	return r.songs[id], nil
+}
+
+func newTestService(songs ...*song.SongEntity) *RecognitionService {
+	repository := &stubSongRepository{songs: make(map[uuid.UUID]*song.SongEntity)}
+	for _, s := range songs {
+		repository.songs[s.ID] = s
+	}
+	return NewRecognitionService(&Config{MaxCandidates: 5}, nil, repository, nil)
+}
+
+// alignedHashes returns n sample hashes and their database counterparts shifted by offset seconds
+func alignedHashes(songID uuid.UUID, n int, offset float64, firstHash int64) ([]fingerprint.Hash, []fingerprint.Hash) {
+	var sample, db []fingerprint.Hash
+	for i := 0; i < n; i++ {
+		t := float64(i) * 0.1
+		sample = append(sample, fingerprint.Hash{HashValue: firstHash + int64(i), TimeOffset: t})
+		db = append(db, fingerprint.Hash{HashValue: firstHash + int64(i), SongID: songID, TimeOffset: t + offset})
+	}
+	return sample, db
+}
+
+func TestScoreCandidates_RanksByAlignedBin(t *testing.T) {
+	strong := uuid.New()
+	weak := uuid.New()
+
+	sample, strongDB := alignedHashes(strong, 20, 30.0, 1000)
+	weakSample, weakDB := alignedHashes(weak, 8, 12.0, 5000)
+	sample = append(sample, weakSample...)
+
+	candidates := scoreCandidates(sample, append(strongDB, weakDB...))
+
+	require.Len(t, candidates, 2)
+	assert.Equal(t, strong, candidates[0].songID)
+	assert.Equal(t, 20, candidates[0].score)
+	assert.Equal(t, 20, candidates[0].matchedHashes)
+	assert.Equal(t, weak, candidates[1].songID)
+	assert.Equal(t, 8, candidates[1].score)
+}
+
+func TestScoreCandidates_UnalignedMatchesDoNotAccumulate(t *testing.T) {
+	songID := uuid.New()
+
+	var sample, db []fingerprint.Hash
+	for i := 0; i < 10; i++ {
+		sample = append(sample, fingerprint.Hash{HashValue: int64(i), TimeOffset: float64(i) * 0.1})
+		// Every match lands in a different offset bin
+		db = append(db, fingerprint.Hash{HashValue: int64(i), SongID: songID, TimeOffset: float64(i) * 1.3})
+	}
+
+	candidates := scoreCandidates(sample, db)
+
+	require.Len(t, candidates, 1)
+	assert.Equal(t, 1, candidates[0].score)
+	assert.Equal(t, 10, candidates[0].matchedHashes)
+}
+
+func TestScoreCandidates_ReportsAlignedOffset(t *testing.T) {
+	songID := uuid.New()
+
+	// The query starts 42.3 s into the track; the database offsets are deliberately far from
+	// the query start so that reporting a raw database offset would be wrong.
+	sample, db := alignedHashes(songID, 50, 42.3, 1000)
+
+	candidates := scoreCandidates(sample, db)
+
+	require.Len(t, candidates, 1)
+	assert.InDelta(t, 42.3, candidates[0].offset, 1e-9)
+	assert.InDelta(t, 42.3, candidates[0].matchedRange.Start, 1e-9)
+	assert.InDelta(t, 42.3+4.9, candidates[0].matchedRange.End, 1e-9)
+}
+
+func TestScoreCandidates_OffsetAveragesJitterWithinBin(t *testing.T) {
+	songID := uuid.New()
+
+	var sample, db []fingerprint.Hash
+	jitter := []float64{-0.01, 0.01, -0.02, 0.02, 0}
+	for i, j := range jitter {
+		q := float64(i)
+		sample = append(sample, fingerprint.Hash{HashValue: int64(i), TimeOffset: q})
+		db = append(db, fingerprint.Hash{HashValue: int64(i), SongID: songID, TimeOffset: q + 95.5 + j})
+	}
+	// A stray match at an unrelated position must not move the reported offset
+	sample = append(sample, fingerprint.Hash{HashValue: 99, TimeOffset: 1})
+	db = append(db, fingerprint.Hash{HashValue: 99, SongID: songID, TimeOffset: 10})
+
+	candidates := scoreCandidates(sample, db)
+
+	require.Len(t, candidates, 1)
+	assert.Equal(t, 5, candidates[0].score)
+	assert.Equal(t, 6, candidates[0].matchedHashes)
+	assert.InDelta(t, 95.5, candidates[0].offset, 1e-9)
+	assert.InDelta(t, 95.5-0.01, candidates[0].matchedRange.Start, 1e-9)
+	assert.InDelta(t, 99.5, candidates[0].matchedRange.End, 1e-9)
+}
+
+func TestRankMatches_ReportsPlaybackPosition(t *testing.T) {
+	track := &song.SongEntity{ID: uuid.New(), Title: "Track"}
+	sample, db := alignedHashes(track.ID, 50, 61.0, 1000)
+
+	results, err := newTestService(track).rankMatches(context.Background(), sample, db, 5.0, DefaultThresholds)
+
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, track, results[0].Song)
+	assert.InDelta(t, 61.0, results[0].TimeOffset, 1e-9)
+	assert.InDelta(t, 66.0, results[0].Position, 1e-9)
+	assert.InDelta(t, 61.0, results[0].MatchedRange.Start, 1e-9)
+	assert.InDelta(t, 65.9, results[0].MatchedRange.End, 1e-9)
+}
+
+func TestQueryDuration(t *testing.T) {
+	fragments := []audio.ProcessedFragment{{TimeOffset: 0}, {TimeOffset: 4.5}}
+
+	expected := 4.5 + float64(audio.WindowSize)/float64(audio.TargetSampleRate)
+	assert.InDelta(t, expected, queryDuration(fragments, audio.TargetSampleRate), 1e-9)
+	assert.Zero(t, queryDuration(nil, audio.TargetSampleRate))
+}
+
+func TestCompetitorScore(t *testing.T) {
+	candidates := []candidate{{score: 30}, {score: 10}, {score: 5}}
+
+	assert.Equal(t, 10, competitorScore(candidates, 0))
+	assert.Equal(t, 30, competitorScore(candidates, 1))
+	assert.Equal(t, 30, competitorScore(candidates, 2))
+	assert.Equal(t, 0, competitorScore(candidates[:1], 0))
+}
+
+func TestConfidence(t *testing.T) {
+	// Full coverage (5% of 1000 hashes) and no competitor
+	assert.InDelta(t, 1.0, confidence(50, 0, 1000), 1e-9)
+	// Tied with another song: half of the coverage
+	assert.InDelta(t, 0.5, confidence(50, 50, 1000), 1e-9)
+	// Half coverage, runner-up at 40% of the score
+	assert.InDelta(t, 0.5*0.8, confidence(25, 10, 1000), 1e-9)
+	// Losing candidate gets no separation credit
+	assert.InDelta(t, 0.5*0.5, confidence(25, 50, 1000), 1e-9)
+	assert.Equal(t, 0.0, confidence(0, 0, 1000))
+	assert.Equal(t, 0.0, confidence(10, 0, 0))
+}
+
+func TestNewRecognitionResponse(t *testing.T) {
+	empty := NewRecognitionResponse(nil)
+	assert.False(t, empty.Found)
+	assert.NotNil(t, empty.Candidates)
+
+	matches := []MatchResult{{Score: 30, Confidence: 0.9}, {Score: 10, Confidence: 0.1}}
+	response := NewRecognitionResponse(matches)
+	assert.True(t, response.Found)
+	assert.Equal(t, 30, response.Score)
+	assert.Equal(t, 0.9, response.Confidence)
+	assert.Len(t, response.Candidates, 2)
+}
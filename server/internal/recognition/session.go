@@ -0,0 +1,513 @@
+package recognition
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go-shazam/pkg/audio"
+	"math"
+	"sync"
+	"sync/atomic"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// session is a connection speaking the versioned JSON protocol. It runs several recognitions
+// at once, each identified by a request ID chosen by the client. Frames are read on the
+// connection goroutine; scoring runs in goroutines so that reading goes on meanwhile.
+type session struct {
+	id      string
+	userID  uuid.UUID // uuid.Nil for anonymous connections
+	conn    *wsConn
+	handler *RecognitionHandler
+	ctx     context.Context // cancelled when the connection ends
+
+	hello        *HelloMessage // nil until the handshake succeeded
+	recognitions map[string]*recognition
+	scoring      sync.WaitGroup
+}
+
+// recognition is the state of one request. Its fields are owned by the connection goroutine
+// except for stream, which scoring goroutines read, and finished.
+type recognition struct {
+	id     string
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	format     AudioFormat
+	stream     *StreamRecognizer
+	decoder    *audio.StreamDecoder // decodes compressed codecs, nil for PCM
+	audioBytes int64                // received so far
+	limited    bool                 // the audio limit was hit, further audio is ignored
+	stopped    bool                 // stop was received, further audio is ignored
+	lock       *songLock            // the song followed in sync sessions, owned by the scoring pass
+	explain    bool                 // scored once at stop and sent with its explanation
+
+	scoring  sync.Mutex  // one scoring pass at a time
+	finished atomic.Bool // the final result was sent
+}
+
+func newSession(conn *wsConn, handler *RecognitionHandler, userID uuid.UUID) *session {
+	return &session{
+		id:           uuid.NewString(),
+		userID:       userID,
+		conn:         conn,
+		handler:      handler,
+		recognitions: make(map[string]*recognition),
+	}
+}
+
+// serve handles the messages of the connection until it is closed, starting with the first
+// message that was already read to detect the protocol. It returns the error that ended the
+// connection.
+func (s *session) serve(ctx context.Context, messageType int, p []byte) error {
+	ctx, cancel := context.WithCancel(ctx)
+	s.ctx = ctx
+	defer func() {
+		cancel()
+		s.abortAll()
+	}()
+
+	for {
+		switch messageType {
+		case websocket.TextMessage:
+			s.handleMessage(p)
+		case websocket.BinaryMessage:
+			s.handleAudio(p)
+		}
+
+		var err error
+		messageType, p, err = s.conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (s *session) handleMessage(p []byte) {
+	var msg ClientMessage
+	if err := json.Unmarshal(p, &msg); err != nil {
+		s.sendError("", ErrorCodeInvalidMessage, fmt.Sprintf("invalid JSON message: %s", err.Error()))
+		return
+	}
+
+	switch msg.Type {
+	case MessageTypeHello:
+		s.handleHello(msg.HelloMessage)
+	case MessageTypeStart, MessageTypeStop, MessageTypeAbort:
+		if s.hello == nil {
+			s.sendError(msg.RequestID, ErrorCodeHandshakeRequired, fmt.Sprintf("send hello before %s", msg.Type))
+			return
+		}
+		s.handleRequest(msg)
+	default:
+		s.sendError(msg.RequestID, ErrorCodeInvalidMessage, fmt.Sprintf("unknown message type %q", msg.Type))
+	}
+}
+
+// handleRequest starts, stops or aborts the recognition of the request ID
+func (s *session) handleRequest(msg ClientMessage) {
+	if msg.Type == MessageTypeStart {
+		if len(msg.RequestID) > MaxRequestIDLength {
+			s.sendError("", ErrorCodeInvalidMessage, ErrInvalidRequestID.Error())
+			return
+		}
+		format := s.hello.AudioFormat
+		if msg.AudioFormat != (AudioFormat{}) {
+			format = msg.AudioFormat
+			if code, err := format.validate(); err != nil {
+				s.sendError(msg.RequestID, code, err.Error())
+				return
+			}
+		}
+		if msg.Explain && !s.handler.isAdmin(s.ctx) {
+			s.sendError(msg.RequestID, ErrorCodeForbidden, ErrExplainForbidden.Error())
+			return
+		}
+		if code, err := s.start(msg.RequestID, format, msg.Explain); err != nil {
+			s.sendError(msg.RequestID, code, err.Error())
+		}
+		return
+	}
+
+	r, ok := s.recognitions[msg.RequestID]
+	if !ok {
+		s.sendError(msg.RequestID, ErrorCodeUnknownRequest, fmt.Sprintf("no recognition with request_id %q", msg.RequestID))
+		return
+	}
+	if msg.Type == MessageTypeStop {
+		s.stop(r)
+		return
+	}
+	r.abort()
+	delete(s.recognitions, msg.RequestID)
+	s.send(AbortedMessage{Type: MessageTypeAborted, SessionID: s.id, RequestID: msg.RequestID})
+}
+
+// handleHello negotiates the audio format and starts the recognition without request ID.
+// A hello on an open session renegotiates and aborts all recognitions.
+func (s *session) handleHello(hello HelloMessage) {
+	if code, err := hello.validate(); err != nil {
+		s.sendError("", code, err.Error())
+		return
+	}
+
+	s.abortAll()
+	s.hello = &hello
+	if _, err := s.start("", hello.AudioFormat, false); err != nil {
+		s.hello = nil
+		s.sendError("", ErrorCodeUnsupportedFormat, err.Error())
+		return
+	}
+	s.send(ReadyMessage{
+		Type:         MessageTypeReady,
+		SessionID:    s.id,
+		HelloMessage: hello,
+	})
+}
+
+// start begins a recognition for the request ID in the format, discarding the audio of a
+// previous one with the same ID. Compressed streams start over with a new decoder, as the
+// client starts a new container for each recognition.
+func (s *session) start(requestID string, format AudioFormat, explain bool) (string, error) {
+	if previous, ok := s.recognitions[requestID]; ok {
+		previous.abort()
+		delete(s.recognitions, requestID)
+	}
+
+	if limit := s.handler.config.WSMaxRecognitions; limit > 0 && len(s.recognitions) >= limit {
+		// Finished recognitions only wait for a repeated stop; make room for the new one
+		for id, r := range s.recognitions {
+			if r.finished.Load() {
+				r.abort()
+				delete(s.recognitions, id)
+			}
+		}
+		if len(s.recognitions) >= limit {
+			return ErrorCodeTooManyRequests, fmt.Errorf("at most %d recognitions can run at once, stop or abort one first", limit)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	r := &recognition{
+		id:      requestID,
+		ctx:     ctx,
+		cancel:  cancel,
+		format:  format,
+		stream:  s.handler.service.NewStream(format.SampleRate),
+		explain: explain,
+	}
+	if format.Codec != CodecPCM {
+		decoder, err := audio.NewStreamDecoder(format.Codec, format.SampleRate)
+		if err != nil {
+			cancel()
+			return ErrorCodeRecognitionFailed, err
+		}
+		r.decoder = decoder
+	}
+
+	s.recognitions[requestID] = r
+	return "", nil
+}
+
+// abort cancels the scoring of the recognition and stops its decoder.
+func (r *recognition) abort() {
+	r.cancel()
+	if r.decoder != nil {
+		r.decoder.Abort()
+		r.decoder = nil
+	}
+}
+
+// abortAll aborts every recognition and waits for their scoring goroutines to return.
+func (s *session) abortAll() {
+	for id, r := range s.recognitions {
+		r.abort()
+		delete(s.recognitions, id)
+	}
+	s.scoring.Wait()
+}
+
+func (s *session) handleAudio(p []byte) {
+	if s.hello == nil {
+		s.sendError("", ErrorCodeHandshakeRequired, "send hello before audio")
+		return
+	}
+
+	requestID := ""
+	if s.hello.Multiplex {
+		var err error
+		if requestID, p, err = splitFrame(p); err != nil {
+			s.sendError("", ErrorCodeInvalidAudio, err.Error())
+			return
+		}
+	}
+	r, ok := s.recognitions[requestID]
+	if !ok {
+		s.sendError(requestID, ErrorCodeUnknownRequest, fmt.Sprintf("no recognition with request_id %q", requestID))
+		return
+	}
+	if r.stopped || r.limited || r.finished.Load() {
+		return
+	}
+
+	r.audioBytes += int64(len(p))
+	limitErr := s.handler.config.audioLimitError(r.audioBytes, r.stream.Duration())
+	if s.hello.Sync {
+		limitErr = s.handler.config.syncLimitError(r.audioBytes, r.stream.Duration())
+	}
+	if err := limitErr; err != nil {
+		r.limited = true
+		s.sendError(r.id, ErrorCodeAudioLimit, err.Error())
+		return
+	}
+
+	samples, err := s.decode(r, p)
+	if err != nil {
+		s.sendError(r.id, ErrorCodeInvalidAudio, err.Error())
+		return
+	}
+	r.stream.Append(samples)
+
+	config := s.handler.config
+	if !(s.hello.Incremental || s.hello.Sync) || r.explain ||
+		r.stream.Duration() < config.StreamMinDuration.Seconds() ||
+		r.stream.PendingDuration() < config.StreamInterval.Seconds() {
+		return
+	}
+	// Skip the pass while the previous one is still running, the next frame tries again
+	if !r.scoring.TryLock() {
+		return
+	}
+	s.scoring.Add(1)
+	go func() {
+		defer s.scoring.Done()
+		defer r.scoring.Unlock()
+		s.sendUpdate(r)
+	}()
+}
+
+// decode returns the samples of a binary frame. Compressed frames return whatever the decoder
+// has produced so far, which lags a little behind the input.
+func (s *session) decode(r *recognition, p []byte) ([]float64, error) {
+	if r.decoder == nil {
+		return decodeSamples(p, r.format.Format, r.format.Channels)
+	}
+	if _, err := r.decoder.Write(p); err != nil {
+		// The stream cannot be decoded any further, the client has to start over
+		r.decoder.Abort()
+		r.decoder = nil
+		r.stopped = true
+		return nil, err
+	}
+	return r.decoder.Samples(), nil
+}
+
+// stop ends the audio of the recognition and scores it in the background. The decoder is
+// handed over to the scoring goroutine, which decodes the rest of a compressed stream.
+func (s *session) stop(r *recognition) {
+	if r.stopped || r.finished.Load() {
+		return
+	}
+	r.stopped = true
+	decoder := r.decoder
+	r.decoder = nil
+
+	s.scoring.Add(1)
+	go func() {
+		defer s.scoring.Done()
+		r.scoring.Lock()
+		defer r.scoring.Unlock()
+		s.sendResult(r, decoder)
+	}()
+}
+
+// sendUpdate re-scores the audio and sends an interim candidate, or the final result once the
+// best candidate is confident enough. Sync sessions keep following the song of the result.
+func (s *session) sendUpdate(r *recognition) {
+	if r.finished.Load() {
+		return
+	}
+	if r.lock != nil {
+		s.sendSync(r)
+		return
+	}
+
+	config := s.handler.config
+	if limit := config.WSMaxAudioDuration.Seconds(); s.hello.Sync && limit > 0 && r.stream.Duration()-r.stream.Since() > limit {
+		// Sync sessions identify songs from the latest audio only
+		r.stream.Restart(r.stream.Duration() - limit/2)
+	}
+
+	matches, err := r.stream.Recognize(r.ctx)
+	if r.ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		s.sendError(r.id, ErrorCodeRecognitionFailed, err.Error())
+		return
+	}
+	if len(matches) == 0 {
+		return
+	}
+
+	response := r.stream.Response(matches)
+	if matches[0].Confidence < config.EarlyMatchConfidence {
+		if s.hello.Incremental {
+			s.sendResultMessage(r, MessageTypeCandidate, response)
+		}
+		return
+	}
+
+	if s.hello.Sync {
+		lock, err := s.handler.service.lockSong(r.ctx, matches[0], r.stream.Since())
+		if r.ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			s.sendError(r.id, ErrorCodeRecognitionFailed, err.Error())
+			return
+		}
+		r.lock = lock
+	} else {
+		r.finished.Store(true)
+	}
+	recordHistory(r.ctx, s.handler.historyService, s.userID, response, s.hello.Metadata)
+	s.sendResultMessage(r, MessageTypeResult, response)
+}
+
+// sendSync aligns the latest audio with the followed song and sends the position. When the
+// song has not been heard for SyncMaxMisses updates, it sends "track_changed" and the next
+// passes identify the song that plays now.
+func (s *session) sendSync(r *recognition) {
+	config := s.handler.config
+	match, err := r.stream.Track(r.ctx, r.lock, config.SyncWindow.Seconds())
+	if r.ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		s.sendError(r.id, ErrorCodeRecognitionFailed, err.Error())
+		return
+	}
+
+	if match.score >= config.SyncMinScore {
+		drift := match.offset - r.lock.offset
+		r.lock.offset = match.offset
+		r.lock.misses = 0
+		s.send(SyncMessage{
+			Type:      MessageTypeSync,
+			SessionID: s.id,
+			RequestID: r.id,
+			Song:      r.lock.song,
+			Position:  match.offset + match.end,
+			Drift:     drift,
+			Score:     match.score,
+			Duration:  match.end,
+		})
+		return
+	}
+
+	r.lock.misses++
+	if r.lock.misses < config.SyncMaxMisses {
+		return
+	}
+	s.send(TrackChangedMessage{
+		Type:      MessageTypeTrackChanged,
+		SessionID: s.id,
+		RequestID: r.id,
+		Song:      r.lock.song,
+		Duration:  match.end,
+	})
+	r.lock = nil
+	// The next song started within the window that no longer matched
+	r.stream.Restart(math.Max(0, match.end-config.SyncWindow.Seconds()))
+}
+
+// sendResult scores everything received and sends the final result, found or not.
+func (s *session) sendResult(r *recognition, decoder *audio.StreamDecoder) {
+	if r.finished.Load() {
+		if decoder != nil {
+			decoder.Abort()
+		}
+		return
+	}
+
+	if decoder != nil {
+		samples, err := decoder.Close()
+		if err != nil {
+			s.sendError(r.id, ErrorCodeInvalidAudio, err.Error())
+			return
+		}
+		r.stream.Append(samples)
+	}
+	if r.stream.Duration() == 0 {
+		s.sendError(r.id, ErrorCodeNoAudio, "no audio data received")
+		return
+	}
+	if r.explain {
+		s.sendExplanation(r)
+		return
+	}
+
+	matches, err := r.stream.Recognize(r.ctx)
+	if r.ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		s.sendError(r.id, ErrorCodeRecognitionFailed, err.Error())
+		return
+	}
+
+	r.finished.Store(true)
+	response := r.stream.Response(matches)
+	recordHistory(r.ctx, s.handler.historyService, s.userID, response, s.hello.Metadata)
+	s.sendResultMessage(r, MessageTypeResult, response)
+}
+
+// sendExplanation scores everything received and sends the result with its explanation.
+// Nothing is added to the history.
+func (s *session) sendExplanation(r *recognition) {
+	response, err := r.stream.Explain(r.ctx)
+	if r.ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		s.sendError(r.id, ErrorCodeRecognitionFailed, err.Error())
+		return
+	}
+
+	r.finished.Store(true)
+	s.send(ResultMessage{
+		Type:                MessageTypeResult,
+		SessionID:           s.id,
+		RequestID:           r.id,
+		RecognitionResponse: response.RecognitionResponse,
+		Duration:            r.stream.Duration(),
+		Explanation:         response.Explanation,
+	})
+}
+
+func (s *session) sendResultMessage(r *recognition, messageType string, response RecognitionResponse) {
+	s.send(ResultMessage{
+		Type:                messageType,
+		SessionID:           s.id,
+		RequestID:           r.id,
+		RecognitionResponse: response,
+		Duration:            r.stream.Duration(),
+	})
+}
+
+func (s *session) sendError(requestID string, code string, message string) {
+	s.send(ErrorMessage{
+		Type:      MessageTypeError,
+		SessionID: s.id,
+		RequestID: requestID,
+		Code:      code,
+		Message:   message,
+	})
+}
+
+func (s *session) send(msg any) {
+	s.conn.WriteJSON(msg)
+}
@@ -0,0 +1,276 @@
+package recognition
+
+import (
+	"context"
+	"encoding/binary"
+	"go-shazam/internal/auth"
+	"go-shazam/internal/fingerprint"
+	"go-shazam/internal/song"
+	"go-shazam/pkg/audio"
+	"math"
+	"math/rand"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingStore holds every hash lookup until the recognition is cancelled
+type blockingStore struct {
+	fingerprint.Store
+}
+
+func (s *blockingStore) FindHashesByValues(ctx context.Context, hashValues []int64) ([]fingerprint.Hash, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+// toneTrack returns a track of random notes with harmonics, distinct for every seed
+func toneTrack(seconds float64, seed int64) []float64 {
+	rng := rand.New(rand.NewSource(seed))
+	samples := make([]float64, int(seconds*audio.TargetSampleRate))
+	freq := 0.0
+	for i := range samples {
+		if i%(audio.TargetSampleRate/4) == 0 {
+			freq = 200 + rng.Float64()*2000
+		}
+		t := float64(i) / audio.TargetSampleRate
+		samples[i] = 0.5*math.Sin(2*math.Pi*freq*t) + 0.25*math.Sin(2*math.Pi*2.5*freq*t) + 0.02*rng.NormFloat64()
+	}
+	return samples
+}
+
+func dialSessionServer(t *testing.T, store fingerprint.Store, songs []*song.SongEntity, config *Config) *websocket.Conn {
+	t.Helper()
+	repository := &stubSongRepository{songs: make(map[uuid.UUID]*song.SongEntity)}
+	for _, s := range songs {
+		repository.songs[s.ID] = s
+	}
+	config.MaxCandidates = 5
+	service := NewRecognitionService(config, fingerprint.NewFingerprintServiceWithStore(store), repository, nil)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	RegisterRoutes(router, NewRecognitionHandler(service, nil, nil, config, nil), testJWTService)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	conn := dial(t, "ws"+strings.TrimPrefix(server.URL, "http")+"/api/recognize/ws")
+	require.NoError(t, conn.WriteJSON(gin.H{"type": "hello", "version": 1, "sample_rate": audio.TargetSampleRate, "multiplex": true}))
+	var ready ReadyMessage
+	require.NoError(t, conn.ReadJSON(&ready))
+	require.Equal(t, MessageTypeReady, ready.Type)
+	return conn
+}
+
+// multiplexedFrame prefixes float32 samples with the request ID
+func multiplexedFrame(requestID string, samples []float64) []byte {
+	frame := append([]byte{byte(len(requestID))}, requestID...)
+	for _, s := range samples {
+		frame = binary.LittleEndian.AppendUint32(frame, math.Float32bits(float32(s)))
+	}
+	return frame
+}
+
+func TestSession_MultiplexedRecognitions(t *testing.T) {
+	store := fingerprint.NewMemoryStore()
+	fingerprintService := fingerprint.NewFingerprintServiceWithStore(store)
+	first := &song.SongEntity{ID: uuid.New(), Title: "First"}
+	second := &song.SongEntity{ID: uuid.New(), Title: "Second"}
+	tracks := map[uuid.UUID][]float64{first.ID: toneTrack(20, 1), second.ID: toneTrack(20, 2)}
+	for songID, samples := range tracks {
+		fragments, err := audio.ProcessAudio(samples, audio.TargetSampleRate)
+		require.NoError(t, err)
+		require.NoError(t, store.SaveFingerprints(context.Background(), fingerprintService.CreateFingerprints(fragments, songID, audio.TargetSampleRate)))
+	}
+
+	conn := dialSessionServer(t, store, []*song.SongEntity{first, second}, &Config{})
+	require.NoError(t, conn.WriteJSON(gin.H{"type": "start", "request_id": "a"}))
+	require.NoError(t, conn.WriteJSON(gin.H{"type": "start", "request_id": "b"}))
+
+	// Interleave five-second excerpts of both tracks
+	a := tracks[first.ID][4*audio.TargetSampleRate : 9*audio.TargetSampleRate]
+	b := tracks[second.ID][10*audio.TargetSampleRate : 15*audio.TargetSampleRate]
+	for start := 0; start < len(a); start += 4096 {
+		end := min(start+4096, len(a))
+		require.NoError(t, conn.WriteMessage(websocket.BinaryMessage, multiplexedFrame("a", a[start:end])))
+		require.NoError(t, conn.WriteMessage(websocket.BinaryMessage, multiplexedFrame("b", b[start:end])))
+	}
+	require.NoError(t, conn.WriteJSON(gin.H{"type": "stop", "request_id": "b"}))
+	require.NoError(t, conn.WriteJSON(gin.H{"type": "stop", "request_id": "a"}))
+
+	results := make(map[string]ResultMessage)
+	for len(results) < 2 {
+		var result ResultMessage
+		require.NoError(t, conn.ReadJSON(&result))
+		require.Equal(t, MessageTypeResult, result.Type)
+		results[result.RequestID] = result
+	}
+
+	require.True(t, results["a"].Found)
+	assert.Equal(t, first.ID, results["a"].Song.ID)
+	assert.InDelta(t, 4, results["a"].TimeOffset, 0.1)
+	require.True(t, results["b"].Found)
+	assert.Equal(t, second.ID, results["b"].Song.ID)
+	assert.InDelta(t, 10, results["b"].TimeOffset, 0.1)
+}
+
+func TestSession_AbortCancelsScoring(t *testing.T) {
+	conn := dialSessionServer(t, &blockingStore{}, nil, &Config{})
+
+	require.NoError(t, conn.WriteJSON(gin.H{"type": "start", "request_id": "slow"}))
+	require.NoError(t, conn.WriteMessage(websocket.BinaryMessage, multiplexedFrame("slow", toneTrack(3, 1))))
+	require.NoError(t, conn.WriteJSON(gin.H{"type": "stop", "request_id": "slow"}))
+
+	// The connection keeps reading while the first recognition is scored
+	require.NoError(t, conn.WriteJSON(gin.H{"type": "start", "request_id": "fast"}))
+	require.NoError(t, conn.WriteJSON(gin.H{"type": "stop", "request_id": "fast"}))
+	var errMsg ErrorMessage
+	require.NoError(t, conn.ReadJSON(&errMsg))
+	assert.Equal(t, "fast", errMsg.RequestID)
+	assert.Equal(t, ErrorCodeNoAudio, errMsg.Code)
+
+	require.NoError(t, conn.WriteJSON(gin.H{"type": "abort", "request_id": "slow"}))
+	var aborted AbortedMessage
+	require.NoError(t, conn.ReadJSON(&aborted))
+	assert.Equal(t, AbortedMessage{Type: MessageTypeAborted, SessionID: aborted.SessionID, RequestID: "slow"}, aborted)
+
+	// The cancelled recognition sends nothing and is gone
+	require.NoError(t, conn.WriteJSON(gin.H{"type": "stop", "request_id": "slow"}))
+	require.NoError(t, conn.ReadJSON(&errMsg))
+	assert.Equal(t, ErrorCodeUnknownRequest, errMsg.Code)
+
+	conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	_, _, err := conn.ReadMessage()
+	assert.Error(t, err, "no message expected after the abort")
+}
+
+func TestSession_TooManyRecognitions(t *testing.T) {
+	conn := dialSessionServer(t, fingerprint.NewMemoryStore(), nil, &Config{WSMaxRecognitions: 2})
+
+	// hello started the recognition without request ID
+	require.NoError(t, conn.WriteJSON(gin.H{"type": "start", "request_id": "a"}))
+	require.NoError(t, conn.WriteJSON(gin.H{"type": "start", "request_id": "b"}))
+
+	var errMsg ErrorMessage
+	require.NoError(t, conn.ReadJSON(&errMsg))
+	assert.Equal(t, "b", errMsg.RequestID)
+	assert.Equal(t, ErrorCodeTooManyRequests, errMsg.Code)
+
+	// Restarting a running request does not count twice
+	require.NoError(t, conn.WriteJSON(gin.H{"type": "start", "request_id": "a"}))
+	require.NoError(t, conn.WriteJSON(gin.H{"type": "stop", "request_id": "a"}))
+	require.NoError(t, conn.ReadJSON(&errMsg))
+	assert.Equal(t, "a", errMsg.RequestID)
+	assert.Equal(t, ErrorCodeNoAudio, errMsg.Code)
+}
+
+func TestSession_MultiplexedFrameErrors(t *testing.T) {
+	conn := dialSessionServer(t, fingerprint.NewMemoryStore(), nil, &Config{})
+
+	require.NoError(t, conn.WriteMessage(websocket.BinaryMessage, []byte{5, 'a'}))
+	var errMsg ErrorMessage
+	require.NoError(t, conn.ReadJSON(&errMsg))
+	assert.Equal(t, ErrorCodeInvalidAudio, errMsg.Code)
+
+	require.NoError(t, conn.WriteMessage(websocket.BinaryMessage, multiplexedFrame("missing", make([]float64, 4))))
+	require.NoError(t, conn.ReadJSON(&errMsg))
+	assert.Equal(t, "missing", errMsg.RequestID)
+	assert.Equal(t, ErrorCodeUnknownRequest, errMsg.Code)
+}
+
+func TestSession_StartNegotiatesFormat(t *testing.T) {
+	conn := dialSessionServer(t, fingerprint.NewMemoryStore(), nil, &Config{})
+
+	// Six bytes are three int16 samples but not a whole number of float32 samples
+	require.NoError(t, conn.WriteJSON(gin.H{"type": "start", "request_id": "a", "format": "int16", "sample_rate": 8000}))
+	require.NoError(t, conn.WriteMessage(websocket.BinaryMessage, append([]byte{1, 'a'}, make([]byte, 6)...)))
+	require.NoError(t, conn.WriteMessage(websocket.BinaryMessage, append([]byte{0}, make([]byte, 6)...)))
+
+	var errMsg ErrorMessage
+	require.NoError(t, conn.ReadJSON(&errMsg))
+	assert.Empty(t, errMsg.RequestID, "the recognition of the hello keeps float32")
+	assert.Equal(t, ErrorCodeInvalidAudio, errMsg.Code)
+
+	require.NoError(t, conn.WriteJSON(gin.H{"type": "start", "request_id": "b", "codec": "flac"}))
+	require.NoError(t, conn.ReadJSON(&errMsg))
+	assert.Equal(t, "b", errMsg.RequestID)
+	assert.Equal(t, ErrorCodeUnsupportedFormat, errMsg.Code)
+}
+
+func TestSplitFrame(t *testing.T) {
+	requestID, p, err := splitFrame([]byte{2, 'i', 'd', 1, 2, 3, 4})
+	require.NoError(t, err)
+	assert.Equal(t, "id", requestID)
+	assert.Equal(t, []byte{1, 2, 3, 4}, p)
+
+	requestID, p, err = splitFrame([]byte{0, 1, 2})
+	require.NoError(t, err)
+	assert.Empty(t, requestID)
+	assert.Equal(t, []byte{1, 2}, p)
+
+	_, _, err = splitFrame(nil)
+	assert.ErrorIs(t, err, ErrInvalidRequestFrame)
+}
+
+func TestSession_ExplainRequiresAdmin(t *testing.T) {
+	conn := dialSessionServer(t, fingerprint.NewMemoryStore(), nil, &Config{})
+
+	require.NoError(t, conn.WriteJSON(gin.H{"type": "start", "request_id": "a", "explain": true}))
+
+	var errMsg ErrorMessage
+	require.NoError(t, conn.ReadJSON(&errMsg))
+	assert.Equal(t, "a", errMsg.RequestID)
+	assert.Equal(t, ErrorCodeForbidden, errMsg.Code)
+}
+
+func TestSession_Explain(t *testing.T) {
+	store := fingerprint.NewMemoryStore()
+	fingerprintService := fingerprint.NewFingerprintServiceWithStore(store)
+	track := &song.SongEntity{ID: uuid.New(), Title: "Track"}
+	samples := toneTrack(20, 1)
+	fragments, err := audio.ProcessAudio(samples, audio.TargetSampleRate)
+	require.NoError(t, err)
+	require.NoError(t, store.SaveFingerprints(context.Background(), fingerprintService.CreateFingerprints(fragments, track.ID, audio.TargetSampleRate)))
+
+	config := &Config{MaxCandidates: 5}
+	repository := &stubSongRepository{songs: map[uuid.UUID]*song.SongEntity{track.ID: track}}
+	service := NewRecognitionService(config, fingerprintService, repository, nil)
+	adminID := uuid.New()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	RegisterRoutes(router, NewRecognitionHandler(service, nil, nil, config, &auth.Config{AdminUserIDs: []uuid.UUID{adminID}}), testJWTService)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	tokens, err := testJWTService.GenerateTokenPair(adminID)
+	require.NoError(t, err)
+	dialer := websocket.Dialer{Subprotocols: []string{auth.WebSocketProtocol, "access_token." + tokens.AccessToken}}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/api/recognize/ws", nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	require.NoError(t, conn.WriteJSON(gin.H{"type": "hello", "version": 1, "sample_rate": audio.TargetSampleRate, "multiplex": true}))
+	var ready ReadyMessage
+	require.NoError(t, conn.ReadJSON(&ready))
+	require.NoError(t, conn.WriteJSON(gin.H{"type": "start", "request_id": "a", "explain": true}))
+	require.NoError(t, conn.WriteMessage(websocket.BinaryMessage, multiplexedFrame("a", samples[5*audio.TargetSampleRate:10*audio.TargetSampleRate])))
+	require.NoError(t, conn.WriteJSON(gin.H{"type": "stop", "request_id": "a"}))
+
+	var result ResultMessage
+	require.NoError(t, conn.ReadJSON(&result))
+	assert.Equal(t, MessageTypeResult, result.Type)
+	assert.Equal(t, "a", result.RequestID)
+	require.True(t, result.Found)
+	assert.Equal(t, track.ID, result.Song.ID)
+	require.NotNil(t, result.Explanation)
+	assert.Equal(t, DefaultThresholds, result.Explanation.Thresholds)
+	require.NotEmpty(t, result.Explanation.Candidates)
+	assert.True(t, result.Explanation.Candidates[0].Accepted)
+}
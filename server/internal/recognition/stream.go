@@ -0,0 +1,268 @@
+package recognition
+
+import (
+	"context"
+	"fmt"
+	"go-shazam/internal/fingerprint"
+	"go-shazam/pkg/audio"
+	"go-shazam/pkg/landmark"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// hashLookup returns the database hashes matching the given sample hash values
+type hashLookup func(ctx context.Context, sampleHashes []fingerprint.Hash) ([]fingerprint.Hash, error)
+
+// matchCache queries each hash value at most once and keeps the database matches, so that
+// overlapping or growing queries only pay for the hash values they have not seen yet.
+type matchCache struct {
+	lookup   hashLookup
+	lookedUp map[int64]bool
+	byHash   map[int64][]fingerprint.Hash
+}
+
+func newMatchCache(fingerprintService *fingerprint.FingerprintService) *matchCache {
+	return newMatchCacheWithLookup(fingerprintService.GetMatchingHashes)
+}
+
+func newMatchCacheWithLookup(lookup hashLookup) *matchCache {
+	return &matchCache{
+		lookup:   lookup,
+		lookedUp: make(map[int64]bool),
+		byHash:   make(map[int64][]fingerprint.Hash),
+	}
+}
+
+// find returns the database hashes matching any of the sample hash values.
+func (c *matchCache) find(ctx context.Context, sampleHashes []fingerprint.Hash) ([]fingerprint.Hash, error) {
+	var newHashes []fingerprint.Hash
+	for _, h := range sampleHashes {
+		if !c.lookedUp[h.HashValue] {
+			c.lookedUp[h.HashValue] = true
+			newHashes = append(newHashes, h)
+		}
+	}
+
+	if len(newHashes) > 0 {
+		dbHashes, err := c.lookup(ctx, newHashes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get matching hashes: %w", err)
+		}
+		for _, h := range dbHashes {
+			c.byHash[h.HashValue] = append(c.byHash[h.HashValue], h)
+		}
+	}
+
+	seen := make(map[int64]bool)
+	var matches []fingerprint.Hash
+	for _, h := range sampleHashes {
+		if seen[h.HashValue] {
+			continue
+		}
+		seen[h.HashValue] = true
+		matches = append(matches, c.byHash[h.HashValue]...)
+	}
+	return matches, nil
+}
+
+// StreamRecognizer fingerprints audio incrementally while it is still arriving. Only new
+// fragments go through the FFT, and only hash values that have not been looked up yet are
+// sent to the database, so re-scoring the growing sample stays cheap.
+//
+// Append, Duration and PendingDuration may be called while Recognize or Explain is running
+// in another goroutine; calls to Recognize and Explain must not overlap.
+type StreamRecognizer struct {
+	service    *RecognitionService
+	sampleRate int
+
+	mu       sync.Mutex
+	pending  []float64 // samples at the client rate that have not been resampled yet
+	ingested int       // samples resampled since the stream began, readable under mu
+
+	samples   []float64 // samples at audio.TargetSampleRate, from sample number base on
+	base      int       // samples discarded from the front
+	processed int       // start of the next FFT window in samples
+	peaks     []landmark.Peak
+	start     float64 // stream time where the audio scored by Recognize begins
+	quality   queryQuality
+	hashes    int // generated by the last Recognize or Explain call
+
+	matches *matchCache
+}
+
+func (s *RecognitionService) NewStream(sampleRate int) *StreamRecognizer {
+	return &StreamRecognizer{
+		service:    s,
+		sampleRate: sampleRate,
+		matches:    newMatchCache(s.fingerprintService),
+	}
+}
+
+// Append buffers samples at the stream sample rate.
+func (r *StreamRecognizer) Append(samples []float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pending = append(r.pending, samples...)
+}
+
+// Duration returns the number of seconds received so far.
+func (r *StreamRecognizer) Duration() float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return float64(r.ingested)/float64(audio.TargetSampleRate) + float64(len(r.pending))/float64(r.sampleRate)
+}
+
+// PendingDuration returns the number of seconds received since the last Recognize call.
+func (r *StreamRecognizer) PendingDuration() float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return float64(len(r.pending)) / float64(r.sampleRate)
+}
+
+// Recognize fingerprints the audio received since the previous call and re-scores the
+// whole stream, or the audio since the last Restart.
+func (r *StreamRecognizer) Recognize(ctx context.Context) ([]MatchResult, error) {
+	if err := r.ingest(); err != nil {
+		return nil, err
+	}
+
+	sampleHashes := landmark.CreateHashes(r.scoredPeaks(), uuid.Nil)
+	r.hashes = len(sampleHashes)
+	if len(sampleHashes) == 0 {
+		return nil, nil
+	}
+
+	dbHashes, err := r.matches.find(ctx, sampleHashes)
+	if err != nil {
+		return nil, err
+	}
+	if len(dbHashes) == 0 {
+		return nil, nil
+	}
+
+	return r.service.rankMatches(ctx, sampleHashes, dbHashes, r.Duration()-r.start, r.service.thresholds.get(ctx))
+}
+
+// Response turns the matches of the last Recognize call into a response with the
+// diagnostics of the audio it scored. Like Recognize, it must not overlap with Explain.
+func (r *StreamRecognizer) Response(matches []MatchResult) RecognitionResponse {
+	response := NewRecognitionResponse(matches)
+	diagnostics := r.quality.diagnostics(r.hashes)
+	response.Diagnostics = &diagnostics
+	return response
+}
+
+// Explain re-scores the whole stream like Recognize and explains the decision.
+func (r *StreamRecognizer) Explain(ctx context.Context) (*ExplainResponse, error) {
+	if err := r.ingest(); err != nil {
+		return nil, err
+	}
+
+	sampleHashes := landmark.CreateHashes(r.scoredPeaks(), uuid.Nil)
+	r.hashes = len(sampleHashes)
+	if len(sampleHashes) == 0 {
+		return nil, ErrNoFingerprints
+	}
+
+	dbHashes, err := r.matches.find(ctx, sampleHashes)
+	if err != nil {
+		return nil, err
+	}
+	return r.service.explainMatches(ctx, sampleHashes, dbHashes, r.Duration()-r.start)
+}
+
+// Restart makes Recognize score only the audio from the stream time at on, as if the stream
+// had begun there, and forgets the audio before it. Offsets in the results are relative to at.
+func (r *StreamRecognizer) Restart(at float64) {
+	r.discard(at)
+	r.start = at
+	r.matches = newMatchCacheWithLookup(r.matches.lookup)
+}
+
+// Since returns the stream time where the audio scored by Recognize begins.
+func (r *StreamRecognizer) Since() float64 {
+	return r.start
+}
+
+// scoredPeaks returns the peaks since the start of the scored audio, timed from that start
+func (r *StreamRecognizer) scoredPeaks() []landmark.Peak {
+	if r.start == 0 {
+		return r.peaks
+	}
+	peaks := make([]landmark.Peak, 0, len(r.peaks))
+	for _, p := range r.peaks {
+		if p.Time >= r.start {
+			p.Time -= r.start
+			peaks = append(peaks, p)
+		}
+	}
+	return peaks
+}
+
+// discard forgets the peaks before the stream time and the samples that no FFT window
+// needs anymore, so that long streams keep a bounded amount of audio.
+func (r *StreamRecognizer) discard(before float64) {
+	first := sort.Search(len(r.peaks), func(i int) bool { return r.peaks[i].Time >= before })
+	if first > 0 {
+		r.peaks = slices.Clone(r.peaks[first:])
+	}
+
+	if drop := min(r.processed, int(before*audio.TargetSampleRate)-r.base); drop > 0 {
+		r.samples = slices.Clone(r.samples[drop:])
+		r.base += drop
+		r.processed -= drop
+	}
+}
+
+// analysed returns the stream time up to which audio has been resampled
+func (r *StreamRecognizer) analysed() float64 {
+	return float64(r.base+len(r.samples)) / float64(audio.TargetSampleRate)
+}
+
+// ingest resamples the pending audio and extracts peaks from every complete window that
+// has not been processed yet.
+func (r *StreamRecognizer) ingest() error {
+	r.mu.Lock()
+	pending := r.pending
+	r.mu.Unlock()
+
+	if len(pending) > 0 {
+		resampled, err := audio.Resample(pending, r.sampleRate, audio.TargetSampleRate)
+		if err != nil {
+			return fmt.Errorf("resampling error: %w", err)
+		}
+		r.samples = append(r.samples, resampled...)
+		r.quality.addSamples(resampled)
+
+		// Samples appended in the meantime stay pending
+		r.mu.Lock()
+		r.pending = r.pending[len(pending):]
+		r.ingested = r.base + len(r.samples)
+		r.mu.Unlock()
+	}
+
+	if len(r.samples)-r.processed < audio.WindowSize {
+		return nil
+	}
+
+	fragments, err := audio.ProcessAudio(r.samples[r.processed:], audio.TargetSampleRate)
+	if err != nil {
+		return fmt.Errorf("processing error: %w", err)
+	}
+
+	// Offsets are relative to the processed slice; rebase them on the start of the stream
+	for i := range fragments {
+		fragments[i].TimeOffset = float64(r.base+r.processed+i*audio.HopSize) / float64(audio.TargetSampleRate)
+	}
+
+	peaks := landmark.ExtractPeaks(fragments, audio.TargetSampleRate)
+	r.peaks = append(r.peaks, peaks...)
+	r.quality.addFragments(fragments)
+	r.quality.addPeaks(len(peaks))
+	r.processed += len(fragments) * audio.HopSize
+
+	return nil
+}
@@ -0,0 +1,56 @@
+package recognition
+
+import (
+	"go-shazam/pkg/audio"
+	"go-shazam/pkg/landmark"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testSignal returns a few seconds of tones and noise with changing pitch at the target rate
+func testSignal(seconds float64) []float64 {
+	rng := rand.New(rand.NewSource(42))
+	n := int(seconds * audio.TargetSampleRate)
+	samples := make([]float64, n)
+	for i := range samples {
+		t := float64(i) / audio.TargetSampleRate
+		freq := 300.0 + 200.0*math.Floor(t*4)
+		samples[i] = 0.6*math.Sin(2*math.Pi*freq*t) + 0.3*math.Sin(2*math.Pi*2.7*freq*t) + 0.05*rng.NormFloat64()
+	}
+	return samples
+}
+
+func TestStreamRecognizer_IngestMatchesBatchFingerprints(t *testing.T) {
+	samples := testSignal(6)
+
+	fragments, err := audio.ProcessAudio(samples, audio.TargetSampleRate)
+	require.NoError(t, err)
+	batch := landmark.CreateHashes(landmark.ExtractPeaks(fragments, audio.TargetSampleRate), uuid.Nil)
+	require.NotEmpty(t, batch)
+
+	stream := (&RecognitionService{}).NewStream(audio.TargetSampleRate)
+	for start := 0; start < len(samples); start += 3000 {
+		end := min(start+3000, len(samples))
+		stream.Append(samples[start:end])
+		require.NoError(t, stream.ingest())
+	}
+
+	incremental := landmark.CreateHashes(stream.peaks, uuid.Nil)
+	assert.ElementsMatch(t, batch, incremental)
+	assert.InDelta(t, 6.0, stream.Duration(), 1e-9)
+	assert.Zero(t, stream.PendingDuration())
+}
+
+func TestStreamRecognizer_Durations(t *testing.T) {
+	stream := (&RecognitionService{}).NewStream(44100)
+
+	stream.Append(make([]float64, 22050))
+
+	assert.InDelta(t, 0.5, stream.Duration(), 1e-9)
+	assert.InDelta(t, 0.5, stream.PendingDuration(), 1e-9)
+}
@@ -0,0 +1,111 @@
+package recognition
+
+import (
+	"context"
+	"fmt"
+	"go-shazam/internal/fingerprint"
+	"go-shazam/internal/song"
+	"go-shazam/pkg/landmark"
+	"math"
+
+	"github.com/google/uuid"
+)
+
+// songLock is the song followed by a stream in sync mode. The hashes of the song around the
+// playback position are kept in memory, so that following the playback position needs a
+// database lookup only once it leaves them: every update scores the latest audio against
+// this song alone.
+type songLock struct {
+	song   *song.SongEntity
+	hashes map[int64][]fingerprint.Hash
+	from   float64 // track time of the first hash loaded
+	to     float64 // track time the loaded hashes end at
+	offset float64 // track time minus stream time at the last update
+	misses int     // updates in a row without the song
+}
+
+// syncMatch is where the latest audio of a stream aligns with the locked song
+type syncMatch struct {
+	offset float64 // track time minus stream time
+	score  int     // pairs in the best offset bin
+	end    float64 // stream time of the end of the audio scored
+}
+
+// lockSong loads the hashes of the matched song from where the query starts. start is the
+// stream time the offsets of the match are relative to.
+func (s *RecognitionService) lockSong(ctx context.Context, match MatchResult, start float64) (*songLock, error) {
+	lock := &songLock{song: match.Song, offset: match.TimeOffset - start}
+	if err := s.loadSongLock(ctx, lock, match.TimeOffset); err != nil {
+		return nil, err
+	}
+	return lock, nil
+}
+
+// loadSongLock replaces the hashes of the lock with SyncPreload seconds of the song from
+// track time from on, or the whole song when SyncPreload is 0.
+func (s *RecognitionService) loadSongLock(ctx context.Context, lock *songLock, from float64) error {
+	from, to := math.Max(0, from), math.MaxFloat64
+	if s.config.SyncPreload > 0 {
+		to = from + s.config.SyncPreload.Seconds()
+	} else {
+		from = 0
+	}
+	hashes, err := s.fingerprintService.GetSongHashes(ctx, lock.song.ID, from, to)
+	if err != nil {
+		return fmt.Errorf("failed to load song hashes: %w", err)
+	}
+
+	lock.hashes = make(map[int64][]fingerprint.Hash)
+	for _, h := range hashes {
+		lock.hashes[h.HashValue] = append(lock.hashes[h.HashValue], h)
+	}
+	lock.from, lock.to = from, to
+	return nil
+}
+
+// Track aligns the last window seconds of the stream with the locked song, using the offset
+// histogram of the song alone. The audio before the window is discarded. The next hashes of
+// the song are loaded as playback leaves the ones in memory; when the window does not align
+// with them, after a seek, its hashes are looked up in the catalog. Like Recognize, it must
+// not overlap with other scoring calls.
+func (r *StreamRecognizer) Track(ctx context.Context, lock *songLock, window float64) (syncMatch, error) {
+	if err := r.ingest(); err != nil {
+		return syncMatch{}, err
+	}
+	end := r.analysed()
+	r.discard(end - window)
+
+	sampleHashes := landmark.CreateHashes(r.peaks, uuid.Nil)
+	r.hashes = len(sampleHashes)
+	if len(sampleHashes) == 0 {
+		return syncMatch{end: end}, nil
+	}
+	sampleHashMap, _ := sampleTimes(sampleHashes)
+
+	// Track time of the window if playback went on since the last update
+	trackStart := lock.offset + end - window
+	if trackStart < lock.from || lock.offset+end > lock.to {
+		if err := r.service.loadSongLock(ctx, lock, trackStart-window); err != nil {
+			return syncMatch{}, err
+		}
+	}
+
+	var dbHashes []fingerprint.Hash
+	for value := range sampleHashMap {
+		dbHashes = append(dbHashes, lock.hashes[value]...)
+	}
+	bins, _ := offsetHistogram(sampleHashMap, dbHashes, lock.song.ID)
+	if len(bins) == 0 || bins[0].Count < r.service.config.SyncMinScore {
+		dbHashes, err := r.service.fingerprintService.GetMatchingHashes(ctx, sampleHashes)
+		if err != nil {
+			return syncMatch{}, fmt.Errorf("failed to look up hashes: %w", err)
+		}
+		if seeked, _ := offsetHistogram(sampleHashMap, dbHashes, lock.song.ID); len(seeked) > 0 && (len(bins) == 0 || seeked[0].Count > bins[0].Count) {
+			bins = seeked
+		}
+	}
+	if len(bins) == 0 {
+		return syncMatch{end: end}, nil
+	}
+	return syncMatch{offset: bins[0].Offset, score: bins[0].Count, end: end}, nil
+}
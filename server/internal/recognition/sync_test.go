@@ -0,0 +1,192 @@
+package recognition
+
+import (
+	"context"
+	"go-shazam/internal/fingerprint"
+	"go-shazam/internal/song"
+	"go-shazam/pkg/audio"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// indexTracks stores the fingerprints of the tracks and returns the store
+func indexTracks(t *testing.T, tracks map[uuid.UUID][]float64) *fingerprint.MemoryStore {
+	t.Helper()
+	store := fingerprint.NewMemoryStore()
+	fingerprintService := fingerprint.NewFingerprintServiceWithStore(store)
+	for songID, samples := range tracks {
+		fragments, err := audio.ProcessAudio(samples, audio.TargetSampleRate)
+		require.NoError(t, err)
+		require.NoError(t, store.SaveFingerprints(context.Background(), fingerprintService.CreateFingerprints(fragments, songID, audio.TargetSampleRate)))
+	}
+	return store
+}
+
+// excerpt returns the samples of the track between two times in seconds
+func excerpt(samples []float64, from float64, to float64) []float64 {
+	return samples[int(from*audio.TargetSampleRate):int(to*audio.TargetSampleRate)]
+}
+
+func TestStreamRecognizer_TrackFollowsSeeks(t *testing.T) {
+	track := &song.SongEntity{ID: uuid.New(), Title: "Track"}
+	samples := toneTrack(60, 1)
+	store := indexTracks(t, map[uuid.UUID][]float64{track.ID: samples})
+	config := &Config{MaxCandidates: 5, SyncMinScore: 10, SyncPreload: 10 * time.Second}
+	service := NewRecognitionService(config, fingerprint.NewFingerprintServiceWithStore(store), &stubSongRepository{songs: map[uuid.UUID]*song.SongEntity{track.ID: track}}, nil)
+
+	stream := service.NewStream(audio.TargetSampleRate)
+	stream.Append(excerpt(samples, 20, 28))
+	matches, err := stream.Recognize(context.Background())
+	require.NoError(t, err)
+	require.NotEmpty(t, matches)
+	lock, err := service.lockSong(context.Background(), matches[0], stream.Since())
+	require.NoError(t, err)
+	assert.InDelta(t, 20, lock.offset, 0.1)
+	assert.InDelta(t, 30, lock.to, 0.1, "only the next seconds of the song are loaded")
+
+	// Playback goes on
+	stream.Append(excerpt(samples, 28, 30))
+	match, err := stream.Track(context.Background(), lock, 5)
+	require.NoError(t, err)
+	assert.InDelta(t, 20, match.offset, 0.1)
+	assert.InDelta(t, 10, match.end, 0.01)
+	assert.Greater(t, match.score, 10)
+
+	// The listener skips ahead twelve seconds, past the hashes loaded next
+	stream.Append(excerpt(samples, 42, 47))
+	match, err = stream.Track(context.Background(), lock, 5)
+	require.NoError(t, err)
+	assert.Less(t, lock.to, 42.0)
+	assert.InDelta(t, 32, match.offset, 0.1)
+	assert.InDelta(t, 47, match.offset+match.end, 0.1)
+
+	// Only the window is kept
+	assert.LessOrEqual(t, len(stream.samples), 6*audio.TargetSampleRate)
+	assert.GreaterOrEqual(t, stream.peaks[0].Time, 10.0)
+}
+
+func TestStreamRecognizer_RestartScoresFromThere(t *testing.T) {
+	first := &song.SongEntity{ID: uuid.New(), Title: "First"}
+	second := &song.SongEntity{ID: uuid.New(), Title: "Second"}
+	tracks := map[uuid.UUID][]float64{first.ID: toneTrack(30, 1), second.ID: toneTrack(30, 2)}
+	store := indexTracks(t, tracks)
+	service := NewRecognitionService(&Config{MaxCandidates: 5}, fingerprint.NewFingerprintServiceWithStore(store), &stubSongRepository{songs: map[uuid.UUID]*song.SongEntity{first.ID: first, second.ID: second}}, nil)
+
+	stream := service.NewStream(audio.TargetSampleRate)
+	stream.Append(excerpt(tracks[first.ID], 0, 10))
+	stream.Append(excerpt(tracks[second.ID], 5, 10))
+	_, err := stream.Recognize(context.Background())
+	require.NoError(t, err)
+
+	stream.Restart(10)
+	matches, err := stream.Recognize(context.Background())
+	require.NoError(t, err)
+
+	require.NotEmpty(t, matches)
+	assert.Equal(t, second.ID, matches[0].Song.ID)
+	assert.InDelta(t, 5, matches[0].TimeOffset, 0.1)
+	assert.InDelta(t, 10, matches[0].Position, 0.1)
+	for _, match := range matches {
+		assert.NotEqual(t, first.ID, match.Song.ID, "audio before the restart is not scored")
+	}
+}
+
+func TestSession_SyncFollowsTrackChanges(t *testing.T) {
+	first := &song.SongEntity{ID: uuid.New(), Title: "First"}
+	second := &song.SongEntity{ID: uuid.New(), Title: "Second"}
+	tracks := map[uuid.UUID][]float64{first.ID: toneTrack(60, 1), second.ID: toneTrack(60, 2)}
+	store := indexTracks(t, tracks)
+	config := &Config{
+		StreamInterval:       time.Second,
+		StreamMinDuration:    3 * time.Second,
+		EarlyMatchConfidence: 0.5,
+		SyncWindow:           3 * time.Second,
+		SyncMinScore:         10,
+		SyncMaxMisses:        2,
+	}
+	conn := dialSessionServer(t, store, []*song.SongEntity{first, second}, config)
+	require.NoError(t, conn.WriteJSON(gin.H{"type": "hello", "version": 1, "sample_rate": audio.TargetSampleRate, "multiplex": true, "sync": true}))
+	var ready ReadyMessage
+	require.NoError(t, conn.ReadJSON(&ready))
+	require.True(t, ready.Sync)
+	require.NoError(t, conn.WriteJSON(gin.H{"type": "start", "request_id": "sync"}))
+
+	messages := make(chan map[string]any, 100)
+	go func() {
+		defer close(messages)
+		for {
+			var msg map[string]any
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			messages <- msg
+		}
+	}()
+
+	// Twelve seconds of the first track from 20s on, then the second track from 30s on until
+	// the server follows it. Scoring passes are skipped while one runs, so how much audio that
+	// takes depends on the speed of the machine.
+	var received []map[string]any
+	following := false
+	collect := func() {
+		for {
+			select {
+			case msg := <-messages:
+				received = append(received, msg)
+				if song, ok := msg["song"].(map[string]any); ok && msg["type"] == MessageTypeSync && song["Title"] == "Second" {
+					following = true
+				}
+			default:
+				return
+			}
+		}
+	}
+	playback := append(append([]float64{}, excerpt(tracks[first.ID], 20, 32)...), excerpt(tracks[second.ID], 30, 60)...)
+	sent := 0
+	for ; sent < len(playback) && !following; sent += 2800 {
+		end := min(sent+2800, len(playback))
+		require.NoError(t, conn.WriteMessage(websocket.BinaryMessage, multiplexedFrame("sync", playback[sent:end])))
+		time.Sleep(10 * time.Millisecond)
+		collect()
+	}
+	require.NoError(t, conn.WriteJSON(gin.H{"type": "stop", "request_id": "sync"}))
+
+	duration := float64(min(sent, len(playback))) / audio.TargetSampleRate
+	for msg := range messages {
+		received = append(received, msg)
+		if msg["type"] == MessageTypeResult && msg["duration"].(float64) >= duration-0.01 {
+			break
+		}
+	}
+
+	// result, syncs, track_changed, result, syncs, final result
+	var sequence []string
+	for _, msg := range received {
+		entry := msg["type"].(string)
+		if song, ok := msg["song"].(map[string]any); ok {
+			entry += ":" + song["Title"].(string)
+		}
+		if len(sequence) == 0 || sequence[len(sequence)-1] != entry {
+			sequence = append(sequence, entry)
+		}
+
+		if msg["type"] == MessageTypeSync {
+			duration := msg["duration"].(float64)
+			expected := 20 + duration
+			if msg["song"].(map[string]any)["Title"] == "Second" {
+				expected = 30 + duration - 12
+			}
+			assert.InDelta(t, expected, msg["position"].(float64), 0.2)
+			assert.InDelta(t, 0, msg["drift"].(float64), 0.2)
+		}
+	}
+	assert.Equal(t, []string{
+		"result:First", "sync:First", "track_changed:First", "result:Second", "sync:Second", "result:Second",
+	}, sequence)
+}
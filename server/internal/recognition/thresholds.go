@@ -0,0 +1,85 @@
+package recognition
+
+import (
+	"context"
+	"go-shazam/internal/logger"
+	"sync"
+	"time"
+)
+
+// Thresholds decide whether a candidate can be a match: its score has to reach
+// max(MinAbsoluteScore, sampleHashes * MinScoreRatio).
+type Thresholds struct {
+	MinAbsoluteScore int     `json:"min_absolute_score"`
+	MinScoreRatio    float64 `json:"min_score_ratio"`
+}
+
+// DefaultThresholds apply until the first calibration has run
+var DefaultThresholds = Thresholds{MinAbsoluteScore: MinAbsoluteScore, MinScoreRatio: MinScoreRatio}
+
+// matchThreshold is the adaptive threshold for a sample of that many hashes
+func (t Thresholds) matchThreshold(sampleHashes int) int {
+	return max(t.MinAbsoluteScore, int(float64(sampleHashes)*t.MinScoreRatio))
+}
+
+// thresholdCache serves the thresholds of the latest calibration. They are reloaded after
+// ttl, so a new calibration takes effect on every instance without a restart. One caller
+// reloads them outside the lock while the others keep the previous thresholds.
+type thresholdCache struct {
+	find func(ctx context.Context) (*Calibration, error) // nil serves the defaults
+	ttl  time.Duration
+
+	mu       sync.RWMutex
+	current  Thresholds
+	loadedAt time.Time
+	loading  bool
+}
+
+func newThresholdCache(repository *CalibrationRepository, ttl time.Duration) *thresholdCache {
+	c := &thresholdCache{ttl: ttl, current: DefaultThresholds}
+	if repository != nil {
+		c.find = repository.FindLatest
+	}
+	return c
+}
+
+func (c *thresholdCache) get(ctx context.Context) Thresholds {
+	if c == nil || c.find == nil {
+		return DefaultThresholds
+	}
+
+	c.mu.RLock()
+	current, fresh := c.current, c.fresh()
+	c.mu.RUnlock()
+	if fresh {
+		return current
+	}
+
+	c.mu.Lock()
+	if c.fresh() || c.loading {
+		current := c.current
+		c.mu.Unlock()
+		return current
+	}
+	c.loading = true
+	c.mu.Unlock()
+
+	calibration, err := c.find(ctx)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil {
+		// Keep serving the previous thresholds and retry after the ttl
+		logger.FromContext(ctx).Error("failed to load recognition thresholds", "error", err)
+	} else if calibration != nil {
+		c.current = calibration.Thresholds
+	}
+	c.loadedAt = time.Now()
+	c.loading = false
+	return c.current
+}
+
+// fresh reports whether the thresholds were loaded less than ttl ago. The caller holds c.mu.
+func (c *thresholdCache) fresh() bool {
+	return !c.loadedAt.IsZero() && time.Since(c.loadedAt) < c.ttl
+}
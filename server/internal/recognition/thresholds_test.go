@@ -0,0 +1,46 @@
+package recognition
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThresholdCache_LoadsOutsideTheLock(t *testing.T) {
+	calibrated := Thresholds{MinAbsoluteScore: 9, MinScoreRatio: 0.02}
+	release := make(chan struct{})
+	loads := 0
+	cache := &thresholdCache{ttl: time.Minute, current: DefaultThresholds}
+	cache.find = func(ctx context.Context) (*Calibration, error) {
+		loads++
+		<-release
+		return &Calibration{Thresholds: calibrated}, nil
+	}
+
+	loaded := make(chan Thresholds)
+	go func() { loaded <- cache.get(context.Background()) }()
+
+	// Other callers do not wait for the slow lookup
+	assert.Eventually(t, func() bool {
+		cache.mu.RLock()
+		defer cache.mu.RUnlock()
+		return cache.loading
+	}, time.Second, time.Millisecond)
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Equal(t, DefaultThresholds, cache.get(context.Background()))
+		}()
+	}
+	wg.Wait()
+
+	close(release)
+	assert.Equal(t, calibrated, <-loaded)
+	assert.Equal(t, calibrated, cache.get(context.Background()))
+	assert.Equal(t, 1, loads)
+}
@@ -0,0 +1,250 @@
+package recognition
+
+import (
+	"context"
+	"fmt"
+	"go-shazam/internal/fingerprint"
+	"go-shazam/internal/logger"
+	"go-shazam/internal/song"
+	"go-shazam/pkg/landmark"
+	"io"
+	"math"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// AlignmentTolerance is how far (in seconds) the track-to-recording alignment of two windows
+// may drift for them to be merged into one segment.
+const AlignmentTolerance = 1.0
+
+// Timeline lists the tracks identified in a long recording in playback order.
+type Timeline struct {
+	Duration float64           `json:"duration"`
+	Segments []TimelineSegment `json:"segments"`
+}
+
+type TimelineSegment struct {
+	Song       *song.SongEntity `json:"song"`
+	Start      float64          `json:"start"`       // seconds into the recording
+	End        float64          `json:"end"`         // seconds into the recording
+	TimeOffset float64          `json:"time_offset"` // position in the track at Start
+	Confidence float64          `json:"confidence"`  // best window confidence
+}
+
+// windowMatch is the best candidate of one analysis window
+type windowMatch struct {
+	start      float64
+	end        float64
+	songID     uuid.UUID
+	offset     float64 // position in the track at the window start
+	confidence float64
+}
+
+// segment is a run of merged windows before the song is resolved
+type segment struct {
+	songID     uuid.UUID
+	start      float64
+	end        float64
+	anchor     float64 // position in the track at recording time 0
+	confidence float64
+}
+
+// IdentifyTimeline slides overlapping windows over a long recording (a DJ mix, a radio
+// capture) and returns every track it recognises, with adjacent windows merged into segments.
+// It takes the peaks of the recording rather than its spectrum, which long recordings
+// extract chunk by chunk.
+func (s *RecognitionService) IdentifyTimeline(ctx context.Context, peaks []landmark.Peak, duration float64) (*Timeline, error) {
+	sampleHashes := landmark.CreateHashes(peaks, uuid.Nil)
+	if len(sampleHashes) == 0 {
+		return nil, ErrNoFingerprints
+	}
+
+	windows, err := s.matchWindows(ctx, sampleHashes, duration, newMatchCache(s.fingerprintService))
+	if err != nil {
+		return nil, err
+	}
+
+	return s.resolveTimeline(ctx, mergeWindows(windows, s.config.TimelineHop.Seconds()), duration)
+}
+
+// matchWindows scores every window of the recording and keeps the windows whose best
+// candidate passes the threshold.
+func (s *RecognitionService) matchWindows(ctx context.Context, sampleHashes []fingerprint.Hash, duration float64, matches *matchCache) ([]windowMatch, error) {
+	log := logger.FromContext(ctx)
+
+	window := s.config.TimelineWindow.Seconds()
+	hop := s.config.TimelineHop.Seconds()
+	if window <= 0 || hop <= 0 {
+		return nil, fmt.Errorf("invalid timeline window %.1fs / hop %.1fs", window, hop)
+	}
+
+	sort.Slice(sampleHashes, func(i, j int) bool {
+		return sampleHashes[i].TimeOffset < sampleHashes[j].TimeOffset
+	})
+
+	thresholds := s.thresholds.get(ctx)
+	var windows []windowMatch
+	for start := 0.0; start < duration; start += hop {
+		end := math.Min(start+window, duration)
+		// The tail is already covered by the previous window
+		if start > 0 && end-start < window/2 {
+			break
+		}
+
+		from := sort.Search(len(sampleHashes), func(i int) bool { return sampleHashes[i].TimeOffset >= start })
+		to := sort.Search(len(sampleHashes), func(i int) bool { return sampleHashes[i].TimeOffset >= end })
+		if to == from {
+			continue
+		}
+
+		// Rebase on the window start so that offsets are positions in the track
+		windowHashes := make([]fingerprint.Hash, to-from)
+		for i, h := range sampleHashes[from:to] {
+			h.TimeOffset -= start
+			windowHashes[i] = h
+		}
+
+		dbHashes, err := matches.find(ctx, windowHashes)
+		if err != nil {
+			return nil, err
+		}
+
+		threshold := thresholds.matchThreshold(len(windowHashes))
+		candidates := scoreCandidates(windowHashes, dbHashes)
+		if len(candidates) == 0 || candidates[0].score < threshold {
+			continue
+		}
+		candidates = verifyCandidates(windowHashes, dbHashes, candidates, threshold, 1)
+		if len(candidates) == 0 || candidates[0].score < threshold {
+			continue
+		}
+
+		best := candidates[0]
+		windows = append(windows, windowMatch{
+			start:      start,
+			end:        end,
+			songID:     best.songID,
+			offset:     best.offset,
+			confidence: confidence(best.score, competitorScore(candidates, 0), len(windowHashes)),
+		})
+	}
+
+	log.Info("Timeline analysis complete",
+		"duration", duration,
+		"matchedWindows", len(windows),
+		"sampleHashes", len(sampleHashes),
+	)
+
+	return windows, nil
+}
+
+// mergeWindows joins consecutive windows of the same song that agree on the alignment and are
+// at most maxGap seconds apart. Overlaps between different songs are split in the middle.
+func mergeWindows(windows []windowMatch, maxGap float64) []segment {
+	var segments []segment
+	for _, w := range windows {
+		anchor := w.offset - w.start
+		if n := len(segments); n > 0 {
+			last := &segments[n-1]
+			if last.songID == w.songID &&
+				math.Abs(last.anchor-anchor) <= AlignmentTolerance &&
+				w.start-last.end <= maxGap {
+				last.end = math.Max(last.end, w.end)
+				last.confidence = math.Max(last.confidence, w.confidence)
+				continue
+			}
+		}
+		segments = append(segments, segment{
+			songID:     w.songID,
+			start:      w.start,
+			end:        w.end,
+			anchor:     anchor,
+			confidence: w.confidence,
+		})
+	}
+
+	for i := 1; i < len(segments); i++ {
+		prev, next := &segments[i-1], &segments[i]
+		if next.start < prev.end {
+			boundary := (next.start + prev.end) / 2
+			prev.end = boundary
+			next.start = boundary
+		}
+	}
+
+	return segments
+}
+
+// resolveTimeline loads the songs of the merged segments.
+func (s *RecognitionService) resolveTimeline(ctx context.Context, segments []segment, duration float64) (*Timeline, error) {
+	songs := make(map[uuid.UUID]*song.SongEntity)
+	timeline := &Timeline{Duration: duration, Segments: []TimelineSegment{}}
+
+	for _, seg := range segments {
+		songEntity, ok := songs[seg.songID]
+		if !ok {
+			var err error
+			songEntity, err = s.songRepository.FindByID(ctx, seg.songID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to find song %s: %w", seg.songID, err)
+			}
+			songs[seg.songID] = songEntity
+		}
+		if songEntity == nil {
+			continue
+		}
+
+		timeline.Segments = append(timeline.Segments, TimelineSegment{
+			Song:       songEntity,
+			Start:      seg.start,
+			End:        seg.end,
+			TimeOffset: seg.anchor + seg.start,
+			Confidence: seg.confidence,
+		})
+	}
+
+	return timeline, nil
+}
+
+// WriteCUE renders the timeline as a CUE sheet for the given recording file. Each track runs
+// until the next one starts; unidentified parts of the recording are not listed.
+func (t *Timeline) WriteCUE(w io.Writer, title string, file string) error {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "TITLE %s\n", cueQuote(title))
+	fmt.Fprintf(&b, "FILE %s WAVE\n", cueQuote(file))
+	for i, seg := range t.Segments {
+		fmt.Fprintf(&b, "  TRACK %02d AUDIO\n", i+1)
+		fmt.Fprintf(&b, "    TITLE %s\n", cueQuote(seg.Song.Title))
+		fmt.Fprintf(&b, "    PERFORMER %s\n", cueQuote(seg.Song.Artist))
+		fmt.Fprintf(&b, "    INDEX 01 %s\n", cueTimestamp(seg.Start))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// cueFileName names the recording of a CUE sheet after the file of the media URL, or after
+// the job when the URL is a page rather than a file.
+func cueFileName(rawURL string, jobID string) string {
+	if u, err := url.Parse(rawURL); err == nil {
+		if name := path.Base(u.Path); path.Ext(name) != "" {
+			return name
+		}
+	}
+	return jobID + ".wav"
+}
+
+// cueTimestamp formats seconds as mm:ss:ff with 75 frames per second
+func cueTimestamp(seconds float64) string {
+	frames := int(math.Round(math.Max(seconds, 0) * 75))
+	return fmt.Sprintf("%02d:%02d:%02d", frames/(75*60), frames/75%60, frames%75)
+}
+
+func cueQuote(value string) string {
+	return `"` + strings.ReplaceAll(value, `"`, "'") + `"`
+}
@@ -0,0 +1,130 @@
+package recognition
+
+import (
+	"bytes"
+	"context"
+	"go-shazam/internal/fingerprint"
+	"go-shazam/internal/song"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mixHashes simulates a recording of two tracks played back to back: songA from 0s to 20s
+// starting 60s into the track, then songB from 20s to 40s starting 5s into the track.
+func mixHashes(songA, songB uuid.UUID) ([]fingerprint.Hash, hashLookup) {
+	var sample []fingerprint.Hash
+	byHash := make(map[int64][]fingerprint.Hash)
+	for i := 0; i < 800; i++ {
+		t := float64(i) * 0.05
+		value := int64(i)
+		sample = append(sample, fingerprint.Hash{HashValue: value, TimeOffset: t})
+		if t < 20 {
+			byHash[value] = append(byHash[value], fingerprint.Hash{HashValue: value, SongID: songA, TimeOffset: t + 60})
+		} else {
+			byHash[value] = append(byHash[value], fingerprint.Hash{HashValue: value, SongID: songB, TimeOffset: t - 20 + 5})
+		}
+	}
+
+	lookup := func(ctx context.Context, sampleHashes []fingerprint.Hash) ([]fingerprint.Hash, error) {
+		var result []fingerprint.Hash
+		for _, h := range sampleHashes {
+			result = append(result, byHash[h.HashValue]...)
+		}
+		return result, nil
+	}
+	return sample, lookup
+}
+
+func TestMatchWindows_TimelineOfTwoTracks(t *testing.T) {
+	songA := &song.SongEntity{ID: uuid.New(), Title: "First", Artist: "A"}
+	songB := &song.SongEntity{ID: uuid.New(), Title: "Second", Artist: "B"}
+	service := newTestService(songA, songB)
+	service.config.TimelineWindow = 10 * time.Second
+	service.config.TimelineHop = 5 * time.Second
+
+	sample, lookup := mixHashes(songA.ID, songB.ID)
+	windows, err := service.matchWindows(context.Background(), sample, 40, newMatchCacheWithLookup(lookup))
+	require.NoError(t, err)
+
+	timeline, err := service.resolveTimeline(context.Background(), mergeWindows(windows, 5), 40)
+	require.NoError(t, err)
+
+	require.Len(t, timeline.Segments, 2)
+	first, second := timeline.Segments[0], timeline.Segments[1]
+
+	assert.Equal(t, songA, first.Song)
+	assert.Equal(t, 0.0, first.Start)
+	assert.InDelta(t, 60.0, first.TimeOffset, 0.05)
+	assert.Equal(t, first.End, second.Start)
+
+	// The boundary falls in the window shared by both tracks
+	assert.InDelta(t, 20.0, second.Start, 2.5)
+	assert.Equal(t, songB, second.Song)
+	assert.InDelta(t, 5.0+second.Start-20, second.TimeOffset, 0.05)
+	assert.Equal(t, 40.0, second.End)
+}
+
+func TestMergeWindows(t *testing.T) {
+	songA := uuid.New()
+	songB := uuid.New()
+
+	windows := []windowMatch{
+		{start: 0, end: 10, songID: songA, offset: 30, confidence: 0.4},
+		{start: 5, end: 15, songID: songA, offset: 35, confidence: 0.7},
+		// Same song, but the track was restarted: a new segment
+		{start: 10, end: 20, songID: songA, offset: 0, confidence: 0.5},
+		{start: 15, end: 25, songID: songB, offset: 2, confidence: 0.6},
+	}
+
+	segments := mergeWindows(windows, 5)
+
+	require.Len(t, segments, 3)
+	assert.Equal(t, segment{songID: songA, start: 0, end: 12.5, anchor: 30, confidence: 0.7}, segments[0])
+	assert.Equal(t, segment{songID: songA, start: 12.5, end: 17.5, anchor: -10, confidence: 0.5}, segments[1])
+	assert.Equal(t, segment{songID: songB, start: 17.5, end: 25, anchor: -13, confidence: 0.6}, segments[2])
+}
+
+func TestMergeWindows_GapSplitsSegment(t *testing.T) {
+	songID := uuid.New()
+
+	segments := mergeWindows([]windowMatch{
+		{start: 0, end: 10, songID: songID, offset: 0},
+		{start: 30, end: 40, songID: songID, offset: 30},
+	}, 5)
+
+	assert.Len(t, segments, 2)
+}
+
+func TestCueFileName(t *testing.T) {
+	assert.Equal(t, "mix.mp3", cueFileName("https://example.com/mixes/mix.mp3?dl=1", "job"))
+	assert.Equal(t, "job.wav", cueFileName("https://www.youtube.com/watch?v=abc", "job"))
+}
+
+func TestTimeline_WriteCUE(t *testing.T) {
+	timeline := &Timeline{
+		Duration: 400,
+		Segments: []TimelineSegment{
+			{Song: &song.SongEntity{Title: "Intro", Artist: "DJ \"X\""}, Start: 0, End: 185.5},
+			{Song: &song.SongEntity{Title: "Outro", Artist: "Y"}, Start: 185.5, End: 400},
+		},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, timeline.WriteCUE(&buf, "Mix", "mix.wav"))
+
+	assert.Equal(t, `TITLE "Mix"
+FILE "mix.wav" WAVE
+  TRACK 01 AUDIO
+    TITLE "Intro"
+    PERFORMER "DJ 'X'"
+    INDEX 01 00:00:00
+  TRACK 02 AUDIO
+    TITLE "Outro"
+    PERFORMER "Y"
+    INDEX 01 03:05:38
+`, buf.String())
+}
@@ -0,0 +1,327 @@
+package recognition
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-shazam/internal/auth"
+	"go-shazam/internal/queue"
+	"go-shazam/internal/utils/converter"
+	fileutils "go-shazam/internal/utils/file"
+	"go-shazam/pkg/audio"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+)
+
+const (
+	JobStatusPending    = "pending"
+	JobStatusProcessing = "processing"
+	JobStatusCompleted  = "completed"
+	JobStatusFailed     = "failed"
+
+	// Recognition jobs run on the default asynq queue
+	jobQueue = "default"
+	// Stored uploads keep a neutral extension so that the converted WAV never overwrites them
+	uploadExt = ".upload"
+	// Failed recognition jobs are retried this many times before the upload is removed
+	uploadMaxRetry = 2
+)
+
+var (
+	ErrUnsupportedAudio = errors.New("unsupported or corrupted audio file")
+	ErrAudioTooShort    = errors.New("audio is too short to recognise")
+	ErrAudioTooLong     = errors.New("audio is too long")
+	ErrJobNotFound      = errors.New("recognition job not found")
+	ErrNoTimeline       = errors.New("recognition job has no timeline")
+	ErrNoFingerprints   = errors.New("no fingerprints generated from audio")
+)
+
+// UploadService recognises uploaded audio files. Short files are answered right away, long
+// files are handed to the worker and their result is polled by job ID.
+type UploadService struct {
+	config             *Config
+	recognitionService *RecognitionService
+	queue              queue.QueueService
+	inspector          queue.Inspector
+	probe              func(path string) (time.Duration, error)
+}
+
+func NewUploadService(
+	config *Config,
+	recognitionService *RecognitionService,
+	queue queue.QueueService,
+	inspector queue.Inspector,
+) *UploadService {
+	return &UploadService{
+		config:             config,
+		recognitionService: recognitionService,
+		queue:              queue,
+		inspector:          inspector,
+		probe:              converter.ProbeDuration,
+	}
+}
+
+// UploadResult holds either the recognition result or the job that will produce it
+type UploadResult struct {
+	Result *RecognitionResponse
+	Job    *RecognitionJobResponse
+}
+
+// Recognize stores the uploaded file, then recognises it synchronously or enqueues a recognition
+// job depending on its duration. The duration is probed from the container, so long files are
+// only decoded by the worker. The job adds its result to the history of the authenticated user
+// with the given client metadata.
+func (s *UploadService) Recognize(ctx context.Context, file io.Reader, metadata json.RawMessage) (*UploadResult, error) {
+	jobID := uuid.NewString()
+	filename := jobID + uploadExt
+	path := filepath.Join(s.config.UploadDir, filename)
+
+	if err := fileutils.SaveFile(s.config.UploadDir, filename, file, nil); err != nil {
+		return nil, fmt.Errorf("failed to save upload: %w", err)
+	}
+
+	duration, err := s.probeDuration(path)
+	if err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+
+	// Containers without a duration are decoded to learn it
+	if duration <= s.config.UploadSyncMaxDuration {
+		samples, err := s.decode(path)
+		if err != nil {
+			return nil, err
+		}
+		duration = samplesDuration(samples)
+		if duration <= s.config.UploadSyncMaxDuration {
+			os.Remove(path)
+			result, err := s.recognizeSamples(ctx, samples)
+			if err != nil {
+				return nil, err
+			}
+			return &UploadResult{Result: result}, nil
+		}
+	}
+
+	userID, _ := auth.GetUserIDFromContext(ctx)
+	payload, err := json.Marshal(RecognizeUploadTaskPayload{
+		File:     filename,
+		UserID:   userID,
+		Metadata: metadata,
+	})
+	if err != nil {
+		os.Remove(path)
+		return nil, fmt.Errorf("failed to marshal task payload: %w", err)
+	}
+
+	_, err = s.queue.Enqueue(RecognizeUploadTaskType, payload,
+		asynq.TaskID(jobID),
+		asynq.MaxRetry(uploadMaxRetry),
+		asynq.Retention(s.config.JobRetention),
+	)
+	if err != nil {
+		os.Remove(path)
+		return nil, fmt.Errorf("failed to enqueue recognition task: %w", err)
+	}
+
+	return &UploadResult{Job: &RecognitionJobResponse{ID: jobID, Status: JobStatusPending}}, nil
+}
+
+// Explain recognises the uploaded file synchronously, whatever its duration, and explains
+// how the candidates were scored.
+func (s *UploadService) Explain(ctx context.Context, file io.Reader) (*ExplainResponse, error) {
+	filename := uuid.NewString() + uploadExt
+	samples, err := s.load(filename, file)
+	if err != nil {
+		return nil, err
+	}
+	os.Remove(filepath.Join(s.config.UploadDir, filename))
+
+	if len(samples) < audio.WindowSize {
+		return nil, ErrAudioTooShort
+	}
+	fragments, err := audio.ProcessAudio(samples, audio.TargetSampleRate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to process audio: %w", err)
+	}
+	return s.recognitionService.ExplainSong(ctx, fragments, audio.TargetSampleRate)
+}
+
+// load stores the upload under filename and decodes it.
+func (s *UploadService) load(filename string, file io.Reader) ([]float64, error) {
+	if err := fileutils.SaveFile(s.config.UploadDir, filename, file, nil); err != nil {
+		return nil, fmt.Errorf("failed to save upload: %w", err)
+	}
+	return s.decode(filepath.Join(s.config.UploadDir, filename))
+}
+
+// probeDuration returns the duration recorded in the stored upload, or 0 if it has none. The
+// upload is rejected if the duration is longer than UploadMaxDuration.
+func (s *UploadService) probeDuration(path string) (time.Duration, error) {
+	duration, err := s.probe(path)
+	if err != nil {
+		if converter.IsRejected(err) {
+			return 0, fmt.Errorf("%w: %s", ErrUnsupportedAudio, err)
+		}
+		return 0, fmt.Errorf("failed to probe upload: %w", err)
+	}
+	if duration > s.config.UploadMaxDuration {
+		return 0, fmt.Errorf("%w: %s exceeds the limit of %s", ErrAudioTooLong, duration.Round(time.Second), s.config.UploadMaxDuration)
+	}
+	return duration, nil
+}
+
+// decode decodes the stored upload. It is removed if the audio cannot be decoded or is longer
+// than UploadMaxDuration.
+func (s *UploadService) decode(path string) ([]float64, error) {
+	samples, err := decodeAudioFile(path)
+	if err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+
+	if duration := samplesDuration(samples); duration > s.config.UploadMaxDuration {
+		os.Remove(path)
+		return nil, fmt.Errorf("%w: %s exceeds the limit of %s", ErrAudioTooLong, duration.Round(time.Second), s.config.UploadMaxDuration)
+	}
+	return samples, nil
+}
+
+// RecognizeFile recognises a file stored in the upload directory.
+func (s *UploadService) RecognizeFile(ctx context.Context, filename string) (*RecognitionResponse, error) {
+	samples, err := decodeAudioFile(filepath.Join(s.config.UploadDir, filepath.Base(filename)))
+	if err != nil {
+		return nil, err
+	}
+	return s.recognizeSamples(ctx, samples)
+}
+
+// RemoveFile deletes a stored upload once it is no longer needed.
+func (s *UploadService) RemoveFile(filename string) error {
+	err := os.Remove(filepath.Join(s.config.UploadDir, filepath.Base(filename)))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// GetJob returns the state of an upload or URL recognition job and its result once completed.
+func (s *UploadService) GetJob(ctx context.Context, jobID string) (*RecognitionJobResponse, error) {
+	info, err := s.getTaskInfo(jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	return newJobResponse(info)
+}
+
+// ExportCUE writes the timeline of a completed URL recognition job as a CUE sheet titled
+// with the URL. Jobs without a timeline, pending or of uploads, give ErrNoTimeline.
+func (s *UploadService) ExportCUE(ctx context.Context, jobID string, w io.Writer) error {
+	info, err := s.getTaskInfo(jobID)
+	if err != nil {
+		return err
+	}
+	job, err := newJobResponse(info)
+	if err != nil {
+		return err
+	}
+	if job.Timeline == nil {
+		return ErrNoTimeline
+	}
+
+	var payload RecognizeURLTaskPayload
+	if err := json.Unmarshal(info.Payload, &payload); err != nil {
+		return fmt.Errorf("failed to unmarshal job payload: %w", err)
+	}
+	return job.Timeline.WriteCUE(w, payload.URL, cueFileName(payload.URL, jobID))
+}
+
+func (s *UploadService) getTaskInfo(jobID string) (*asynq.TaskInfo, error) {
+	info, err := s.inspector.GetTaskInfo(jobQueue, jobID)
+	if err != nil {
+		if errors.Is(err, queue.ErrTaskNotFound) {
+			return nil, ErrJobNotFound
+		}
+		return nil, fmt.Errorf("failed to get job: %w", err)
+	}
+	if info.Type != RecognizeUploadTaskType && info.Type != RecognizeURLTaskType {
+		return nil, ErrJobNotFound
+	}
+	return info, nil
+}
+
+func (s *UploadService) recognizeSamples(ctx context.Context, samples []float64) (*RecognitionResponse, error) {
+	if len(samples) < audio.WindowSize {
+		return nil, ErrAudioTooShort
+	}
+
+	return s.recognitionService.IdentifySamples(ctx, samples)
+}
+
+func newJobResponse(info *asynq.TaskInfo) (*RecognitionJobResponse, error) {
+	job := &RecognitionJobResponse{ID: info.ID}
+
+	switch info.State {
+	case asynq.TaskStateActive:
+		job.Status = JobStatusProcessing
+	case asynq.TaskStateCompleted:
+		job.Status = JobStatusCompleted
+		if info.Type == RecognizeURLTaskType {
+			var timeline Timeline
+			if err := json.Unmarshal(info.Result, &timeline); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal job result: %w", err)
+			}
+			job.Timeline = &timeline
+			break
+		}
+		var result RecognitionResponse
+		if err := json.Unmarshal(info.Result, &result); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal job result: %w", err)
+		}
+		job.Result = &result
+	case asynq.TaskStateArchived:
+		job.Status = JobStatusFailed
+		job.Error = info.LastErr
+	default:
+		// Pending, scheduled, retry and aggregating tasks are still waiting for a worker
+		job.Status = JobStatusPending
+	}
+
+	return job, nil
+}
+
+func samplesDuration(samples []float64) time.Duration {
+	return time.Duration(float64(len(samples)) / audio.TargetSampleRate * float64(time.Second))
+}
+
+// decodeAudioFile converts any format ffmpeg understands to mono samples at the target rate.
+// Only files ffmpeg rejects are ErrUnsupportedAudio.
+func decodeAudioFile(path string) ([]float64, error) {
+	wavPath, err := converter.ConvertToWav(path, audio.TargetSampleRate)
+	if err != nil {
+		return nil, decodeError(err)
+	}
+	defer os.Remove(wavPath)
+
+	samples, _, err := audio.LoadWav(wavPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load converted audio: %w", err)
+	}
+	return samples, nil
+}
+
+// decodeError tells a file ffmpeg rejected, which is ErrUnsupportedAudio, from a failure to
+// run ffmpeg, which a retry may get past.
+func decodeError(err error) error {
+	if converter.IsRejected(err) {
+		return fmt.Errorf("%w: %s", ErrUnsupportedAudio, err)
+	}
+	return fmt.Errorf("failed to decode audio: %w", err)
+}
@@ -0,0 +1,284 @@
+package recognition
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"go-shazam/internal/queue"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubInspector serves task infos from memory
+type stubInspector struct {
+	tasks map[string]*asynq.TaskInfo
+}
+
+func (i *stubInspector) GetTaskInfo(queueName string, id string) (*asynq.TaskInfo, error) {
+	info, ok := i.tasks[id]
+	if !ok {
+		return nil, queue.ErrTaskNotFound
+	}
+	return info, nil
+}
+
+func (i *stubInspector) Close() error {
+	return nil
+}
+
+func setupUploadRouter(inspector queue.Inspector) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	config := &Config{UploadMaxBytes: 1024, FingerprintMaxBytes: 1024}
+	handler := NewRecognitionHandler(nil, NewUploadService(config, nil, nil, inspector), nil, config, nil)
+	router := gin.New()
+	RegisterRoutes(router, handler, testJWTService)
+	return router
+}
+
+func TestNewJobResponse(t *testing.T) {
+	result, err := json.Marshal(RecognitionResponse{Found: false, Candidates: []MatchResult{}})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		info     *asynq.TaskInfo
+		expected RecognitionJobResponse
+	}{
+		{
+			name:     "pending",
+			info:     &asynq.TaskInfo{ID: "job", State: asynq.TaskStatePending},
+			expected: RecognitionJobResponse{ID: "job", Status: JobStatusPending},
+		},
+		{
+			name:     "retry",
+			info:     &asynq.TaskInfo{ID: "job", State: asynq.TaskStateRetry, LastErr: "db is down"},
+			expected: RecognitionJobResponse{ID: "job", Status: JobStatusPending},
+		},
+		{
+			name:     "active",
+			info:     &asynq.TaskInfo{ID: "job", State: asynq.TaskStateActive},
+			expected: RecognitionJobResponse{ID: "job", Status: JobStatusProcessing},
+		},
+		{
+			name: "completed",
+			info: &asynq.TaskInfo{ID: "job", State: asynq.TaskStateCompleted, Result: result},
+			expected: RecognitionJobResponse{ID: "job", Status: JobStatusCompleted, Result: &RecognitionResponse{
+				Found:      false,
+				Candidates: []MatchResult{},
+			}},
+		},
+		{
+			name: "completed URL recognition",
+			info: &asynq.TaskInfo{ID: "job", Type: RecognizeURLTaskType, State: asynq.TaskStateCompleted, Result: []byte(`{"duration": 60, "segments": []}`)},
+			expected: RecognitionJobResponse{ID: "job", Status: JobStatusCompleted, Timeline: &Timeline{
+				Duration: 60,
+				Segments: []TimelineSegment{},
+			}},
+		},
+		{
+			name:     "archived",
+			info:     &asynq.TaskInfo{ID: "job", State: asynq.TaskStateArchived, LastErr: "unsupported or corrupted audio file"},
+			expected: RecognitionJobResponse{ID: "job", Status: JobStatusFailed, Error: "unsupported or corrupted audio file"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job, err := newJobResponse(tt.info)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, *job)
+		})
+	}
+}
+
+func TestUploadService_GetJob_IgnoresOtherTaskTypes(t *testing.T) {
+	jobID := uuid.NewString()
+	inspector := &stubInspector{tasks: map[string]*asynq.TaskInfo{
+		jobID: {ID: jobID, Type: "song:add_song", State: asynq.TaskStateCompleted},
+	}}
+	service := NewUploadService(&Config{}, nil, nil, inspector)
+
+	_, err := service.GetJob(context.Background(), jobID)
+
+	assert.ErrorIs(t, err, ErrJobNotFound)
+}
+
+func TestUploadService_Recognize_LongUploadIsNotDecoded(t *testing.T) {
+	dir := t.TempDir()
+	jobs := &stubQueue{}
+	config := &Config{UploadDir: dir, UploadSyncMaxDuration: time.Minute, UploadMaxDuration: time.Hour}
+	service := NewUploadService(config, nil, jobs, nil)
+	service.probe = func(path string) (time.Duration, error) {
+		return 10 * time.Minute, nil
+	}
+
+	// Not audio at all: decoding it would fail
+	result, err := service.Recognize(context.Background(), strings.NewReader("not audio"), nil)
+
+	require.NoError(t, err)
+	require.NotNil(t, result.Job)
+	require.Len(t, jobs.tasks, 1)
+	assert.Equal(t, RecognizeUploadTaskType, jobs.tasks[0].Type())
+	assert.FileExists(t, filepath.Join(dir, result.Job.ID+uploadExt), "kept for the worker")
+}
+
+func TestUploadService_Recognize_TooLong(t *testing.T) {
+	dir := t.TempDir()
+	jobs := &stubQueue{}
+	config := &Config{UploadDir: dir, UploadSyncMaxDuration: time.Minute, UploadMaxDuration: time.Hour}
+	service := NewUploadService(config, nil, jobs, nil)
+	service.probe = func(path string) (time.Duration, error) {
+		return 2 * time.Hour, nil
+	}
+
+	_, err := service.Recognize(context.Background(), strings.NewReader("not audio"), nil)
+
+	assert.ErrorIs(t, err, ErrAudioTooLong)
+	assert.Empty(t, jobs.tasks)
+	files, _ := os.ReadDir(dir)
+	assert.Empty(t, files, "the upload is removed")
+}
+
+func TestRecognitionHandler_GetJob(t *testing.T) {
+	jobID := uuid.NewString()
+	router := setupUploadRouter(&stubInspector{tasks: map[string]*asynq.TaskInfo{
+		jobID: {ID: jobID, Type: RecognizeUploadTaskType, State: asynq.TaskStateActive},
+	}})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/recognize/jobs/"+jobID, nil))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var job RecognitionJobResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+	assert.Equal(t, RecognitionJobResponse{ID: jobID, Status: JobStatusProcessing}, job)
+}
+
+func TestRecognitionHandler_GetJob_CUE(t *testing.T) {
+	urlJob, uploadJob := uuid.NewString(), uuid.NewString()
+	timeline := `{"duration": 400, "segments": [
+		{"song": {"Title": "Intro", "Artist": "DJ X"}, "start": 0, "end": 185.5},
+		{"song": {"Title": "Outro", "Artist": "Y"}, "start": 185.5, "end": 400}
+	]}`
+	router := setupUploadRouter(&stubInspector{tasks: map[string]*asynq.TaskInfo{
+		urlJob: {
+			ID:      urlJob,
+			Type:    RecognizeURLTaskType,
+			State:   asynq.TaskStateCompleted,
+			Payload: []byte(`{"url": "https://example.com/mixes/mix.mp3"}`),
+			Result:  []byte(timeline),
+		},
+		uploadJob: {ID: uploadJob, Type: RecognizeUploadTaskType, State: asynq.TaskStateCompleted, Result: []byte(`{"found": false}`)},
+	}})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/recognize/jobs/"+urlJob+"?format=cue", nil))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="`+urlJob+`.cue"`, w.Header().Get("Content-Disposition"))
+	assert.Equal(t, `TITLE "https://example.com/mixes/mix.mp3"
+FILE "mix.mp3" WAVE
+  TRACK 01 AUDIO
+    TITLE "Intro"
+    PERFORMER "DJ X"
+    INDEX 01 00:00:00
+  TRACK 02 AUDIO
+    TITLE "Outro"
+    PERFORMER "Y"
+    INDEX 01 03:05:38
+`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/recognize/jobs/"+uploadJob+"?format=cue", nil))
+	assert.Equal(t, http.StatusConflict, w.Code, "uploads have no timeline")
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/recognize/jobs/"+urlJob+"?format=xml", nil))
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+}
+
+func TestRecognitionHandler_GetJob_NotFound(t *testing.T) {
+	router := setupUploadRouter(&stubInspector{})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/recognize/jobs/"+uuid.NewString(), nil))
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestRecognitionHandler_GetJob_InvalidID(t *testing.T) {
+	router := setupUploadRouter(&stubInspector{})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/recognize/jobs/not-a-uuid", nil))
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestRecognitionHandler_Upload_MissingFile(t *testing.T) {
+	router := setupUploadRouter(&stubInspector{})
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	require.NoError(t, form.WriteField("name", "clip"))
+	require.NoError(t, form.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/api/recognize", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+}
+
+func TestRecognitionHandler_Upload_InvalidMetadata(t *testing.T) {
+	router := setupUploadRouter(&stubInspector{})
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", "clip.mp3")
+	require.NoError(t, err)
+	_, err = part.Write([]byte("ID3"))
+	require.NoError(t, err)
+	require.NoError(t, form.WriteField("metadata", `["not", "an", "object"]`))
+	require.NoError(t, form.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/api/recognize", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+}
+
+func TestRecognitionHandler_Upload_TooLarge(t *testing.T) {
+	router := setupUploadRouter(&stubInspector{})
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", "clip.mp3")
+	require.NoError(t, err)
+	_, err = part.Write(make([]byte, 4096))
+	require.NoError(t, err)
+	require.NoError(t, form.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/api/recognize", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+}
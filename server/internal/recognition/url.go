@@ -0,0 +1,136 @@
+package recognition
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go-shazam/internal/queue"
+	"go-shazam/pkg/audio"
+	"go-shazam/pkg/landmark"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+)
+
+const (
+	// Downloaded media keeps a neutral extension, like uploads
+	mediaExt = ".media"
+	// Failed URL recognitions are retried this many times, for media hosts that are briefly down
+	urlMaxRetry = 2
+)
+
+// URLService recognises the songs playing in remote media. Downloads can take minutes, so
+// every URL is recognised by a background job whose timeline is polled by job ID.
+type URLService struct {
+	config             *Config
+	recognitionService *RecognitionService
+	fetcher            *MediaFetcher
+	queue              queue.QueueService
+}
+
+func NewURLService(
+	config *Config,
+	recognitionService *RecognitionService,
+	fetcher *MediaFetcher,
+	queue queue.QueueService,
+) *URLService {
+	return &URLService{
+		config:             config,
+		recognitionService: recognitionService,
+		fetcher:            fetcher,
+		queue:              queue,
+	}
+}
+
+// Submit enqueues the recognition of the media behind rawURL.
+func (s *URLService) Submit(ctx context.Context, rawURL string) (*RecognitionJobResponse, error) {
+	u, err := ParseMediaURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := json.Marshal(RecognizeURLTaskPayload{URL: u.String()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal task payload: %w", err)
+	}
+
+	jobID := uuid.NewString()
+	_, err = s.queue.Enqueue(RecognizeURLTaskType, payload,
+		asynq.TaskID(jobID),
+		asynq.MaxRetry(urlMaxRetry),
+		asynq.Retention(s.config.JobRetention),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to enqueue recognition task: %w", err)
+	}
+
+	return &RecognitionJobResponse{ID: jobID, Status: JobStatusPending}, nil
+}
+
+// RecognizeURL downloads the media, converts its audio and lists the songs it contains.
+func (s *URLService) RecognizeURL(ctx context.Context, rawURL string) (*Timeline, error) {
+	if err := os.MkdirAll(s.config.UploadDir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create download directory: %w", err)
+	}
+	path := filepath.Join(s.config.UploadDir, uuid.NewString()+mediaExt)
+	defer os.Remove(path)
+
+	downloadCtx, cancel := context.WithTimeout(ctx, s.config.URLDownloadTimeout)
+	err := s.fetcher.Fetch(downloadCtx, rawURL, path)
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+
+	// Decoding stops a second past the limit, enough to tell that the media is too long
+	limit := time.Duration(0)
+	if s.config.URLMaxDuration > 0 {
+		limit = s.config.URLMaxDuration + time.Second
+	}
+	var extractor peakExtractor
+	if err := audio.DecodeFileChunks(path, audio.TargetSampleRate, limit, extractor.add); err != nil {
+		return nil, decodeError(err)
+	}
+
+	duration := time.Duration(float64(extractor.decoded) / audio.TargetSampleRate * float64(time.Second))
+	if s.config.URLMaxDuration > 0 && duration > s.config.URLMaxDuration {
+		return nil, fmt.Errorf("%w: longer than the limit of %s", ErrAudioTooLong, s.config.URLMaxDuration)
+	}
+	if extractor.decoded < audio.WindowSize {
+		return nil, ErrAudioTooShort
+	}
+	return s.recognitionService.IdentifyTimeline(ctx, extractor.peaks, extractor.duration)
+}
+
+// peakExtractor turns decoded audio into spectrogram peaks chunk by chunk. Only the samples
+// of the next window are kept, so long media never sits in memory as samples or spectra.
+type peakExtractor struct {
+	samples  []float64 // samples not covered by a whole window yet
+	offset   int       // position of samples[0] in the recording
+	decoded  int       // samples decoded so far
+	duration float64   // seconds covered by the fragments processed so far
+	peaks    []landmark.Peak
+}
+
+func (e *peakExtractor) add(samples []float64) {
+	e.samples = append(e.samples, samples...)
+	e.decoded += len(samples)
+	if len(e.samples) < audio.WindowSize {
+		return
+	}
+
+	fragments, _ := audio.ProcessAudio(e.samples, audio.TargetSampleRate)
+	// Offsets are relative to the chunk; rebase them on the start of the recording
+	for i := range fragments {
+		fragments[i].TimeOffset = float64(e.offset+i*audio.HopSize) / audio.TargetSampleRate
+	}
+	e.peaks = append(e.peaks, landmark.ExtractPeaks(fragments, audio.TargetSampleRate)...)
+	e.duration = queryDuration(fragments, audio.TargetSampleRate)
+
+	processed := len(fragments) * audio.HopSize
+	e.samples = append([]float64(nil), e.samples[processed:]...)
+	e.offset += processed
+}
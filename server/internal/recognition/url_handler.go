@@ -0,0 +1,53 @@
+package recognition
+
+import (
+	"errors"
+	"go-shazam/internal/auth"
+	"go-shazam/internal/logger"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type URLHandler struct {
+	urlService *URLService
+}
+
+func NewURLHandler(urlService *URLService) *URLHandler {
+	return &URLHandler{urlService: urlService}
+}
+
+// RegisterURLRoutes registers the recognition of remote media. Downloads cost the server
+// bandwidth and time, so only signed-in users can submit URLs.
+func RegisterURLRoutes(r *gin.Engine, h *URLHandler, jwtService *auth.JWTService) {
+	r.POST("/api/recognize/url", auth.AuthMiddleware(jwtService), h.Recognize)
+}
+
+type URLRecognitionRequest struct {
+	URL string `json:"url" binding:"required"`
+}
+
+// Recognize enqueues the recognition of the media behind a URL and answers with 202 and the
+// job to poll at /api/recognize/jobs/:id. The completed job holds the timeline of the songs
+// found in the media.
+func (h *URLHandler) Recognize(c *gin.Context) {
+	var req URLRecognitionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+
+	job, err := h.urlService.Submit(c.Request.Context(), req.URL)
+	if err != nil {
+		if errors.Is(err, ErrInvalidMediaURL) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+		logger.FromContext(c.Request.Context()).Error("failed to submit URL recognition", "error", err, "url", req.URL)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to submit URL recognition"})
+		return
+	}
+
+	c.Header("Location", "/api/recognize/jobs/"+job.ID)
+	c.JSON(http.StatusAccepted, job)
+}
@@ -0,0 +1,368 @@
+package recognition
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"go-shazam/internal/fingerprint"
+	"go-shazam/internal/song"
+	"go-shazam/pkg/audio"
+	"go-shazam/pkg/landmark"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubMediaDownloader records the pages it was asked to download and writes content to them.
+// With fetch set it downloads that URL through the proxy of the download instead, like yt-dlp.
+type stubMediaDownloader struct {
+	downloads []MediaDownload
+	content   []byte
+	fetch     string
+	transport *http.Transport // base transport of the fetch, trusting the TLS test server
+}
+
+func (d *stubMediaDownloader) DownloadMedia(ctx context.Context, download MediaDownload) error {
+	d.downloads = append(d.downloads, download)
+	if d.fetch == "" {
+		return os.WriteFile(download.Path, d.content, 0o644)
+	}
+
+	proxy, err := url.Parse(download.Proxy)
+	if err != nil {
+		return err
+	}
+	transport := d.transport.Clone()
+	transport.Proxy = http.ProxyURL(proxy)
+	defer transport.CloseIdleConnections()
+
+	resp, err := (&http.Client{Transport: transport}).Get(d.fetch)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("proxy answered %d", resp.StatusCode)
+	}
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(download.Path, content, 0o644)
+}
+
+// stubQueue records the enqueued tasks
+type stubQueue struct {
+	tasks []*asynq.Task
+}
+
+func (q *stubQueue) Enqueue(taskType string, payload []byte, opts ...asynq.Option) (*asynq.TaskInfo, error) {
+	q.tasks = append(q.tasks, asynq.NewTask(taskType, payload, opts...))
+	return &asynq.TaskInfo{Type: taskType, Payload: payload}, nil
+}
+
+func (q *stubQueue) Close() error {
+	return nil
+}
+
+// mediaServer serves the fixture files of the URL tests
+func mediaServer(t *testing.T, wav []byte) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/clip.wav", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "audio/wav")
+		w.Write(wav)
+	})
+	mux.HandleFunc("/stream.mp3", func(w http.ResponseWriter, r *http.Request) {
+		// No Content-Length: the size is only known while downloading
+		w.Header().Set("Content-Type", "audio/mpeg")
+		for i := 0; i < 4; i++ {
+			w.Write(make([]byte, 512))
+			w.(http.Flusher).Flush()
+		}
+	})
+	mux.HandleFunc("/watch", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, "<html><video src=\"/clip.wav\"></video></html>")
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// wavFile encodes samples at audio.TargetSampleRate as a 16-bit mono WAV file
+func wavFile(samples []float64) []byte {
+	var data bytes.Buffer
+	for _, s := range samples {
+		binary.Write(&data, binary.LittleEndian, int16(math.Max(-1, math.Min(1, s))*math.MaxInt16))
+	}
+
+	var file bytes.Buffer
+	file.WriteString("RIFF")
+	binary.Write(&file, binary.LittleEndian, uint32(36+data.Len()))
+	file.WriteString("WAVEfmt ")
+	for _, field := range []any{uint32(16), uint16(1), uint16(1), uint32(audio.TargetSampleRate), uint32(audio.TargetSampleRate * 2), uint16(2), uint16(16)} {
+		binary.Write(&file, binary.LittleEndian, field)
+	}
+	file.WriteString("data")
+	binary.Write(&file, binary.LittleEndian, uint32(data.Len()))
+	file.Write(data.Bytes())
+	return file.Bytes()
+}
+
+func TestMediaFetcher_DirectAudio(t *testing.T) {
+	wav := wavFile(toneTrack(1, 1))
+	server := mediaServer(t, wav)
+	downloader := &stubMediaDownloader{}
+	fetcher := NewMediaFetcher(&Config{URLMaxBytes: 1 << 20, URLAllowPrivate: true}, downloader)
+	path := filepath.Join(t.TempDir(), "clip"+mediaExt)
+
+	require.NoError(t, fetcher.Fetch(context.Background(), server.URL+"/clip.wav", path))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, wav, data)
+	assert.Empty(t, downloader.downloads)
+}
+
+// mediaServers serves the fixture files over HTTP and HTTPS
+func mediaServers(t *testing.T, wav []byte) []*httptest.Server {
+	plain := mediaServer(t, wav)
+	secure := httptest.NewTLSServer(plain.Config.Handler)
+	t.Cleanup(secure.Close)
+	return []*httptest.Server{plain, secure}
+}
+
+func TestMediaFetcher_PageGoesToDownloaderThroughProxy(t *testing.T) {
+	wav := wavFile(toneTrack(1, 1))
+
+	for _, server := range mediaServers(t, wav) {
+		t.Run(server.URL, func(t *testing.T) {
+			downloader := &stubMediaDownloader{fetch: server.URL + "/clip.wav", transport: server.Client().Transport.(*http.Transport)}
+			config := &Config{URLAllowPrivate: true, URLMediaHosts: []string{"media.example"}, URLExtractors: []string{"youtube"}}
+			path := filepath.Join(t.TempDir(), "clip"+mediaExt)
+
+			// Not fetched by the server: pages of media sites go straight to the downloader
+			require.NoError(t, NewMediaFetcher(config, downloader).Fetch(context.Background(), "https://www.Media.example/watch?v=1", path))
+
+			require.Len(t, downloader.downloads, 1)
+			assert.Equal(t, "https://www.Media.example/watch?v=1", downloader.downloads[0].URL)
+			assert.Equal(t, []string{"youtube"}, downloader.downloads[0].Extractors)
+			data, err := os.ReadFile(path)
+			require.NoError(t, err)
+			assert.Equal(t, wav, data)
+		})
+	}
+}
+
+func TestMediaFetcher_DownloaderCannotReachPrivateAddress(t *testing.T) {
+	for _, server := range mediaServers(t, wavFile(toneTrack(1, 1))) {
+		t.Run(server.URL, func(t *testing.T) {
+			downloader := &stubMediaDownloader{fetch: server.URL + "/clip.wav", transport: server.Client().Transport.(*http.Transport)}
+			config := &Config{URLMediaHosts: []string{"media.example"}}
+			path := filepath.Join(t.TempDir(), "clip"+mediaExt)
+
+			err := NewMediaFetcher(config, downloader).Fetch(context.Background(), "https://media.example/watch?v=1", path)
+
+			assert.ErrorIs(t, err, ErrPrivateAddress)
+			assert.NoFileExists(t, path)
+		})
+	}
+}
+
+func TestRejectPrivateAddress(t *testing.T) {
+	tests := []struct {
+		ip      string
+		private bool
+	}{
+		{"93.184.216.34", false},
+		{"2606:2800:220:1:248:1893:25c8:1946", false},
+		{"127.0.0.1", true},
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true},
+		{"0.0.0.0", true},
+		{"0.1.2.3", true},
+		{"100.64.0.1", true},
+		{"100.127.255.254", true},
+		{"100.128.0.1", false},
+		{"192.0.0.170", true},
+		{"198.18.0.1", true},
+		{"198.19.255.254", true},
+		{"240.0.0.1", true},
+		{"255.255.255.255", true},
+		{"::1", true},
+		{"::", true},
+		{"fc00::1", true},
+		{"fe80::1", true},
+		{"::ffff:10.0.0.1", true},
+		{"64:ff9b::a00:1", true},
+		{"64:ff9b::5db8:d822", true},
+		{"64:ff9b:1::1", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			err := rejectPrivateAddress("tcp", net.JoinHostPort(tt.ip, "443"), nil)
+			if tt.private {
+				assert.ErrorIs(t, err, ErrPrivateAddress)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestPeakExtractor_MatchesWholeRecording(t *testing.T) {
+	samples := toneTrack(5, 1)
+	fragments, err := audio.ProcessAudio(samples, audio.TargetSampleRate)
+	require.NoError(t, err)
+
+	var extractor peakExtractor
+	for start := 0; start < len(samples); start += 3001 {
+		extractor.add(samples[start:min(start+3001, len(samples))])
+	}
+
+	assert.Equal(t, landmark.ExtractPeaks(fragments, audio.TargetSampleRate), extractor.peaks)
+	assert.InDelta(t, queryDuration(fragments, audio.TargetSampleRate), extractor.duration, 1e-9)
+	assert.Equal(t, len(samples), extractor.decoded)
+}
+
+func TestMediaFetcher_Errors(t *testing.T) {
+	server := mediaServer(t, make([]byte, 4096))
+
+	tests := []struct {
+		name     string
+		config   *Config
+		url      string
+		expected error
+	}{
+		{"content length over the limit", &Config{URLMaxBytes: 1024, URLAllowPrivate: true}, server.URL + "/clip.wav", ErrMediaTooLarge},
+		{"stream over the limit", &Config{URLMaxBytes: 1024, URLAllowPrivate: true}, server.URL + "/stream.mp3", ErrMediaTooLarge},
+		{"missing", &Config{URLAllowPrivate: true}, server.URL + "/missing.mp3", ErrMediaUnavailable},
+		{"page of another site", &Config{URLAllowPrivate: true}, server.URL + "/watch?v=1", ErrUnsupportedMedia},
+		{"private address", &Config{}, server.URL + "/clip.wav", ErrPrivateAddress},
+		{"unsupported scheme", &Config{}, "ftp://example.com/clip.mp3", ErrInvalidMediaURL},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "clip"+mediaExt)
+
+			err := NewMediaFetcher(tt.config, &stubMediaDownloader{}).Fetch(context.Background(), tt.url, path)
+
+			assert.ErrorIs(t, err, tt.expected)
+			assert.NoFileExists(t, path)
+		})
+	}
+}
+
+func TestRecognizeURLTaskHandler_SkipsRetryOfTooLargeMedia(t *testing.T) {
+	server := mediaServer(t, make([]byte, 4096))
+	config := &Config{UploadDir: t.TempDir(), URLMaxBytes: 1024, URLDownloadTimeout: time.Minute, URLAllowPrivate: true}
+	handler := NewRecognizeURLTaskHandler(NewURLService(config, nil, NewMediaFetcher(config, &stubMediaDownloader{}), nil))
+	payload, err := json.Marshal(RecognizeURLTaskPayload{URL: server.URL + "/clip.wav"})
+	require.NoError(t, err)
+
+	err = handler.ProcessTask(context.Background(), asynq.NewTask(RecognizeURLTaskType, payload))
+
+	assert.ErrorContains(t, err, ErrMediaTooLarge.Error())
+	assert.ErrorIs(t, err, asynq.SkipRetry)
+	entries, err := os.ReadDir(config.UploadDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "the download is removed")
+}
+
+func TestURLService_RecognizeURL(t *testing.T) {
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		t.Skip("ffmpeg is not installed")
+	}
+
+	store := fingerprint.NewMemoryStore()
+	fingerprintService := fingerprint.NewFingerprintServiceWithStore(store)
+	track := &song.SongEntity{ID: uuid.New(), Title: "Track"}
+	samples := toneTrack(40, 1)
+	fragments, err := audio.ProcessAudio(samples, audio.TargetSampleRate)
+	require.NoError(t, err)
+	require.NoError(t, store.SaveFingerprints(context.Background(), fingerprintService.CreateFingerprints(fragments, track.ID, audio.TargetSampleRate)))
+
+	config := &Config{
+		MaxCandidates:      5,
+		TimelineWindow:     10 * time.Second,
+		TimelineHop:        5 * time.Second,
+		UploadDir:          t.TempDir(),
+		URLMaxBytes:        10 << 20,
+		URLMaxDuration:     time.Minute,
+		URLDownloadTimeout: time.Minute,
+		URLAllowPrivate:    true,
+	}
+	recognitionService := NewRecognitionService(config, fingerprintService, &stubSongRepository{songs: map[uuid.UUID]*song.SongEntity{track.ID: track}}, nil)
+	server := mediaServer(t, wavFile(samples[10*audio.TargetSampleRate:35*audio.TargetSampleRate]))
+	service := NewURLService(config, recognitionService, NewMediaFetcher(config, &stubMediaDownloader{}), nil)
+
+	timeline, err := service.RecognizeURL(context.Background(), server.URL+"/clip.wav")
+	require.NoError(t, err)
+
+	require.Len(t, timeline.Segments, 1)
+	assert.Equal(t, track.ID, timeline.Segments[0].Song.ID)
+	assert.InDelta(t, 10, timeline.Segments[0].TimeOffset, 1)
+
+	// Longer media is rejected after conversion
+	config.URLMaxDuration = 10 * time.Second
+	_, err = service.RecognizeURL(context.Background(), server.URL+"/clip.wav")
+	assert.ErrorIs(t, err, ErrAudioTooLong)
+}
+
+func TestURLHandler_Recognize(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	queue := &stubQueue{}
+	router := gin.New()
+	RegisterURLRoutes(router, NewURLHandler(NewURLService(&Config{}, nil, nil, queue)), testJWTService)
+	tokens, err := testJWTService.GenerateTokenPair(uuid.New())
+	require.NoError(t, err)
+
+	recognize := func(body string, authenticated bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/recognize/url", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if authenticated {
+			req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, recognize(`{"url": "https://example.com/clip.mp3"}`, false).Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, recognize(`{}`, true).Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, recognize(`{"url": "file:///etc/passwd"}`, true).Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, recognize(`{"url": "clip.mp3"}`, true).Code)
+	assert.Empty(t, queue.tasks)
+
+	w := recognize(`{"url": "https://example.com/watch?v=1"}`, true)
+	require.Equal(t, http.StatusAccepted, w.Code)
+	var job RecognitionJobResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+	assert.Equal(t, JobStatusPending, job.Status)
+	assert.Equal(t, "/api/recognize/jobs/"+job.ID, w.Header().Get("Location"))
+
+	require.Len(t, queue.tasks, 1)
+	assert.Equal(t, RecognizeURLTaskType, queue.tasks[0].Type())
+	assert.JSONEq(t, `{"url": "https://example.com/watch?v=1"}`, string(queue.tasks[0].Payload()))
+}
@@ -0,0 +1,187 @@
+package recognition
+
+import (
+	"fmt"
+	"go-shazam/internal/fingerprint"
+	"math"
+	"sort"
+
+	"github.com/google/uuid"
+)
+
+// Temporal-consistency verification of the best candidates. A real match has its pairs on a
+// line of slope 1 (reference time = query time + offset) spread across the query; random hash
+// collisions that happen to pile up in one offset bin do not.
+const (
+	VerificationWindow = 1.0  // seconds around the best offset searched for the pairs of a candidate
+	InlierTolerance    = 0.1  // seconds a pair may deviate from the fitted line
+	MaxSlopeDeviation  = 0.05 // the fitted slope must lie within 1 ± MaxSlopeDeviation
+	MinInlierRatio     = 0.5  // share of the pairs in the window that must lie on the line
+	MinMatchSpread     = 0.25 // share of the query the inliers must span
+	MinSpreadDuration  = 1.0  // queries spanning less (seconds) skip the spread check
+	// Pairs are subsampled to keep the pairwise slopes of the regression cheap
+	maxRegressionPairs = 200
+)
+
+// timePair is a matched hash: where it occurs in the query and in the track
+type timePair struct {
+	query     float64
+	reference float64
+}
+
+// verification is the outcome of the temporal-consistency check of one candidate
+type verification struct {
+	slope     float64
+	intercept float64
+	pairs     int     // pairs within the verification window
+	inliers   int     // pairs within InlierTolerance of the fitted line
+	spread    float64 // share of the query spanned by the inliers
+}
+
+func (v verification) passed(threshold int) bool {
+	return v.rejection(threshold) == ""
+}
+
+// rejection describes the first check the candidate failed, empty if it passed
+func (v verification) rejection(threshold int) string {
+	switch {
+	case math.Abs(v.slope-1) > MaxSlopeDeviation:
+		return fmt.Sprintf("slope %.3f is not within 1 ± %.2f", v.slope, MaxSlopeDeviation)
+	case v.inliers < threshold:
+		return fmt.Sprintf("%d pairs on the line, below the threshold %d", v.inliers, threshold)
+	case float64(v.inliers) < MinInlierRatio*float64(v.pairs):
+		return fmt.Sprintf("%d of %d pairs on the line, below the inlier ratio %.2f", v.inliers, v.pairs, MinInlierRatio)
+	case v.spread < MinMatchSpread:
+		return fmt.Sprintf("matches span %.0f%% of the query, below %.0f%%", v.spread*100, MinMatchSpread*100)
+	}
+	return ""
+}
+
+// verifyCandidates checks the candidates at or above the threshold in order until limit of
+// them passed, and drops those that fail. The remaining candidates are kept unchecked as they
+// only serve as competitors.
+func verifyCandidates(sampleHashes []fingerprint.Hash, dbHashes []fingerprint.Hash, candidates []candidate, threshold int, limit int) []candidate {
+	sampleHashMap, querySpan := sampleTimes(sampleHashes)
+
+	verified := make([]candidate, 0, len(candidates))
+	passed := 0
+	for i, c := range candidates {
+		if c.score < threshold || passed >= limit {
+			verified = append(verified, candidates[i:]...)
+			break
+		}
+
+		pairs := candidatePairs(sampleHashMap, dbHashes, c.songID, c.offset)
+		if verifyPairs(pairs, querySpan).passed(threshold) {
+			verified = append(verified, c)
+			passed++
+		}
+	}
+	return verified
+}
+
+// sampleTimes indexes the query times of the sample hashes by value and returns the time
+// span they cover
+func sampleTimes(sampleHashes []fingerprint.Hash) (map[int64][]float64, TimeRange) {
+	sampleHashMap := make(map[int64][]float64)
+	querySpan := TimeRange{Start: math.Inf(1), End: math.Inf(-1)}
+	for _, h := range sampleHashes {
+		sampleHashMap[h.HashValue] = append(sampleHashMap[h.HashValue], h.TimeOffset)
+		querySpan.Start = math.Min(querySpan.Start, h.TimeOffset)
+		querySpan.End = math.Max(querySpan.End, h.TimeOffset)
+	}
+	return sampleHashMap, querySpan
+}
+
+// candidatePairs returns the pairs of the song within VerificationWindow of its best offset
+func candidatePairs(sampleHashMap map[int64][]float64, dbHashes []fingerprint.Hash, songID uuid.UUID, offset float64) []timePair {
+	var pairs []timePair
+	for _, dbHash := range dbHashes {
+		if dbHash.SongID != songID {
+			continue
+		}
+		for _, sampleOffset := range sampleHashMap[dbHash.HashValue] {
+			if math.Abs(dbHash.TimeOffset-sampleOffset-offset) <= VerificationWindow {
+				pairs = append(pairs, timePair{query: sampleOffset, reference: dbHash.TimeOffset})
+			}
+		}
+	}
+	return pairs
+}
+
+// verifyPairs fits reference = slope * query + intercept with the Theil-Sen estimator, which
+// ignores up to ~29% of outliers, and measures how many pairs lie on the line and how much of
+// the query they span.
+func verifyPairs(pairs []timePair, querySpan TimeRange) verification {
+	v := verification{pairs: len(pairs)}
+	if len(pairs) < 2 {
+		return v
+	}
+
+	v.slope = theilSenSlope(pairs)
+	residuals := make([]float64, len(pairs))
+	for i, p := range pairs {
+		residuals[i] = p.reference - v.slope*p.query
+	}
+	v.intercept = median(residuals)
+
+	var inlierTimes []float64
+	for _, p := range pairs {
+		if math.Abs(p.reference-v.slope*p.query-v.intercept) <= InlierTolerance {
+			inlierTimes = append(inlierTimes, p.query)
+		}
+	}
+	v.inliers = len(inlierTimes)
+
+	duration := querySpan.End - querySpan.Start
+	if duration < MinSpreadDuration {
+		v.spread = 1
+		return v
+	}
+	if len(inlierTimes) > 0 {
+		// The 10th to 90th percentile so that a few stray inliers do not make a burst look spread
+		sort.Float64s(inlierTimes)
+		low := inlierTimes[int(0.1*float64(len(inlierTimes)-1))]
+		high := inlierTimes[int(math.Ceil(0.9*float64(len(inlierTimes)-1)))]
+		v.spread = (high - low) / duration
+	}
+	return v
+}
+
+// theilSenSlope is the median of the slopes between all pairs that are apart in query time
+func theilSenSlope(pairs []timePair) float64 {
+	if len(pairs) > maxRegressionPairs {
+		// Evenly spaced subsample, deterministic so that results are reproducible
+		sampled := make([]timePair, maxRegressionPairs)
+		for i := range sampled {
+			sampled[i] = pairs[i*len(pairs)/maxRegressionPairs]
+		}
+		pairs = sampled
+	}
+
+	var slopes []float64
+	for i := range pairs {
+		for j := i + 1; j < len(pairs); j++ {
+			dq := pairs[j].query - pairs[i].query
+			if math.Abs(dq) < InlierTolerance {
+				// Too close for the quantised hash times to tell the slope
+				continue
+			}
+			slopes = append(slopes, (pairs[j].reference-pairs[i].reference)/dq)
+		}
+	}
+	if len(slopes) == 0 {
+		return 0
+	}
+	return median(slopes)
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
@@ -0,0 +1,152 @@
+package recognition
+
+import (
+	"context"
+	"go-shazam/internal/fingerprint"
+	"go-shazam/internal/song"
+	"math/rand"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// noiseCatalog returns a random query and a catalog of random tracks drawing from a small hash
+// space, so that chance collisions pile up in some offset bins the way they do in a large catalog
+func noiseCatalog(rng *rand.Rand, songs int) ([]fingerprint.Hash, []fingerprint.Hash) {
+	const hashSpace = 200
+
+	sample := make([]fingerprint.Hash, 300)
+	for i := range sample {
+		sample[i] = fingerprint.Hash{HashValue: rng.Int63n(hashSpace), TimeOffset: rng.Float64() * 10}
+	}
+
+	var db []fingerprint.Hash
+	for s := 0; s < songs; s++ {
+		songID := uuid.New()
+		for i := 0; i < 2000; i++ {
+			db = append(db, fingerprint.Hash{HashValue: rng.Int63n(hashSpace), SongID: songID, TimeOffset: rng.Float64() * 200})
+		}
+	}
+	return sample, db
+}
+
+// jitteredHashes returns n sample hashes over duration seconds and their database counterparts
+// shifted by offset, with the timing jitter of hashes taken on different frame grids
+func jitteredHashes(rng *rand.Rand, songID uuid.UUID, n int, duration float64, offset float64, firstHash int64) ([]fingerprint.Hash, []fingerprint.Hash) {
+	var sample, db []fingerprint.Hash
+	for i := 0; i < n; i++ {
+		t := rng.Float64() * duration
+		hash := firstHash + int64(i)
+		sample = append(sample, fingerprint.Hash{HashValue: hash, TimeOffset: t})
+		db = append(db, fingerprint.Hash{HashValue: hash, SongID: songID, TimeOffset: t + offset + (rng.Float64()-0.5)*0.09})
+	}
+	return sample, db
+}
+
+func TestVerifyCandidates_RejectsNoiseQueries(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+
+	for run := 0; run < 5; run++ {
+		sample, db := noiseCatalog(rng, 50)
+		threshold := DefaultThresholds.matchThreshold(len(sample))
+
+		candidates := scoreCandidates(sample, db)
+		require.GreaterOrEqual(t, candidates[0].score, threshold, "the noise has to pass the histogram threshold for the test to be meaningful")
+
+		verified := verifyCandidates(sample, db, candidates, threshold, len(candidates))
+		for _, c := range verified {
+			assert.Less(t, c.score, threshold, "a noise candidate passed verification")
+		}
+	}
+}
+
+func TestRankMatches_FindsTrackAmongNoise(t *testing.T) {
+	rng := rand.New(rand.NewSource(11))
+	track := &song.SongEntity{ID: uuid.New(), Title: "Track"}
+
+	noiseSample, db := noiseCatalog(rng, 50)
+	sample, trackDB := jitteredHashes(rng, track.ID, 60, 10, 42.0, 1000)
+	sample = append(sample, noiseSample...)
+	db = append(db, trackDB...)
+
+	results, err := newTestService(track).rankMatches(context.Background(), sample, db, 10.0, DefaultThresholds)
+
+	require.NoError(t, err)
+	require.Len(t, results, 1, "only the real track survives verification")
+	assert.Equal(t, track, results[0].Song)
+	assert.InDelta(t, 42.0, results[0].TimeOffset, 0.05)
+}
+
+func TestRankMatches_NoiseOnly(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	sample, db := noiseCatalog(rng, 50)
+
+	results, err := newTestService().rankMatches(context.Background(), sample, db, 10.0, DefaultThresholds)
+
+	require.NoError(t, err)
+	assert.Empty(t, results)
+}
+
+func TestVerifyPairs_AcceptsAlignedPairsWithOutliers(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	var pairs []timePair
+	for i := 0; i < 60; i++ {
+		q := rng.Float64() * 10
+		pairs = append(pairs, timePair{query: q, reference: q + 30 + (rng.Float64()-0.5)*0.09})
+	}
+	for i := 0; i < 20; i++ {
+		q := rng.Float64() * 10
+		pairs = append(pairs, timePair{query: q, reference: q + 30 + (rng.Float64()*2 - 1)})
+	}
+
+	v := verifyPairs(pairs, TimeRange{Start: 0, End: 10})
+
+	assert.InDelta(t, 1.0, v.slope, 0.02)
+	assert.InDelta(t, 30.0, v.intercept, 0.05)
+	assert.GreaterOrEqual(t, v.inliers, 60)
+	assert.Greater(t, v.spread, 0.7)
+	assert.True(t, v.passed(DefaultThresholds.matchThreshold(80)))
+}
+
+func TestVerifyPairs_RejectsBurst(t *testing.T) {
+	// Every match comes from the same half second of a ten second query
+	var pairs []timePair
+	for i := 0; i < 30; i++ {
+		q := 4 + float64(i)*0.015
+		pairs = append(pairs, timePair{query: q, reference: q + 30})
+	}
+
+	v := verifyPairs(pairs, TimeRange{Start: 0, End: 10})
+
+	assert.Less(t, v.spread, MinMatchSpread)
+	assert.False(t, v.passed(5))
+}
+
+func TestVerifyPairs_RejectsWrongSlope(t *testing.T) {
+	// A sustained note repeating one hash at a single position of the track
+	var pairs []timePair
+	for i := 0; i < 30; i++ {
+		pairs = append(pairs, timePair{query: float64(i) * 0.3, reference: 30 + float64(i)*0.01})
+	}
+
+	v := verifyPairs(pairs, TimeRange{Start: 0, End: 9})
+
+	assert.InDelta(t, 1.0/30, v.slope, 1e-9)
+	assert.False(t, v.passed(5))
+}
+
+func TestVerifyPairs_ShortQuerySkipsSpread(t *testing.T) {
+	pairs := []timePair{{query: 0.1, reference: 10.1}, {query: 0.3, reference: 10.3}, {query: 0.5, reference: 10.5}}
+
+	v := verifyPairs(pairs, TimeRange{Start: 0, End: 0.6})
+
+	assert.Equal(t, 1.0, v.spread)
+	assert.Equal(t, 3, v.inliers)
+}
+
+func TestMedian(t *testing.T) {
+	assert.Equal(t, 2.0, median([]float64{3, 1, 2}))
+	assert.Equal(t, 2.5, median([]float64{4, 1, 3, 2}))
+}
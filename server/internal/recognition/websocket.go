@@ -0,0 +1,224 @@
+package recognition
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Sessions get this long to answer the close frame on shutdown before they are cut off
+const closeGracePeriod = time.Second
+
+var (
+	ErrTooManySessions    = errors.New("too many concurrent recognition sessions, try again later")
+	ErrServerShuttingDown = errors.New("server is shutting down")
+	ErrAudioLimitExceeded = errors.New("audio limit exceeded")
+	ErrIdleTimeout        = errors.New("no message received in time")
+)
+
+// wsConn serialises writes to a WebSocket connection and applies the read and write
+// deadlines. Every message and pong received extends the read deadline.
+type wsConn struct {
+	*websocket.Conn
+	config *Config
+
+	mu sync.Mutex // guards writes
+}
+
+func newWSConn(conn *websocket.Conn, config *Config) *wsConn {
+	c := &wsConn{Conn: conn, config: config}
+	if config.WSMaxMessageBytes > 0 {
+		conn.SetReadLimit(config.WSMaxMessageBytes)
+	}
+	c.extendReadDeadline()
+	conn.SetPongHandler(func(string) error {
+		c.extendReadDeadline()
+		return nil
+	})
+	return c
+}
+
+func (c *wsConn) extendReadDeadline() {
+	if c.config.WSReadTimeout > 0 {
+		c.SetReadDeadline(time.Now().Add(c.config.WSReadTimeout))
+	}
+}
+
+func (c *wsConn) writeDeadline() time.Time {
+	if c.config.WSWriteTimeout > 0 {
+		return time.Now().Add(c.config.WSWriteTimeout)
+	}
+	return time.Time{}
+}
+
+func (c *wsConn) ReadMessage() (int, []byte, error) {
+	messageType, p, err := c.Conn.ReadMessage()
+	if err == nil {
+		c.extendReadDeadline()
+	}
+	return messageType, p, err
+}
+
+// WriteJSON sends a message; a client that does not read within the write timeout fails the write.
+func (c *wsConn) WriteJSON(v any) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.SetWriteDeadline(c.writeDeadline())
+	return c.Conn.WriteJSON(v)
+}
+
+// closeWithError sends a final error message and a close frame.
+func (c *wsConn) closeWithError(closeCode int, code string, err error) {
+	c.WriteJSON(ErrorMessage{Type: MessageTypeError, Code: code, Message: err.Error()})
+	c.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(closeCode, err.Error()), c.writeDeadline())
+}
+
+// keepalive pings the client every WSPingInterval until done is closed. Clients answer with
+// a pong, which keeps the read deadline of idle but healthy connections from expiring.
+func (c *wsConn) keepalive(done <-chan struct{}) {
+	if c.config.WSPingInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(c.config.WSPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := c.WriteControl(websocket.PingMessage, nil, c.writeDeadline()); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// closeAfterReadError tells the client why the connection ends when the read loop stopped
+// on a timeout. Oversized messages were already answered with a "message too big" close
+// frame, closed connections need no answer.
+func (c *wsConn) closeAfterReadError(err error) {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		c.closeWithError(websocket.CloseGoingAway, ErrorCodeIdleTimeout, ErrIdleTimeout)
+	}
+}
+
+// sessionRegistry limits the number of concurrent WebSocket sessions and closes them on
+// shutdown.
+type sessionRegistry struct {
+	maxSessions int // 0 for no limit
+
+	mu       sync.Mutex
+	conns    map[*wsConn]struct{}
+	draining bool
+	wg       sync.WaitGroup
+}
+
+func newSessionRegistry(maxSessions int) *sessionRegistry {
+	return &sessionRegistry{maxSessions: maxSessions, conns: make(map[*wsConn]struct{})}
+}
+
+// acquire registers a connection, or fails if the limit is reached or the server is shutting down.
+func (r *sessionRegistry) acquire(conn *wsConn) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.draining {
+		return ErrServerShuttingDown
+	}
+	if r.maxSessions > 0 && len(r.conns) >= r.maxSessions {
+		return ErrTooManySessions
+	}
+	r.conns[conn] = struct{}{}
+	r.wg.Add(1)
+	return nil
+}
+
+func (r *sessionRegistry) release(conn *wsConn) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.conns[conn]; ok {
+		delete(r.conns, conn)
+		r.wg.Done()
+	}
+}
+
+func (r *sessionRegistry) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.conns)
+}
+
+// shutdown rejects new sessions, asks the open ones to close and waits until they have
+// ended. Connections still open when ctx is done are closed without a handshake.
+func (r *sessionRegistry) shutdown(ctx context.Context) error {
+	r.mu.Lock()
+	r.draining = true
+	conns := make([]*wsConn, 0, len(r.conns))
+	for conn := range r.conns {
+		conns = append(conns, conn)
+	}
+	r.mu.Unlock()
+
+	for _, conn := range conns {
+		conn.closeWithError(websocket.CloseGoingAway, ErrorCodeServerShutdown, ErrServerShuttingDown)
+		// The read loop ends with the close frame of the client, or at the latest after the grace period
+		conn.SetReadDeadline(time.Now().Add(closeGracePeriod))
+	}
+
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		for _, conn := range conns {
+			conn.Close()
+		}
+		return fmt.Errorf("failed to drain recognition sessions: %w", ctx.Err())
+	}
+}
+
+// audioLimitError checks an audio frame against the limits of a recognition. bytes includes
+// the frame, duration is the audio accepted before it.
+func (c *Config) audioLimitError(bytes int64, duration float64) error {
+	if c.WSMaxAudioBytes > 0 && bytes > c.WSMaxAudioBytes {
+		return fmt.Errorf("%w: more than %d bytes of audio received, send stop or start a new recognition", ErrAudioLimitExceeded, c.WSMaxAudioBytes)
+	}
+	if c.WSMaxAudioDuration > 0 && duration >= c.WSMaxAudioDuration.Seconds() {
+		return fmt.Errorf("%w: %s of audio received, send stop or start a new recognition", ErrAudioLimitExceeded, c.WSMaxAudioDuration)
+	}
+	return nil
+}
+
+// syncLimitError checks the audio of a sync recognition, which streams for as long as the
+// music plays and keeps only the latest audio in memory. It accepts WSMaxAudioBytes for every
+// WSMaxAudioDuration of audio decoded, the byte rate of other recognitions.
+func (c *Config) syncLimitError(bytes int64, duration float64) error {
+	if c.WSMaxAudioBytes > 0 {
+		limit := c.WSMaxAudioBytes
+		if c.WSMaxAudioDuration > 0 {
+			limit = int64(float64(limit) * math.Max(1, duration/c.WSMaxAudioDuration.Seconds()))
+		}
+		if bytes > limit {
+			return fmt.Errorf("%w: more than %d bytes received for %.0fs of audio, start a new recognition", ErrAudioLimitExceeded, limit, duration)
+		}
+	}
+	if c.WSMaxSyncDuration > 0 && duration >= c.WSMaxSyncDuration.Seconds() {
+		return fmt.Errorf("%w: %s of audio received, start a new recognition", ErrAudioLimitExceeded, c.WSMaxSyncDuration)
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package recognition
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// handshake opens a versioned session, which also makes sure the server registered it
+func handshake(t *testing.T, conn *websocket.Conn) {
+	t.Helper()
+	require.NoError(t, conn.WriteJSON(gin.H{"type": "hello", "version": 1}))
+	var ready ReadyMessage
+	require.NoError(t, conn.ReadJSON(&ready))
+	require.Equal(t, MessageTypeReady, ready.Type)
+}
+
+// readClose reads the final error message and the close frame that follows it
+func readClose(t *testing.T, conn *websocket.Conn) (ErrorMessage, int) {
+	t.Helper()
+	var errMsg ErrorMessage
+	require.NoError(t, conn.ReadJSON(&errMsg))
+
+	_, _, err := conn.ReadMessage()
+	var closeErr *websocket.CloseError
+	require.ErrorAs(t, err, &closeErr)
+	return errMsg, closeErr.Code
+}
+
+func TestWebSocket_TooManySessions(t *testing.T) {
+	conn, _, url := dialTestServerWithConfig(t, &Config{WSMaxSessions: 1})
+	handshake(t, conn)
+
+	errMsg, closeCode := readClose(t, dial(t, url))
+	assert.Equal(t, ErrorCodeTooManySessions, errMsg.Code)
+	assert.Equal(t, websocket.CloseTryAgainLater, closeCode)
+}
+
+func TestSession_AudioLimit(t *testing.T) {
+	conn, _, _ := dialTestServerWithConfig(t, &Config{WSMaxAudioBytes: 1024})
+	handshake(t, conn)
+
+	require.NoError(t, conn.WriteMessage(websocket.BinaryMessage, make([]byte, 2048)))
+
+	var errMsg ErrorMessage
+	require.NoError(t, conn.ReadJSON(&errMsg))
+	assert.Equal(t, ErrorCodeAudioLimit, errMsg.Code)
+	assert.Contains(t, errMsg.Message, "more than 1024 bytes")
+
+	// Further audio is ignored without another error, a new recognition starts over
+	require.NoError(t, conn.WriteMessage(websocket.BinaryMessage, make([]byte, 2048)))
+	require.NoError(t, conn.WriteJSON(gin.H{"type": "start"}))
+	require.NoError(t, conn.WriteJSON(gin.H{"type": "stop"}))
+	require.NoError(t, conn.ReadJSON(&errMsg))
+	assert.Equal(t, ErrorCodeNoAudio, errMsg.Code)
+}
+
+func TestLegacyProtocol_AudioLimit(t *testing.T) {
+	conn, _, _ := dialTestServerWithConfig(t, &Config{WSMaxAudioDuration: time.Second})
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("start:8000")))
+	// Two seconds of audio, the second frame is over the limit
+	require.NoError(t, conn.WriteMessage(websocket.BinaryMessage, make([]byte, 8000*4)))
+	require.NoError(t, conn.WriteMessage(websocket.BinaryMessage, make([]byte, 8000*4)))
+
+	var response map[string]string
+	require.NoError(t, conn.ReadJSON(&response))
+	assert.Equal(t, ErrorCodeAudioLimit, response["code"])
+}
+
+func TestWebSocket_MessageTooBig(t *testing.T) {
+	conn, _, _ := dialTestServerWithConfig(t, &Config{WSMaxMessageBytes: 512})
+	handshake(t, conn)
+
+	require.NoError(t, conn.WriteMessage(websocket.BinaryMessage, make([]byte, 1024)))
+
+	_, _, err := conn.ReadMessage()
+	var closeErr *websocket.CloseError
+	require.ErrorAs(t, err, &closeErr)
+	assert.Equal(t, websocket.CloseMessageTooBig, closeErr.Code)
+}
+
+func TestWebSocket_IdleTimeout(t *testing.T) {
+	conn, _, _ := dialTestServerWithConfig(t, &Config{WSReadTimeout: 100 * time.Millisecond})
+
+	errMsg, closeCode := readClose(t, conn)
+	assert.Equal(t, ErrorCodeIdleTimeout, errMsg.Code)
+	assert.Equal(t, websocket.CloseGoingAway, closeCode)
+}
+
+func TestWebSocket_KeepaliveKeepsIdleSessionOpen(t *testing.T) {
+	conn, _, _ := dialTestServerWithConfig(t, &Config{
+		WSReadTimeout:  200 * time.Millisecond,
+		WSPingInterval: 50 * time.Millisecond,
+	})
+	handshake(t, conn)
+
+	// The client answers the pings while reading; the server sends nothing else
+	conn.SetReadDeadline(time.Now().Add(600 * time.Millisecond))
+	_, _, err := conn.ReadMessage()
+
+	var netErr net.Error
+	require.True(t, errors.As(err, &netErr), "unexpected error %v", err)
+	assert.True(t, netErr.Timeout())
+}
+
+func TestWebSocket_ShutdownDrainsSessions(t *testing.T) {
+	conn, handler, url := dialTestServerWithConfig(t, &Config{})
+	handshake(t, conn)
+
+	shutdown := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		shutdown <- handler.Shutdown(ctx)
+	}()
+
+	errMsg, closeCode := readClose(t, conn)
+	assert.Equal(t, ErrorCodeServerShutdown, errMsg.Code)
+	assert.Equal(t, websocket.CloseGoingAway, closeCode)
+	require.NoError(t, <-shutdown)
+	assert.Zero(t, handler.sessions.count())
+
+	errMsg, _ = readClose(t, dial(t, url))
+	assert.Equal(t, ErrorCodeServerShutdown, errMsg.Code)
+}
+
+func TestConfig_AudioLimitError(t *testing.T) {
+	config := &Config{WSMaxAudioBytes: 100, WSMaxAudioDuration: 10 * time.Second}
+
+	assert.NoError(t, config.audioLimitError(100, 9.9))
+	assert.ErrorIs(t, config.audioLimitError(101, 0), ErrAudioLimitExceeded)
+	assert.ErrorIs(t, config.audioLimitError(0, 10), ErrAudioLimitExceeded)
+	assert.NoError(t, (&Config{}).audioLimitError(1<<40, 1e6))
+}
+
+func TestConfig_SyncLimitError(t *testing.T) {
+	config := &Config{WSMaxAudioBytes: 100, WSMaxAudioDuration: 10 * time.Second, WSMaxSyncDuration: time.Hour}
+
+	assert.NoError(t, config.syncLimitError(100, 0))
+	assert.ErrorIs(t, config.syncLimitError(101, 0), ErrAudioLimitExceeded, "audio that decodes to nothing")
+	assert.NoError(t, config.syncLimitError(1000, 100), "the byte limit grows with the audio")
+	assert.ErrorIs(t, config.syncLimitError(1001, 100), ErrAudioLimitExceeded)
+	assert.ErrorIs(t, config.syncLimitError(0, 3600), ErrAudioLimitExceeded)
+	assert.NoError(t, (&Config{}).syncLimitError(1<<40, 1e6))
+}
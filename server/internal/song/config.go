@@ -0,0 +1,28 @@
+package song
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/viper"
+)
+
+type Config struct {
+	// Songs added from uploaded audio files
+	UploadDir      string // shared with the worker, which ingests the stored files
+	UploadMaxBytes int64  // largest accepted upload
+}
+
+func LoadConfig() *Config {
+	viper.SetConfigFile(".env")
+	viper.ReadInConfig()
+	viper.AutomaticEnv()
+
+	viper.SetDefault("SONG_UPLOAD_DIR", filepath.Join(os.TempDir(), "go-shazam-songs"))
+	viper.SetDefault("SONG_UPLOAD_MAX_BYTES", 200<<20)
+
+	return &Config{
+		UploadDir:      viper.GetString("SONG_UPLOAD_DIR"),
+		UploadMaxBytes: viper.GetInt64("SONG_UPLOAD_MAX_BYTES"),
+	}
+}
@@ -15,3 +15,78 @@ type DownloadedSong struct {
 type GetSongRequest struct {
 	Link string `json:"link" binding:"required"`
 }
+
+const (
+	TrackStatusEnqueued        = "enqueued"
+	TrackStatusAlreadyEnqueued = "already_enqueued"
+	TrackStatusFailed          = "failed"
+)
+
+// EnqueueSongResponse lists the tracks enqueued for one link. The batch ID is passed to the
+// tasks of the tracks, so that the worker logs can be followed per link.
+type EnqueueSongResponse struct {
+	Message string          `json:"message"`
+	BatchID string          `json:"batch_id"`
+	Tracks  []EnqueuedTrack `json:"tracks"`
+}
+
+type EnqueuedTrack struct {
+	SourceID string `json:"source_id"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+}
+
+func (r *EnqueueSongResponse) count(status string) int {
+	n := 0
+	for _, track := range r.Tracks {
+		if track.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+type ListSongsRequest struct {
+	Query  string `form:"q"`
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=100"`
+	Offset int    `form:"offset" binding:"omitempty,min=0"`
+}
+
+// UpdateSongRequest is a partial update: only non-nil fields are applied
+type UpdateSongRequest struct {
+	Title    *string `json:"title" binding:"omitempty,min=1"`
+	Artist   *string `json:"artist" binding:"omitempty,min=1"`
+	Duration *int    `json:"duration" binding:"omitempty,min=0"`
+	SourceID *string `json:"source_id"`
+}
+
+type SongResponse struct {
+	ID        string `json:"id"`
+	Title     string `json:"title"`
+	Artist    string `json:"artist"`
+	Duration  int    `json:"duration"`
+	SourceID  string `json:"source_id"`
+	HashCount *int64 `json:"hash_count,omitempty"`
+}
+
+type SongListResponse struct {
+	Items  []SongResponse `json:"items"`
+	Total  int            `json:"total"`
+	Limit  int            `json:"limit"`
+	Offset int            `json:"offset"`
+}
+
+// UploadSongRequest holds the form fields sent with an uploaded file. Title and artist
+// default to the tags of the file.
+type UploadSongRequest struct {
+	Title    string `form:"title"`
+	Artist   string `form:"artist"`
+	Duration int    `form:"duration" binding:"omitempty,min=0"`
+}
+
+type UploadSongResponse struct {
+	SourceID string `json:"source_id"`
+	Title    string `json:"title"`
+	Artist   string `json:"artist"`
+	Duration int    `json:"duration,omitempty"`
+}
@@ -9,3 +9,8 @@ type SongEntity struct {
 	Duration int       `db:"duration"`
 	SourceID string    `db:"source_id"`
 }
+
+type SongHashCount struct {
+	SongEntity
+	HashCount int64 `db:"hash_count"`
+}
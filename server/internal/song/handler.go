@@ -3,8 +3,11 @@ package song
 import (
 	"errors"
 	"go-shazam/internal/auth"
+	"go-shazam/internal/logger"
+	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
 type SongHandler struct {
@@ -15,9 +18,19 @@ func NewSongHandler(songService *SongService) *SongHandler {
 	return &SongHandler{songService: songService}
 }
 
-func RegisterRoutes(r *gin.Engine, h *SongHandler, jwtService *auth.JWTService) {
+func RegisterRoutes(r *gin.Engine, h *SongHandler, jwtService *auth.JWTService, authConfig *auth.Config) {
 	authMiddleware := auth.AuthMiddleware(jwtService)
 	r.POST("/api/song/add", authMiddleware, h.Add)
+
+	// Catalog management
+	catalogGroup := r.Group("/api/song")
+	catalogGroup.Use(authMiddleware, auth.AdminMiddleware(authConfig))
+	{
+		catalogGroup.GET("", h.List)
+		catalogGroup.GET("/:id", h.Get)
+		catalogGroup.PATCH("/:id", h.Update)
+		catalogGroup.DELETE("/:id", h.Delete)
+	}
 }
 
 func (h *SongHandler) Add(c *gin.Context) {
@@ -28,15 +41,122 @@ func (h *SongHandler) Add(c *gin.Context) {
 		return
 	}
 
-	err := h.songService.EnqueueSong(c.Request.Context(), songRequest.Link)
+	batch, err := h.songService.EnqueueSong(c.Request.Context(), songRequest.Link)
 	if err != nil {
 		if errors.Is(err, ErrSongTaskAlreadyExists) {
 			c.JSON(400, gin.H{"error": ErrSongTaskAlreadyExists.Error()})
 			return
 		}
+		if errors.Is(err, ErrUnsupportedLink) {
+			c.JSON(422, gin.H{"error": ErrUnsupportedLink.Error()})
+			return
+		}
 		c.JSON(500, err.Error())
 		return
 	}
 
-	c.JSON(200, gin.H{"message": "We will add this song soon"})
+	batch.Message = "We will add this song soon"
+	if len(batch.Tracks) > 1 {
+		batch.Message = "We will add these songs soon"
+	}
+	c.JSON(200, batch)
+}
+
+func (h *SongHandler) List(c *gin.Context) {
+	log := logger.FromContext(c.Request.Context())
+
+	var req ListSongsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+
+	songs, err := h.songService.ListSongs(c.Request.Context(), &req)
+	if err != nil {
+		log.Error("failed to list songs", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list songs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, songs)
+}
+
+func (h *SongHandler) Get(c *gin.Context) {
+	log := logger.FromContext(c.Request.Context())
+
+	id, ok := parseSongID(c)
+	if !ok {
+		return
+	}
+
+	song, err := h.songService.GetSong(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, ErrSongNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": ErrSongNotFound.Error()})
+			return
+		}
+		log.Error("failed to get song", "error", err, "song_id", id)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get song"})
+		return
+	}
+
+	c.JSON(http.StatusOK, song)
+}
+
+func (h *SongHandler) Update(c *gin.Context) {
+	log := logger.FromContext(c.Request.Context())
+
+	id, ok := parseSongID(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateSongRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+
+	song, err := h.songService.UpdateSong(c.Request.Context(), id, &req)
+	if err != nil {
+		if errors.Is(err, ErrSongNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": ErrSongNotFound.Error()})
+			return
+		}
+		log.Error("failed to update song", "error", err, "song_id", id)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update song"})
+		return
+	}
+
+	c.JSON(http.StatusOK, song)
+}
+
+func (h *SongHandler) Delete(c *gin.Context) {
+	log := logger.FromContext(c.Request.Context())
+
+	id, ok := parseSongID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.songService.DeleteSong(c.Request.Context(), id); err != nil {
+		if errors.Is(err, ErrSongNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": ErrSongNotFound.Error()})
+			return
+		}
+		log.Error("failed to delete song", "error", err, "song_id", id)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete song"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func parseSongID(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid song id"})
+		return uuid.Nil, false
+	}
+	return id, true
 }
@@ -3,27 +3,51 @@ package song
 import (
 	"bytes"
 	"encoding/json"
+	"go-shazam/internal/auth"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
+var (
+	testAdminID = uuid.MustParse("0193a5f0-0000-7000-8000-000000000001")
+	testUserID  = uuid.MustParse("0193a5f0-0000-7000-8000-000000000002")
+)
+
+var testAuthConfig = &auth.Config{
+	AccessTokenSecret:  "test-access-secret",
+	RefreshTokenSecret: "test-refresh-secret",
+	AccessTokenTTL:     time.Minute,
+	RefreshTokenTTL:    time.Hour,
+	AdminUserIDs:       []uuid.UUID{testAdminID},
+}
+
 func setupTestRouter(handler *SongHandler) *gin.Engine {
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
-	RegisterRoutes(router, handler)
+	RegisterRoutes(router, handler, auth.NewJWTService(testAuthConfig), testAuthConfig)
 	return router
 }
 
+func authorize(t *testing.T, req *http.Request, userID uuid.UUID) {
+	tokens, err := auth.NewJWTService(testAuthConfig).GenerateTokenPair(userID)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+}
+
 func TestSongHandler_Add_InvalidJSON(t *testing.T) {
 	handler := NewSongHandler(nil)
 	router := setupTestRouter(handler)
 
 	req, _ := http.NewRequest(http.MethodPost, "/api/song/add", bytes.NewBufferString("invalid json"))
 	req.Header.Set("Content-Type", "application/json")
+	authorize(t, req, testUserID)
 
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
@@ -42,6 +66,59 @@ func TestSongHandler_Add_MissingLink(t *testing.T) {
 
 	req, _ := http.NewRequest(http.MethodPost, "/api/song/add", bytes.NewBuffer(jsonBody))
 	req.Header.Set("Content-Type", "application/json")
+	authorize(t, req, testUserID)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+}
+
+func TestSongHandler_Add_Unauthorized(t *testing.T) {
+	handler := NewSongHandler(nil)
+	router := setupTestRouter(handler)
+
+	req, _ := http.NewRequest(http.MethodPost, "/api/song/add", bytes.NewBufferString(`{"link":"x"}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestSongHandler_Catalog_RequiresAdmin(t *testing.T) {
+	handler := NewSongHandler(nil)
+	router := setupTestRouter(handler)
+
+	req, _ := http.NewRequest(http.MethodGet, "/api/song", nil)
+	authorize(t, req, testUserID)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestSongHandler_Get_InvalidID(t *testing.T) {
+	handler := NewSongHandler(nil)
+	router := setupTestRouter(handler)
+
+	req, _ := http.NewRequest(http.MethodGet, "/api/song/not-a-uuid", nil)
+	authorize(t, req, testAdminID)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestSongHandler_List_InvalidLimit(t *testing.T) {
+	handler := NewSongHandler(nil)
+	router := setupTestRouter(handler)
+
+	req, _ := http.NewRequest(http.MethodGet, "/api/song?limit=1000", nil)
+	authorize(t, req, testAdminID)
 
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
@@ -0,0 +1,101 @@
+package song
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const (
+	// Source IDs enqueued before they were namespaced are Spotify track IDs
+	legacyProvider = "spotify"
+	// MetadataProvidersGroup is the fx value group the metadata providers are provided in
+	MetadataProvidersGroup = `group:"metadata_providers"`
+)
+
+var (
+	ErrUnsupportedLink       = errors.New("links from this site are not supported")
+	ErrUnknownMetadataSource = errors.New("unknown metadata source")
+)
+
+// MetadataProvider is one source of song metadata, such as Spotify or MusicBrainz. Its IDs
+// are unique within the provider only; the registry namespaces them with the provider name.
+type MetadataProvider interface {
+	Name() string
+	// Hosts are the link hosts the provider understands
+	Hosts() []string
+	GetSongMetadata(ctx context.Context, id string) (*SongMetadata, error)
+	// ExtractSourceIDs is only given links whose host the registry matched to the provider
+	ExtractSourceIDs(ctx context.Context, link string) ([]string, error)
+}
+
+// MetadataRegistry is the SongMetadataSource of the application. Links go to the provider of
+// their host, and the source IDs it returns are namespaced as "<provider>:<id>", so that
+// GetSongMetadata can send them back to the same provider.
+type MetadataRegistry struct {
+	providers map[string]MetadataProvider // by name
+	hosts     map[string]MetadataProvider
+}
+
+func NewMetadataRegistry(providers []MetadataProvider) (SongMetadataSource, error) {
+	r := &MetadataRegistry{
+		providers: make(map[string]MetadataProvider),
+		hosts:     make(map[string]MetadataProvider),
+	}
+	for _, p := range providers {
+		if _, ok := r.providers[p.Name()]; ok {
+			return nil, fmt.Errorf("metadata provider %s registered twice", p.Name())
+		}
+		r.providers[p.Name()] = p
+		for _, host := range p.Hosts() {
+			host = normalizeHost(host)
+			if other, ok := r.hosts[host]; ok {
+				return nil, fmt.Errorf("host %s claimed by metadata providers %s and %s", host, other.Name(), p.Name())
+			}
+			r.hosts[host] = p
+		}
+	}
+	return r, nil
+}
+
+func (r *MetadataRegistry) GetSongMetadata(ctx context.Context, sourceID string) (*SongMetadata, error) {
+	name, id, ok := strings.Cut(sourceID, ":")
+	if !ok {
+		name, id = legacyProvider, sourceID
+	}
+
+	provider, ok := r.providers[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownMetadataSource, name)
+	}
+	return provider.GetSongMetadata(ctx, id)
+}
+
+func (r *MetadataRegistry) ExtractSourceIDs(ctx context.Context, link string) ([]string, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse URL: %w", err)
+	}
+
+	host := normalizeHost(u.Hostname())
+	provider, ok := r.hosts[host]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedLink, host)
+	}
+
+	ids, err := provider.ExtractSourceIDs(ctx, link)
+	if err != nil {
+		return nil, err
+	}
+	for i, id := range ids {
+		ids[i] = provider.Name() + ":" + id
+	}
+	return ids, nil
+}
+
+// normalizeHost lowercases a host name and drops the trailing dot of a fully qualified name
+func normalizeHost(host string) string {
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
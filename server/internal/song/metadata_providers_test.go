@@ -0,0 +1,55 @@
+package song_test
+
+import (
+	"context"
+	"fmt"
+	"go-shazam/internal/musicbrainz"
+	"go-shazam/internal/song"
+	"go-shazam/internal/spotify"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const releaseID = "4f2b1f5c-6a0e-4c5c-8f4c-7d0c4f3f6b2e"
+
+func TestMetadataRegistry_RealProviders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/release/"+releaseID, r.URL.Path)
+		fmt.Fprint(w, `{"media":[{"tracks":[{"recording":{"id":"r1"}},{"recording":{"id":"r2"}}]}]}`)
+	}))
+	t.Cleanup(server.Close)
+
+	registry, err := song.NewMetadataRegistry([]song.MetadataProvider{
+		// Track links need no token
+		&spotify.SpotifySongMetadataSource{},
+		musicbrainz.NewMusicBrainzSongMetadataSource(&musicbrainz.Config{BaseURL: server.URL, UserAgent: "go-shazam-test/1.0", MaxTracks: 10}),
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		link string
+		ids  []string
+	}{
+		{"spotify", "https://open.spotify.com/track/4iV5W9uYEdYUVa79Axb7Rh", []string{"spotify:4iV5W9uYEdYUVa79Axb7Rh"}},
+		{"spotify mixed case", "https://Open.Spotify.com/track/4iV5W9uYEdYUVa79Axb7Rh", []string{"spotify:4iV5W9uYEdYUVa79Axb7Rh"}},
+		{"spotify with port", "https://open.spotify.com:443/track/4iV5W9uYEdYUVa79Axb7Rh", []string{"spotify:4iV5W9uYEdYUVa79Axb7Rh"}},
+		{"musicbrainz", "https://musicbrainz.org/release/" + releaseID, []string{"musicbrainz:r1", "musicbrainz:r2"}},
+		{"musicbrainz mixed case", "https://MusicBrainz.org/release/" + releaseID, []string{"musicbrainz:r1", "musicbrainz:r2"}},
+		{"musicbrainz with port and trailing dot", "https://musicbrainz.org.:443/release/" + releaseID, []string{"musicbrainz:r1", "musicbrainz:r2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids, err := registry.ExtractSourceIDs(context.Background(), tt.link)
+			require.NoError(t, err)
+			assert.Equal(t, tt.ids, ids)
+		})
+	}
+
+	_, err = registry.ExtractSourceIDs(context.Background(), "https://example.com/track/4iV5W9uYEdYUVa79Axb7Rh")
+	assert.ErrorIs(t, err, song.ErrUnsupportedLink)
+}
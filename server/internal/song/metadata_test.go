@@ -0,0 +1,72 @@
+package song
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubProvider returns the last path segment of a link as its single source ID
+type stubProvider struct {
+	name  string
+	hosts []string
+	asked []string // IDs passed to GetSongMetadata
+}
+
+func (p *stubProvider) Name() string    { return p.name }
+func (p *stubProvider) Hosts() []string { return p.hosts }
+
+func (p *stubProvider) GetSongMetadata(ctx context.Context, id string) (*SongMetadata, error) {
+	p.asked = append(p.asked, id)
+	return &SongMetadata{Title: id, Artist: p.name}, nil
+}
+
+func (p *stubProvider) ExtractSourceIDs(ctx context.Context, link string) ([]string, error) {
+	return []string{link[len(link)-3:]}, nil
+}
+
+func TestMetadataRegistry(t *testing.T) {
+	spotify := &stubProvider{name: "spotify", hosts: []string{"open.spotify.com"}}
+	musicbrainz := &stubProvider{name: "musicbrainz", hosts: []string{"musicbrainz.org"}}
+	registry, err := NewMetadataRegistry([]MetadataProvider{spotify, musicbrainz})
+	require.NoError(t, err)
+
+	ids, err := registry.ExtractSourceIDs(context.Background(), "https://MusicBrainz.org/recording/abc")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"musicbrainz:abc"}, ids)
+
+	ids, err = registry.ExtractSourceIDs(context.Background(), "https://open.spotify.com/track/xyz")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"spotify:xyz"}, ids)
+
+	_, err = registry.ExtractSourceIDs(context.Background(), "https://example.com/track/xyz")
+	assert.ErrorIs(t, err, ErrUnsupportedLink)
+
+	meta, err := registry.GetSongMetadata(context.Background(), "musicbrainz:abc")
+	require.NoError(t, err)
+	assert.Equal(t, &SongMetadata{Title: "abc", Artist: "musicbrainz"}, meta)
+
+	// Tasks enqueued before source IDs were namespaced hold bare Spotify IDs
+	_, err = registry.GetSongMetadata(context.Background(), "xyz")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"xyz"}, spotify.asked)
+
+	_, err = registry.GetSongMetadata(context.Background(), "deezer:123")
+	assert.ErrorIs(t, err, ErrUnknownMetadataSource)
+}
+
+func TestNewMetadataRegistry_Conflicts(t *testing.T) {
+	_, err := NewMetadataRegistry([]MetadataProvider{
+		&stubProvider{name: "spotify", hosts: []string{"open.spotify.com"}},
+		&stubProvider{name: "spotify"},
+	})
+	assert.ErrorContains(t, err, "registered twice")
+
+	_, err = NewMetadataRegistry([]MetadataProvider{
+		&stubProvider{name: "spotify", hosts: []string{"open.spotify.com"}},
+		&stubProvider{name: "other", hosts: []string{"open.spotify.com"}},
+	})
+	assert.ErrorContains(t, err, "claimed by")
+}
@@ -10,22 +10,29 @@ import (
 var Module = fx.Module(
 	"song",
 	fx.Provide(
+		LoadConfig,
 		NewSongRepository,
 		NewSongService,
+		NewUploadService,
+		fx.Annotate(NewMetadataRegistry, fx.ParamTags(MetadataProvidersGroup)),
 	),
 )
 
 var HttpModule = fx.Module(
 	"song-http",
-	fx.Provide(NewSongHandler),
-	fx.Invoke(RegisterRoutes),
+	fx.Provide(NewSongHandler, NewUploadHandler),
+	fx.Invoke(RegisterRoutes, RegisterUploadRoutes),
 )
 
 var QueueModule = fx.Module(
 	"song-queue",
-	fx.Provide(NewAddSongTaskHandler),
+	fx.Provide(NewAddSongTaskHandler, NewAddUploadedSongTaskHandler),
 	fx.Invoke(func(w queue.WorkerServer, h *AddSongTaskHandler) {
 		fmt.Printf("[Queue] Registering handler for task type: %s\n", AddSongTaskType)
 		w.RegisterServiceHandler(AddSongTaskType, h)
 	}),
+	fx.Invoke(func(w queue.WorkerServer, h *AddUploadedSongTaskHandler) {
+		fmt.Printf("[Queue] Registering handler for task type: %s\n", AddUploadedSongTaskType)
+		w.RegisterServiceHandler(AddUploadedSongTaskType, h)
+	}),
 )
@@ -3,17 +3,20 @@ package song
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 
 	"github.com/hibiken/asynq"
 )
 
 const (
-	AddSongTaskType = "song:add_song"
+	AddSongTaskType         = "song:add_song"
+	AddUploadedSongTaskType = "song:add_uploaded_song"
 )
 
 type AddSongTaskPayload struct {
-	ID string `json:"id"`
+	ID      string `json:"id"`
+	BatchID string `json:"batch_id,omitempty"` // the link the track was enqueued from
 }
 
 type AddSongTaskHandler struct {
@@ -33,7 +36,7 @@ func (h *AddSongTaskHandler) ProcessTask(ctx context.Context, task *asynq.Task)
 		return fmt.Errorf("failed to unmarshal payload: %w", err)
 	}
 
-	fmt.Printf("[Worker] Processing song with ID: %s\n", payload.ID)
+	fmt.Printf("[Worker] Processing song with ID: %s (batch %s)\n", payload.ID, payload.BatchID)
 
 	songMeta, err := h.songService.AddSong(ctx, payload.ID)
 	if err != nil {
@@ -44,3 +47,55 @@ func (h *AddSongTaskHandler) ProcessTask(ctx context.Context, task *asynq.Task)
 	fmt.Printf("[Worker] Successfully added song: %s - %s\n", songMeta.Artist, songMeta.Title)
 	return nil
 }
+
+type AddUploadedSongTaskPayload struct {
+	File       string `json:"file"`      // name of the stored upload in the upload directory
+	SourceID   string `json:"source_id"` // checksum of the file
+	Title      string `json:"title"`
+	Artist     string `json:"artist"`
+	DurationMs int    `json:"duration_ms,omitempty"` // taken from the audio when zero
+}
+
+type AddUploadedSongTaskHandler struct {
+	uploadService *UploadService
+}
+
+func NewAddUploadedSongTaskHandler(uploadService *UploadService) *AddUploadedSongTaskHandler {
+	return &AddUploadedSongTaskHandler{uploadService: uploadService}
+}
+
+func (h *AddUploadedSongTaskHandler) ProcessTask(ctx context.Context, task *asynq.Task) error {
+	fmt.Printf("[Worker] Received task: %s\n", task.Type())
+
+	var payload AddUploadedSongTaskPayload
+	if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+		fmt.Printf("[Worker] Failed to unmarshal payload: %v\n", err)
+		return fmt.Errorf("failed to unmarshal payload: %w", err)
+	}
+
+	fmt.Printf("[Worker] Processing uploaded song: %s - %s\n", payload.Artist, payload.Title)
+
+	songMeta, err := h.uploadService.AddFile(ctx, payload)
+	if err != nil {
+		fmt.Printf("[Worker] Failed to add uploaded song: %v\n", err)
+		if errors.Is(err, ErrUnsupportedAudio) {
+			h.removeFile(payload.File)
+			return fmt.Errorf("failed to add uploaded song: %v: %w", err, asynq.SkipRetry)
+		}
+		// Keep the file for the next attempt
+		if retried, _ := asynq.GetRetryCount(ctx); retried >= uploadMaxRetry {
+			h.removeFile(payload.File)
+		}
+		return fmt.Errorf("failed to add uploaded song: %w", err)
+	}
+	h.removeFile(payload.File)
+
+	fmt.Printf("[Worker] Successfully added uploaded song: %s - %s\n", songMeta.Artist, songMeta.Title)
+	return nil
+}
+
+func (h *AddUploadedSongTaskHandler) removeFile(filename string) {
+	if err := h.uploadService.RemoveFile(filename); err != nil {
+		fmt.Printf("[Worker] Failed to remove upload %s: %v\n", filename, err)
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"go-shazam/internal/core/db"
+	"strings"
 
 	"github.com/google/uuid"
 )
@@ -13,6 +15,20 @@ type SongRepositoryInterface interface {
 	Save(ctx context.Context, song *SongEntity) error
 	FindByID(ctx context.Context, id uuid.UUID) (*SongEntity, error)
 	FindByTitleAndArtist(ctx context.Context, title string, artist string) (*SongEntity, error)
+	FindBySourceID(ctx context.Context, sourceID string) (*SongEntity, error)
+	List(ctx context.Context, filter SongFilter) ([]SongEntity, error)
+	Count(ctx context.Context, filter SongFilter) (int, error)
+	Update(ctx context.Context, song *SongEntity) error
+	Delete(ctx context.Context, id uuid.UUID) (bool, error)
+	FindLowHashDensity(ctx context.Context, minHashesPerSecond float64, limit int) ([]SongHashCount, error)
+	StorageSizes(ctx context.Context) ([]db.RelationSize, error)
+}
+
+// SongFilter narrows catalog listings. Query is matched against title and artist.
+type SongFilter struct {
+	Query  string
+	Limit  int
+	Offset int
 }
 
 type SongRepository struct {
@@ -47,6 +63,18 @@ func (r *SongRepository) FindByTitleAndArtist(ctx context.Context, title string,
 	return &song, nil
 }
 
+func (r *SongRepository) FindBySourceID(ctx context.Context, sourceID string) (*SongEntity, error) {
+	query := "SELECT * FROM songs WHERE source_id = $1 LIMIT 1"
+	var song SongEntity
+	if err := r.db.Connection(ctx).GetContext(ctx, &song, query, sourceID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &song, nil
+}
+
 func (r *SongRepository) Save(ctx context.Context, song *SongEntity) error {
 	query := `
 		INSERT INTO songs (id, title, artist, duration, source_id)
@@ -55,3 +83,87 @@ func (r *SongRepository) Save(ctx context.Context, song *SongEntity) error {
 	_, err := r.db.Connection(ctx).NamedExecContext(ctx, query, song)
 	return err
 }
+
+func (r *SongRepository) List(ctx context.Context, filter SongFilter) ([]SongEntity, error) {
+	where, args := filter.where()
+	query := fmt.Sprintf(
+		"SELECT * FROM songs %s ORDER BY artist, title, id LIMIT $%d OFFSET $%d",
+		where, len(args)+1, len(args)+2,
+	)
+	args = append(args, filter.Limit, filter.Offset)
+
+	songs := []SongEntity{}
+	if err := r.db.Connection(ctx).SelectContext(ctx, &songs, query, args...); err != nil {
+		return nil, err
+	}
+	return songs, nil
+}
+
+func (r *SongRepository) Count(ctx context.Context, filter SongFilter) (int, error) {
+	where, args := filter.where()
+	query := "SELECT COUNT(*) FROM songs " + where
+
+	var count int
+	if err := r.db.Connection(ctx).GetContext(ctx, &count, query, args...); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (r *SongRepository) Update(ctx context.Context, song *SongEntity) error {
+	query := `
+		UPDATE songs
+		SET title = :title, artist = :artist, duration = :duration, source_id = :source_id
+		WHERE id = :id
+	`
+	_, err := r.db.Connection(ctx).NamedExecContext(ctx, query, song)
+	return err
+}
+
+// Delete removes the song; its fingerprints are removed by ON DELETE CASCADE.
+func (r *SongRepository) Delete(ctx context.Context, id uuid.UUID) (bool, error) {
+	result, err := r.db.Connection(ctx).ExecContext(ctx, "DELETE FROM songs WHERE id = $1", id)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// FindLowHashDensity returns songs with fewer than minHashesPerSecond hashes per second of duration,
+// sparsest first. Such songs are usually broken or silent ingestions.
+func (r *SongRepository) FindLowHashDensity(ctx context.Context, minHashesPerSecond float64, limit int) ([]SongHashCount, error) {
+	query := `
+		SELECT s.*, COUNT(f.id) AS hash_count
+		FROM songs s
+		LEFT JOIN fingerprints f ON f.song_id = s.id
+		GROUP BY s.id
+		HAVING COUNT(f.id) < $1 * GREATEST(s.duration, 1000) / 1000.0
+		ORDER BY COUNT(f.id)::float / GREATEST(s.duration, 1000), s.id
+		LIMIT $2
+	`
+	songs := []SongHashCount{}
+	if err := r.db.Connection(ctx).SelectContext(ctx, &songs, query, minHashesPerSecond, limit); err != nil {
+		return nil, err
+	}
+	return songs, nil
+}
+
+func (r *SongRepository) StorageSizes(ctx context.Context) ([]db.RelationSize, error) {
+	return r.db.RelationSizes(ctx, "songs")
+}
+
+func (f SongFilter) where() (string, []interface{}) {
+	query := strings.TrimSpace(f.Query)
+	if query == "" {
+		return "", nil
+	}
+	return "WHERE title ILIKE $1 OR artist ILIKE $1", []interface{}{"%" + escapeLike(query) + "%"}
+}
+
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
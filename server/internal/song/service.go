@@ -5,11 +5,11 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"go-shazam/internal/audio"
 	"go-shazam/internal/core/db"
 	"go-shazam/internal/fingerprint"
 	"go-shazam/internal/queue"
 	"go-shazam/internal/utils/converter"
+	"go-shazam/pkg/audio"
 	"os"
 	"path/filepath"
 
@@ -17,11 +17,21 @@ import (
 	"github.com/hibiken/asynq"
 )
 
-var ErrSongTaskAlreadyExists = errors.New("Song is already being processed")
+const (
+	defaultSongListLimit = 20
+)
+
+var (
+	ErrSongTaskAlreadyExists = errors.New("Song is already being processed")
+	ErrSongNotFound          = errors.New("song not found")
+	ErrUnsupportedAudio      = errors.New("unsupported or corrupted audio file")
+)
 
 type SongMetadataSource interface {
 	GetSongMetadata(ctx context.Context, sourceID string) (*SongMetadata, error)
-	ExtractSourceID(link string) (string, error)
+	// ExtractSourceIDs returns the source IDs of the tracks behind a link to a track or to a
+	// collection of tracks
+	ExtractSourceIDs(ctx context.Context, link string) ([]string, error)
 }
 
 type SongDownloader interface {
@@ -59,13 +69,46 @@ func (s *SongService) GetSongMetadata(ctx context.Context, sourceID string) (*So
 	return s.songMetadataSource.GetSongMetadata(ctx, sourceID)
 }
 
-func (s *SongService) EnqueueSong(ctx context.Context, link string) error {
-	sourceID, err := s.songMetadataSource.ExtractSourceID(link)
+// EnqueueSong enqueues one AddSongTaskType task per track behind the link, which can point to
+// a single track or to a collection such as an album or playlist. Tracks already being
+// processed are not enqueued again; ErrSongTaskAlreadyExists is only returned when that is
+// the case for every track.
+func (s *SongService) EnqueueSong(ctx context.Context, link string) (*EnqueueSongResponse, error) {
+	sourceIDs, err := s.songMetadataSource.ExtractSourceIDs(ctx, link)
 	if err != nil {
-		return fmt.Errorf("failed to extract source ID from link: %w", err)
+		return nil, fmt.Errorf("failed to extract source ID from link: %w", err)
+	}
+
+	batch := &EnqueueSongResponse{BatchID: uuid.NewString(), Tracks: make([]EnqueuedTrack, 0, len(sourceIDs))}
+	var enqueueErr error
+	for _, sourceID := range sourceIDs {
+		track := EnqueuedTrack{SourceID: sourceID, Status: TrackStatusEnqueued}
+		if err := s.enqueueTrack(sourceID, batch.BatchID); err != nil {
+			switch {
+			case errors.Is(err, ErrSongTaskAlreadyExists):
+				track.Status = TrackStatusAlreadyEnqueued
+			default:
+				track.Status = TrackStatusFailed
+				track.Error = err.Error()
+				enqueueErr = err
+			}
+		}
+		batch.Tracks = append(batch.Tracks, track)
 	}
 
-	payload, err := json.Marshal(AddSongTaskPayload{ID: sourceID})
+	enqueued, conflicts := batch.count(TrackStatusEnqueued), batch.count(TrackStatusAlreadyEnqueued)
+	if enqueued == 0 && conflicts == len(batch.Tracks) {
+		return nil, ErrSongTaskAlreadyExists
+	}
+	if enqueued == 0 && conflicts == 0 {
+		return nil, enqueueErr
+	}
+	return batch, nil
+}
+
+// enqueueTrack enqueues the task of one track, deduplicated by using the source ID as task ID
+func (s *SongService) enqueueTrack(sourceID string, batchID string) error {
+	payload, err := json.Marshal(AddSongTaskPayload{ID: sourceID, BatchID: batchID})
 	if err != nil {
 		return fmt.Errorf("failed to marshal task payload: %w", err)
 	}
@@ -86,16 +129,9 @@ func (s *SongService) AddSong(ctx context.Context, sourceID string) (*SongMetada
 		return nil, fmt.Errorf("failed to get song metadata: %w", err)
 	}
 
-	existingSong, err := s.songRepository.FindByTitleAndArtist(ctx, songMeta.Title, songMeta.Artist)
-	if err != nil {
-		return nil, err
-	}
-	if existingSong != nil {
-		return &SongMetadata{
-			Title:      existingSong.Title,
-			Artist:     existingSong.Artist,
-			DurationMs: existingSong.Duration,
-		}, nil
+	existingSong, err := s.findExisting(ctx, songMeta)
+	if err != nil || existingSong != nil {
+		return existingSong, err
 	}
 
 	downloadedSong, err := s.songDownloader.DownloadSong(ctx, songMeta, os.TempDir())
@@ -103,31 +139,97 @@ func (s *SongService) AddSong(ctx context.Context, sourceID string) (*SongMetada
 		return nil, fmt.Errorf("failed to download song: %w", err)
 	}
 
-	// Convert to WAV
 	fullPath := filepath.Join(downloadedSong.Path, downloadedSong.Filename)
 	defer os.Remove(fullPath) // Cleanup downloaded file
 
-	wavPath, err := converter.ConvertToWav(fullPath, audio.TargetSampleRate)
+	if err := s.saveSong(ctx, songMeta, fullPath, downloadedSong.SourceID); err != nil {
+		return nil, err
+	}
+	return songMeta, nil
+}
+
+// AddSongFile adds the audio file at path to the catalog under the given metadata, like
+// AddSong without downloading anything. A zero duration is taken from the decoded audio. The
+// file is left in place.
+func (s *SongService) AddSongFile(ctx context.Context, songMeta *SongMetadata, path string, sourceID string) (*SongMetadata, error) {
+	existingSong, err := s.findExisting(ctx, songMeta)
+	if err != nil || existingSong != nil {
+		return existingSong, err
+	}
+
+	if err := s.saveSong(ctx, songMeta, path, sourceID); err != nil {
+		return nil, err
+	}
+	return songMeta, nil
+}
+
+// AddSongSamples adds audio decoded at audio.TargetSampleRate to the catalog under the given
+// metadata. A zero duration is taken from the samples.
+func (s *SongService) AddSongSamples(ctx context.Context, songMeta *SongMetadata, samples []float64, sourceID string) (*SongMetadata, error) {
+	existingSong, err := s.findExisting(ctx, songMeta)
+	if err != nil || existingSong != nil {
+		return existingSong, err
+	}
+
+	if err := s.saveSamples(ctx, songMeta, samples, audio.TargetSampleRate, sourceID); err != nil {
+		return nil, err
+	}
+	return songMeta, nil
+}
+
+// findExisting returns the metadata of the catalog song with the same title and artist
+func (s *SongService) findExisting(ctx context.Context, songMeta *SongMetadata) (*SongMetadata, error) {
+	existingSong, err := s.songRepository.FindByTitleAndArtist(ctx, songMeta.Title, songMeta.Artist)
 	if err != nil {
-		return nil, fmt.Errorf("failed to convert to wav: %w", err)
+		return nil, err
+	}
+	if existingSong == nil {
+		return nil, nil
+	}
+	return &SongMetadata{
+		Title:      existingSong.Title,
+		Artist:     existingSong.Artist,
+		DurationMs: existingSong.Duration,
+	}, nil
+}
+
+// saveSong converts the audio file and saves it with saveSamples.
+func (s *SongService) saveSong(ctx context.Context, songMeta *SongMetadata, path string, sourceID string) error {
+	// Convert to WAV
+	wavPath, err := converter.ConvertToWav(path, audio.TargetSampleRate)
+	if err != nil {
+		// Only a file ffmpeg cannot read is unsupported; failing to run ffmpeg is worth a retry
+		if converter.IsRejected(err) {
+			return fmt.Errorf("%w: %s", ErrUnsupportedAudio, err)
+		}
+		return fmt.Errorf("failed to convert audio: %w", err)
 	}
 	defer os.Remove(wavPath) // Cleanup converted WAV file
 
 	// Load WAV
 	samples, sampleRate, err := audio.LoadWav(wavPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load wav: %w", err)
+		return fmt.Errorf("failed to load converted audio: %w", err)
+	}
+	return s.saveSamples(ctx, songMeta, samples, sampleRate, sourceID)
+}
+
+// saveSamples fingerprints the audio, then saves the song and its fingerprints in one
+// transaction.
+func (s *SongService) saveSamples(ctx context.Context, songMeta *SongMetadata, samples []float64, sampleRate int, sourceID string) error {
+	if songMeta.DurationMs == 0 {
+		songMeta.DurationMs = len(samples) * 1000 / sampleRate
 	}
 
 	// Process audio (FFT) - CPU bound, done outside transaction
 	fragments, err := audio.ProcessAudio(samples, sampleRate)
 	if err != nil {
-		return nil, fmt.Errorf("failed to process audio: %w", err)
+		return fmt.Errorf("failed to process audio: %w", err)
 	}
 
 	songID, err := uuid.NewV7()
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate uuid: %w", err)
+		return fmt.Errorf("failed to generate uuid: %w", err)
 	}
 
 	songEntity := &SongEntity{
@@ -135,7 +237,7 @@ func (s *SongService) AddSong(ctx context.Context, sourceID string) (*SongMetada
 		Title:    songMeta.Title,
 		Artist:   songMeta.Artist,
 		Duration: songMeta.DurationMs,
-		SourceID: downloadedSong.SourceID,
+		SourceID: sourceID,
 	}
 
 	// Calculate fingerprints (CPU bound)
@@ -154,10 +256,114 @@ func (s *SongService) AddSong(ctx context.Context, sourceID string) (*SongMetada
 	})
 
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	fmt.Printf("Processed %d fragments and saved %d hashes for song %s\n", len(fragments), len(hashes), songMeta.Title)
 
-	return songMeta, nil
+	return nil
+}
+
+func (s *SongService) ListSongs(ctx context.Context, req *ListSongsRequest) (*SongListResponse, error) {
+	filter := SongFilter{
+		Query:  req.Query,
+		Limit:  req.Limit,
+		Offset: req.Offset,
+	}
+	if filter.Limit == 0 {
+		filter.Limit = defaultSongListLimit
+	}
+
+	total, err := s.songRepository.Count(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count songs: %w", err)
+	}
+
+	songs, err := s.songRepository.List(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list songs: %w", err)
+	}
+
+	items := make([]SongResponse, len(songs))
+	for i := range songs {
+		items[i] = toSongResponse(&songs[i])
+	}
+
+	return &SongListResponse{
+		Items:  items,
+		Total:  total,
+		Limit:  filter.Limit,
+		Offset: filter.Offset,
+	}, nil
+}
+
+func (s *SongService) GetSong(ctx context.Context, id uuid.UUID) (*SongResponse, error) {
+	songEntity, err := s.songRepository.FindByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find song: %w", err)
+	}
+	if songEntity == nil {
+		return nil, ErrSongNotFound
+	}
+
+	hashCount, err := s.fingerprintService.CountSongHashes(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count song hashes: %w", err)
+	}
+
+	response := toSongResponse(songEntity)
+	response.HashCount = &hashCount
+	return &response, nil
+}
+
+func (s *SongService) UpdateSong(ctx context.Context, id uuid.UUID, req *UpdateSongRequest) (*SongResponse, error) {
+	songEntity, err := s.songRepository.FindByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find song: %w", err)
+	}
+	if songEntity == nil {
+		return nil, ErrSongNotFound
+	}
+
+	if req.Title != nil {
+		songEntity.Title = *req.Title
+	}
+	if req.Artist != nil {
+		songEntity.Artist = *req.Artist
+	}
+	if req.Duration != nil {
+		songEntity.Duration = *req.Duration
+	}
+	if req.SourceID != nil {
+		songEntity.SourceID = *req.SourceID
+	}
+
+	if err := s.songRepository.Update(ctx, songEntity); err != nil {
+		return nil, fmt.Errorf("failed to update song: %w", err)
+	}
+
+	response := toSongResponse(songEntity)
+	return &response, nil
+}
+
+// DeleteSong removes the song together with its fingerprints.
+func (s *SongService) DeleteSong(ctx context.Context, id uuid.UUID) error {
+	deleted, err := s.songRepository.Delete(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete song: %w", err)
+	}
+	if !deleted {
+		return ErrSongNotFound
+	}
+	return nil
+}
+
+func toSongResponse(song *SongEntity) SongResponse {
+	return SongResponse{
+		ID:       song.ID.String(),
+		Title:    song.Title,
+		Artist:   song.Artist,
+		Duration: song.Duration,
+		SourceID: song.SourceID,
+	}
 }
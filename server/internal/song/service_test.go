@@ -2,7 +2,9 @@ package song
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"go-shazam/internal/core/db"
 	"testing"
 
 	"github.com/google/uuid"
@@ -23,12 +25,12 @@ func (m *MockSongMetadataSource) GetSongMetadata(ctx context.Context, sourceID s
 	return args.Get(0).(*SongMetadata), args.Error(1)
 }
 
-func (m *MockSongMetadataSource) ExtractSourceID(link string) (string, error) {
-	args := m.Called(link)
+func (m *MockSongMetadataSource) ExtractSourceIDs(ctx context.Context, link string) ([]string, error) {
+	args := m.Called(ctx, link)
 	if args.Get(0) == nil {
-		return "", args.Error(1)
+		return nil, args.Error(1)
 	}
-	return args.Get(0).(string), args.Error(1)
+	return args.Get(0).([]string), args.Error(1)
 }
 
 type MockSongDownloader struct {
@@ -68,6 +70,53 @@ func (m *MockSongRepository) FindByTitleAndArtist(ctx context.Context, title str
 	return args.Get(0).(*SongEntity), args.Error(1)
 }
 
+func (m *MockSongRepository) FindBySourceID(ctx context.Context, sourceID string) (*SongEntity, error) {
+	args := m.Called(ctx, sourceID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*SongEntity), args.Error(1)
+}
+
+func (m *MockSongRepository) List(ctx context.Context, filter SongFilter) ([]SongEntity, error) {
+	args := m.Called(ctx, filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]SongEntity), args.Error(1)
+}
+
+func (m *MockSongRepository) Count(ctx context.Context, filter SongFilter) (int, error) {
+	args := m.Called(ctx, filter)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockSongRepository) Update(ctx context.Context, song *SongEntity) error {
+	args := m.Called(ctx, song)
+	return args.Error(0)
+}
+
+func (m *MockSongRepository) Delete(ctx context.Context, id uuid.UUID) (bool, error) {
+	args := m.Called(ctx, id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockSongRepository) FindLowHashDensity(ctx context.Context, minHashesPerSecond float64, limit int) ([]SongHashCount, error) {
+	args := m.Called(ctx, minHashesPerSecond, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]SongHashCount), args.Error(1)
+}
+
+func (m *MockSongRepository) StorageSizes(ctx context.Context) ([]db.RelationSize, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]db.RelationSize), args.Error(1)
+}
+
 func TestSongService_GetSongMetadata_Success(t *testing.T) {
 	mockMetadataSource := new(MockSongMetadataSource)
 	mockDownloader := new(MockSongDownloader)
@@ -126,28 +175,152 @@ func TestSongService_EnqueueSong_Success(t *testing.T) {
 	mockMetadataSource := new(MockSongMetadataSource)
 	mockQueue := new(MockQueueService)
 
-	mockMetadataSource.On("ExtractSourceID", "https://open.spotify.com/track/123").Return("123", nil)
+	mockMetadataSource.On("ExtractSourceIDs", mock.Anything, "https://open.spotify.com/track/123").Return([]string{"123"}, nil)
 	mockQueue.On("Enqueue", AddSongTaskType, mock.Anything).Return(nil, nil)
 
 	service := NewSongService(mockMetadataSource, nil, nil, nil, nil, mockQueue)
 
-	err := service.EnqueueSong(context.Background(), "https://open.spotify.com/track/123")
+	batch, err := service.EnqueueSong(context.Background(), "https://open.spotify.com/track/123")
 
 	assert.NoError(t, err)
+	assert.NotEmpty(t, batch.BatchID)
+	assert.Equal(t, []EnqueuedTrack{{SourceID: "123", Status: TrackStatusEnqueued}}, batch.Tracks)
 	mockMetadataSource.AssertExpectations(t)
 	mockQueue.AssertExpectations(t)
 }
 
+func TestSongService_EnqueueSong_Album(t *testing.T) {
+	mockMetadataSource := new(MockSongMetadataSource)
+	mockQueue := new(MockQueueService)
+
+	mockMetadataSource.On("ExtractSourceIDs", mock.Anything, "https://open.spotify.com/album/1").Return([]string{"a", "b", "c"}, nil)
+	var batchIDs []string
+	payloadFor := func(id string) any {
+		return mock.MatchedBy(func(payload []byte) bool {
+			var p AddSongTaskPayload
+			if json.Unmarshal(payload, &p) != nil || p.ID != id {
+				return false
+			}
+			batchIDs = append(batchIDs, p.BatchID)
+			return true
+		})
+	}
+	mockQueue.On("Enqueue", AddSongTaskType, payloadFor("a")).Return(nil, nil)
+	mockQueue.On("Enqueue", AddSongTaskType, payloadFor("b")).Return(nil, asynq.ErrTaskIDConflict)
+	mockQueue.On("Enqueue", AddSongTaskType, payloadFor("c")).Return(nil, errors.New("redis is down"))
+
+	service := NewSongService(mockMetadataSource, nil, nil, nil, nil, mockQueue)
+
+	batch, err := service.EnqueueSong(context.Background(), "https://open.spotify.com/album/1")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c"}, []string{batch.Tracks[0].SourceID, batch.Tracks[1].SourceID, batch.Tracks[2].SourceID})
+	assert.Equal(t, TrackStatusEnqueued, batch.Tracks[0].Status)
+	assert.Equal(t, TrackStatusAlreadyEnqueued, batch.Tracks[1].Status)
+	assert.Equal(t, TrackStatusFailed, batch.Tracks[2].Status)
+	assert.Contains(t, batch.Tracks[2].Error, "redis is down")
+	for _, id := range batchIDs {
+		assert.Equal(t, batch.BatchID, id)
+	}
+	mockQueue.AssertExpectations(t)
+}
+
+func TestSongService_EnqueueSong_AllAlreadyEnqueued(t *testing.T) {
+	mockMetadataSource := new(MockSongMetadataSource)
+	mockQueue := new(MockQueueService)
+
+	mockMetadataSource.On("ExtractSourceIDs", mock.Anything, "https://open.spotify.com/track/123").Return([]string{"123"}, nil)
+	mockQueue.On("Enqueue", AddSongTaskType, mock.Anything).Return(nil, asynq.ErrTaskIDConflict)
+
+	service := NewSongService(mockMetadataSource, nil, nil, nil, nil, mockQueue)
+
+	batch, err := service.EnqueueSong(context.Background(), "https://open.spotify.com/track/123")
+
+	assert.ErrorIs(t, err, ErrSongTaskAlreadyExists)
+	assert.Nil(t, batch)
+}
+
 func TestSongService_EnqueueSong_InvalidLink(t *testing.T) {
 	mockMetadataSource := new(MockSongMetadataSource)
 
-	mockMetadataSource.On("ExtractSourceID", "invalid-link").Return("", errors.New("invalid link"))
+	mockMetadataSource.On("ExtractSourceIDs", mock.Anything, "invalid-link").Return(nil, errors.New("invalid link"))
 
 	service := NewSongService(mockMetadataSource, nil, nil, nil, nil, nil)
 
-	err := service.EnqueueSong(context.Background(), "invalid-link")
+	_, err := service.EnqueueSong(context.Background(), "invalid-link")
 
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "failed to extract source ID")
 	mockMetadataSource.AssertExpectations(t)
 }
+
+func TestSongService_ListSongs_DefaultLimit(t *testing.T) {
+	mockRepository := new(MockSongRepository)
+
+	songID := uuid.New()
+	filter := SongFilter{Query: "queen", Limit: defaultSongListLimit}
+	mockRepository.On("Count", mock.Anything, filter).Return(1, nil)
+	mockRepository.On("List", mock.Anything, filter).Return([]SongEntity{
+		{ID: songID, Title: "Bohemian Rhapsody", Artist: "Queen", Duration: 354000, SourceID: "yt"},
+	}, nil)
+
+	service := NewSongService(nil, nil, mockRepository, nil, nil, nil)
+
+	result, err := service.ListSongs(context.Background(), &ListSongsRequest{Query: "queen"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.Total)
+	assert.Equal(t, defaultSongListLimit, result.Limit)
+	assert.Len(t, result.Items, 1)
+	assert.Equal(t, songID.String(), result.Items[0].ID)
+	mockRepository.AssertExpectations(t)
+}
+
+func TestSongService_UpdateSong_PartialUpdate(t *testing.T) {
+	mockRepository := new(MockSongRepository)
+
+	songID := uuid.New()
+	existing := &SongEntity{ID: songID, Title: "Old Title", Artist: "Artist", Duration: 1000, SourceID: "yt"}
+	mockRepository.On("FindByID", mock.Anything, songID).Return(existing, nil)
+	mockRepository.On("Update", mock.Anything, mock.MatchedBy(func(s *SongEntity) bool {
+		return s.Title == "New Title" && s.Artist == "Artist" && s.Duration == 1000
+	})).Return(nil)
+
+	service := NewSongService(nil, nil, mockRepository, nil, nil, nil)
+
+	newTitle := "New Title"
+	result, err := service.UpdateSong(context.Background(), songID, &UpdateSongRequest{Title: &newTitle})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "New Title", result.Title)
+	mockRepository.AssertExpectations(t)
+}
+
+func TestSongService_UpdateSong_NotFound(t *testing.T) {
+	mockRepository := new(MockSongRepository)
+
+	songID := uuid.New()
+	mockRepository.On("FindByID", mock.Anything, songID).Return(nil, nil)
+
+	service := NewSongService(nil, nil, mockRepository, nil, nil, nil)
+
+	result, err := service.UpdateSong(context.Background(), songID, &UpdateSongRequest{})
+
+	assert.ErrorIs(t, err, ErrSongNotFound)
+	assert.Nil(t, result)
+	mockRepository.AssertExpectations(t)
+}
+
+func TestSongService_DeleteSong_NotFound(t *testing.T) {
+	mockRepository := new(MockSongRepository)
+
+	songID := uuid.New()
+	mockRepository.On("Delete", mock.Anything, songID).Return(false, nil)
+
+	service := NewSongService(nil, nil, mockRepository, nil, nil, nil)
+
+	err := service.DeleteSong(context.Background(), songID)
+
+	assert.ErrorIs(t, err, ErrSongNotFound)
+	mockRepository.AssertExpectations(t)
+}
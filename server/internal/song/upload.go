@@ -0,0 +1,123 @@
+package song
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-shazam/internal/queue"
+	"go-shazam/internal/utils/converter"
+	fileutils "go-shazam/internal/utils/file"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+)
+
+const (
+	// Stored uploads keep a neutral extension so that the converted WAV never overwrites them
+	uploadExt = ".upload"
+	// Failed ingestions are retried this many times before the upload is removed
+	uploadMaxRetry = 2
+)
+
+var ErrMissingMetadata = errors.New("title and artist are required when the file has no tags for them")
+
+// UploadService adds songs from uploaded audio files. The files are stored in the upload
+// directory and ingested by the worker, through the same path as songs found on Spotify.
+type UploadService struct {
+	config      *Config
+	songService *SongService
+	queue       queue.QueueService
+	readTags    func(path string) (*converter.Tags, error)
+}
+
+func NewUploadService(config *Config, songService *SongService, queue queue.QueueService) *UploadService {
+	return &UploadService{config: config, songService: songService, queue: queue, readTags: converter.ReadTags}
+}
+
+// Upload stores the file and enqueues its ingestion. Missing title and artist are read from
+// the tags of the file; a missing duration is taken from the decoded audio by the worker.
+// The same file cannot be enqueued twice while it is processed.
+func (s *UploadService) Upload(ctx context.Context, file io.Reader, songMeta SongMetadata) (*UploadSongResponse, error) {
+	filename := uuid.NewString() + uploadExt
+	path := filepath.Join(s.config.UploadDir, filename)
+
+	hash := sha256.New()
+	if err := fileutils.SaveFile(s.config.UploadDir, filename, file, hash); err != nil {
+		return nil, fmt.Errorf("failed to save upload: %w", err)
+	}
+	checksum := hex.EncodeToString(hash.Sum(nil))
+
+	if songMeta.Title == "" || songMeta.Artist == "" {
+		tags, err := s.readTags(path)
+		if err != nil {
+			os.Remove(path)
+			if converter.IsRejected(err) {
+				return nil, fmt.Errorf("%w: %s", ErrUnsupportedAudio, err)
+			}
+			return nil, fmt.Errorf("failed to read tags: %w", err)
+		}
+		songMeta.Title = cmp.Or(songMeta.Title, tags.Title)
+		songMeta.Artist = cmp.Or(songMeta.Artist, tags.Artist)
+		songMeta.DurationMs = cmp.Or(songMeta.DurationMs, tags.DurationMs)
+	}
+	if songMeta.Title == "" || songMeta.Artist == "" {
+		os.Remove(path)
+		return nil, ErrMissingMetadata
+	}
+
+	sourceID := UploadSourceID(checksum)
+	payload, err := json.Marshal(AddUploadedSongTaskPayload{
+		File:       filename,
+		SourceID:   sourceID,
+		Title:      songMeta.Title,
+		Artist:     songMeta.Artist,
+		DurationMs: songMeta.DurationMs,
+	})
+	if err != nil {
+		os.Remove(path)
+		return nil, fmt.Errorf("failed to marshal task payload: %w", err)
+	}
+
+	if _, err = s.queue.Enqueue(AddUploadedSongTaskType, payload, asynq.TaskID(sourceID), asynq.MaxRetry(uploadMaxRetry)); err != nil {
+		os.Remove(path)
+		if errors.Is(err, asynq.ErrTaskIDConflict) {
+			return nil, ErrSongTaskAlreadyExists
+		}
+		return nil, fmt.Errorf("failed to enqueue song task: %w", err)
+	}
+
+	return &UploadSongResponse{
+		SourceID: sourceID,
+		Title:    songMeta.Title,
+		Artist:   songMeta.Artist,
+		Duration: songMeta.DurationMs,
+	}, nil
+}
+
+// UploadSourceID is the source ID of a song added from a file, given the hex SHA-256 of the
+// file. The same file gets the same source ID whether it is uploaded or imported.
+func UploadSourceID(checksum string) string {
+	return "upload:" + checksum
+}
+
+// AddFile ingests a file stored in the upload directory.
+func (s *UploadService) AddFile(ctx context.Context, payload AddUploadedSongTaskPayload) (*SongMetadata, error) {
+	songMeta := &SongMetadata{Title: payload.Title, Artist: payload.Artist, DurationMs: payload.DurationMs}
+	return s.songService.AddSongFile(ctx, songMeta, filepath.Join(s.config.UploadDir, filepath.Base(payload.File)), payload.SourceID)
+}
+
+// RemoveFile deletes a stored upload once it is no longer needed.
+func (s *UploadService) RemoveFile(filename string) error {
+	err := os.Remove(filepath.Join(s.config.UploadDir, filepath.Base(filename)))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package song
+
+import (
+	"errors"
+	"fmt"
+	"go-shazam/internal/auth"
+	"go-shazam/internal/logger"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type UploadHandler struct {
+	config        *Config
+	uploadService *UploadService
+}
+
+func NewUploadHandler(config *Config, uploadService *UploadService) *UploadHandler {
+	return &UploadHandler{config: config, uploadService: uploadService}
+}
+
+// RegisterUploadRoutes registers the ingestion of uploaded audio files. Anything uploaded
+// ends up in the catalog as is, so only admins can upload songs.
+func RegisterUploadRoutes(r *gin.Engine, h *UploadHandler, jwtService *auth.JWTService, authConfig *auth.Config) {
+	r.POST("/api/song/upload", auth.AuthMiddleware(jwtService), auth.AdminMiddleware(authConfig), h.Upload)
+}
+
+// Upload adds the audio file sent as the "file" field of a multipart form to the catalog,
+// with the optional "title", "artist" and "duration" (ms) fields. It answers with 202 once
+// the file is stored and its ingestion is enqueued.
+func (h *UploadHandler) Upload(c *gin.Context) {
+	log := logger.FromContext(c.Request.Context())
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.config.UploadMaxBytes)
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("file is larger than %d bytes", h.config.UploadMaxBytes)})
+			return
+		}
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "audio file is required in the \"file\" field"})
+		return
+	}
+
+	var req UploadSongRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		log.Error("failed to open uploaded file", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read uploaded file"})
+		return
+	}
+	defer file.Close()
+
+	response, err := h.uploadService.Upload(c.Request.Context(), file, SongMetadata{
+		Title:      strings.TrimSpace(req.Title),
+		Artist:     strings.TrimSpace(req.Artist),
+		DurationMs: req.Duration,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrUnsupportedAudio), errors.Is(err, ErrMissingMetadata):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		case errors.Is(err, ErrSongTaskAlreadyExists):
+			c.JSON(http.StatusBadRequest, gin.H{"error": ErrSongTaskAlreadyExists.Error()})
+		default:
+			log.Error("failed to upload song", "error", err, "filename", fileHeader.Filename)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to upload song"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusAccepted, response)
+}
@@ -0,0 +1,173 @@
+package song
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"go-shazam/internal/auth"
+	"go-shazam/internal/utils/converter"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/hibiken/asynq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func setupUploadRouter(uploadService *UploadService, config *Config) http.Handler {
+	router := setupTestRouter(NewSongHandler(nil))
+	RegisterUploadRoutes(router, NewUploadHandler(config, uploadService), auth.NewJWTService(testAuthConfig), testAuthConfig)
+	return router
+}
+
+func uploadRequest(t *testing.T, content []byte, fields map[string]string) *http.Request {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	if content != nil {
+		part, err := writer.CreateFormFile("file", "master.flac")
+		require.NoError(t, err)
+		part.Write(content)
+	}
+	for name, value := range fields {
+		require.NoError(t, writer.WriteField(name, value))
+	}
+	require.NoError(t, writer.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/api/song/upload", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func TestUploadHandler_Upload(t *testing.T) {
+	config := &Config{UploadDir: t.TempDir(), UploadMaxBytes: 1024}
+	mockQueue := new(MockQueueService)
+	router := setupUploadRouter(NewUploadService(config, nil, mockQueue), config)
+	content := []byte("unreleased master")
+	checksum := sha256.Sum256(content)
+	sourceID := "upload:" + hex.EncodeToString(checksum[:])
+
+	var payload AddUploadedSongTaskPayload
+	mockQueue.On("Enqueue", AddUploadedSongTaskType, mock.Anything).Run(func(args mock.Arguments) {
+		require.NoError(t, json.Unmarshal(args.Get(1).([]byte), &payload))
+	}).Return(nil, nil).Once()
+
+	req := uploadRequest(t, content, map[string]string{"title": " Demo ", "artist": "Band", "duration": "1000"})
+	authorize(t, req, testAdminID)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusAccepted, w.Code)
+	var response UploadSongResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, UploadSongResponse{SourceID: sourceID, Title: "Demo", Artist: "Band", Duration: 1000}, response)
+
+	assert.Equal(t, sourceID, payload.SourceID)
+	assert.Equal(t, "Demo", payload.Title)
+	stored, err := os.ReadFile(filepath.Join(config.UploadDir, payload.File))
+	require.NoError(t, err)
+	assert.Equal(t, content, stored)
+
+	// The same file is already being processed
+	mockQueue.On("Enqueue", AddUploadedSongTaskType, mock.Anything).Return(nil, asynq.ErrTaskIDConflict).Once()
+	req = uploadRequest(t, content, map[string]string{"title": "Demo", "artist": "Band"})
+	authorize(t, req, testAdminID)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	entries, err := os.ReadDir(config.UploadDir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "the second upload is removed")
+	mockQueue.AssertExpectations(t)
+}
+
+func TestUploadHandler_Upload_Rejected(t *testing.T) {
+	config := &Config{UploadDir: t.TempDir(), UploadMaxBytes: 1024}
+	uploadService := NewUploadService(config, nil, new(MockQueueService))
+	uploadService.readTags = func(path string) (*converter.Tags, error) {
+		return nil, fmt.Errorf("ffprobe failed: %w", &exec.ExitError{})
+	}
+	router := setupUploadRouter(uploadService, config)
+
+	tests := []struct {
+		name     string
+		userID   string
+		content  []byte
+		fields   map[string]string
+		expected int
+	}{
+		{"not an admin", "user", []byte("audio"), map[string]string{"title": "Demo", "artist": "Band"}, http.StatusForbidden},
+		{"missing file", "admin", nil, map[string]string{"title": "Demo", "artist": "Band"}, http.StatusUnprocessableEntity},
+		{"too large", "admin", make([]byte, 2048), map[string]string{"title": "Demo", "artist": "Band"}, http.StatusRequestEntityTooLarge},
+		{"negative duration", "admin", []byte("audio"), map[string]string{"title": "Demo", "artist": "Band", "duration": "-1"}, http.StatusUnprocessableEntity},
+		// Without title the tags are read, which ffprobe rejects for anything but audio
+		{"no title and no tags", "admin", []byte("not audio"), map[string]string{"artist": "Band"}, http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := uploadRequest(t, tt.content, tt.fields)
+			if tt.userID == "admin" {
+				authorize(t, req, testAdminID)
+			} else {
+				authorize(t, req, testUserID)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expected, w.Code)
+		})
+	}
+
+	entries, err := os.ReadDir(config.UploadDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestAddUploadedSongTaskHandler_SkipsRetryOfUnsupportedAudio(t *testing.T) {
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		t.Skip("ffmpeg is not installed")
+	}
+	config := &Config{UploadDir: t.TempDir()}
+	require.NoError(t, os.WriteFile(filepath.Join(config.UploadDir, "song.upload"), []byte("not audio"), 0o644))
+	mockRepository := new(MockSongRepository)
+	mockRepository.On("FindByTitleAndArtist", mock.Anything, "Demo", "Band").Return(nil, nil)
+	uploadService := NewUploadService(config, NewSongService(nil, nil, mockRepository, nil, nil, nil), nil)
+	payload, err := json.Marshal(AddUploadedSongTaskPayload{File: "song.upload", SourceID: "upload:1", Title: "Demo", Artist: "Band"})
+	require.NoError(t, err)
+
+	err = NewAddUploadedSongTaskHandler(uploadService).ProcessTask(context.Background(), asynq.NewTask(AddUploadedSongTaskType, payload))
+
+	assert.ErrorContains(t, err, ErrUnsupportedAudio.Error())
+	assert.ErrorIs(t, err, asynq.SkipRetry)
+	assert.NoFileExists(t, filepath.Join(config.UploadDir, "song.upload"))
+	mockRepository.AssertExpectations(t)
+}
+
+func TestAddUploadedSongTaskHandler_RetriesWhenFFmpegCannotRun(t *testing.T) {
+	// Nothing to run on the PATH: the file is not at fault
+	t.Setenv("PATH", t.TempDir())
+	config := &Config{UploadDir: t.TempDir()}
+	require.NoError(t, os.WriteFile(filepath.Join(config.UploadDir, "song.upload"), []byte("not audio"), 0o644))
+	mockRepository := new(MockSongRepository)
+	mockRepository.On("FindByTitleAndArtist", mock.Anything, "Demo", "Band").Return(nil, nil)
+	uploadService := NewUploadService(config, NewSongService(nil, nil, mockRepository, nil, nil, nil), nil)
+	payload, err := json.Marshal(AddUploadedSongTaskPayload{File: "song.upload", SourceID: "upload:1", Title: "Demo", Artist: "Band"})
+	require.NoError(t, err)
+
+	err = NewAddUploadedSongTaskHandler(uploadService).ProcessTask(context.Background(), asynq.NewTask(AddUploadedSongTaskType, payload))
+
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, ErrUnsupportedAudio)
+	assert.NotErrorIs(t, err, asynq.SkipRetry)
+	assert.FileExists(t, filepath.Join(config.UploadDir, "song.upload"), "kept for the next attempt")
+}
@@ -7,6 +7,8 @@ import (
 type Config struct {
 	ClientID     string
 	ClientSecret string
+	Market       string // country code of the market whose top tracks artist links add
+	MaxTracks    int    // most tracks added from one album, playlist or artist link
 }
 
 func LoadConfig() *Config {
@@ -18,8 +20,16 @@ func LoadConfig() *Config {
 		panic("SPOTIFY_CLIENT_ID and SPOTIFY_CLIENT_SECRET must be set")
 	}
 
+	viper.SetDefault("SPOTIFY_MARKET", "US")
+	viper.SetDefault("SPOTIFY_MAX_TRACKS", 200)
+	if viper.GetInt("SPOTIFY_MAX_TRACKS") < 1 {
+		panic("SPOTIFY_MAX_TRACKS must be at least 1")
+	}
+
 	return &Config{
 		ClientID:     viper.GetString("SPOTIFY_CLIENT_ID"),
 		ClientSecret: viper.GetString("SPOTIFY_CLIENT_SECRET"),
+		Market:       viper.GetString("SPOTIFY_MARKET"),
+		MaxTracks:    viper.GetInt("SPOTIFY_MAX_TRACKS"),
 	}
 }
@@ -19,6 +19,23 @@ func TestLoadConfig_Success(t *testing.T) {
 
 	assert.Equal(t, "test-client-id", config.ClientID)
 	assert.Equal(t, "test-client-secret", config.ClientSecret)
+	assert.Equal(t, "US", config.Market)
+	assert.Equal(t, 200, config.MaxTracks)
+}
+
+func TestLoadConfig_InvalidMaxTracks(t *testing.T) {
+	os.Setenv("SPOTIFY_CLIENT_ID", "test-client-id")
+	os.Setenv("SPOTIFY_CLIENT_SECRET", "test-client-secret")
+	os.Setenv("SPOTIFY_MAX_TRACKS", "-1")
+	defer func() {
+		os.Unsetenv("SPOTIFY_CLIENT_ID")
+		os.Unsetenv("SPOTIFY_CLIENT_SECRET")
+		os.Unsetenv("SPOTIFY_MAX_TRACKS")
+	}()
+
+	assert.Panics(t, func() {
+		LoadConfig()
+	})
 }
 
 func TestLoadConfig_MissingClientID(t *testing.T) {
@@ -1,7 +1,14 @@
 package spotify
 
-import "go.uber.org/fx"
+import (
+	"go-shazam/internal/song"
+
+	"go.uber.org/fx"
+)
 
 var Module = fx.Module("spotify",
-	fx.Provide(NewSpotifySongMetadataSource, LoadConfig),
+	fx.Provide(
+		fx.Annotate(NewSpotifySongMetadataSource, fx.ResultTags(song.MetadataProvidersGroup)),
+		LoadConfig,
+	),
 )
@@ -15,14 +15,30 @@ import (
 	"golang.org/x/oauth2/clientcredentials"
 )
 
+const (
+	linkTrack    = "track"
+	linkAlbum    = "album"
+	linkPlaylist = "playlist"
+	linkArtist   = "artist"
+)
+
+var linkKinds = map[string]bool{linkTrack: true, linkAlbum: true, linkPlaylist: true, linkArtist: true}
+
+// hosts are the hosts of Spotify links
+var hosts = []string{"open.spotify.com", "www.open.spotify.com", "spotify.com", "www.spotify.com"}
+
+var ErrNoTracks = errors.New("no tracks found behind the Spotify link")
+
 type SpotifySongMetadataSource struct {
-	config *clientcredentials.Config
-	token  *oauth2.Token
-	client *spotify.Client
-	mu     sync.Mutex
+	config    *clientcredentials.Config
+	token     *oauth2.Token
+	client    *spotify.Client
+	market    string // market of artist top tracks
+	maxTracks int    // most tracks taken from an album, playlist or artist
+	mu        sync.Mutex
 }
 
-func NewSpotifySongMetadataSource(spotifyConfig *Config) (song.SongMetadataSource, error) {
+func NewSpotifySongMetadataSource(spotifyConfig *Config) (song.MetadataProvider, error) {
 	config := &clientcredentials.Config{
 		ClientID:     spotifyConfig.ClientID,
 		ClientSecret: spotifyConfig.ClientSecret,
@@ -40,12 +56,22 @@ func NewSpotifySongMetadataSource(spotifyConfig *Config) (song.SongMetadataSourc
 	client := spotify.New(httpClient, spotify.WithRetry(true))
 
 	return &SpotifySongMetadataSource{
-		config: config,
-		token:  token,
-		client: client,
+		config:    config,
+		token:     token,
+		client:    client,
+		market:    spotifyConfig.Market,
+		maxTracks: spotifyConfig.MaxTracks,
 	}, nil
 }
 
+func (s *SpotifySongMetadataSource) Name() string {
+	return "spotify"
+}
+
+func (s *SpotifySongMetadataSource) Hosts() []string {
+	return hosts
+}
+
 func (s *SpotifySongMetadataSource) GetSongMetadata(ctx context.Context, id string) (*song.SongMetadata, error) {
 	if err := s.ensureToken(ctx); err != nil {
 		return nil, fmt.Errorf("failed to ensure token: %w", err)
@@ -67,42 +93,123 @@ func (s *SpotifySongMetadataSource) GetSongMetadata(ctx context.Context, id stri
 	}, nil
 }
 
-func (s *SpotifySongMetadataSource) ExtractSourceID(link string) (string, error) {
-	return s.extractIDFromLink(link)
+// ExtractSourceIDs returns the track IDs behind a track, album, playlist or artist link.
+// Artist links stand for the top tracks of the artist in the configured market. Collections
+// are cut at MaxTracks tracks.
+func (s *SpotifySongMetadataSource) ExtractSourceIDs(ctx context.Context, link string) ([]string, error) {
+	kind, id, err := s.parseLink(link)
+	if err != nil {
+		return nil, err
+	}
+	if kind == linkTrack {
+		return []string{id}, nil
+	}
+
+	if err := s.ensureToken(ctx); err != nil {
+		return nil, fmt.Errorf("failed to ensure token: %w", err)
+	}
+
+	var ids []string
+	switch kind {
+	case linkAlbum:
+		ids, err = s.albumTrackIDs(ctx, spotify.ID(id))
+	case linkPlaylist:
+		ids, err = s.playlistTrackIDs(ctx, spotify.ID(id))
+	case linkArtist:
+		ids, err = s.artistTopTrackIDs(ctx, spotify.ID(id))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s tracks from Spotify: %w", kind, err)
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNoTracks, kind)
+	}
+	return ids, nil
 }
 
-func (s *SpotifySongMetadataSource) extractIDFromLink(link string) (string, error) {
-	u, err := url.Parse(link)
+func (s *SpotifySongMetadataSource) albumTrackIDs(ctx context.Context, id spotify.ID) ([]string, error) {
+	page, err := s.client.GetAlbumTracks(ctx, id, spotify.Limit(50))
 	if err != nil {
-		return "", fmt.Errorf("failed to parse URL: %w", err)
+		return nil, err
 	}
 
-	if !s.isValidHost(u) {
-		return "", errors.New("invalid Spotify link: unsupported host")
+	var ids []string
+	for {
+		for _, track := range page.Tracks {
+			ids = append(ids, track.ID.String())
+		}
+		if len(ids) >= s.maxTracks {
+			return ids[:s.maxTracks], nil
+		}
+		if err := s.client.NextPage(ctx, page); err != nil {
+			if errors.Is(err, spotify.ErrNoMorePages) {
+				return ids, nil
+			}
+			return nil, err
+		}
 	}
+}
 
-	pathParts := strings.Split(u.Path, "/")
-	for i, part := range pathParts {
-		if part == "track" && i+1 < len(pathParts) {
-			trackID := pathParts[i+1]
-			if index := strings.Index(trackID, "?"); index != -1 {
-				trackID = trackID[:index]
+func (s *SpotifySongMetadataSource) playlistTrackIDs(ctx context.Context, id spotify.ID) ([]string, error) {
+	page, err := s.client.GetPlaylistItems(ctx, id, spotify.Limit(100))
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for {
+		for _, item := range page.Items {
+			// Local files, podcast episodes and tracks unavailable in the market have no track ID
+			if item.IsLocal || item.Track.Track == nil || item.Track.Track.ID == "" {
+				continue
+			}
+			ids = append(ids, item.Track.Track.ID.String())
+		}
+		if len(ids) >= s.maxTracks {
+			return ids[:s.maxTracks], nil
+		}
+		if err := s.client.NextPage(ctx, page); err != nil {
+			if errors.Is(err, spotify.ErrNoMorePages) {
+				return ids, nil
 			}
-			return trackID, nil
+			return nil, err
 		}
 	}
+}
+
+func (s *SpotifySongMetadataSource) artistTopTrackIDs(ctx context.Context, id spotify.ID) ([]string, error) {
+	tracks, err := s.client.GetArtistsTopTracks(ctx, id, s.market)
+	if err != nil {
+		return nil, err
+	}
 
-	return "", errors.New("invalid Spotify link: track ID not found")
+	ids := make([]string, 0, len(tracks))
+	for _, track := range tracks {
+		ids = append(ids, track.ID.String())
+	}
+	return ids[:min(len(ids), s.maxTracks)], nil
 }
 
-func (s *SpotifySongMetadataSource) isValidHost(u *url.URL) bool {
-	validHosts := map[string]bool{
-		"open.spotify.com":     true,
-		"www.open.spotify.com": true,
-		"spotify.com":          true,
-		"www.spotify.com":      true,
+// parseLink returns the kind of a Spotify link and the ID of the track or collection. The
+// registry only hands over links of Spotify hosts.
+func (s *SpotifySongMetadataSource) parseLink(link string) (string, string, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to parse URL: %w", err)
 	}
-	return validHosts[u.Host]
+
+	pathParts := strings.Split(u.Path, "/")
+	for i, part := range pathParts {
+		if linkKinds[part] && i+1 < len(pathParts) && pathParts[i+1] != "" {
+			id := pathParts[i+1]
+			if index := strings.Index(id, "?"); index != -1 {
+				id = id[:index]
+			}
+			return part, id, nil
+		}
+	}
+
+	return "", "", errors.New("invalid Spotify link: track ID not found")
 }
 
 func (s *SpotifySongMetadataSource) ensureToken(ctx context.Context) error {
@@ -1,62 +1,111 @@
 package spotify
 
 import (
-	"net/url"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/zmb3/spotify/v2"
+	"golang.org/x/oauth2"
 )
 
-func TestSpotifySongMetadataSource_ExtractSourceID_ValidSpotifyLink(t *testing.T) {
+func TestSpotifySongMetadataSource_ExtractSourceIDs_ValidSpotifyLink(t *testing.T) {
 	source := &SpotifySongMetadataSource{}
 
-	id, err := source.ExtractSourceID("https://open.spotify.com/track/4iV5W9uYEdYUVa79Axb7Rh")
+	ids, err := source.ExtractSourceIDs(context.Background(), "https://open.spotify.com/track/4iV5W9uYEdYUVa79Axb7Rh")
 
 	assert.NoError(t, err)
-	assert.Equal(t, "4iV5W9uYEdYUVa79Axb7Rh", id)
+	assert.Equal(t, []string{"4iV5W9uYEdYUVa79Axb7Rh"}, ids)
 }
 
-func TestSpotifySongMetadataSource_ExtractSourceID_SpotifyLinkWithQueryParams(t *testing.T) {
+func TestSpotifySongMetadataSource_ExtractSourceIDs_SpotifyLinkWithQueryParams(t *testing.T) {
 	source := &SpotifySongMetadataSource{}
 
-	id, err := source.ExtractSourceID("https://open.spotify.com/track/4iV5W9uYEdYUVa79Axb7Rh?si=abcd1234")
+	ids, err := source.ExtractSourceIDs(context.Background(), "https://open.spotify.com/track/4iV5W9uYEdYUVa79Axb7Rh?si=abcd1234")
 
 	assert.NoError(t, err)
-	assert.Equal(t, "4iV5W9uYEdYUVa79Axb7Rh", id)
+	assert.Equal(t, []string{"4iV5W9uYEdYUVa79Axb7Rh"}, ids)
 }
 
-func TestSpotifySongMetadataSource_ExtractSourceID_InvalidHost(t *testing.T) {
+func TestSpotifySongMetadataSource_ExtractSourceIDs_NoTrackID(t *testing.T) {
 	source := &SpotifySongMetadataSource{}
 
-	id, err := source.ExtractSourceID("https://example.com/track/123")
+	ids, err := source.ExtractSourceIDs(context.Background(), "https://open.spotify.com/show/123")
 
 	assert.Error(t, err)
-	assert.Empty(t, id)
-	assert.Contains(t, err.Error(), "invalid Spotify link")
-}
-
-func TestSpotifySongMetadataSource_ExtractSourceID_NoTrackID(t *testing.T) {
-	source := &SpotifySongMetadataSource{}
-
-	id, err := source.ExtractSourceID("https://open.spotify.com/album/123")
-
-	assert.Error(t, err)
-	assert.Empty(t, id)
+	assert.Empty(t, ids)
 	assert.Contains(t, err.Error(), "track ID not found")
 }
 
-func TestSpotifySongMetadataSource_isValidHost_ValidHosts(t *testing.T) {
-	source := &SpotifySongMetadataSource{}
-
-	assert.True(t, source.isValidHost(&url.URL{Host: "open.spotify.com"}))
-	assert.True(t, source.isValidHost(&url.URL{Host: "www.spotify.com"}))
-	assert.True(t, source.isValidHost(&url.URL{Host: "spotify.com"}))
-	assert.True(t, source.isValidHost(&url.URL{Host: "www.open.spotify.com"}))
+// stubSource points a source at a stub of the Spotify Web API
+func stubSource(server *httptest.Server, maxTracks int) *SpotifySongMetadataSource {
+	return &SpotifySongMetadataSource{
+		token:     &oauth2.Token{AccessToken: "token", Expiry: time.Now().Add(time.Hour)},
+		client:    spotify.New(server.Client(), spotify.WithBaseURL(server.URL+"/")),
+		market:    "SE",
+		maxTracks: maxTracks,
+	}
 }
 
-func TestSpotifySongMetadataSource_isValidHost_InvalidHost(t *testing.T) {
-	source := &SpotifySongMetadataSource{}
-
-	assert.False(t, source.isValidHost(&url.URL{Host: "example.com"}))
-	assert.False(t, source.isValidHost(&url.URL{Host: "youtube.com"}))
+func TestSpotifySongMetadataSource_ExtractSourceIDs(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	mux.HandleFunc("/albums/album1/tracks", func(w http.ResponseWriter, r *http.Request) {
+		// Two pages
+		if r.URL.Query().Get("offset") == "2" {
+			fmt.Fprint(w, `{"items": [{"id": "t3"}], "offset": 2, "total": 3}`)
+			return
+		}
+		fmt.Fprintf(w, `{"items": [{"id": "t1"}, {"id": "t2"}], "offset": 0, "total": 3, "next": "%s/albums/album1/tracks?offset=2"}`, server.URL)
+	})
+	mux.HandleFunc("/playlists/list1/tracks", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"items": [
+			{"track": {"type": "track", "id": "p1"}},
+			{"is_local": true, "track": {"type": "track", "id": ""}},
+			{"track": null},
+			{"track": {"type": "track", "id": "p2"}}
+		]}`)
+	})
+	mux.HandleFunc("/artists/artist1/top-tracks", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "SE", r.URL.Query().Get("country"))
+		fmt.Fprint(w, `{"tracks": [{"id": "a1"}, {"id": "a2"}, {"id": "a3"}]}`)
+	})
+	mux.HandleFunc("/albums/empty/tracks", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"items": []}`)
+	})
+	source := stubSource(server, 10)
+
+	tests := []struct {
+		link     string
+		expected []string
+	}{
+		{"https://open.spotify.com/track/4iV5W9uYEdYUVa79Axb7Rh?si=1", []string{"4iV5W9uYEdYUVa79Axb7Rh"}},
+		{"https://open.spotify.com/album/album1", []string{"t1", "t2", "t3"}},
+		{"https://open.spotify.com/playlist/list1?si=abc", []string{"p1", "p2"}},
+		{"https://open.spotify.com/intl-de/artist/artist1", []string{"a1", "a2", "a3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.link, func(t *testing.T) {
+			ids, err := source.ExtractSourceIDs(context.Background(), tt.link)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, ids)
+		})
+	}
+
+	_, err := source.ExtractSourceIDs(context.Background(), "https://open.spotify.com/album/empty")
+	assert.ErrorIs(t, err, ErrNoTracks)
+
+	_, err = source.ExtractSourceIDs(context.Background(), "https://open.spotify.com/show/1")
+	assert.ErrorContains(t, err, "invalid Spotify link")
+
+	source.maxTracks = 2
+	ids, err := source.ExtractSourceIDs(context.Background(), "https://open.spotify.com/album/album1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"t1", "t2"}, ids, "collections are cut at the limit")
 }
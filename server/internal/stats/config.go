@@ -0,0 +1,25 @@
+package stats
+
+import "github.com/spf13/viper"
+
+type Config struct {
+	RefreshCron        string
+	LowHashesPerSecond float64
+	LowHashSongsLimit  int
+}
+
+func LoadConfig() *Config {
+	viper.SetConfigFile(".env")
+	viper.ReadInConfig()
+	viper.AutomaticEnv()
+
+	viper.SetDefault("STATS_REFRESH_CRON", "@every 1h")
+	viper.SetDefault("STATS_LOW_HASHES_PER_SECOND", 20.0)
+	viper.SetDefault("STATS_LOW_HASH_SONGS_LIMIT", 20)
+
+	return &Config{
+		RefreshCron:        viper.GetString("STATS_REFRESH_CRON"),
+		LowHashesPerSecond: viper.GetFloat64("STATS_LOW_HASHES_PER_SECOND"),
+		LowHashSongsLimit:  viper.GetInt("STATS_LOW_HASH_SONGS_LIMIT"),
+	}
+}
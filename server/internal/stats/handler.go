@@ -0,0 +1,39 @@
+package stats
+
+import (
+	"errors"
+	"go-shazam/internal/auth"
+	"go-shazam/internal/logger"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type StatsHandler struct {
+	statsService *StatsService
+}
+
+func NewStatsHandler(statsService *StatsService) *StatsHandler {
+	return &StatsHandler{statsService: statsService}
+}
+
+func RegisterRoutes(r *gin.Engine, h *StatsHandler, jwtService *auth.JWTService, authConfig *auth.Config) {
+	r.GET("/api/stats", auth.AuthMiddleware(jwtService), auth.AdminMiddleware(authConfig), h.Get)
+}
+
+func (h *StatsHandler) Get(c *gin.Context) {
+	log := logger.FromContext(c.Request.Context())
+
+	stats, err := h.statsService.GetStats(c.Request.Context())
+	if errors.Is(err, ErrStatsNotReady) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": ErrStatsNotReady.Error()})
+		return
+	}
+	if err != nil {
+		log.Error("failed to get catalog stats", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get catalog stats"})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
@@ -0,0 +1,35 @@
+package stats
+
+import (
+	"go-shazam/internal/core/db"
+	"go-shazam/internal/fingerprint"
+	"time"
+)
+
+type CatalogStats struct {
+	ComputedAt     time.Time                        `json:"computed_at"`
+	TotalSongs     int                              `json:"total_songs"`
+	TotalHashes    int64                            `json:"total_hashes"`
+	DistinctHashes int64                            `json:"distinct_hashes"`
+	HashesPerSong  fingerprint.HashesPerSongSummary `json:"hashes_per_song"`
+	HashFrequency  []FrequencyBucket                `json:"hash_frequency"`
+	LowHashSongs   []LowHashSong                    `json:"low_hash_songs"`
+	Storage        []db.RelationSize                `json:"storage"`
+}
+
+// FrequencyBucket counts hash values that occur in between MinSongs and MaxSongs songs
+type FrequencyBucket struct {
+	MinSongs int     `json:"min_songs"`
+	MaxSongs int     `json:"max_songs"`
+	Hashes   int64   `json:"hashes"`
+	Share    float64 `json:"share"`
+}
+
+type LowHashSong struct {
+	ID              string  `json:"id"`
+	Title           string  `json:"title"`
+	Artist          string  `json:"artist"`
+	Duration        int     `json:"duration"`
+	HashCount       int64   `json:"hash_count"`
+	HashesPerSecond float64 `json:"hashes_per_second"`
+}
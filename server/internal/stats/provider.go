@@ -0,0 +1,34 @@
+package stats
+
+import (
+	"fmt"
+	"go-shazam/internal/queue"
+
+	"go.uber.org/fx"
+)
+
+var Module = fx.Module(
+	"stats",
+	fx.Provide(
+		LoadConfig,
+		NewRepository,
+		NewStatsService,
+	),
+)
+
+var HttpModule = fx.Module(
+	"stats-http",
+	fx.Provide(NewStatsHandler),
+	fx.Invoke(RegisterRoutes),
+)
+
+var QueueModule = fx.Module(
+	"stats-queue",
+	fx.Provide(NewRefreshStatsTaskHandler),
+	fx.Invoke(func(w queue.WorkerServer, s queue.Scheduler, h *RefreshStatsTaskHandler, config *Config) error {
+		fmt.Printf("[Queue] Registering handler for task type: %s\n", RefreshStatsTaskType)
+		w.RegisterServiceHandler(RefreshStatsTaskType, h)
+		_, err := s.RegisterOnce(config.RefreshCron, RefreshStatsTaskType, nil)
+		return err
+	}),
+)
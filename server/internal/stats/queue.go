@@ -0,0 +1,33 @@
+package stats
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hibiken/asynq"
+)
+
+const (
+	RefreshStatsTaskType = "stats:refresh"
+)
+
+type RefreshStatsTaskHandler struct {
+	statsService *StatsService
+}
+
+func NewRefreshStatsTaskHandler(statsService *StatsService) *RefreshStatsTaskHandler {
+	return &RefreshStatsTaskHandler{statsService: statsService}
+}
+
+func (h *RefreshStatsTaskHandler) ProcessTask(ctx context.Context, task *asynq.Task) error {
+	fmt.Printf("[Worker] Received task: %s\n", task.Type())
+
+	stats, err := h.statsService.Refresh(ctx)
+	if err != nil {
+		fmt.Printf("[Worker] Failed to refresh catalog stats: %v\n", err)
+		return fmt.Errorf("failed to refresh catalog stats: %w", err)
+	}
+
+	fmt.Printf("[Worker] Refreshed catalog stats: %d songs, %d hashes\n", stats.TotalSongs, stats.TotalHashes)
+	return nil
+}
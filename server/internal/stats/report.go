@@ -0,0 +1,64 @@
+package stats
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+)
+
+// WriteReport renders the statistics as a plain-text report.
+func WriteReport(w io.Writer, stats *CatalogStats) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "Catalog statistics (computed %s)\n\n", stats.ComputedAt.Format(time.RFC3339))
+
+	fmt.Fprintf(tw, "Songs:\t%d\n", stats.TotalSongs)
+	fmt.Fprintf(tw, "Hashes:\t%d\n", stats.TotalHashes)
+	fmt.Fprintf(tw, "Distinct hash values:\t%d\n", stats.DistinctHashes)
+	fmt.Fprintf(tw, "Hashes per song:\tmin %d / median %.0f / max %d\n\n",
+		stats.HashesPerSong.Min, stats.HashesPerSong.Median, stats.HashesPerSong.Max)
+
+	fmt.Fprintln(tw, "Hash document frequency")
+	fmt.Fprintln(tw, "Songs\tHash values\tShare")
+	for _, b := range stats.HashFrequency {
+		songs := fmt.Sprint(b.MinSongs)
+		if b.MaxSongs != b.MinSongs {
+			songs = fmt.Sprintf("%d-%d", b.MinSongs, b.MaxSongs)
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%.2f%%\n", songs, b.Hashes, b.Share*100)
+	}
+	fmt.Fprintln(tw)
+
+	fmt.Fprintln(tw, "Songs with suspiciously few hashes")
+	if len(stats.LowHashSongs) == 0 {
+		fmt.Fprintln(tw, "(none)")
+	} else {
+		fmt.Fprintln(tw, "ID\tArtist\tTitle\tHashes\tHashes/s")
+		for _, s := range stats.LowHashSongs {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%.1f\n", s.ID, s.Artist, s.Title, s.HashCount, s.HashesPerSecond)
+		}
+	}
+	fmt.Fprintln(tw)
+
+	fmt.Fprintln(tw, "Storage")
+	fmt.Fprintln(tw, "Relation\tKind\tSize")
+	for _, r := range stats.Storage {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", r.Name, r.Kind, formatBytes(r.Bytes))
+	}
+
+	return tw.Flush()
+}
+
+func formatBytes(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,59 @@
+package stats
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"go-shazam/internal/core/db"
+	"time"
+)
+
+// catalogStatsID is the key of the single cached snapshot row
+const catalogStatsID = 1
+
+type Repository struct {
+	db *db.Repository
+}
+
+func NewRepository(db *db.Repository) *Repository {
+	return &Repository{db: db}
+}
+
+type snapshotRow struct {
+	ComputedAt time.Time `db:"computed_at"`
+	Payload    []byte    `db:"payload"`
+}
+
+// FindSnapshot returns the last cached snapshot or nil if none has been computed yet.
+func (r *Repository) FindSnapshot(ctx context.Context) (*CatalogStats, error) {
+	query := "SELECT computed_at, payload FROM catalog_stats WHERE id = $1"
+	var row snapshotRow
+	if err := r.db.Connection(ctx).GetContext(ctx, &row, query, catalogStatsID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var stats CatalogStats
+	if err := json.Unmarshal(row.Payload, &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+func (r *Repository) SaveSnapshot(ctx context.Context, stats *CatalogStats) error {
+	payload, err := json.Marshal(stats)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO catalog_stats (id, computed_at, payload)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (id) DO UPDATE SET computed_at = EXCLUDED.computed_at, payload = EXCLUDED.payload
+	`
+	_, err = r.db.Connection(ctx).ExecContext(ctx, query, catalogStatsID, stats.ComputedAt, payload)
+	return err
+}
@@ -0,0 +1,163 @@
+package stats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-shazam/internal/fingerprint"
+	"go-shazam/internal/song"
+	"time"
+)
+
+var ErrStatsNotReady = errors.New("catalog stats have not been computed yet")
+
+type StatsService struct {
+	config                *Config
+	repository            *Repository
+	songRepository        song.SongRepositoryInterface
+	fingerprintRepository *fingerprint.Repository
+}
+
+func NewStatsService(
+	config *Config,
+	repository *Repository,
+	songRepository song.SongRepositoryInterface,
+	fingerprintRepository *fingerprint.Repository,
+) *StatsService {
+	return &StatsService{
+		config:                config,
+		repository:            repository,
+		songRepository:        songRepository,
+		fingerprintRepository: fingerprintRepository,
+	}
+}
+
+// GetStats returns the cached snapshot, or ErrStatsNotReady until the refresh job has
+// computed the first one.
+func (s *StatsService) GetStats(ctx context.Context) (*CatalogStats, error) {
+	stats, err := s.repository.FindSnapshot(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load stats snapshot: %w", err)
+	}
+	if stats == nil {
+		return nil, ErrStatsNotReady
+	}
+	return stats, nil
+}
+
+// Refresh recomputes the statistics and replaces the cached snapshot.
+func (s *StatsService) Refresh(ctx context.Context) (*CatalogStats, error) {
+	stats, err := s.Compute(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repository.SaveSnapshot(ctx, stats); err != nil {
+		return nil, fmt.Errorf("failed to save stats snapshot: %w", err)
+	}
+
+	return stats, nil
+}
+
+// Compute runs all aggregates against the live index. It scans the whole fingerprints
+// table and should not be called on the request path.
+func (s *StatsService) Compute(ctx context.Context) (*CatalogStats, error) {
+	totalSongs, err := s.songRepository.Count(ctx, song.SongFilter{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to count songs: %w", err)
+	}
+
+	totalHashes, err := s.fingerprintRepository.CountHashes(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count hashes: %w", err)
+	}
+
+	perSong, err := s.fingerprintRepository.HashesPerSong(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarise hashes per song: %w", err)
+	}
+
+	frequencies, err := s.fingerprintRepository.DocumentFrequencies(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute hash document frequencies: %w", err)
+	}
+
+	lowHashSongs, err := s.songRepository.FindLowHashDensity(ctx, s.config.LowHashesPerSecond, s.config.LowHashSongsLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find songs with few hashes: %w", err)
+	}
+
+	songSizes, err := s.songRepository.StorageSizes(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get songs storage size: %w", err)
+	}
+
+	fingerprintSizes, err := s.fingerprintRepository.StorageSizes(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get fingerprints storage size: %w", err)
+	}
+
+	buckets, distinctHashes := bucketFrequencies(frequencies)
+
+	return &CatalogStats{
+		ComputedAt:     time.Now().UTC(),
+		TotalSongs:     totalSongs,
+		TotalHashes:    totalHashes,
+		DistinctHashes: distinctHashes,
+		HashesPerSong:  *perSong,
+		HashFrequency:  buckets,
+		LowHashSongs:   toLowHashSongs(lowHashSongs),
+		Storage:        append(songSizes, fingerprintSizes...),
+	}, nil
+}
+
+// bucketFrequencies groups the document frequency distribution into power-of-two buckets
+// (1, 2, 3-4, 5-8, ...) and returns them with the number of distinct hash values.
+func bucketFrequencies(frequencies []fingerprint.DocumentFrequency) ([]FrequencyBucket, int64) {
+	var total int64
+	for _, f := range frequencies {
+		total += f.Hashes
+	}
+
+	buckets := []FrequencyBucket{}
+	for _, f := range frequencies {
+		if f.Songs <= 0 {
+			continue
+		}
+
+		minSongs, maxSongs := 1, 1
+		for maxSongs < f.Songs {
+			minSongs = maxSongs + 1
+			maxSongs *= 2
+		}
+
+		if n := len(buckets); n == 0 || buckets[n-1].MaxSongs != maxSongs {
+			buckets = append(buckets, FrequencyBucket{MinSongs: minSongs, MaxSongs: maxSongs})
+		}
+		buckets[len(buckets)-1].Hashes += f.Hashes
+	}
+
+	for i := range buckets {
+		if total > 0 {
+			buckets[i].Share = float64(buckets[i].Hashes) / float64(total)
+		}
+	}
+
+	return buckets, total
+}
+
+func toLowHashSongs(songs []song.SongHashCount) []LowHashSong {
+	result := make([]LowHashSong, len(songs))
+	for i, s := range songs {
+		seconds := float64(max(s.Duration, 1000)) / 1000
+		result[i] = LowHashSong{
+			ID:              s.ID.String(),
+			Title:           s.Title,
+			Artist:          s.Artist,
+			Duration:        s.Duration,
+			HashCount:       s.HashCount,
+			HashesPerSecond: float64(s.HashCount) / seconds,
+		}
+	}
+	return result
+}
@@ -0,0 +1,63 @@
+package stats
+
+import (
+	"bytes"
+	"go-shazam/internal/core/db"
+	"go-shazam/internal/fingerprint"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBucketFrequencies(t *testing.T) {
+	frequencies := []fingerprint.DocumentFrequency{
+		{Songs: 1, Hashes: 50},
+		{Songs: 2, Hashes: 20},
+		{Songs: 3, Hashes: 10},
+		{Songs: 4, Hashes: 10},
+		{Songs: 7, Hashes: 10},
+	}
+
+	buckets, total := bucketFrequencies(frequencies)
+
+	assert.Equal(t, int64(100), total)
+	assert.Equal(t, []FrequencyBucket{
+		{MinSongs: 1, MaxSongs: 1, Hashes: 50, Share: 0.5},
+		{MinSongs: 2, MaxSongs: 2, Hashes: 20, Share: 0.2},
+		{MinSongs: 3, MaxSongs: 4, Hashes: 20, Share: 0.2},
+		{MinSongs: 5, MaxSongs: 8, Hashes: 10, Share: 0.1},
+	}, buckets)
+}
+
+func TestBucketFrequencies_Empty(t *testing.T) {
+	buckets, total := bucketFrequencies(nil)
+
+	assert.Empty(t, buckets)
+	assert.Equal(t, int64(0), total)
+}
+
+func TestWriteReport(t *testing.T) {
+	stats := &CatalogStats{
+		ComputedAt:     time.Date(2024, 11, 25, 12, 0, 0, 0, time.UTC),
+		TotalSongs:     2,
+		TotalHashes:    1500,
+		DistinctHashes: 1200,
+		HashesPerSong:  fingerprint.HashesPerSongSummary{Min: 10, Median: 755, Max: 1490},
+		HashFrequency:  []FrequencyBucket{{MinSongs: 1, MaxSongs: 1, Hashes: 1200, Share: 1}},
+		LowHashSongs: []LowHashSong{
+			{ID: "song-id", Title: "Silence", Artist: "Nobody", HashCount: 10, HashesPerSecond: 0.5},
+		},
+		Storage: []db.RelationSize{{Name: "fingerprints", Kind: "table", Bytes: 3 * 1024 * 1024}},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteReport(&buf, stats))
+
+	report := buf.String()
+	assert.Contains(t, report, "2024-11-25T12:00:00Z")
+	assert.Contains(t, report, "min 10 / median 755 / max 1490")
+	assert.Contains(t, report, "Silence")
+	assert.Contains(t, report, "3.0 MiB")
+}
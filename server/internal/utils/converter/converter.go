@@ -1,6 +1,7 @@
 package converter
 
 import (
+	"errors"
 	"fmt"
 	"os/exec"
 	"path/filepath"
@@ -18,8 +19,15 @@ func ConvertToWav(inputPath string, sampleRate int) (string, error) {
 
 	output, err := cmd.CombinedOutput()
 	if err != nil {
-		return "", fmt.Errorf("ffmpeg conversion failed: %s, output: %s", err, string(output))
+		return "", fmt.Errorf("ffmpeg conversion failed: %w, output: %s", err, string(output))
 	}
 
 	return outputPath, nil
 }
+
+// IsRejected reports whether err comes from ffmpeg or ffprobe exiting with an error because
+// they could not read the input, rather than from failing to run them at all.
+func IsRejected(err error) bool {
+	var exitErr *exec.ExitError
+	return errors.As(err, &exitErr)
+}
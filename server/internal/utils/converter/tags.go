@@ -0,0 +1,86 @@
+package converter
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Tags are the metadata ffprobe reads from an audio file. Missing tags are left empty.
+type Tags struct {
+	Title      string
+	Artist     string
+	Album      string
+	DurationMs int
+}
+
+type probeOutput struct {
+	Format struct {
+		Duration string            `json:"duration"`
+		Tags     map[string]string `json:"tags"`
+	} `json:"format"`
+	Streams []struct {
+		Tags map[string]string `json:"tags"`
+	} `json:"streams"`
+}
+
+func ReadTags(inputPath string) (*Tags, error) {
+	// ffprobe -v error -print_format json -show_format -show_streams input.mp3
+	cmd := exec.Command("ffprobe", "-v", "error", "-print_format", "json", "-show_format", "-show_streams", inputPath)
+
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("ffprobe failed: %w", err)
+	}
+
+	var probe probeOutput
+	if err := json.Unmarshal(output, &probe); err != nil {
+		return nil, fmt.Errorf("failed to parse ffprobe output: %w", err)
+	}
+
+	// MP3 and MP4 keep tags on the container, Ogg on the stream; tag names vary in case
+	all := []map[string]string{probe.Format.Tags}
+	for _, stream := range probe.Streams {
+		all = append(all, stream.Tags)
+	}
+	tag := func(name string) string {
+		for _, tags := range all {
+			for key, value := range tags {
+				if strings.EqualFold(key, name) && strings.TrimSpace(value) != "" {
+					return strings.TrimSpace(value)
+				}
+			}
+		}
+		return ""
+	}
+
+	tags := &Tags{Title: tag("title"), Artist: tag("artist"), Album: tag("album")}
+	if tags.Artist == "" {
+		tags.Artist = tag("album_artist")
+	}
+	if seconds, err := strconv.ParseFloat(probe.Format.Duration, 64); err == nil {
+		tags.DurationMs = int(seconds * 1000)
+	}
+	return tags, nil
+}
+
+// ProbeDuration returns the duration of an audio file from its container, without decoding
+// it. Streams that do not record a duration, such as WebM from MediaRecorder, return 0.
+func ProbeDuration(inputPath string) (time.Duration, error) {
+	// ffprobe -v error -show_entries format=duration -of default=noprint_wrappers=1:nokey=1 input.mp3
+	cmd := exec.Command("ffprobe", "-v", "error", "-show_entries", "format=duration", "-of", "default=noprint_wrappers=1:nokey=1", inputPath)
+
+	output, err := cmd.Output()
+	if err != nil {
+		return 0, fmt.Errorf("ffprobe failed: %w", err)
+	}
+
+	seconds, err := strconv.ParseFloat(strings.TrimSpace(string(output)), 64)
+	if err != nil {
+		return 0, nil
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
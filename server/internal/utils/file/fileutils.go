@@ -2,6 +2,7 @@ package fileutils
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 )
@@ -32,3 +33,24 @@ func CreateFile(dir string, filename string) (*os.File, error) {
 	}
 	return nil, fmt.Errorf("file already exists: %s", path)
 }
+
+// SaveFile stores the content read from r as a new file in dir. The content is also written
+// to hash unless it is nil, to checksum the file on the way. Nothing is left behind when it
+// fails.
+func SaveFile(dir string, filename string, r io.Reader, hash io.Writer) error {
+	out, err := CreateFile(dir, filename)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer out.Close()
+
+	if hash != nil {
+		r = io.TeeReader(r, hash)
+	}
+	if _, err := io.Copy(out, r); err != nil {
+		out.Close()
+		os.Remove(out.Name())
+		return fmt.Errorf("failed to save file: %w", err)
+	}
+	return nil
+}
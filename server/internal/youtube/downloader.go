@@ -237,10 +237,15 @@ func (s *YoutubeSongDownloader) parseYouTubeDurationMs(duration string) (int, er
 	return (hours*3600 + minutes*60 + seconds) * 1000, nil
 }
 
+// findDurationMatch picks the result closest to the song duration. Songs of unknown duration
+// take the most relevant result.
 func (s *YoutubeSongDownloader) findDurationMatch(duration int, results []*SearchResult) (*SearchResult, error) {
 	if len(results) == 0 {
 		return nil, fmt.Errorf("no results found")
 	}
+	if duration <= 0 {
+		return results[0], nil
+	}
 
 	diff := func(a, b int) int {
 		return int(math.Abs(float64(a - b)))
@@ -0,0 +1,28 @@
+package youtube
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestYoutubeSongDownloader_findDurationMatch(t *testing.T) {
+	results := []*SearchResult{
+		{ID: "first", DurationMs: 600000},
+		{ID: "short", DurationMs: 30000},
+		{ID: "close", DurationMs: 185000},
+	}
+	s := &YoutubeSongDownloader{}
+
+	match, err := s.findDurationMatch(180000, results)
+	require.NoError(t, err)
+	assert.Equal(t, "close", match.ID)
+
+	match, err = s.findDurationMatch(0, results)
+	require.NoError(t, err)
+	assert.Equal(t, "first", match.ID, "songs of unknown duration take the most relevant result")
+
+	_, err = s.findDurationMatch(180000, nil)
+	assert.Error(t, err)
+}
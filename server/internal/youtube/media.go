@@ -0,0 +1,64 @@
+package youtube
+
+import (
+	"context"
+	"fmt"
+	"go-shazam/internal/logger"
+	"go-shazam/internal/recognition"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/lrstanley/go-ytdlp"
+)
+
+type YoutubeMediaDownloader struct{}
+
+func NewYoutubeMediaDownloader() recognition.MediaDownloader {
+	return &YoutubeMediaDownloader{}
+}
+
+// DownloadMedia downloads the best audio of a page handled by one of the allowed extractors.
+// The generic extractor, which would follow any link on any page, is always disabled. yt-dlp
+// skips media over the limits instead of failing, which leaves no file behind.
+func (d *YoutubeMediaDownloader) DownloadMedia(ctx context.Context, download recognition.MediaDownload) error {
+	logger := logger.FromContext(ctx)
+
+	dl := ytdlp.New().
+		IgnoreConfig().
+		UseExtractors(strings.Join(append(slices.Clone(download.Extractors), "-generic"), ",")).
+		Format("bestaudio/best").
+		Output(download.Path).
+		NoPlaylist().
+		CacheDir("/tmp/ytcache").
+		HTTPChunkSize("10M")
+	if download.Proxy != "" {
+		// The native downloader sends fragmented formats through the proxy as well
+		dl = dl.Proxy(download.Proxy).Downloader("native")
+	}
+	if download.MaxBytes > 0 {
+		dl = dl.MaxFileSize(fmt.Sprintf("%d", download.MaxBytes))
+	}
+	if download.MaxDuration > 0 {
+		// Live streams never end; media without a known duration is checked after conversion
+		dl = dl.MatchFilters(fmt.Sprintf("!is_live & duration <=? %d", int(download.MaxDuration.Seconds())))
+	}
+
+	logger.Info("Downloading media with yt-dlp", "url", download.URL)
+	if _, err := dl.Run(ctx, download.URL); err != nil {
+		logger.Error("Failed to download media with yt-dlp", "url", download.URL, "error", err)
+		os.Remove(download.Path)
+		return fmt.Errorf("failed to download media: %w", err)
+	}
+
+	info, err := os.Stat(download.Path)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%w: longer than %s, larger than %d bytes or live", recognition.ErrMediaTooLarge, download.MaxDuration, download.MaxBytes)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stat downloaded file: %w", err)
+	}
+
+	logger.Info("Downloaded media successfully", "url", download.URL, "size_bytes", info.Size())
+	return nil
+}
@@ -3,5 +3,5 @@ package youtube
 import "go.uber.org/fx"
 
 var Module = fx.Module("youtube",
-	fx.Provide(NewYoutubeSongDownloader),
+	fx.Provide(NewYoutubeSongDownloader, NewYoutubeMediaDownloader),
 )
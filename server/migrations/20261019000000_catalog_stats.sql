@@ -0,0 +1,9 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS catalog_stats (
+    id SMALLINT PRIMARY KEY,
+    computed_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    payload JSONB NOT NULL -- Serialized CatalogStats snapshot
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS catalog_stats;
@@ -0,0 +1,23 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS recognitions (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL,
+    song_id UUID NOT NULL,
+    score INTEGER NOT NULL,
+    confidence DOUBLE PRECISION NOT NULL,
+    metadata JSONB, -- Optional client metadata
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    CONSTRAINT fk_recognitions_user
+        FOREIGN KEY(user_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE,
+    CONSTRAINT fk_recognitions_song
+        FOREIGN KEY(song_id)
+        REFERENCES songs(id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_recognitions_user_created_at ON recognitions(user_id, created_at DESC);
+
+-- +goose Down
+DROP TABLE IF EXISTS recognitions;
@@ -0,0 +1,11 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS recognition_calibrations (
+    id UUID PRIMARY KEY,
+    computed_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    payload JSONB NOT NULL -- Serialized Calibration, the latest one sets the recognition thresholds
+);
+
+CREATE INDEX IF NOT EXISTS idx_recognition_calibrations_computed_at ON recognition_calibrations(computed_at DESC);
+
+-- +goose Down
+DROP TABLE IF EXISTS recognition_calibrations;
@@ -0,0 +1,6 @@
+-- +goose Up
+-- Imports and uploads look songs up by the checksum in their source ID
+CREATE INDEX IF NOT EXISTS idx_songs_source_id ON songs(source_id);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_songs_source_id;
@@ -0,0 +1,22 @@
+package migrations
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFS_ContainsGooseMigrations(t *testing.T) {
+	files, err := fs.Glob(FS, "*.sql")
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+
+	for _, name := range files {
+		content, err := fs.ReadFile(FS, name)
+		require.NoError(t, err)
+		assert.Contains(t, string(content), "-- +goose Up", name)
+		assert.Contains(t, string(content), "-- +goose Down", name)
+	}
+}
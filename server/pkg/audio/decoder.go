@@ -0,0 +1,161 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os/exec"
+	"sync"
+)
+
+// Compressed formats accepted by StreamDecoder
+const (
+	CodecWebM = "webm" // Opus or Vorbis in WebM, as produced by MediaRecorder
+	CodecOgg  = "ogg"  // Opus or Vorbis in Ogg
+	CodecMP3  = "mp3"
+)
+
+// ffmpeg demuxers of the supported codecs
+var codecFormats = map[string]string{
+	CodecWebM: "matroska",
+	CodecOgg:  "ogg",
+	CodecMP3:  "mp3",
+}
+
+var ErrUnsupportedCodec = errors.New("unsupported codec")
+
+func IsSupportedCodec(codec string) bool {
+	_, ok := codecFormats[codec]
+	return ok
+}
+
+// StreamDecoder decodes a compressed audio stream with ffmpeg as it arrives. Compressed bytes
+// are written to the decoder and mono samples at the requested rate are read back.
+type StreamDecoder struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stderr bytes.Buffer
+	done   chan struct{} // closed once the output has been read to the end
+
+	mu      sync.Mutex
+	samples []float64
+	readErr error
+}
+
+func NewStreamDecoder(codec string, sampleRate int) (*StreamDecoder, error) {
+	format, ok := codecFormats[codec]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedCodec, codec)
+	}
+
+	d := &StreamDecoder{done: make(chan struct{})}
+	d.cmd = exec.Command(
+		"ffmpeg",
+		"-f", format,
+		"-i", "pipe:0",
+		"-ar", fmt.Sprint(sampleRate),
+		"-ac", "1",
+		"-f", "f32le",
+		"pipe:1",
+		"-loglevel", "error",
+		"-nostats",
+	)
+	d.cmd.Stderr = &d.stderr
+
+	var err error
+	if d.stdin, err = d.cmd.StdinPipe(); err != nil {
+		return nil, fmt.Errorf("failed to open ffmpeg stdin: %w", err)
+	}
+	stdout, err := d.cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ffmpeg stdout: %w", err)
+	}
+	if err := d.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start ffmpeg: %w", err)
+	}
+
+	go d.readOutput(stdout)
+	return d, nil
+}
+
+// Write feeds compressed bytes to the decoder.
+func (d *StreamDecoder) Write(p []byte) (int, error) {
+	n, err := d.stdin.Write(p)
+	if err != nil {
+		return n, fmt.Errorf("ffmpeg stopped decoding: %w", err)
+	}
+	return n, nil
+}
+
+// Samples returns the samples decoded since the previous call.
+func (d *StreamDecoder) Samples() []float64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	samples := d.samples
+	d.samples = nil
+	return samples
+}
+
+// Close ends the input, waits for ffmpeg to decode the rest and returns the remaining samples.
+func (d *StreamDecoder) Close() ([]float64, error) {
+	d.stdin.Close()
+	<-d.done
+
+	if err := d.cmd.Wait(); err != nil {
+		return nil, fmt.Errorf("ffmpeg failed: %v, stderr: %s", err, d.stderr.String())
+	}
+
+	d.mu.Lock()
+	readErr := d.readErr
+	d.mu.Unlock()
+	if readErr != nil {
+		return nil, fmt.Errorf("failed to read ffmpeg output: %w", readErr)
+	}
+
+	return d.Samples(), nil
+}
+
+// Abort stops decoding and discards everything that was not read yet.
+func (d *StreamDecoder) Abort() {
+	d.stdin.Close()
+	d.cmd.Process.Kill()
+	<-d.done
+	d.cmd.Wait()
+}
+
+func (d *StreamDecoder) readOutput(stdout io.Reader) {
+	defer close(d.done)
+
+	buf := make([]byte, 32*1024)
+	var partial []byte // bytes of a sample split across reads
+	for {
+		n, err := stdout.Read(buf)
+		if n > 0 {
+			data := append(partial, buf[:n]...)
+			whole := len(data) - len(data)%4
+
+			samples := make([]float64, whole/4)
+			for i := range samples {
+				bits := binary.LittleEndian.Uint32(data[i*4 : (i+1)*4])
+				samples[i] = float64(math.Float32frombits(bits))
+			}
+			partial = append([]byte(nil), data[whole:]...)
+
+			d.mu.Lock()
+			d.samples = append(d.samples, samples...)
+			d.mu.Unlock()
+		}
+		if err != nil {
+			if err != io.EOF {
+				d.mu.Lock()
+				d.readErr = err
+				d.mu.Unlock()
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package audio_test
+
+import (
+	"go-shazam/pkg/audio"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The fixtures hold about two seconds of audio:
+//   - silence.webm: Opus in WebM, 100 frames of 20ms
+//   - silence.ogg:  the same Opus stream in Ogg
+//   - silence.mp3:  MPEG-1 Layer III, 77 frames of 1152 samples at 44.1kHz
+//   - tone.mp3:     77 frames at 44.1kHz holding a single spectral line, which decodes to a
+//     1072 Hz sine at half of full scale
+func requireFFmpeg(t *testing.T) {
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		t.Skip("ffmpeg is not installed")
+	}
+}
+
+// decodeFixture streams the fixture to the decoder in small chunks, like a WebSocket client
+func decodeFixture(t *testing.T, codec string, name string) []float64 {
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	require.NoError(t, err)
+
+	decoder, err := audio.NewStreamDecoder(codec, audio.TargetSampleRate)
+	require.NoError(t, err)
+
+	var samples []float64
+	for start := 0; start < len(data); start += 256 {
+		_, err := decoder.Write(data[start:min(start+256, len(data))])
+		require.NoError(t, err)
+		samples = append(samples, decoder.Samples()...)
+	}
+
+	rest, err := decoder.Close()
+	require.NoError(t, err)
+	return append(samples, rest...)
+}
+
+func TestStreamDecoder_Fixtures(t *testing.T) {
+	requireFFmpeg(t)
+
+	tests := []struct {
+		codec    string
+		file     string
+		duration float64
+	}{
+		{audio.CodecWebM, "silence.webm", 2.0},
+		{audio.CodecOgg, "silence.ogg", 2.0},
+		{audio.CodecMP3, "silence.mp3", 77 * 1152 / 44100.0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			samples := decodeFixture(t, tt.codec, tt.file)
+
+			duration := float64(len(samples)) / audio.TargetSampleRate
+			assert.InDelta(t, tt.duration, duration, 0.1)
+			for _, s := range samples {
+				if math.Abs(s) > 1e-3 {
+					t.Fatalf("expected silence, got sample %f", s)
+				}
+			}
+		})
+	}
+}
+
+func TestStreamDecoder_Tone(t *testing.T) {
+	requireFFmpeg(t)
+
+	samples := decodeFixture(t, audio.CodecMP3, "tone.mp3")
+	require.InDelta(t, 77*1152/44100.0, float64(len(samples))/audio.TargetSampleRate, 0.1)
+
+	// Skip the decoder delay at the start
+	samples = samples[audio.TargetSampleRate/10:]
+	sum := 0.0
+	for _, s := range samples {
+		sum += s * s
+	}
+	assert.InDelta(t, 0.5/math.Sqrt2, math.Sqrt(sum/float64(len(samples))), 0.05, "RMS of the sine")
+
+	tone := magnitude(samples, 1072)
+	for _, freq := range []float64{440, 900, 1250, 2000} {
+		assert.Greater(t, tone, 20*magnitude(samples, freq), "energy at %v Hz", freq)
+	}
+}
+
+// magnitude returns the amplitude of the frequency in the samples (Goertzel algorithm)
+func magnitude(samples []float64, freq float64) float64 {
+	c := 2 * math.Cos(2*math.Pi*freq/audio.TargetSampleRate)
+	var s1, s2 float64
+	for _, x := range samples {
+		s1, s2 = x+c*s1-s2, s1
+	}
+	return 2 * math.Sqrt(s1*s1+s2*s2-c*s1*s2) / float64(len(samples))
+}
+
+func TestStreamDecoder_InvalidData(t *testing.T) {
+	requireFFmpeg(t)
+
+	decoder, err := audio.NewStreamDecoder(audio.CodecOgg, audio.TargetSampleRate)
+	require.NoError(t, err)
+
+	// ffmpeg may exit before all of the input was written
+	decoder.Write([]byte("this is not an ogg stream"))
+
+	_, err = decoder.Close()
+	assert.Error(t, err)
+}
+
+func TestNewStreamDecoder_UnsupportedCodec(t *testing.T) {
+	_, err := audio.NewStreamDecoder("flac", audio.TargetSampleRate)
+
+	assert.ErrorIs(t, err, audio.ErrUnsupportedCodec)
+}
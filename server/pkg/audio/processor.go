@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
+	"io"
 	"math"
 	"math/cmplx"
 	"os"
+	"time"
 
 	"os/exec"
 
@@ -19,6 +21,8 @@ const (
 	WindowSize       = 2048
 	Overlap          = 0.50 // 50% overlap
 	TargetSampleRate = 11200
+	// HopSize is the distance in samples between the starts of consecutive fragments
+	HopSize = int(WindowSize * (1 - Overlap))
 )
 
 // ProcessedFragment represents the result of processing a single audio fragment
@@ -80,6 +84,89 @@ func Resample(input []float64, oldRate, newRate int) ([]float64, error) {
 	return out, nil
 }
 
+// DecodeFile converts any file ffmpeg understands to mono samples at the given rate. The
+// samples are piped back, no intermediate file is written.
+func DecodeFile(path string, sampleRate int) ([]float64, error) {
+	cmd := exec.Command(
+		"ffmpeg",
+		"-i", path,
+		"-ar", fmt.Sprint(sampleRate),
+		"-ac", "1",
+		"-f", "f32le",
+		"pipe:1",
+		"-loglevel", "error",
+		"-nostats",
+	)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("ffmpeg failed to decode %s: %w, stderr: %s", path, err, stderr.String())
+	}
+
+	raw := stdout.Bytes()
+	samples := make([]float64, len(raw)/4)
+	for i := range samples {
+		samples[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(raw[i*4:])))
+	}
+	return samples, nil
+}
+
+// DecodeFileChunks decodes a file like DecodeFile but hands the samples to fn as ffmpeg
+// produces them, so long files are never held in memory. A positive maxDuration stops the
+// decoding after that much audio.
+func DecodeFileChunks(path string, sampleRate int, maxDuration time.Duration, fn func(samples []float64)) error {
+	args := []string{"-i", path, "-ar", fmt.Sprint(sampleRate), "-ac", "1"}
+	if maxDuration > 0 {
+		args = append(args, "-t", fmt.Sprintf("%.3f", maxDuration.Seconds()))
+	}
+	args = append(args, "-f", "f32le", "pipe:1", "-loglevel", "error", "-nostats")
+
+	cmd := exec.Command("ffmpeg", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to open ffmpeg stdout: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start ffmpeg: %w", err)
+	}
+
+	buf := make([]byte, 256*1024)
+	var partial []byte // bytes of a sample split across reads
+	var readErr error
+	for {
+		n, err := stdout.Read(buf)
+		if n > 0 {
+			data := append(partial, buf[:n]...)
+			whole := len(data) - len(data)%4
+
+			samples := make([]float64, whole/4)
+			for i := range samples {
+				samples[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(data[i*4:])))
+			}
+			partial = append([]byte(nil), data[whole:]...)
+			fn(samples)
+		}
+		if err != nil {
+			if err != io.EOF {
+				readErr = err
+			}
+			break
+		}
+	}
+
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("ffmpeg failed to decode %s: %w, stderr: %s", path, err, stderr.String())
+	}
+	if readErr != nil {
+		return fmt.Errorf("failed to read ffmpeg output: %w", readErr)
+	}
+	return nil
+}
+
 // Returns the audio samples and sample rate
 func LoadWav(path string) ([]float64, int, error) {
 	f, err := os.Open(path)
@@ -128,7 +215,7 @@ func LoadWav(path string) ([]float64, int, error) {
 
 // ProcessAudio processes the audio samples: chunks, applies Hamming window, and performs FFT.
 func ProcessAudio(samples []float64, sampleRate int) ([]ProcessedFragment, error) {
-	step := int(WindowSize * (1 - Overlap))
+	step := HopSize
 	if step == 0 {
 		step = 1 // Avoid infinite loop if WindowSize is small
 	}
@@ -1,7 +1,7 @@
 package audio_test
 
 import (
-	"go-shazam/internal/audio"
+	"go-shazam/pkg/audio"
 	"math"
 	"testing"
 
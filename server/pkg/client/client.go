@@ -0,0 +1,114 @@
+// Package client is a reference client for recognising audio without uploading it. It computes
+// the same hashes as the server from a file or from samples and submits them to
+// POST /api/recognize/fingerprints.
+//
+//	c := client.New("http://localhost:5000")
+//	result, err := c.RecognizeFile(ctx, "clip.mp3")
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-shazam/pkg/landmark"
+	"net/http"
+	"strings"
+)
+
+// ErrProfileMismatch is returned when the server fingerprints with a different profile
+// version than this package, which then has to be updated.
+var ErrProfileMismatch = errors.New("fingerprint profile version does not match the server")
+
+// APIError is an error response of the server
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("server responded with %d: %s", e.StatusCode, e.Message)
+}
+
+type Client struct {
+	BaseURL     string
+	AccessToken string // optional, adds the recognitions to the user's history
+	HTTPClient  *http.Client
+}
+
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		HTTPClient: http.DefaultClient,
+	}
+}
+
+// RecognizeFile fingerprints an audio file locally and submits the hashes.
+func (c *Client) RecognizeFile(ctx context.Context, path string) (*RecognitionResponse, error) {
+	req, err := FingerprintFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return c.Recognize(ctx, req)
+}
+
+// Recognize submits precomputed hashes.
+func (c *Client) Recognize(ctx context.Context, req *FingerprintRequest) (*RecognitionResponse, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal fingerprints: %w", err)
+	}
+
+	var result RecognitionResponse
+	if err := c.do(ctx, http.MethodPost, "/api/recognize/fingerprints", body, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Profile returns the fingerprint profile of the server.
+func (c *Client) Profile(ctx context.Context) (*landmark.Profile, error) {
+	var profile landmark.Profile
+	if err := c.do(ctx, http.MethodGet, "/api/recognize/fingerprints/profile", nil, &profile); err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+func (c *Client) do(ctx context.Context, method string, path string, body []byte, out any) error {
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.AccessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.AccessToken)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var errorBody struct {
+			Error          string `json:"error"`
+			ProfileVersion int    `json:"profile_version"`
+		}
+		json.NewDecoder(resp.Body).Decode(&errorBody)
+
+		if resp.StatusCode == http.StatusConflict && errorBody.ProfileVersion != 0 {
+			return fmt.Errorf("%w: server uses version %d, client uses %d", ErrProfileMismatch, errorBody.ProfileVersion, landmark.ProfileVersion)
+		}
+		return &APIError{StatusCode: resp.StatusCode, Message: errorBody.Error}
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"go-shazam/internal/recognition"
+	"go-shazam/internal/song"
+	"go-shazam/pkg/audio"
+	"go-shazam/pkg/landmark"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testSignal returns a few seconds of tones and noise with changing pitch at the target rate
+func testSignal(seconds float64) []float64 {
+	rng := rand.New(rand.NewSource(42))
+	samples := make([]float64, int(seconds*audio.TargetSampleRate))
+	for i := range samples {
+		t := float64(i) / audio.TargetSampleRate
+		freq := 300.0 + 200.0*math.Floor(t*4)
+		samples[i] = 0.6*math.Sin(2*math.Pi*freq*t) + 0.3*math.Sin(2*math.Pi*2.7*freq*t) + 0.05*rng.NormFloat64()
+	}
+	return samples
+}
+
+func TestFingerprintSamples_MatchesServerHashes(t *testing.T) {
+	samples := testSignal(5)
+
+	req, err := FingerprintSamples(samples, audio.TargetSampleRate)
+	require.NoError(t, err)
+
+	fragments, err := audio.ProcessAudio(samples, audio.TargetSampleRate)
+	require.NoError(t, err)
+	expected := landmark.CreateHashes(landmark.ExtractPeaks(fragments, audio.TargetSampleRate), uuid.Nil)
+
+	require.NotEmpty(t, expected)
+	assert.Equal(t, landmark.ProfileVersion, req.ProfileVersion)
+	assert.InDelta(t, 5.0, req.Duration, 1e-9)
+	assert.Equal(t, newFingerprintHashes(expected), req.Hashes)
+
+	// The server decodes the submission as it was computed
+	body, err := json.Marshal(req)
+	require.NoError(t, err)
+	var submitted recognition.FingerprintRequest
+	require.NoError(t, json.Unmarshal(body, &submitted))
+	assert.Equal(t, recognition.NewFingerprintHashes(expected), submitted.Hashes)
+}
+
+func TestRecognitionResponse_DecodesServerResponse(t *testing.T) {
+	entity := &song.SongEntity{ID: uuid.New(), Title: "Title", Artist: "Artist", Duration: 180, SourceID: "spotify:1"}
+	match := recognition.MatchResult{Song: entity, TimeOffset: 12, Position: 17, MatchedRange: recognition.TimeRange{Start: 12, End: 16}, Score: 40, Confidence: 0.9, MatchedHashes: 60}
+	body, err := json.Marshal(recognition.NewRecognitionResponse([]recognition.MatchResult{match}))
+	require.NoError(t, err)
+
+	var result RecognitionResponse
+	require.NoError(t, json.Unmarshal(body, &result))
+
+	require.True(t, result.Found)
+	assert.Equal(t, Song{ID: entity.ID, Title: "Title", Artist: "Artist", Duration: 180, SourceID: "spotify:1"}, *result.Song)
+	require.Len(t, result.Candidates, 1)
+	assert.Equal(t, TimeRange{Start: 12, End: 16}, result.Candidates[0].MatchedRange)
+	assert.Equal(t, 60, result.Candidates[0].MatchedHashes)
+}
+
+func TestFingerprintSamples_TooShort(t *testing.T) {
+	_, err := FingerprintSamples(make([]float64, audio.WindowSize-1), audio.TargetSampleRate)
+	assert.ErrorIs(t, err, ErrAudioTooShort)
+}
+
+func TestFingerprintFile(t *testing.T) {
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		t.Skip("ffmpeg is not installed")
+	}
+
+	req, err := FingerprintFile("../audio/testdata/silence.mp3")
+	require.NoError(t, err)
+	assert.InDelta(t, 2.0, req.Duration, 0.2)
+}
+
+func TestClient_Recognize(t *testing.T) {
+	songID := uuid.New()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/api/recognize/fingerprints", r.URL.Path)
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, []any{[]any{42.0, 0.5}}, body["hashes"])
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"found": true, "song": {"id": "` + songID.String() + `"}, "score": 12, "candidates": []}`))
+	}))
+	defer server.Close()
+
+	c := New(server.URL + "/")
+	c.AccessToken = "token"
+	result, err := c.Recognize(context.Background(), &FingerprintRequest{
+		ProfileVersion: landmark.ProfileVersion,
+		Hashes:         []FingerprintHash{{Hash: 42, TimeOffset: 0.5}},
+	})
+
+	require.NoError(t, err)
+	assert.True(t, result.Found)
+	assert.Equal(t, songID, result.Song.ID)
+	assert.Equal(t, 12, result.Score)
+}
+
+func TestClient_RecognizeProfileMismatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(`{"error": "fingerprint profile version does not match the server", "profile_version": 2}`))
+	}))
+	defer server.Close()
+
+	_, err := New(server.URL).Recognize(context.Background(), &FingerprintRequest{ProfileVersion: 1})
+
+	assert.ErrorIs(t, err, ErrProfileMismatch)
+}
+
+func TestClient_APIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write([]byte(`{"error": "invalid fingerprints: no hashes submitted"}`))
+	}))
+	defer server.Close()
+
+	_, err := New(server.URL).Recognize(context.Background(), &FingerprintRequest{})
+
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusUnprocessableEntity, apiErr.StatusCode)
+	assert.Equal(t, "invalid fingerprints: no hashes submitted", apiErr.Message)
+}
@@ -0,0 +1,105 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// ErrInvalidHash is returned when a submitted hash pair cannot be decoded
+var ErrInvalidHash = errors.New("invalid fingerprint hash")
+
+// FingerprintHash is a (hash, time_offset) pair, encoded as a two-element JSON array to keep
+// large submissions small: [hash, time_offset].
+type FingerprintHash struct {
+	Hash       int64
+	TimeOffset float64 // seconds from the start of the query to the anchor peak
+}
+
+func (h FingerprintHash) MarshalJSON() ([]byte, error) {
+	return json.Marshal([2]any{h.Hash, h.TimeOffset})
+}
+
+func (h *FingerprintHash) UnmarshalJSON(b []byte) error {
+	var pair []json.Number
+	if err := json.Unmarshal(b, &pair); err != nil || len(pair) != 2 {
+		return fmt.Errorf("%w: expected a [hash, time_offset] pair, got %s", ErrInvalidHash, b)
+	}
+
+	hash, err := pair[0].Int64()
+	if err != nil {
+		return fmt.Errorf("%w: hash %s is not an integer", ErrInvalidHash, pair[0])
+	}
+	offset, err := pair[1].Float64()
+	if err != nil {
+		return fmt.Errorf("%w: time offset %s is not a number", ErrInvalidHash, pair[1])
+	}
+
+	h.Hash = hash
+	h.TimeOffset = offset
+	return nil
+}
+
+// FingerprintRequest is the body of POST /api/recognize/fingerprints: hashes computed with the
+// profile ProfileVersion refers to, instead of the audio itself.
+type FingerprintRequest struct {
+	ProfileVersion int               `json:"profile_version"`
+	Duration       float64           `json:"duration"` // seconds of audio fingerprinted
+	Hashes         []FingerprintHash `json:"hashes"`
+	// Client metadata stored with the recognition in the history of authenticated users
+	Metadata json.RawMessage `json:"metadata,omitempty"`
+}
+
+// RecognitionResponse is the result of a recognition
+type RecognitionResponse struct {
+	Found       bool          `json:"found"`
+	Song        *Song         `json:"song,omitempty"`
+	TimeOffset  float64       `json:"time_offset,omitempty"`
+	Position    float64       `json:"position,omitempty"`
+	Score       int           `json:"score,omitempty"`
+	Confidence  float64       `json:"confidence,omitempty"`
+	Candidates  []MatchResult `json:"candidates"`
+	Diagnostics *Diagnostics  `json:"diagnostics,omitempty"`
+}
+
+// Song is a catalog song. The server encodes its fields under their Go names.
+type Song struct {
+	ID       uuid.UUID
+	Title    string
+	Artist   string
+	Duration int
+	SourceID string
+}
+
+type MatchResult struct {
+	Song          *Song     `json:"song"`
+	TimeOffset    float64   `json:"time_offset"`   // where the query starts in the track, in seconds
+	Position      float64   `json:"position"`      // playback position at the end of the query
+	MatchedRange  TimeRange `json:"matched_range"` // track time covered by the aligned hashes
+	Score         int       `json:"score"`
+	Confidence    float64   `json:"confidence"`
+	MatchedHashes int       `json:"matched_hashes"`
+}
+
+type TimeRange struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+}
+
+// Diagnostics describe the quality of the query audio when nothing was found
+type Diagnostics struct {
+	Duration       float64 `json:"duration"`         // seconds
+	RMSLevel       float64 `json:"rms_level"`        // dBFS
+	ClippingRatio  float64 `json:"clipping_ratio"`   // share of samples at full scale
+	SNR            float64 `json:"snr"`              // estimated signal-to-noise ratio in dB
+	PeaksPerSecond float64 `json:"peaks_per_second"` // spectrogram peaks kept for hashing
+	Hashes         int     `json:"hashes"`
+	Hints          []Hint  `json:"hints"`
+}
+
+type Hint struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
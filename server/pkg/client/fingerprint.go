@@ -0,0 +1,58 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"go-shazam/pkg/audio"
+	"go-shazam/pkg/landmark"
+
+	"github.com/google/uuid"
+)
+
+var ErrAudioTooShort = errors.New("audio is too short to fingerprint")
+
+// FingerprintFile decodes an audio file in any format ffmpeg understands and computes its
+// hashes. ffmpeg has to be installed.
+func FingerprintFile(path string) (*FingerprintRequest, error) {
+	samples, err := audio.DecodeFile(path, audio.TargetSampleRate)
+	if err != nil {
+		return nil, err
+	}
+	return FingerprintSamples(samples, audio.TargetSampleRate)
+}
+
+// FingerprintSamples computes the hashes of mono samples in [-1, 1]. Samples at another rate
+// than the profile's are resampled with ffmpeg.
+func FingerprintSamples(samples []float64, sampleRate int) (*FingerprintRequest, error) {
+	if sampleRate != audio.TargetSampleRate {
+		var err error
+		samples, err = audio.Resample(samples, sampleRate, audio.TargetSampleRate)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resample audio: %w", err)
+		}
+	}
+	if len(samples) < audio.WindowSize {
+		return nil, ErrAudioTooShort
+	}
+
+	fragments, err := audio.ProcessAudio(samples, audio.TargetSampleRate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to process audio: %w", err)
+	}
+	peaks := landmark.ExtractPeaks(fragments, audio.TargetSampleRate)
+	hashes := landmark.CreateHashes(peaks, uuid.Nil)
+
+	return &FingerprintRequest{
+		ProfileVersion: landmark.ProfileVersion,
+		Duration:       float64(len(samples)) / audio.TargetSampleRate,
+		Hashes:         newFingerprintHashes(hashes),
+	}, nil
+}
+
+func newFingerprintHashes(hashes []landmark.Hash) []FingerprintHash {
+	submitted := make([]FingerprintHash, len(hashes))
+	for i, h := range hashes {
+		submitted[i] = FingerprintHash{Hash: h.HashValue, TimeOffset: h.TimeOffset}
+	}
+	return submitted
+}
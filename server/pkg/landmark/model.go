@@ -0,0 +1,19 @@
+// Package landmark computes the fingerprint of audio: the strongest spectrogram peaks, paired
+// into hashes of their frequencies and time delta. The server and clients that fingerprint
+// audio themselves share it, so both produce the same hashes for the same profile.
+package landmark
+
+import "github.com/google/uuid"
+
+type Peak struct {
+	Frequency float64
+	Magnitude float64
+	Time      float64
+	BandIndex int
+}
+
+type Hash struct {
+	HashValue  int64     `db:"hash"`
+	SongID     uuid.UUID `db:"song_id"`
+	TimeOffset float64   `db:"time_offset"`
+}
@@ -1,7 +1,7 @@
-package fingerprint
+package landmark
 
 import (
-	"go-shazam/internal/audio"
+	"go-shazam/pkg/audio"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
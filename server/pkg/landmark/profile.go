@@ -0,0 +1,35 @@
+package landmark
+
+import "go-shazam/pkg/audio"
+
+// ProfileVersion identifies the audio processing, peak picking and hashing parameters. Bump it
+// whenever a change produces different hashes for the same audio: the catalog has to be
+// re-fingerprinted and clients that compute hashes themselves are rejected until they update.
+const ProfileVersion = 1
+
+// MaxHashValue is the largest hash generateHash can produce (10 + 10 + 14 bits)
+const MaxHashValue int64 = 1<<34 - 1
+
+// Profile describes how hashes are computed, for clients that fingerprint audio themselves
+type Profile struct {
+	Version      int     `json:"version"`
+	SampleRate   int     `json:"sample_rate"`
+	WindowSize   int     `json:"window_size"`
+	HopSize      int     `json:"hop_size"`
+	FanOut       int     `json:"fan_out"`
+	MinTimeDelta float64 `json:"min_time_delta"`
+	MaxTimeDelta float64 `json:"max_time_delta"`
+}
+
+// CurrentProfile returns the profile the server fingerprints with
+func CurrentProfile() Profile {
+	return Profile{
+		Version:      ProfileVersion,
+		SampleRate:   audio.TargetSampleRate,
+		WindowSize:   audio.WindowSize,
+		HopSize:      audio.HopSize,
+		FanOut:       FanOut,
+		MinTimeDelta: MinTimeDelta,
+		MaxTimeDelta: MaxTimeDelta,
+	}
+}